@@ -16,7 +16,7 @@ if [ ! -f .env ]; then
     echo "Then edit .env and set:"
     echo "  - OPENAI_API_KEY (required)"
     echo "  - JWT_SECRET (recommended)"
-    echo "  - INTERNAL_API_KEY (recommended)"
+    echo "  - INTERNAL_API_KEY (required)"
     exit 1
 fi
 
@@ -0,0 +1,8 @@
+-- Add aggregated agent metrics reported by the Go service in stats batches
+ALTER TABLE "user_server_configs" ADD COLUMN "tool_calls_count" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "user_server_configs" ADD COLUMN "suppressed_responses_count" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "user_server_configs" ADD COLUMN "huma_errors_count" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "user_server_configs" ADD COLUMN "response_count" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "user_server_configs" ADD COLUMN "response_latency_ms_sum" BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE "user_server_configs" ADD COLUMN "response_latency_ms_max" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "user_server_configs" ADD COLUMN "typing_time_ms" BIGINT NOT NULL DEFAULT 0;
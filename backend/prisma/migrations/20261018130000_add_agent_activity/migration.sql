@@ -0,0 +1,7 @@
+-- Add action type and decision trail to agent actions
+ALTER TABLE "agent_actions" ADD COLUMN "action_type" TEXT NOT NULL DEFAULT 'message';
+ALTER TABLE "agent_actions" ADD COLUMN "outcome" TEXT;
+ALTER TABLE "agent_actions" ADD COLUMN "activity" JSONB;
+
+-- CreateIndex
+CREATE INDEX "agent_actions_action_type_idx" ON "agent_actions"("action_type");
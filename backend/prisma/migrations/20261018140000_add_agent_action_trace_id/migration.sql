@@ -0,0 +1,2 @@
+-- Add trace ID for correlating agent actions with distributed traces
+ALTER TABLE "agent_actions" ADD COLUMN "trace_id" TEXT;
@@ -0,0 +1,2 @@
+-- Add per-user rate limits to server configs
+ALTER TABLE "user_server_configs" ADD COLUMN "user_limits" JSONB;
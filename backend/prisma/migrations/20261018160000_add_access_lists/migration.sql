@@ -0,0 +1,6 @@
+-- Add channel, user and role lists to server configs
+ALTER TABLE "user_server_configs" ADD COLUMN "read_channels" TEXT[] DEFAULT ARRAY[]::TEXT[];
+ALTER TABLE "user_server_configs" ADD COLUMN "respond_channels" TEXT[] DEFAULT ARRAY[]::TEXT[];
+ALTER TABLE "user_server_configs" ADD COLUMN "ignored_users" TEXT[] DEFAULT ARRAY[]::TEXT[];
+ALTER TABLE "user_server_configs" ADD COLUMN "ignored_roles" TEXT[] DEFAULT ARRAY[]::TEXT[];
+ALTER TABLE "user_server_configs" ADD COLUMN "respond_to_roles" TEXT[] DEFAULT ARRAY[]::TEXT[];
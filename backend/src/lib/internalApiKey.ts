@@ -0,0 +1,12 @@
+// Key shared with the Go service (discord-user-client), sent and checked as
+// X-API-Key. Like the Go service, the backend refuses to start without one
+// rather than falling back to a placeholder.
+function requireInternalApiKey(): string {
+  const key = process.env.INTERNAL_API_KEY;
+  if (!key) {
+    throw new Error('INTERNAL_API_KEY is required');
+  }
+  return key;
+}
+
+export const INTERNAL_API_KEY = requireInternalApiKey();
@@ -2,6 +2,7 @@ import { Router, Request, Response } from 'express';
 import { requireAuth, getAuth } from '@clerk/express';
 import { prisma } from '../lib/prisma.js';
 import { getOrCreateUser } from '../middleware/clerk.js';
+import { INTERNAL_API_KEY } from '../lib/internalApiKey.js';
 
 const router = Router();
 
@@ -577,12 +578,13 @@ router.get('/guilds', requireAuth(), async (req: Request, res: Response) => {
       return res.status(400).json({ error: 'Discord not connected' });
     }
 
-    // Forward request to Go service with user's token for multi-user support
+    // Forward request to Go service, identifying the account by user ID (never the raw token)
     const GO_SERVICE_URL = process.env.GO_SERVICE_URL || 'http://localhost:8080';
     console.log(`[Guilds] Fetching from Go service: ${GO_SERVICE_URL}/guilds`);
     const goResponse = await fetch(`${GO_SERVICE_URL}/guilds`, {
       headers: {
-        'X-Discord-Token': user.discordToken
+        'X-API-Key': INTERNAL_API_KEY,
+        'X-User-ID': user.id
       }
     });
 
@@ -601,21 +603,169 @@ router.get('/guilds', requireAuth(), async (req: Request, res: Response) => {
   }
 });
 
+// Proxy endpoint: Live agent activity (server-sent events) from the Go service,
+// limited to the user's own servers
+router.get('/activity-stream', requireAuth(), async (req: Request, res: Response) => {
+  try {
+    const auth = getAuth(req);
+    if (!auth.userId) {
+      return res.status(401).json({ error: 'Unauthorized' });
+    }
+
+    const user = await getOrCreateUser(auth.userId);
+
+    const configs = await prisma.userServerConfig.findMany({
+      where: { userId: user.id },
+      include: { server: true }
+    });
+    const ownGuildIds = configs.map((config) => config.server.guildId);
+
+    // Optional ?guild_id=a,b narrows the stream; it can never widen it
+    const requested = typeof req.query.guild_id === 'string'
+      ? req.query.guild_id.split(',').map((id) => id.trim()).filter(Boolean)
+      : [];
+    const guildIds = requested.length > 0
+      ? requested.filter((id) => ownGuildIds.includes(id))
+      : ownGuildIds;
+
+    if (guildIds.length === 0) {
+      return res.status(404).json({ error: 'No servers to stream' });
+    }
+
+    const GO_SERVICE_URL = process.env.GO_SERVICE_URL || 'http://localhost:8080';
+    const abort = new AbortController();
+    req.on('close', () => abort.abort());
+
+    const goResponse = await fetch(`${GO_SERVICE_URL}/events?guild_id=${encodeURIComponent(guildIds.join(','))}`, {
+      headers: {
+        'X-API-Key': INTERNAL_API_KEY
+      },
+      signal: abort.signal
+    });
+
+    if (!goResponse.ok || !goResponse.body) {
+      console.log(`[ActivityStream] Go service returned status: ${goResponse.status}`);
+      return res.status(502).json({ error: 'Activity stream unavailable' });
+    }
+
+    res.setHeader('Content-Type', 'text/event-stream');
+    res.setHeader('Cache-Control', 'no-cache');
+    res.setHeader('Connection', 'keep-alive');
+    res.setHeader('X-Accel-Buffering', 'no');
+    res.flushHeaders();
+
+    for await (const chunk of goResponse.body as unknown as AsyncIterable<Uint8Array>) {
+      res.write(chunk);
+    }
+    res.end();
+  } catch (error) {
+    if ((error as Error).name === 'AbortError') {
+      return;
+    }
+    console.error('[ActivityStream] Error:', error);
+    if (!res.headersSent) {
+      res.status(500).json({ error: 'Failed to open activity stream' });
+    } else {
+      res.end();
+    }
+  }
+});
+
 // ============================================================================
 // INTERNAL ENDPOINTS (for Go service - API key auth)
 // ============================================================================
 
-// Update agent stats (called by Go service when messages are sent/received)
-// Now requires guildId to update the correct server configuration
+// Shape of a single entry in a version 2 (batched) stats report
+interface StatsBatchEntry {
+  userId: string;
+  guildId: string;
+  messagesReceived?: number;
+  messagesSent?: number;
+  toolCalls?: number;
+  suppressedResponses?: number;
+  humaErrors?: number;
+  responseCount?: number;
+  responseLatencyMsSum?: number;
+  responseLatencyMsMax?: number;
+  typingTimeMs?: number;
+  lastMessageReceivedAt?: string;
+  lastMessageSentAt?: string;
+}
+
+// Apply one aggregated stats entry to the matching server configuration
+async function applyStatsBatchEntry(entry: StatsBatchEntry): Promise<boolean> {
+  if (!entry.userId || !entry.guildId) {
+    return false;
+  }
+
+  const config = await prisma.userServerConfig.findFirst({
+    where: {
+      userId: entry.userId,
+      server: { guildId: entry.guildId }
+    }
+  });
+
+  if (!config) {
+    return false;
+  }
+
+  const data: any = {
+    messagesReceivedCount: { increment: entry.messagesReceived || 0 },
+    messagesSentCount: { increment: entry.messagesSent || 0 },
+    toolCallsCount: { increment: entry.toolCalls || 0 },
+    suppressedResponsesCount: { increment: entry.suppressedResponses || 0 },
+    humaErrorsCount: { increment: entry.humaErrors || 0 },
+    responseCount: { increment: entry.responseCount || 0 },
+    responseLatencyMsSum: { increment: BigInt(entry.responseLatencyMsSum || 0) },
+    typingTimeMs: { increment: BigInt(entry.typingTimeMs || 0) }
+  };
+
+  if ((entry.responseLatencyMsMax || 0) > config.responseLatencyMsMax) {
+    data.responseLatencyMsMax = entry.responseLatencyMsMax;
+  }
+  if (entry.lastMessageReceivedAt) {
+    data.lastMessageReceivedAt = new Date(entry.lastMessageReceivedAt);
+  }
+  if (entry.lastMessageSentAt) {
+    data.lastMessageSentAt = new Date(entry.lastMessageSentAt);
+  }
+
+  await prisma.userServerConfig.update({
+    where: { id: config.id },
+    data
+  });
+
+  return true;
+}
+
+// Update agent stats (called by Go service)
+// Version 2 payloads carry aggregated counters for many (user, guild) pairs:
+//   { version: 2, periodStart, periodEnd, entries: [...] }
+// Legacy payloads carry a single event: { userId, guildId, event }
 router.post('/stats', async (req: Request, res: Response) => {
   try {
     const apiKey = req.headers['x-api-key'];
-    const expectedApiKey = process.env.INTERNAL_API_KEY || 'default-internal-key';
-
-    if (apiKey !== expectedApiKey) {
+    if (apiKey !== INTERNAL_API_KEY) {
       return res.status(401).json({ error: 'Unauthorized' });
     }
 
+    if (req.body.version === 2) {
+      const { entries } = req.body;
+
+      if (!Array.isArray(entries)) {
+        return res.status(400).json({ error: 'entries must be an array' });
+      }
+
+      let applied = 0;
+      for (const entry of entries as StatsBatchEntry[]) {
+        if (await applyStatsBatchEntry(entry)) {
+          applied++;
+        }
+      }
+
+      return res.json({ success: true, applied, skipped: entries.length - applied });
+    }
+
     const { userId, event, guildId } = req.body;
 
     if (!userId || !event) {
@@ -673,17 +823,53 @@ router.post('/stats', async (req: Request, res: Response) => {
   }
 });
 
-// Record agent action (called by Go service when agent sends a message)
+// Record agent action (called by Go service)
+// actionType "message" (default) is a sent message; actionType "activity" is the
+// full decision trail for a trigger, including triggers the agent stayed silent on
 router.post('/agent-action', async (req: Request, res: Response) => {
   try {
     const apiKey = req.headers['x-api-key'];
-    const expectedApiKey = process.env.INTERNAL_API_KEY || 'default-internal-key';
-
-    if (apiKey !== expectedApiKey) {
+    if (apiKey !== INTERNAL_API_KEY) {
       return res.status(401).json({ error: 'Unauthorized' });
     }
 
-    const { userId, guildId, channelId, channelName, agentMessage, triggerDescription, messageHistory } = req.body;
+    if (req.body.actionType === 'activity') {
+      const { userId, guildId, channelId, channelName, triggerDescription, outcome, startedAt, endedAt, entries, traceId } = req.body;
+
+      if (!userId || !guildId || !Array.isArray(entries)) {
+        return res.status(400).json({ error: 'userId, guildId, and entries are required' });
+      }
+
+      const config = await prisma.userServerConfig.findFirst({
+        where: {
+          userId,
+          server: { guildId }
+        }
+      });
+
+      if (!config) {
+        return res.json({ success: true, message: 'Server config not found' });
+      }
+
+      await prisma.agentAction.create({
+        data: {
+          userServerConfigId: config.id,
+          actionType: 'activity',
+          channelId: channelId || '',
+          channelName: channelName || 'unknown',
+          agentMessage: '',
+          triggerDescription: triggerDescription || '',
+          messageHistory: { preceding: [], agentResponse: {} },
+          outcome: outcome || null,
+          activity: { startedAt, endedAt, entries },
+          traceId: traceId || null
+        }
+      });
+
+      return res.status(201).json({ success: true });
+    }
+
+    const { userId, guildId, channelId, channelName, agentMessage, triggerDescription, messageHistory, traceId } = req.body;
 
     if (!userId || !guildId || !channelId || !agentMessage) {
       return res.status(400).json({ error: 'userId, guildId, channelId, and agentMessage are required' });
@@ -709,7 +895,8 @@ router.post('/agent-action', async (req: Request, res: Response) => {
         channelName: channelName || 'unknown',
         agentMessage: agentMessage,
         triggerDescription: triggerDescription || '',
-        messageHistory: messageHistory || { preceding: [], agentResponse: {} }
+        messageHistory: messageHistory || { preceding: [], agentResponse: {} },
+        traceId: traceId || null
       }
     });
 
@@ -729,9 +916,7 @@ router.get('/tokens', async (req: Request, res: Response) => {
   try {
     // Simple API key authentication for internal service
     const apiKey = req.headers['x-api-key'];
-    const expectedApiKey = process.env.INTERNAL_API_KEY || 'default-internal-key';
-
-    if (apiKey !== expectedApiKey) {
+    if (apiKey !== INTERNAL_API_KEY) {
       return res.status(401).json({ error: 'Unauthorized' });
     }
 
@@ -778,6 +963,12 @@ router.get('/tokens', async (req: Request, res: Response) => {
           personality: config.personality || '',
           rules: config.rules || '',
           information: config.information || '',
+          userLimits: config.userLimits,
+          readChannels: config.readChannels,
+          respondChannels: config.respondChannels,
+          ignoredUsers: config.ignoredUsers,
+          ignoredRoles: config.ignoredRoles,
+          respondToRoles: config.respondToRoles,
           websites: config.server.websites.map(w => ({
             url: w.url,
             name: w.name || '',
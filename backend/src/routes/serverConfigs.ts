@@ -1,5 +1,6 @@
 import { Router, Request, Response } from 'express';
 import { requireAuth, getAuth } from '@clerk/express';
+import { Prisma } from '@prisma/client';
 import { prisma } from '../lib/prisma.js';
 import { getOrCreateUser } from '../middleware/clerk.js';
 
@@ -29,6 +30,46 @@ async function fetchDiscordUserInfo(token: string): Promise<{ username: string;
   }
 }
 
+const USER_LIMIT_FIELDS = ['messageBurst', 'messagesPerMinute', 'mentionBurst', 'mentionsPerMinute', 'ignoreAfter', 'ignoreMinutes'] as const;
+type UserLimits = Record<typeof USER_LIMIT_FIELDS[number], number>;
+
+// Check per-user rate limits the way the Discord client does, returning an error message if they're invalid
+function validateUserLimits(value: unknown): { limits?: UserLimits; error?: string } {
+  if (typeof value !== 'object' || value === null || Array.isArray(value)) {
+    return { error: 'userLimits must be an object or null' };
+  }
+  const input = value as Record<string, unknown>;
+  const limits = {} as UserLimits;
+  for (const field of USER_LIMIT_FIELDS) {
+    const n = input[field];
+    if (typeof n !== 'number' || !Number.isFinite(n) || n < 0) {
+      return { error: `userLimits.${field} must be a non-negative number` };
+    }
+    if (!field.endsWith('PerMinute') && !Number.isInteger(n)) {
+      return { error: `userLimits.${field} must be a whole number` };
+    }
+    limits[field] = n;
+  }
+  if ((limits.messageBurst > 0 && limits.messagesPerMinute <= 0) || (limits.mentionBurst > 0 && limits.mentionsPerMinute <= 0)) {
+    return { error: 'userLimits: a bucket with a burst needs a positive rate per minute' };
+  }
+  if (limits.ignoreAfter > 0 && limits.ignoreMinutes === 0) {
+    return { error: 'userLimits: ignoreAfter needs ignoreMinutes' };
+  }
+  return { limits };
+}
+
+const ACCESS_LIST_FIELDS = ['readChannels', 'respondChannels', 'ignoredUsers', 'ignoredRoles', 'respondToRoles'] as const;
+type AccessListField = typeof ACCESS_LIST_FIELDS[number];
+
+// Check a channel, user or role list holds Discord IDs, returning them without duplicates
+function validateIdList(field: AccessListField, value: unknown): { ids?: string[]; error?: string } {
+  if (!Array.isArray(value) || !value.every(id => typeof id === 'string' && /^\d+$/.test(id))) {
+    return { error: `${field} must be a list of Discord IDs` };
+  }
+  return { ids: [...new Set(value as string[])] };
+}
+
 // GET /api/server-configs - List all server configs for authenticated user
 router.get('/', requireAuth(), async (req: Request, res: Response) => {
   try {
@@ -65,6 +106,12 @@ router.get('/', requireAuth(), async (req: Request, res: Response) => {
         personality: c.personality,
         rules: c.rules,
         information: c.information,
+        userLimits: c.userLimits,
+        readChannels: c.readChannels,
+        respondChannels: c.respondChannels,
+        ignoredUsers: c.ignoredUsers,
+        ignoredRoles: c.ignoredRoles,
+        respondToRoles: c.respondToRoles,
         messagesSentCount: c.messagesSentCount,
         messagesReceivedCount: c.messagesReceivedCount,
         lastMessageSentAt: c.lastMessageSentAt,
@@ -152,6 +199,12 @@ router.post('/', requireAuth(), async (req: Request, res: Response) => {
         personality: config.personality,
         rules: config.rules,
         information: config.information,
+        userLimits: config.userLimits,
+        readChannels: config.readChannels,
+        respondChannels: config.respondChannels,
+        ignoredUsers: config.ignoredUsers,
+        ignoredRoles: config.ignoredRoles,
+        respondToRoles: config.respondToRoles,
         messagesSentCount: config.messagesSentCount,
         messagesReceivedCount: config.messagesReceivedCount,
         lastMessageSentAt: config.lastMessageSentAt,
@@ -215,6 +268,12 @@ router.get('/:configId', requireAuth(), async (req: Request, res: Response) => {
         personality: config.personality,
         rules: config.rules,
         information: config.information,
+        userLimits: config.userLimits,
+        readChannels: config.readChannels,
+        respondChannels: config.respondChannels,
+        ignoredUsers: config.ignoredUsers,
+        ignoredRoles: config.ignoredRoles,
+        respondToRoles: config.respondToRoles,
         messagesSentCount: config.messagesSentCount,
         messagesReceivedCount: config.messagesReceivedCount,
         lastMessageSentAt: config.lastMessageSentAt,
@@ -245,7 +304,7 @@ router.put('/:configId', requireAuth(), async (req: Request, res: Response) => {
 
     const user = await getOrCreateUser(auth.userId);
     const { configId } = req.params;
-    const { botName, personality, rules, information, botActive } = req.body;
+    const { botName, personality, rules, information, botActive, userLimits } = req.body;
 
     // Verify ownership
     const existing = await prisma.userServerConfig.findUnique({
@@ -267,7 +326,8 @@ router.put('/:configId', requireAuth(), async (req: Request, res: Response) => {
       rules?: string;
       information?: string;
       botActive?: boolean;
-    } = {};
+      userLimits?: Prisma.InputJsonValue | typeof Prisma.DbNull;
+    } & Partial<Record<AccessListField, string[]>> = {};
 
     if (typeof botName === 'string') {
       updateData.botName = botName;
@@ -288,6 +348,25 @@ router.put('/:configId', requireAuth(), async (req: Request, res: Response) => {
       }
       updateData.botActive = botActive;
     }
+    if (userLimits === null) {
+      updateData.userLimits = Prisma.DbNull;
+    } else if (userLimits !== undefined) {
+      const { limits, error } = validateUserLimits(userLimits);
+      if (error) {
+        return res.status(400).json({ error });
+      }
+      updateData.userLimits = limits;
+    }
+    for (const field of ACCESS_LIST_FIELDS) {
+      if (req.body[field] === undefined) {
+        continue;
+      }
+      const { ids, error } = validateIdList(field, req.body[field]);
+      if (error) {
+        return res.status(400).json({ error });
+      }
+      updateData[field] = ids;
+    }
 
     if (Object.keys(updateData).length === 0) {
       return res.status(400).json({ error: 'At least one field must be provided' });
@@ -315,7 +394,13 @@ router.put('/:configId', requireAuth(), async (req: Request, res: Response) => {
         botActive: config.botActive,
         personality: config.personality,
         rules: config.rules,
-        information: config.information
+        information: config.information,
+        userLimits: config.userLimits,
+        readChannels: config.readChannels,
+        respondChannels: config.respondChannels,
+        ignoredUsers: config.ignoredUsers,
+        ignoredRoles: config.ignoredRoles,
+        respondToRoles: config.respondToRoles
       }
     });
   } catch (error) {
@@ -389,9 +474,13 @@ router.get('/:configId/actions', requireAuth(), async (req: Request, res: Respon
       return res.status(403).json({ error: 'Access denied' });
     }
 
-    // Fetch recent agent actions
+    // Fetch recent agent actions filtered by type: "message" (default), "activity" or "all"
+    const actionType = (req.query.type as string) || 'message';
     const actions = await prisma.agentAction.findMany({
-      where: { userServerConfigId: configId },
+      where: {
+        userServerConfigId: configId,
+        ...(actionType !== 'all' ? { actionType } : {})
+      },
       orderBy: { createdAt: 'desc' },
       take: limit
     });
@@ -400,11 +489,15 @@ router.get('/:configId/actions', requireAuth(), async (req: Request, res: Respon
       success: true,
       actions: actions.map(a => ({
         id: a.id,
+        actionType: a.actionType,
         channelId: a.channelId,
         channelName: a.channelName,
         agentMessage: a.agentMessage,
         triggerDescription: a.triggerDescription,
         messageHistory: a.messageHistory,
+        outcome: a.outcome,
+        activity: a.activity,
+        traceId: a.traceId,
         createdAt: a.createdAt
       }))
     });
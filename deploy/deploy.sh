@@ -39,7 +39,7 @@ echo "=== Health Checks ==="
 echo -n "Backend: "
 curl -sf http://localhost:3000/health && echo "OK" || echo "FAILED"
 echo -n "Discord Client: "
-curl -sf http://localhost:8080/health && echo "OK" || echo "FAILED"
+curl -sf http://localhost:8080/readyz && echo "OK" || echo "FAILED"
 echo -n "Frontend: "
 curl -sf http://localhost:3001 > /dev/null && echo "OK" || echo "FAILED"
 
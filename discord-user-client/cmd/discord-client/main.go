@@ -1,114 +1,70 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 
 	"github.com/joho/godotenv"
-	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
-	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
-	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
-	"github.com/mjacniacki/neonrain/discord-user-client/internal/server"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
 )
 
+// command is a subcommand of discord-client
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
+
+// commands lists the subcommands; serve is the default
+var commands = []command{
+	{"serve", "run the client (default)", serve},
+	{"validate-config", "check the configuration, backend, HUMA key and token configs", validateConfig},
+	{"simulate", "feed a scripted conversation through an agent with a fake HUMA and Discord", simulateCommand},
+	{"replay", "re-drive a RECORD_FILE recording or an /events log through the client", replayCommand},
+}
+
 func main() {
 	// Load .env file (optional in Docker)
 	_ = godotenv.Load()
 
-	// Get backend URL and API key from environment
-	backendURL := os.Getenv("BACKEND_URL")
-	if backendURL == "" {
-		backendURL = "http://localhost:3000"
+	// Structured logging; also routes the standard logger used by dependencies
+	logConfig, err := logging.ConfigFromEnv()
+	if err != nil {
+		slog.Error("Invalid logging configuration", "error", err)
+		os.Exit(1)
 	}
+	logging.Setup(logConfig)
 
-	apiKey := os.Getenv("INTERNAL_API_KEY")
-	if apiKey == "" {
-		apiKey = "default-internal-key"
+	// Without a subcommand, or with only flags, serve as before
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		serve(args)
+		return
 	}
 
-	// Check for HUMA API key
-	humaAPIKey := os.Getenv("HUMA_API_KEY")
-	if humaAPIKey == "" {
-		log.Fatal("HUMA_API_KEY environment variable is required")
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(args[1:])
+			return
+		}
 	}
-
-	httpPort := os.Getenv("HTTP_PORT")
-	if httpPort == "" {
-		httpPort = "8080"
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
 	}
+	printUsage()
+	if args[0] != "help" {
+		os.Exit(2)
+	}
+}
 
-	// Initialize HUMA manager
-	humaManager := huma.NewManager(humaAPIKey)
-
-	// Initialize backend client
-	backendClient := backend.NewClient(backendURL, apiKey)
-
-	// Set backend client on HUMA manager for agent action reporting
-	humaManager.SetBackendClient(backendClient)
-
-	pollInterval := 2 * time.Second
-	log.Printf("Starting Discord client service with HUMA integration")
-	log.Printf("Backend URL: %s", backendURL)
-	log.Printf("HUMA API: https://api.humalike.tech")
-	log.Printf("Poll interval: %v", pollInterval)
-
-	// Initialize client manager for multi-user support
-	clientManager := client.NewClientManager(humaManager, backendClient)
-
-	// Initialize HTTP server
-	httpServer := server.NewServer(httpPort, clientManager)
-	httpServer.Start()
-
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
-
-	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-
-	// Initial fetch
-	go func() {
-		tokenConfigs, err := backendClient.FetchTokenConfigs()
-		if err != nil {
-			log.Printf("Error fetching tokens: %v", err)
-			log.Println("Waiting for Discord accounts to be connected...")
-		} else if len(tokenConfigs) > 0 {
-			// Count total servers across all tokens
-			totalServers := 0
-			for _, tc := range tokenConfigs {
-				totalServers += len(tc.Servers)
-			}
-			log.Printf("Found %d token(s) with %d total server config(s)", len(tokenConfigs), totalServers)
-			clientManager.SyncTokenConfigs(tokenConfigs)
-		} else {
-			log.Println("No Discord tokens found, waiting for users to connect Discord accounts...")
-		}
-	}()
-
-	// Poll for config changes
-	for {
-		select {
-		case <-ticker.C:
-			tokenConfigs, err := backendClient.FetchTokenConfigs()
-			if err != nil {
-				log.Printf("Error fetching tokens: %v", err)
-				continue
-			}
-
-			// Sync all configs with the client manager
-			// This handles:
-			// - New tokens (creates new connections)
-			// - Removed tokens (disconnects)
-			// - Updated configs (updates guild monitoring)
-			clientManager.SyncTokenConfigs(tokenConfigs)
-
-		case <-sigChan:
-			log.Println("\nShutting down...")
-			clientManager.DisconnectAll()
-			return
-		}
+// printUsage lists the subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: discord-client [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
 	}
+	fmt.Fprintln(os.Stderr, "\nRun 'discord-client <command> -help' for the flags of a command.")
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/config"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/server"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/standalone"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+)
+
+// serve runs the client: it polls for token configs and keeps Discord
+// connections and HUMA agents in sync with them
+func serve(args []string) {
+	logger := logging.For("main")
+
+	// Flags override env vars, which override the optional config file
+	cfg, err := config.Load("discord-client serve", args, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	for _, secret := range cfg.Secrets() {
+		logging.AddSecret(secret)
+	}
+	if cfg.Dev && cfg.Backend.InternalAPIKey == config.DefaultInternalKey {
+		logger.Warn("Using the default internal API key; do not run like this in production")
+	}
+
+	// Export traces when an OTLP collector is configured
+	var tracer *tracing.Tracer
+	if cfg.Tracing.Endpoint != "" {
+		headers := tracing.ParseHeaders(cfg.Tracing.Headers)
+		tracer = tracing.NewTracer(cfg.Tracing.ServiceName, tracing.NewOTLPExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, headers))
+		tracer.Start(tracing.DefaultFlushInterval)
+		tracing.SetTracer(tracer)
+		logger.Info("Exporting traces", "endpoint", cfg.Tracing.Endpoint)
+	}
+
+	// Record traffic for replay when asked to
+	var recorder *recording.Recorder
+	if cfg.RecordFile != "" {
+		recorder, err = recording.Open(cfg.RecordFile)
+		if err != nil {
+			logger.Error("Failed to open recording", "path", cfg.RecordFile, "error", err)
+			os.Exit(1)
+		}
+		recorder.Settings(recording.Settings{
+			TypingWPM:      cfg.Huma.TypingWPM,
+			MaxTypingDelay: cfg.Huma.MaxTypingDelay,
+			FetchLimit:     cfg.Huma.FetchLimit,
+			HistorySize:    cfg.History.Size,
+			DebounceWindow: cfg.Events.DebounceWindow,
+
+			SendCooldown:        cfg.SendPolicy.Cooldown,
+			SendRequireHuman:    cfg.SendPolicy.RequireHumanMessage,
+			SendChannelHourly:   cfg.SendPolicy.ChannelHourlySends,
+			SendGuildHourly:     cfg.SendPolicy.GuildHourlySends,
+			SendDuplicateWindow: cfg.SendPolicy.DuplicateWindow,
+		})
+		logger.Warn("Recording Discord and HUMA traffic, including message content", "path", cfg.RecordFile)
+	}
+
+	// Initialize HUMA manager
+	humaManager := huma.NewManager(cfg.Huma.APIKey)
+	humaManager.SetBaseURL(cfg.Huma.URL)
+	humaManager.SetTuning(huma.Tuning{
+		TypingWPM:      cfg.Huma.TypingWPM,
+		MaxTypingDelay: cfg.Huma.MaxTypingDelay,
+		FetchLimit:     cfg.Huma.FetchLimit,
+		RequestTimeout: cfg.Huma.Timeout,
+	})
+	humaManager.SetSendPolicy(huma.SendPolicy{
+		Cooldown:            cfg.SendPolicy.Cooldown,
+		RequireHumanMessage: cfg.SendPolicy.RequireHumanMessage,
+		ChannelHourlySends:  cfg.SendPolicy.ChannelHourlySends,
+		GuildHourlySends:    cfg.SendPolicy.GuildHourlySends,
+		DuplicateWindow:     cfg.SendPolicy.DuplicateWindow,
+	})
+	humaManager.SetRecorder(recorder)
+
+	// Configs come from the backend, or from a local file in standalone mode
+	var (
+		backendClient *backend.Client
+		configSource  backend.ConfigSource
+		reporter      backend.Reporter
+		sink          *standalone.Sink
+	)
+	if cfg.Standalone.ConfigFile != "" {
+		source, err := standalone.NewSource(cfg.Standalone.ConfigFile)
+		if err != nil {
+			logger.Error("Invalid standalone config", "path", cfg.Standalone.ConfigFile, "error", err)
+			os.Exit(1)
+		}
+		sink, err = standalone.NewSink(cfg.Standalone.SinkFile)
+		if err != nil {
+			logger.Error("Failed to open standalone sink", "path", cfg.Standalone.SinkFile, "error", err)
+			os.Exit(1)
+		}
+		configSource, reporter = source, sink
+		logger.Info("Running in standalone mode", "config", cfg.Standalone.ConfigFile, "sink", cfg.Standalone.SinkFile)
+	} else {
+		backendClient = backend.NewClient(cfg.Backend.URL, cfg.Backend.InternalAPIKey)
+		backendClient.SetTimeout(cfg.Backend.Timeout)
+		configSource, reporter = backendClient, backendClient
+	}
+
+	// Set reporter on HUMA manager for agent action reporting
+	humaManager.SetReporter(reporter)
+
+	// Aggregate stats and flush them in batches
+	statsReporter := backend.NewStatsReporter(reporter, cfg.Backend.StatsFlushInterval)
+	statsReporter.Start()
+	humaManager.SetStatsReporter(statsReporter)
+
+	logger.Info("Starting Discord client service with HUMA integration", "config", cfg)
+
+	// Initialize client manager for multi-user support
+	clientManager := client.NewClientManager(humaManager, backendClient)
+	clientManager.SetConfigSource(configSource)
+	clientManager.SetHistorySize(cfg.History.Size)
+	clientManager.SetDispatchConfig(client.DispatchConfig{
+		Workers:       cfg.Events.Workers,
+		QueueSize:     cfg.Events.QueueSize,
+		Overflow:      client.OverflowPolicy(cfg.Events.Overflow),
+		AmbientSample: cfg.Events.AmbientSample,
+	})
+	clientManager.SetDebounceWindow(cfg.Events.DebounceWindow)
+	clientManager.SetStatsReporter(statsReporter)
+	clientManager.SetRecorder(recorder)
+
+	// Guild and channel pauses survive restarts until lifted
+	pauses, err := pause.NewStore(cfg.PauseStateFile)
+	if err != nil {
+		logger.Error("Failed to load pause state", "path", cfg.PauseStateFile, "error", err)
+		os.Exit(1)
+	}
+	clientManager.SetPauseStore(pauses)
+	if active := pauses.List(); len(active) > 0 {
+		logger.Warn("Restored pauses", "count", len(active))
+	}
+
+	// Initialize HTTP server
+	httpServer := server.NewServer(cfg.HTTP.Port, clientManager)
+	httpServer.SetAPIKey(cfg.Backend.InternalAPIKey)
+	if cfg.HTTP.TLSCertFile != "" {
+		if err := httpServer.SetTLS(cfg.HTTP.TLSCertFile, cfg.HTTP.TLSKeyFile, cfg.HTTP.TLSClientCAFile); err != nil {
+			logger.Error("Invalid HTTP TLS configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	httpServer.Start()
+
+	ticker := time.NewTicker(cfg.Backend.PollInterval)
+	defer ticker.Stop()
+
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+
+	// Initial fetch
+	go func() {
+		tokenConfigs, err := configSource.FetchTokenConfigs()
+		if err != nil {
+			logger.Error("Error fetching tokens", "error", err)
+			logger.Info("Waiting for Discord accounts to be connected")
+		} else if len(tokenConfigs) > 0 {
+			// Count total servers across all tokens
+			totalServers := 0
+			for _, tc := range tokenConfigs {
+				totalServers += len(tc.Servers)
+			}
+			logger.Info("Found token configs", "tokens", len(tokenConfigs), "servers", totalServers)
+			clientManager.SyncTokenConfigs(tokenConfigs)
+		} else {
+			logger.Info("No Discord tokens found, waiting for users to connect Discord accounts")
+		}
+	}()
+
+	// Poll for config changes
+	for {
+		select {
+		case <-ticker.C:
+			tokenConfigs, err := configSource.FetchTokenConfigs()
+			if err != nil {
+				logger.Error("Error fetching tokens", "error", err)
+				continue
+			}
+
+			// Sync all configs with the client manager
+			// This handles:
+			// - New tokens (creates new connections)
+			// - Removed tokens (disconnects)
+			// - Updated configs (updates guild monitoring)
+			clientManager.SyncTokenConfigs(tokenConfigs)
+
+		case <-sigChan:
+			// A second signal skips the drain
+			go func() {
+				<-sigChan
+				logger.Warn("Second signal received, exiting immediately")
+				os.Exit(1)
+			}()
+			shutdown(cfg.Shutdown, httpServer, clientManager, humaManager, statsReporter, sink, recorder, tracer)
+			return
+		}
+	}
+}
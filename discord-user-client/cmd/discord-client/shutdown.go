@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/config"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/server"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/standalone"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+)
+
+// shutdown stops the service in dependency order within the deadline: stop
+// taking Discord events, let pending messages finish or cancel them, flush
+// backend reports, close HUMA sockets and Discord sessions, then stop serving HTTP.
+func shutdown(timeouts config.Shutdown, httpServer *server.Server, clientManager *client.ClientManager, humaManager *huma.Manager, statsReporter *backend.StatsReporter, sink *standalone.Sink, recorder *recording.Recorder, tracer *tracing.Tracer) {
+	logger := logging.For("main")
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Timeout)
+	defer cancel()
+
+	logger.Info("Shutting down", "timeout", timeouts.Timeout, "drain_timeout", timeouts.DrainTimeout)
+
+	// 1. Stop taking new work; /readyz fails so traffic moves elsewhere
+	httpServer.BeginShutdown()
+	clientManager.StopAcceptingEvents()
+
+	// 2. Let messages being typed go out, cancel the rest at the drain deadline
+	drainCtx, cancelDrain := context.WithTimeout(ctx, timeouts.DrainTimeout)
+	drained := humaManager.Drain(drainCtx)
+	cancelDrain()
+
+	// 3. Flush decision trails and agent actions, then close HUMA sockets
+	abandonedReports := humaManager.Shutdown(ctx)
+
+	// 4. Send the last stats batch
+	statsDone := make(chan struct{})
+	go func() {
+		statsReporter.Stop()
+		close(statsDone)
+	}()
+	select {
+	case <-statsDone:
+	case <-ctx.Done():
+		logger.Warn("Shutdown deadline reached while flushing stats")
+	}
+	unflushedStats, _ := statsReporter.Backlog()
+
+	// Standalone mode: everything has been reported, close the local sink
+	if sink != nil {
+		if err := sink.Close(); err != nil {
+			logger.Warn("Failed to close standalone sink", "error", err)
+		}
+	}
+
+	// 5. Close Discord sessions
+	clientManager.DisconnectAll()
+
+	// Nothing is sent or received from here on
+	if err := recorder.Close(); err != nil {
+		logger.Warn("Failed to close recording", "error", err)
+	}
+
+	// 6. Stop serving HTTP
+	httpErr := httpServer.Shutdown(ctx)
+	if httpErr != nil {
+		logger.Warn("HTTP server did not shut down cleanly", "error", httpErr)
+	}
+
+	if tracer != nil {
+		tracer.Shutdown()
+	}
+
+	logger.Info("Shutdown complete",
+		"duration", time.Since(start).Round(time.Millisecond),
+		"deadline_exceeded", ctx.Err() != nil,
+		"pending_messages", drained.Pending,
+		"messages_finished", drained.Finished,
+		"messages_canceled", drained.Canceled,
+		"reports_abandoned", abandonedReports,
+		"stats_unflushed", unflushedStats,
+		"http_clean", httpErr == nil,
+	)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/replay"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/simulate"
+)
+
+// simulateCommand runs a scripted conversation through an agent and prints its decisions
+func simulateCommand(args []string) {
+	flags := flag.NewFlagSet("discord-client simulate", flag.ContinueOnError)
+	opts := simulationFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: discord-client simulate [flags] script.yaml")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		os.Exit(exitCodeFor(err))
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	script, err := simulate.LoadScript(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	runSimulation(script, *opts)
+}
+
+// replayCommand replays a RECORD_FILE recording, or rebuilds a script from a
+// recorded /events log and runs it
+func replayCommand(args []string) {
+	flags := flag.NewFlagSet("discord-client replay", flag.ContinueOnError)
+	opts := simulationFlags(flags)
+	guildID := flags.String("guild", "", "guild to replay (default: the guild of the first received message)")
+	scriptOut := flags.String("script-out", "", "also write the rebuilt script to this file, to edit and rerun with simulate")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: discord-client replay [flags] recording.jsonl.gz|events.jsonl")
+		fmt.Fprintln(flags.Output(), "Replays a RECORD_FILE recording, or the output of GET /events as JSON lines or raw SSE; - reads stdin.")
+		fmt.Fprintln(flags.Output(), "-guild, -script-out, -typing-wpm and -timeout apply to /events logs only.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		os.Exit(exitCodeFor(err))
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var input io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open event log: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	buffered := bufio.NewReader(input)
+	if head, _ := buffered.Peek(512); recording.IsRecording(head) {
+		replayRecording(buffered)
+		return
+	}
+
+	script, err := simulate.ScriptFromEvents(buffered, *guildID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *scriptOut != "" {
+		data, err := script.Marshal()
+		if err == nil {
+			err = os.WriteFile(*scriptOut, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write script: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	runSimulation(script, *opts)
+}
+
+// replayRecording replays a recording against a virtual clock and prints how
+// the client's sends compare. Exits 1 if they differ.
+func replayRecording(input io.Reader) {
+	entries, err := recording.Read(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	result, err := replay.Run(ctx, entries, os.Stdout, replay.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stdout, "finished in %s\n", time.Since(started).Round(time.Millisecond))
+	if result.Mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// simulationFlags registers the flags shared by simulate and replay
+func simulationFlags(flags *flag.FlagSet) *simulate.Options {
+	opts := &simulate.Options{}
+	flags.IntVar(&opts.TypingWPM, "typing-wpm", simulate.DefaultTypingWPM, "simulated typing speed; lower it to widen the window for cancels")
+	flags.DurationVar(&opts.StepTimeout, "timeout", simulate.DefaultStepTimeout, "how long one tool call may take")
+	return opts
+}
+
+// runSimulation runs the script until done or interrupted. Exits 1 if the run
+// fails or differs from the recording.
+func runSimulation(script *simulate.Script, opts simulate.Options) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	result, err := simulate.Run(ctx, script, os.Stdout, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stdout, "finished in %s\n", time.Since(started).Round(time.Millisecond))
+	if result.Mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// exitCodeFor maps a flag parse error to an exit code: 0 for -help, 2 otherwise
+func exitCodeFor(err error) int {
+	if err == flag.ErrHelp {
+		return 0
+	}
+	return 2
+}
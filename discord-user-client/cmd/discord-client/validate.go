@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/config"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/standalone"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// validateConfig loads the configuration like serve does, then checks that the
+// backend (or standalone config file), the HUMA key and the token configs work.
+// Exits non-zero if anything fails.
+func validateConfig(args []string) {
+	cfg, err := config.Load("discord-client validate-config", args, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "FAIL configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	fmt.Fprintln(out, "ok   configuration")
+	if err := cfg.Print(indent(out)); err != nil {
+		fmt.Fprintf(out, "FAIL %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	check := func(name string, err error, detail string) {
+		if err != nil {
+			failed = true
+			fmt.Fprintf(out, "FAIL %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(out, "ok   %s (%s)\n", name, detail)
+	}
+
+	var (
+		tokenConfigs []types.TokenConfig
+		fetchErr     error
+	)
+	if cfg.Standalone.ConfigFile != "" {
+		tokenConfigs, fetchErr = standalone.Load(cfg.Standalone.ConfigFile)
+		check("standalone config", fetchErr, cfg.Standalone.ConfigFile)
+	} else {
+		backendClient := backend.NewClient(cfg.Backend.URL, cfg.Backend.InternalAPIKey)
+		backendClient.SetTimeout(cfg.Backend.Timeout)
+		tokenConfigs, fetchErr = backendClient.FetchTokenConfigs()
+		check("backend", fetchErr, cfg.Backend.URL)
+	}
+
+	humaClient := huma.NewClient(cfg.Huma.APIKey)
+	humaClient.SetBaseURL(cfg.Huma.URL)
+	check("HUMA API key", humaClient.CheckAPIKey(), cfg.Huma.URL)
+
+	if fetchErr == nil {
+		problems := checkTokenConfigs(out, tokenConfigs)
+		if problems > 0 {
+			failed = true
+			fmt.Fprintf(out, "FAIL token configs: %d problems\n", problems)
+		} else {
+			fmt.Fprintf(out, "ok   token configs (%d tokens)\n", len(tokenConfigs))
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkTokenConfigs prints a line per token and returns the number of problems
+func checkTokenConfigs(out io.Writer, tokenConfigs []types.TokenConfig) int {
+	problems := 0
+	problem := func(format string, args ...interface{}) {
+		problems++
+		fmt.Fprintf(out, "       problem: "+format+"\n", args...)
+	}
+
+	seenTokens := make(map[string]string) // fingerprint -> first user
+	for i, tc := range tokenConfigs {
+		if tc.DiscordToken == "" {
+			fmt.Fprintf(out, "     token %d (user %s)\n", i+1, tc.UserID)
+			problem("empty Discord token")
+			continue
+		}
+
+		fingerprint := client.TokenFingerprint(tc.DiscordToken)
+		active := 0
+		for _, server := range tc.Servers {
+			if server.BotActive {
+				active++
+			}
+		}
+		fmt.Fprintf(out, "     %s (user %s): %d servers, %d active\n", fingerprint, tc.UserID, len(tc.Servers), active)
+
+		if user, ok := seenTokens[fingerprint]; ok {
+			// Supported, the client shares one connection between them
+			fmt.Fprintf(out, "       note: same token as user %s\n", user)
+		} else {
+			seenTokens[fingerprint] = tc.UserID
+		}
+
+		seenGuilds := make(map[string]bool)
+		for _, server := range tc.Servers {
+			if server.GuildID == "" {
+				problem("server %q has no guild ID", server.GuildName)
+				continue
+			}
+			if seenGuilds[server.GuildID] {
+				problem("guild %s is configured more than once", server.GuildID)
+			}
+			seenGuilds[server.GuildID] = true
+		}
+	}
+	return problems
+}
+
+// indentWriter prefixes every line with spaces
+type indentWriter struct {
+	w         io.Writer
+	lineStart bool
+}
+
+// indent nests the printed config under its check line
+func indent(w io.Writer) io.Writer {
+	return &indentWriter{w: w, lineStart: true}
+}
+
+func (iw *indentWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if iw.lineStart {
+			if _, err := iw.w.Write([]byte("       ")); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := iw.w.Write([]byte{b}); err != nil {
+			return 0, err
+		}
+		iw.lineStart = b == '\n'
+	}
+	return len(p), nil
+}
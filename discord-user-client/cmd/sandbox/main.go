@@ -0,0 +1,116 @@
+// Command sandbox runs the client against a fake Discord guild, the fake HUMA
+// and a stand-in backend, with a REPL to type as guild members and act as the
+// agent. No tokens or API keys are needed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/sandbox"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/server"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	opts := sandbox.DefaultOptions()
+	flags := flag.NewFlagSet("sandbox", flag.ContinueOnError)
+	flags.StringVar(&opts.GuildName, "guild", opts.GuildName, "name of the fake guild")
+	channels := flags.String("channels", strings.Join(opts.Channels, ","), "comma-separated channel names")
+	users := flags.String("users", strings.Join(opts.Users, ","), "comma-separated guild members to start with")
+	flags.StringVar(&opts.BotName, "bot", opts.BotName, "username of the bot account")
+	flags.StringVar(&opts.Personality, "personality", opts.Personality, "bot personality")
+	flags.StringVar(&opts.Rules, "rules", opts.Rules, "bot rules")
+	flags.StringVar(&opts.Information, "information", opts.Information, "bot information")
+	flags.IntVar(&opts.TypingWPM, "typing-wpm", opts.TypingWPM, "simulated typing speed of the bot")
+	flags.DurationVar(&opts.DebounceWindow, "debounce", 0, "batch a channel's messages until it has been quiet this long, like DEBOUNCE_WINDOW")
+	flags.StringVar(&opts.HumaURL, "huma-url", "", "use a real HUMA at this URL with HUMA_API_KEY instead of the fake")
+	port := flags.String("port", "", "also serve the client's HTTP API on this port (API key \""+sandbox.APIKey+"\")")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sandbox [flags]")
+		fmt.Fprintln(flags.Output(), "Runs the client against a fake Discord guild, HUMA and backend. Logs go to stderr at warn unless LOG_LEVEL is set.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	opts.Channels = splitList(*channels)
+	opts.Users = splitList(*users)
+	if opts.HumaURL != "" {
+		opts.HumaAPIKey = os.Getenv("HUMA_API_KEY")
+		if opts.HumaAPIKey == "" {
+			fmt.Fprintln(os.Stderr, "-huma-url needs HUMA_API_KEY")
+			os.Exit(2)
+		}
+	}
+
+	// Keep logs out of the transcript unless asked for
+	logConfig, err := logging.ConfigFromEnv()
+	if err != nil {
+		slog.Error("Invalid logging configuration", "error", err)
+		os.Exit(1)
+	}
+	if os.Getenv("LOG_LEVEL") == "" {
+		logConfig.Level = slog.LevelWarn
+	}
+	logging.Setup(logConfig)
+
+	box, err := sandbox.Start(opts, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var httpServer *server.Server
+	if *port != "" {
+		httpServer = server.NewServer(*port, box.Manager)
+		httpServer.SetAPIKey(sandbox.APIKey)
+		httpServer.Start()
+		fmt.Printf("HTTP API on :%s (X-API-Key: %s)\n", *port, sandbox.APIKey)
+	}
+
+	// Ctrl-C ends the REPL like /quit
+	done := make(chan error, 1)
+	go func() { done <- sandbox.NewREPL(box).Run(os.Stdin) }()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err = <-done:
+	case <-sigChan:
+	}
+
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		httpServer.Shutdown(ctx)
+		cancel()
+	}
+	box.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -9,6 +9,7 @@ require (
 	github.com/gorilla/websocket v1.5.0
 	github.com/joho/godotenv v1.5.1
 	go.jetify.com/ai v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -3,15 +3,18 @@ package ai
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
 	"go.jetify.com/ai"
 	"go.jetify.com/ai/api"
 	"go.jetify.com/ai/provider/openai"
 )
 
+// logger is used for prompt, chunk and typing events
+var logger = logging.For("ai")
+
 // MessageSender defines the interface for sending messages
 type MessageSender interface {
 	SendMessage(channelID, content string) error
@@ -46,9 +49,8 @@ func (sp *StreamProcessor) ProcessPrompt(ctx context.Context, prompt string, cha
 		Chunks: []string{},
 	}
 
-	// Log the complete prompt being sent to AI
-	log.Printf("[AI-PROMPT] Sending to AI:\n%s", prompt)
-	log.Printf("[AI-PROMPT] ---END OF PROMPT---")
+	// Prompts contain user messages, so they are only logged at debug level
+	logger.Debug("Sending prompt to AI", logging.KeyChannelID, channelID, "prompt", prompt)
 
 	// Start typing indicator loop in background
 	// Discord typing indicator lasts ~10s, so we refresh every 8s
@@ -89,7 +91,7 @@ func (sp *StreamProcessor) ProcessPrompt(ctx context.Context, prompt string, cha
 			chunks := extractChunks(&buffer)
 			for _, chunk := range chunks {
 				if chunk != "" {
-					log.Printf("[AI-CHUNK] Sending: %s", chunk)
+					logger.Debug("Sending chunk", "chunk", chunk)
 					result.Chunks = append(result.Chunks, chunk)
 
 					// Send chunk via sender with rate limiting
@@ -98,12 +100,12 @@ func (sp *StreamProcessor) ProcessPrompt(ctx context.Context, prompt string, cha
 						// Time since last message includes streaming time
 						delay := calculateTypingDelay(chunk, lastSendTime)
 						if delay > 0 {
-							log.Printf("[AI-RATELIMIT] Delaying %v to simulate 90 WPM", delay)
+							logger.Debug("Delaying to simulate 90 WPM", "delay", delay)
 							time.Sleep(delay)
 						}
 
 						if err := sp.sender.SendMessage(channelID, chunk); err != nil {
-							log.Printf("[AI] Error sending chunk: %v", err)
+							logger.Error("Error sending chunk", "error", err)
 						}
 
 						// Track when we sent this message
@@ -116,25 +118,25 @@ func (sp *StreamProcessor) ProcessPrompt(ctx context.Context, prompt string, cha
 
 	// Send any remaining buffer content as the final message
 	if buffer != "" {
-		log.Printf("[AI-CHUNK] Sending final chunk: %s", buffer)
+		logger.Debug("Sending final chunk", "chunk", buffer)
 		result.Chunks = append(result.Chunks, buffer)
 
 		if sp.sender != nil && channelID != "" {
 			// Calculate delay for final chunk
 			delay := calculateTypingDelay(buffer, lastSendTime)
 			if delay > 0 {
-				log.Printf("[AI-RATELIMIT] Delaying %v to simulate 90 WPM", delay)
+				logger.Debug("Delaying to simulate 90 WPM", "delay", delay)
 				time.Sleep(delay)
 			}
 
 			if err := sp.sender.SendMessage(channelID, buffer); err != nil {
-				log.Printf("[AI] Error sending final chunk: %v", err)
+				logger.Error("Error sending final chunk", "error", err)
 			}
 			// No typing indicator after final chunk
 		}
 	}
 
-	log.Printf("[AI] Full response: %s", result.FullResponse)
+	logger.Debug("Full response", logging.KeyChannelID, channelID, "response", result.FullResponse)
 	return result
 }
 
@@ -217,10 +219,10 @@ func (sp *StreamProcessor) ProcessPromptWithoutSending(ctx context.Context, prom
 func (sp *StreamProcessor) typingIndicatorLoop(channelID string, stop <-chan struct{}) {
 	// Send initial typing indicator immediately
 	if err := sp.sender.SendTypingIndicator(channelID); err != nil {
-		log.Printf("[AI-TYPING] Error sending initial typing indicator: %v", err)
+		logger.Warn("Error sending initial typing indicator", "error", err)
 		return
 	}
-	log.Printf("[AI-TYPING] Started typing indicator loop")
+	logger.Debug("Started typing indicator loop")
 
 	ticker := time.NewTicker(8 * time.Second)
 	defer ticker.Stop()
@@ -228,14 +230,14 @@ func (sp *StreamProcessor) typingIndicatorLoop(channelID string, stop <-chan str
 	for {
 		select {
 		case <-stop:
-			log.Printf("[AI-TYPING] Stopped typing indicator loop")
+			logger.Debug("Stopped typing indicator loop")
 			return
 		case <-ticker.C:
 			if err := sp.sender.SendTypingIndicator(channelID); err != nil {
-				log.Printf("[AI-TYPING] Error sending typing indicator: %v", err)
+				logger.Warn("Error sending typing indicator", "error", err)
 				// Continue loop even on error
 			} else {
-				log.Printf("[AI-TYPING] Refreshed typing indicator")
+				logger.Debug("Refreshed typing indicator")
 			}
 		}
 	}
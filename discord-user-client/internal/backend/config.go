@@ -6,29 +6,106 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
+// ConfigSource provides the token configs to run. *Client fetches them from the
+// backend; standalone mode reads them from a local file.
+type ConfigSource interface {
+	FetchTokenConfigs() ([]types.TokenConfig, error)
+}
+
+// Reporter receives stats and agent reports. *Client sends them to the backend;
+// standalone mode appends them to a local file.
+type Reporter interface {
+	ReportStatsBatch(batch types.StatsBatchPayload) error
+	ReportAgentAction(action types.AgentActionPayload) error
+	ReportAgentActivity(activity types.AgentActivityPayload) error
+}
+
 // Client handles communication with the backend API
 type Client struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+
+	// Outcome of the most recent calls, for health checks
+	contactMu   sync.Mutex
+	lastSuccess time.Time
+	lastError   error
+	lastErrorAt time.Time
+}
+
+// Contact is the outcome of the most recent backend calls
+type Contact struct {
+	LastSuccess time.Time
+	LastError   error
+	LastErrorAt time.Time
 }
 
+// Reachable reports whether the most recent call reached the backend
+func (c Contact) Reachable() bool {
+	return !c.LastSuccess.IsZero() && !c.LastErrorAt.After(c.LastSuccess)
+}
+
+// DefaultTimeout bounds each backend request unless SetTimeout changes it
+const DefaultTimeout = 10 * time.Second
+
 // NewClient creates a new backend API client
 func NewClient(baseURL, apiKey string) *Client {
 	return &Client{
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: DefaultTimeout,
 		},
 	}
 }
 
+// SetTimeout sets the timeout for each backend request. Call before use.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.httpClient.Timeout = timeout
+}
+
+// do sends a request and records latency and errors for the endpoint.
+// Non-2xx responses count as errors.
+func (c *Client) do(endpoint string, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	metrics.BackendRequestDuration.Observe(time.Since(start).Seconds(), endpoint)
+	if err != nil || resp.StatusCode >= 300 {
+		metrics.BackendRequestErrors.Inc(endpoint)
+	}
+
+	// Client errors still mean the backend is up
+	c.contactMu.Lock()
+	switch {
+	case err != nil:
+		c.lastError, c.lastErrorAt = err, time.Now()
+	case resp.StatusCode >= 500:
+		c.lastError, c.lastErrorAt = fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode), time.Now()
+	default:
+		c.lastSuccess = time.Now()
+	}
+	c.contactMu.Unlock()
+	return resp, err
+}
+
+// LastContact returns the outcome of the most recent backend calls
+func (c *Client) LastContact() Contact {
+	c.contactMu.Lock()
+	defer c.contactMu.Unlock()
+	return Contact{
+		LastSuccess: c.lastSuccess,
+		LastError:   c.lastError,
+		LastErrorAt: c.lastErrorAt,
+	}
+}
+
 // FetchTokenConfigs retrieves token configurations with multiple servers per token
 func (c *Client) FetchTokenConfigs() ([]types.TokenConfig, error) {
 	req, err := http.NewRequest("GET", c.baseURL+"/api/discord/tokens", nil)
@@ -38,7 +115,7 @@ func (c *Client) FetchTokenConfigs() ([]types.TokenConfig, error) {
 
 	req.Header.Set("X-API-Key", c.apiKey)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do("tokens", req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch tokens: %w", err)
 	}
@@ -74,9 +151,12 @@ func (c *Client) FetchTokenConfigs() ([]types.TokenConfig, error) {
 	return configs, nil
 }
 
-// ReportStats sends a stats event to the backend for a specific guild
-func (c *Client) ReportStats(userID, guildID, event string) error {
-	payload := fmt.Sprintf(`{"userId":"%s","guildId":"%s","event":"%s"}`, userID, guildID, event)
+// ReportStatsBatch sends a batch of aggregated stats to the backend
+func (c *Client) ReportStatsBatch(batch types.StatsBatchPayload) error {
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
 
 	req, err := http.NewRequest("POST", c.baseURL+"/api/discord/stats", nil)
 	if err != nil {
@@ -85,9 +165,9 @@ func (c *Client) ReportStats(userID, guildID, event string) error {
 
 	req.Header.Set("X-API-Key", c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	req.Body = io.NopCloser(strings.NewReader(payload))
+	req.Body = io.NopCloser(strings.NewReader(string(payload)))
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do("stats", req)
 	if err != nil {
 		return fmt.Errorf("failed to send stats: %w", err)
 	}
@@ -103,6 +183,10 @@ func (c *Client) ReportStats(userID, guildID, event string) error {
 
 // ReportAgentAction sends an agent action with message history to the backend
 func (c *Client) ReportAgentAction(action types.AgentActionPayload) error {
+	if action.ActionType == "" {
+		action.ActionType = types.AgentActionTypeMessage
+	}
+
 	payload, err := json.Marshal(action)
 	if err != nil {
 		return fmt.Errorf("failed to marshal action: %w", err)
@@ -117,7 +201,7 @@ func (c *Client) ReportAgentAction(action types.AgentActionPayload) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Body = io.NopCloser(strings.NewReader(string(payload)))
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do("agent-action", req)
 	if err != nil {
 		return fmt.Errorf("failed to send agent action: %w", err)
 	}
@@ -130,3 +214,35 @@ func (c *Client) ReportAgentAction(action types.AgentActionPayload) error {
 
 	return nil
 }
+
+// ReportAgentActivity sends the agent's decision trail for a trigger to the backend
+func (c *Client) ReportAgentActivity(activity types.AgentActivityPayload) error {
+	activity.ActionType = types.AgentActionTypeActivity
+
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL+"/api/discord/agent-action", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = io.NopCloser(strings.NewReader(string(payload)))
+
+	resp, err := c.do("agent-action", req)
+	if err != nil {
+		return fmt.Errorf("failed to send agent activity: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("backend returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
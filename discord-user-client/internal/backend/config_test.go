@@ -9,25 +9,21 @@ import (
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
-func TestFetchDiscordTokens_Success(t *testing.T) {
+func TestFetchTokenConfigs_Success(t *testing.T) {
 	// Create a mock server
 	mockResponse := types.TokenResponse{
 		Success: true,
-		Tokens: []struct {
-			UserID           string             `json:"userId"`
-			UserEmail        string             `json:"userEmail"`
-			DiscordToken     string             `json:"discordToken"`
-			SelectedChannels []types.ChannelInfo `json:"selectedChannels"`
-			Prompt           string             `json:"prompt"`
-		}{
+		Tokens: []types.TokenConfig{
 			{
 				UserID:       "user123",
-				UserEmail:    "test@example.com",
 				DiscordToken: "token123",
-				SelectedChannels: []types.ChannelInfo{
-					{ChannelID: "channel1", GuildID: "guild1"},
+				Servers: []types.ServerConfig{
+					{GuildID: "guild1", GuildName: "Guild One", BotActive: true, Rules: "Be helpful"},
 				},
-				Prompt: "Be helpful",
+			},
+			{
+				UserID:       "user456",
+				DiscordToken: "",
 			},
 		},
 	}
@@ -54,44 +50,38 @@ func TestFetchDiscordTokens_Success(t *testing.T) {
 	client := NewClient(server.URL, "test-key")
 
 	// Fetch tokens
-	configs, err := client.FetchDiscordTokens()
+	configs, err := client.FetchTokenConfigs()
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	// Verify results
+	// Verify results (tokens without a Discord token are filtered out)
 	if len(configs) != 1 {
 		t.Fatalf("Expected 1 config, got %d", len(configs))
 	}
 
 	config := configs[0]
-	if config.Token != "token123" {
-		t.Errorf("Expected token 'token123', got '%s'", config.Token)
+	if config.DiscordToken != "token123" {
+		t.Errorf("Expected token 'token123', got '%s'", config.DiscordToken)
 	}
-	if config.Email != "test@example.com" {
-		t.Errorf("Expected email 'test@example.com', got '%s'", config.Email)
+	if config.UserID != "user123" {
+		t.Errorf("Expected user ID 'user123', got '%s'", config.UserID)
 	}
-	if config.Prompt != "Be helpful" {
-		t.Errorf("Expected prompt 'Be helpful', got '%s'", config.Prompt)
+	if len(config.Servers) != 1 {
+		t.Fatalf("Expected 1 server config, got %d", len(config.Servers))
 	}
-	if len(config.SelectedChannels) != 1 {
-		t.Fatalf("Expected 1 selected channel, got %d", len(config.SelectedChannels))
+	if config.Servers[0].GuildID != "guild1" {
+		t.Errorf("Expected guild ID 'guild1', got '%s'", config.Servers[0].GuildID)
 	}
-	if config.SelectedChannels[0].ChannelID != "channel1" {
-		t.Errorf("Expected channel ID 'channel1', got '%s'", config.SelectedChannels[0].ChannelID)
+	if config.Servers[0].Rules != "Be helpful" {
+		t.Errorf("Expected rules 'Be helpful', got '%s'", config.Servers[0].Rules)
 	}
 }
 
-func TestFetchDiscordTokens_EmptyTokens(t *testing.T) {
+func TestFetchTokenConfigs_EmptyTokens(t *testing.T) {
 	mockResponse := types.TokenResponse{
 		Success: true,
-		Tokens:  []struct {
-			UserID           string             `json:"userId"`
-			UserEmail        string             `json:"userEmail"`
-			DiscordToken     string             `json:"discordToken"`
-			SelectedChannels []types.ChannelInfo `json:"selectedChannels"`
-			Prompt           string             `json:"prompt"`
-		}{},
+		Tokens:  []types.TokenConfig{},
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -101,7 +91,7 @@ func TestFetchDiscordTokens_EmptyTokens(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL, "test-key")
-	configs, err := client.FetchDiscordTokens()
+	configs, err := client.FetchTokenConfigs()
 
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
@@ -111,21 +101,21 @@ func TestFetchDiscordTokens_EmptyTokens(t *testing.T) {
 	}
 }
 
-func TestFetchDiscordTokens_Unauthorized(t *testing.T) {
+func TestFetchTokenConfigs_Unauthorized(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 	}))
 	defer server.Close()
 
 	client := NewClient(server.URL, "wrong-key")
-	_, err := client.FetchDiscordTokens()
+	_, err := client.FetchTokenConfigs()
 
 	if err == nil {
 		t.Error("Expected error for unauthorized request, got nil")
 	}
 }
 
-func TestFetchDiscordTokens_InvalidJSON(t *testing.T) {
+func TestFetchTokenConfigs_InvalidJSON(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte("invalid json"))
@@ -133,23 +123,17 @@ func TestFetchDiscordTokens_InvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL, "test-key")
-	_, err := client.FetchDiscordTokens()
+	_, err := client.FetchTokenConfigs()
 
 	if err == nil {
 		t.Error("Expected error for invalid JSON, got nil")
 	}
 }
 
-func TestFetchDiscordTokens_SuccessFalse(t *testing.T) {
+func TestFetchTokenConfigs_SuccessFalse(t *testing.T) {
 	mockResponse := types.TokenResponse{
 		Success: false,
-		Tokens:  []struct {
-			UserID           string             `json:"userId"`
-			UserEmail        string             `json:"userEmail"`
-			DiscordToken     string             `json:"discordToken"`
-			SelectedChannels []types.ChannelInfo `json:"selectedChannels"`
-			Prompt           string             `json:"prompt"`
-		}{},
+		Tokens:  []types.TokenConfig{},
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -159,7 +143,7 @@ func TestFetchDiscordTokens_SuccessFalse(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL, "test-key")
-	_, err := client.FetchDiscordTokens()
+	_, err := client.FetchTokenConfigs()
 
 	if err == nil {
 		t.Error("Expected error when success=false, got nil")
@@ -0,0 +1,300 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// DefaultStatsFlushInterval is how often aggregated stats are sent to the backend
+const DefaultStatsFlushInterval = 30 * time.Second
+
+// statsKey identifies the (user, guild) pair counters are aggregated under
+type statsKey struct {
+	userID  string
+	guildID string
+}
+
+// guildStats holds the counters for one (user, guild) pair
+type guildStats struct {
+	messagesReceived      int64
+	messagesSent          int64
+	toolCalls             int64
+	suppressedResponses   int64
+	humaErrors            int64
+	responseCount         int64
+	responseLatencyMsSum  int64
+	responseLatencyMsMax  int64
+	typingTimeMs          int64
+	lastMessageReceivedAt time.Time
+	lastMessageSentAt     time.Time
+}
+
+// merge adds the counters from other into s
+func (s *guildStats) merge(other *guildStats) {
+	s.messagesReceived += other.messagesReceived
+	s.messagesSent += other.messagesSent
+	s.toolCalls += other.toolCalls
+	s.suppressedResponses += other.suppressedResponses
+	s.humaErrors += other.humaErrors
+	s.responseCount += other.responseCount
+	s.responseLatencyMsSum += other.responseLatencyMsSum
+	if other.responseLatencyMsMax > s.responseLatencyMsMax {
+		s.responseLatencyMsMax = other.responseLatencyMsMax
+	}
+	s.typingTimeMs += other.typingTimeMs
+	if other.lastMessageReceivedAt.After(s.lastMessageReceivedAt) {
+		s.lastMessageReceivedAt = other.lastMessageReceivedAt
+	}
+	if other.lastMessageSentAt.After(s.lastMessageSentAt) {
+		s.lastMessageSentAt = other.lastMessageSentAt
+	}
+}
+
+// statsLogger is used for stats flush failures
+var statsLogger = logging.For("stats")
+
+// StatsReporter aggregates stats per (user, guild) and flushes them to the
+// backend in batches. All Record methods are safe to call on a nil reporter.
+type StatsReporter struct {
+	client      Reporter
+	interval    time.Duration
+	mu          sync.Mutex
+	pending     map[statsKey]*guildStats
+	periodStart time.Time
+	stopChan    chan struct{}
+	doneChan    chan struct{}
+}
+
+// NewStatsReporter creates a new stats reporter that flushes every interval
+func NewStatsReporter(client Reporter, interval time.Duration) *StatsReporter {
+	if interval <= 0 {
+		interval = DefaultStatsFlushInterval
+	}
+	return &StatsReporter{
+		client:      client,
+		interval:    interval,
+		pending:     make(map[statsKey]*guildStats),
+		periodStart: time.Now(),
+	}
+}
+
+// Start begins the periodic flush loop
+func (r *StatsReporter) Start() {
+	r.mu.Lock()
+	if r.stopChan != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.stopChan = make(chan struct{})
+	r.doneChan = make(chan struct{})
+	stopChan, doneChan := r.stopChan, r.doneChan
+	r.mu.Unlock()
+
+	go r.flushLoop(stopChan, doneChan)
+}
+
+// Stop stops the flush loop and sends any remaining stats
+func (r *StatsReporter) Stop() {
+	r.mu.Lock()
+	stopChan := r.stopChan
+	doneChan := r.doneChan
+	r.stopChan = nil
+	r.mu.Unlock()
+
+	if stopChan != nil {
+		close(stopChan)
+		<-doneChan
+	}
+
+	if err := r.Flush(); err != nil {
+		statsLogger.Error("Final flush failed", "error", err)
+	}
+}
+
+// flushLoop flushes pending stats on every tick until stopped
+func (r *StatsReporter) flushLoop(stopChan <-chan struct{}, doneChan chan<- struct{}) {
+	defer close(doneChan)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				statsLogger.Warn("Flush failed", "error", err)
+			}
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// Flush sends all pending stats to the backend in a single batch.
+// On failure the counters are kept and retried on the next flush.
+func (r *StatsReporter) Flush() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if len(r.pending) == 0 {
+		r.periodStart = time.Now()
+		r.mu.Unlock()
+		return nil
+	}
+	batch := r.pending
+	periodStart := r.periodStart
+	r.pending = make(map[statsKey]*guildStats)
+	r.periodStart = time.Now()
+	r.mu.Unlock()
+
+	payload := buildStatsBatch(batch, periodStart, time.Now())
+	if err := r.client.ReportStatsBatch(payload); err != nil {
+		// Put the counters back so they are not lost
+		r.mu.Lock()
+		for key, stats := range batch {
+			if existing, ok := r.pending[key]; ok {
+				existing.merge(stats)
+			} else {
+				r.pending[key] = stats
+			}
+		}
+		r.periodStart = periodStart
+		r.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Backlog returns the number of (user, guild) counters waiting to be flushed and
+// the start of the oldest unflushed period. Failed flushes keep the period open,
+// so the age keeps growing while the backend rejects batches.
+func (r *StatsReporter) Backlog() (pending int, since time.Time) {
+	if r == nil {
+		return 0, time.Time{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending), r.periodStart
+}
+
+// Interval returns how often stats are flushed
+func (r *StatsReporter) Interval() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.interval
+}
+
+// buildStatsBatch converts aggregated counters into the backend payload
+func buildStatsBatch(batch map[statsKey]*guildStats, periodStart, periodEnd time.Time) types.StatsBatchPayload {
+	entries := make([]types.GuildStatsEntry, 0, len(batch))
+	for key, s := range batch {
+		entry := types.GuildStatsEntry{
+			UserID:               key.userID,
+			GuildID:              key.guildID,
+			MessagesReceived:     s.messagesReceived,
+			MessagesSent:         s.messagesSent,
+			ToolCalls:            s.toolCalls,
+			SuppressedResponses:  s.suppressedResponses,
+			HumaErrors:           s.humaErrors,
+			ResponseCount:        s.responseCount,
+			ResponseLatencyMsSum: s.responseLatencyMsSum,
+			ResponseLatencyMsMax: s.responseLatencyMsMax,
+			TypingTimeMs:         s.typingTimeMs,
+		}
+		if !s.lastMessageReceivedAt.IsZero() {
+			entry.LastMessageReceivedAt = s.lastMessageReceivedAt.UTC().Format(time.RFC3339)
+		}
+		if !s.lastMessageSentAt.IsZero() {
+			entry.LastMessageSentAt = s.lastMessageSentAt.UTC().Format(time.RFC3339)
+		}
+		entries = append(entries, entry)
+	}
+
+	return types.StatsBatchPayload{
+		Version:     types.StatsBatchVersion,
+		PeriodStart: periodStart.UTC().Format(time.RFC3339),
+		PeriodEnd:   periodEnd.UTC().Format(time.RFC3339),
+		Entries:     entries,
+	}
+}
+
+// record applies fn to the counters for (userID, guildID)
+func (r *StatsReporter) record(userID, guildID string, fn func(s *guildStats)) {
+	if r == nil || userID == "" || guildID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := statsKey{userID: userID, guildID: guildID}
+	s, ok := r.pending[key]
+	if !ok {
+		s = &guildStats{}
+		r.pending[key] = s
+	}
+	fn(s)
+}
+
+// RecordMessageReceived counts an inbound Discord message
+func (r *StatsReporter) RecordMessageReceived(userID, guildID string) {
+	r.record(userID, guildID, func(s *guildStats) {
+		s.messagesReceived++
+		s.lastMessageReceivedAt = time.Now()
+	})
+}
+
+// RecordMessageSent counts a message sent by the agent
+func (r *StatsReporter) RecordMessageSent(userID, guildID string) {
+	r.record(userID, guildID, func(s *guildStats) {
+		s.messagesSent++
+		s.lastMessageSentAt = time.Now()
+	})
+}
+
+// RecordResponseLatency records the time between a trigger and the agent's reply
+func (r *StatsReporter) RecordResponseLatency(userID, guildID string, latency time.Duration) {
+	r.record(userID, guildID, func(s *guildStats) {
+		ms := latency.Milliseconds()
+		s.responseCount++
+		s.responseLatencyMsSum += ms
+		if ms > s.responseLatencyMsMax {
+			s.responseLatencyMsMax = ms
+		}
+	})
+}
+
+// RecordToolCall counts a tool call requested by HUMA
+func (r *StatsReporter) RecordToolCall(userID, guildID string) {
+	r.record(userID, guildID, func(s *guildStats) {
+		s.toolCalls++
+	})
+}
+
+// RecordSuppressedResponse counts a pending reply that was canceled or superseded
+func (r *StatsReporter) RecordSuppressedResponse(userID, guildID string) {
+	r.record(userID, guildID, func(s *guildStats) {
+		s.suppressedResponses++
+	})
+}
+
+// RecordHumaError counts a failed interaction with HUMA
+func (r *StatsReporter) RecordHumaError(userID, guildID string) {
+	r.record(userID, guildID, func(s *guildStats) {
+		s.humaErrors++
+	})
+}
+
+// RecordTypingTime adds simulated typing time
+func (r *StatsReporter) RecordTypingTime(userID, guildID string, d time.Duration) {
+	r.record(userID, guildID, func(s *guildStats) {
+		s.typingTimeMs += d.Milliseconds()
+	})
+}
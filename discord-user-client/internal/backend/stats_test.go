@@ -0,0 +1,174 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// statsServer records the batches posted to /api/discord/stats
+type statsServer struct {
+	mu      sync.Mutex
+	batches []types.StatsBatchPayload
+	status  int
+}
+
+func (s *statsServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/discord/stats" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-API-Key") != "test-key" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var batch types.StatsBatchPayload
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("Failed to decode batch: %v", err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status != 0 && s.status != http.StatusOK {
+			http.Error(w, "unavailable", s.status)
+			return
+		}
+		s.batches = append(s.batches, batch)
+		w.Write([]byte(`{"success":true}`))
+	}
+}
+
+func TestStatsReporter_AggregatesPerUserAndGuild(t *testing.T) {
+	srv := &statsServer{}
+	server := httptest.NewServer(srv.handler(t))
+	defer server.Close()
+
+	reporter := NewStatsReporter(NewClient(server.URL, "test-key"), time.Hour)
+
+	reporter.RecordMessageReceived("user1", "guild1")
+	reporter.RecordMessageReceived("user1", "guild1")
+	reporter.RecordMessageReceived("user1", "guild2")
+	reporter.RecordMessageSent("user1", "guild1")
+	reporter.RecordToolCall("user1", "guild1")
+	reporter.RecordSuppressedResponse("user1", "guild1")
+	reporter.RecordHumaError("user1", "guild1")
+	reporter.RecordResponseLatency("user1", "guild1", 1500*time.Millisecond)
+	reporter.RecordResponseLatency("user1", "guild1", 500*time.Millisecond)
+	reporter.RecordTypingTime("user1", "guild1", 2*time.Second)
+
+	// Events without user or guild are ignored
+	reporter.RecordMessageReceived("", "guild1")
+	reporter.RecordMessageReceived("user1", "")
+
+	if err := reporter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(srv.batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(srv.batches))
+	}
+
+	batch := srv.batches[0]
+	if batch.Version != types.StatsBatchVersion {
+		t.Errorf("Expected version %d, got %d", types.StatsBatchVersion, batch.Version)
+	}
+	if len(batch.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(batch.Entries))
+	}
+
+	var guild1 *types.GuildStatsEntry
+	for i := range batch.Entries {
+		if batch.Entries[i].GuildID == "guild1" {
+			guild1 = &batch.Entries[i]
+		}
+	}
+	if guild1 == nil {
+		t.Fatal("Expected an entry for guild1")
+	}
+
+	if guild1.MessagesReceived != 2 {
+		t.Errorf("Expected 2 messages received, got %d", guild1.MessagesReceived)
+	}
+	if guild1.MessagesSent != 1 || guild1.ToolCalls != 1 || guild1.SuppressedResponses != 1 || guild1.HumaErrors != 1 {
+		t.Errorf("Unexpected counters: %+v", guild1)
+	}
+	if guild1.ResponseCount != 2 || guild1.ResponseLatencyMsSum != 2000 || guild1.ResponseLatencyMsMax != 1500 {
+		t.Errorf("Unexpected latency stats: %+v", guild1)
+	}
+	if guild1.TypingTimeMs != 2000 {
+		t.Errorf("Expected 2000ms typing time, got %d", guild1.TypingTimeMs)
+	}
+	if guild1.LastMessageReceivedAt == "" || guild1.LastMessageSentAt == "" {
+		t.Error("Expected last message timestamps to be set")
+	}
+
+	// Nothing pending, so no new batch is sent
+	if err := reporter.Flush(); err != nil {
+		t.Fatalf("Second flush failed: %v", err)
+	}
+	if len(srv.batches) != 1 {
+		t.Errorf("Expected no new batch for empty flush, got %d batches", len(srv.batches))
+	}
+}
+
+func TestStatsReporter_KeepsCountersOnFailure(t *testing.T) {
+	srv := &statsServer{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(srv.handler(t))
+	defer server.Close()
+
+	reporter := NewStatsReporter(NewClient(server.URL, "test-key"), time.Hour)
+	reporter.RecordMessageReceived("user1", "guild1")
+
+	if err := reporter.Flush(); err == nil {
+		t.Fatal("Expected error when backend is unavailable")
+	}
+
+	// Backend recovers; the failed counters are merged with new ones
+	srv.mu.Lock()
+	srv.status = http.StatusOK
+	srv.mu.Unlock()
+	reporter.RecordMessageReceived("user1", "guild1")
+
+	if err := reporter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(srv.batches) != 1 || len(srv.batches[0].Entries) != 1 {
+		t.Fatalf("Expected 1 batch with 1 entry, got %+v", srv.batches)
+	}
+	if got := srv.batches[0].Entries[0].MessagesReceived; got != 2 {
+		t.Errorf("Expected 2 messages received after retry, got %d", got)
+	}
+}
+
+func TestStatsReporter_StopFlushes(t *testing.T) {
+	srv := &statsServer{}
+	server := httptest.NewServer(srv.handler(t))
+	defer server.Close()
+
+	reporter := NewStatsReporter(NewClient(server.URL, "test-key"), time.Hour)
+	reporter.Start()
+	reporter.RecordMessageSent("user1", "guild1")
+	reporter.Stop()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.batches) != 1 {
+		t.Fatalf("Expected Stop to flush 1 batch, got %d", len(srv.batches))
+	}
+}
+
+func TestStatsReporter_NilSafe(t *testing.T) {
+	var reporter *StatsReporter
+	reporter.RecordMessageReceived("user1", "guild1")
+	reporter.RecordToolCall("user1", "guild1")
+	if err := reporter.Flush(); err != nil {
+		t.Errorf("Expected nil reporter flush to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// guildAccess is a guild's channel, user and role lists. The zero value
+// restricts nothing.
+type guildAccess struct {
+	readChannels    []string
+	respondChannels []string
+	ignoredUsers    []string
+	ignoredRoles    []string
+	respondToRoles  []string
+}
+
+// newGuildAccess takes the lists from a guild's config
+func newGuildAccess(config types.ServerConfig) guildAccess {
+	return guildAccess{
+		readChannels:    config.ReadChannels,
+		respondChannels: config.RespondChannels,
+		ignoredUsers:    config.IgnoredUsers,
+		ignoredRoles:    config.IgnoredRoles,
+		respondToRoles:  config.RespondToRoles,
+	}
+}
+
+// canRead reports whether messages in a channel are read
+func (g guildAccess) canRead(channelID string) bool {
+	return len(g.readChannels) == 0 || slices.Contains(g.readChannels, channelID)
+}
+
+// canRespond reports whether the bot may respond in a channel. Only channels
+// it reads qualify.
+func (g guildAccess) canRespond(channelID string) bool {
+	return g.canRead(channelID) && (len(g.respondChannels) == 0 || slices.Contains(g.respondChannels, channelID))
+}
+
+// ignores reports whether a member's messages are not read at all
+func (g guildAccess) ignores(userID string, roles []string) bool {
+	if slices.Contains(g.ignoredUsers, userID) {
+		return true
+	}
+	for _, role := range roles {
+		if slices.Contains(g.ignoredRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondsTo reports whether a member with roles may get a response
+func (g guildAccess) respondsTo(roles []string) bool {
+	if len(g.respondToRoles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if slices.Contains(g.respondToRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// access returns a guild's lists. Guilds without a config, as in single-guild
+// mode, have none.
+func (dc *DiscordClient) access(guildID string) guildAccess {
+	if dc.configProvider == nil {
+		return guildAccess{}
+	}
+	config, ok := dc.configProvider.GetConfigForGuild(guildID)
+	if !ok {
+		return guildAccess{}
+	}
+	return newGuildAccess(config.ServerConfig)
+}
+
+// memberRoles returns the roles of a message's author in a guild. The gateway
+// usually includes the member; otherwise it is looked up in the state cache.
+func (dc *DiscordClient) memberRoles(guildID string, msg *discordgo.Message) []string {
+	if msg.Member != nil {
+		return msg.Member.Roles
+	}
+	if dc.session == nil || dc.session.State() == nil {
+		return nil
+	}
+	member, err := dc.session.State().Member(guildID, msg.Author.ID)
+	if err != nil {
+		return nil
+	}
+	return member.Roles
+}
+
+// readFilter returns which messages fetched from a guild's channel are read,
+// or nil if all of them are. Like live messages, those in channels the bot
+// doesn't read and from users it ignores are left out.
+func (dc *DiscordClient) readFilter(guildID, channelID string) func(*discordgo.Message) bool {
+	access := dc.access(guildID)
+	switch {
+	case !access.canRead(channelID):
+		return func(*discordgo.Message) bool { return false }
+	case len(access.ignoredUsers) == 0 && len(access.ignoredRoles) == 0:
+		return nil
+	}
+	return func(msg *discordgo.Message) bool {
+		return msg.Author == nil || !access.ignores(msg.Author.ID, dc.memberRoles(guildID, msg))
+	}
+}
+
+// historyFilter is readFilter for a channel whose guild isn't known, as with
+// messages fetched from Discord. The guild is looked up in the state cache.
+func (dc *DiscordClient) historyFilter(channelID string) func(*discordgo.Message) bool {
+	return dc.readFilter(dc.channelGuild(channelID), channelID)
+}
+
+// channelGuild returns the guild of a channel in the state cache, or "" if
+// the channel isn't cached
+func (dc *DiscordClient) channelGuild(channelID string) string {
+	if dc.session == nil || dc.session.State() == nil {
+		return ""
+	}
+	channel, err := dc.session.State().Channel(channelID)
+	if err != nil {
+		return ""
+	}
+	return channel.GuildID
+}
+
+// resolveChannelGuild returns the guild of a channel, asking Discord when the
+// channel isn't cached
+func (dc *DiscordClient) resolveChannelGuild(channelID string) (string, error) {
+	if guildID := dc.channelGuild(channelID); guildID != "" {
+		return guildID, nil
+	}
+	channel, err := dc.session.Channel(channelID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up channel %s: %w", channelID, err)
+	}
+	if channel.GuildID == "" {
+		return "", fmt.Errorf("channel %s is not in a guild", channelID)
+	}
+	return channel.GuildID, nil
+}
+
+// CanRead reports whether the bot reads a channel of a guild
+func (dc *DiscordClient) CanRead(guildID, channelID string) bool {
+	return dc.access(guildID).canRead(channelID)
+}
+
+// CanRespondIn reports whether the agent may send in a channel of a guild
+func (dc *DiscordClient) CanRespondIn(guildID, channelID string) bool {
+	return dc.access(guildID).canRespond(channelID)
+}
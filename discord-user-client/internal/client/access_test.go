@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+func TestGuildAccess(t *testing.T) {
+	open := guildAccess{}
+	if !open.canRead("c1") || !open.canRespond("c1") || open.ignores("u1", []string{"r1"}) || !open.respondsTo(nil) {
+		t.Error("Expected empty lists to restrict nothing")
+	}
+
+	access := newGuildAccess(types.ServerConfig{
+		ReadChannels:    []string{"c1", "c2"},
+		RespondChannels: []string{"c1", "c3"},
+		IgnoredUsers:    []string{"u9"},
+		IgnoredRoles:    []string{"muted"},
+		RespondToRoles:  []string{"member", "vip"},
+	})
+	tests := []struct {
+		channel          string
+		read, respond    bool
+		userID           string
+		roles            []string
+		ignored, replied bool
+	}{
+		{channel: "c1", read: true, respond: true, userID: "u1", roles: []string{"member"}, replied: true},
+		{channel: "c2", read: true, respond: false, userID: "u1", roles: []string{"vip", "muted"}, ignored: true, replied: true},
+		// Responding in a channel also needs it to be read
+		{channel: "c3", read: false, respond: false, userID: "u9", roles: []string{"member"}, ignored: true, replied: true},
+		{channel: "c4", read: false, respond: false, userID: "u2"},
+	}
+	for _, tt := range tests {
+		if got := access.canRead(tt.channel); got != tt.read {
+			t.Errorf("canRead(%s): expected %v, got %v", tt.channel, tt.read, got)
+		}
+		if got := access.canRespond(tt.channel); got != tt.respond {
+			t.Errorf("canRespond(%s): expected %v, got %v", tt.channel, tt.respond, got)
+		}
+		if got := access.ignores(tt.userID, tt.roles); got != tt.ignored {
+			t.Errorf("ignores(%s, %v): expected %v, got %v", tt.userID, tt.roles, tt.ignored, got)
+		}
+		if got := access.respondsTo(tt.roles); got != tt.replied {
+			t.Errorf("respondsTo(%v): expected %v, got %v", tt.roles, tt.replied, got)
+		}
+	}
+}
+
+func TestChannelAndRoleLists(t *testing.T) {
+	server := humatest.NewServer("")
+	defer server.Close()
+	received := make(chan humatest.Event, 10)
+	server.OnEvent = func(event humatest.Event) {
+		if event.Name != "" {
+			received <- event
+		}
+	}
+
+	manager := huma.NewManager("test-key")
+	manager.SetBaseURL(server.URL())
+	manager.SetTuning(huma.Tuning{TypingWPM: 10000, MaxTypingDelay: 10 * time.Millisecond})
+	defer manager.Shutdown(context.Background())
+
+	fake := newFakeDiscord()
+	fake.AddChannel("g1", "c2", "announcements")
+	fake.AddChannel("g1", "c3", "staff")
+	fake.AddGuild("g2", "Other")
+	fake.AddChannel("g2", "c9", "elsewhere")
+	fake.AddMember("g1", &discordgo.User{ID: "u1", Username: "alice"}, "member")
+	fake.AddMember("g1", &discordgo.User{ID: "u2", Username: "bob"}, "member", "muted")
+	fake.AddMember("g1", &discordgo.User{ID: "u3", Username: "carol"})
+	configs := staticConfigs{"g1": {
+		ServerConfig: types.ServerConfig{
+			GuildID:         "g1",
+			GuildName:       "Guild",
+			BotActive:       true,
+			ReadChannels:    []string{"c1", "c2"},
+			RespondChannels: []string{"c1"},
+			IgnoredRoles:    []string{"muted"},
+			RespondToRoles:  []string{"member"},
+		},
+		UserID: "owner",
+	}}
+	dc := NewMultiGuildDiscordClient(manager, nil, configs)
+	fake.SetEventHandler(dc.AttachSession(fake, "acct"))
+	dc.UpdateMonitoredGuilds([]string{"g1"})
+	fake.Connect()
+	defer dc.Disconnect()
+
+	posts := []struct{ channel, author, content string }{
+		{"c3", "u1", "in an unread channel"},
+		{"c1", "u2", "from a muted member"},
+		{"c1", "u3", "from a non-member"},
+		{"c2", "u1", "in a read-only channel"},
+		{"c1", "u1", "from a member"},
+	}
+	for _, post := range posts {
+		if _, err := fake.Post(post.channel, post.author, post.content); err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+	}
+
+	// Only the member's message in #general reaches HUMA, and it arrives after
+	// the others were handled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var event humatest.Event
+	select {
+	case event = <-received:
+	case <-ctx.Done():
+		t.Fatal("Expected the member's message to reach the agent")
+	}
+	if !strings.Contains(event.Description, "from a member") {
+		t.Errorf("Expected only the member's message forwarded, got %s", event.Description)
+	}
+	select {
+	case extra := <-received:
+		t.Errorf("Expected nothing else forwarded, got %s", extra.Description)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Read channels keep their history; unread channels and ignored members don't
+	var general []string
+	for _, msg := range dc.historyManager.GetMessages("c1") {
+		general = append(general, msg.Content)
+	}
+	if strings.Join(general, "|") != "from a non-member|from a member" {
+		t.Errorf("Unexpected #general history: %v", general)
+	}
+	if len(dc.historyManager.GetMessages("c2")) != 1 || len(dc.historyManager.GetMessages("c3")) != 0 {
+		t.Errorf("Expected history only for read channels")
+	}
+
+	// The agent is told which channels it reads and may respond in
+	monitored, _ := event.Context["monitoredChannels"].([]interface{})
+	canRespond := map[string]interface{}{}
+	for _, raw := range monitored {
+		if channel, ok := raw.(map[string]interface{}); ok {
+			canRespond[channel["id"].(string)] = channel["canRespond"]
+		}
+	}
+	if len(canRespond) != 2 || canRespond["c1"] != true || canRespond["c2"] != false {
+		t.Errorf("Expected #general and #announcements, responding only in #general, got %v", canRespond)
+	}
+
+	agentID, err := server.WaitForAgent(ctx)
+	if err != nil {
+		t.Fatalf("Expected an agent to be created: %v", err)
+	}
+	callID, err := server.CallTool(agentID, "send_message", map[string]interface{}{"channel_id": "c2", "message": "hello"})
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	if result, err := server.WaitToolResult(ctx, callID); err != nil || result.Success {
+		t.Errorf("Expected a send to a read-only channel refused, got %+v (%v)", result, err)
+	}
+	if len(fake.Sent()) != 0 {
+		t.Errorf("Expected nothing sent, got %+v", fake.Sent())
+	}
+	if _, err := dc.FetchChannelMessages("g1", "c3", 10); err == nil {
+		t.Error("Expected fetching an unread channel to fail")
+	}
+	if _, err := dc.FetchChannelMessages("g1", "c1", 10); err != nil {
+		t.Errorf("Expected fetching a read channel to work: %v", err)
+	}
+	// Another guild's channels don't get the lists of the agent's guild
+	if _, err := dc.FetchChannelMessages("g1", "c9", 10); err == nil {
+		t.Error("Expected fetching another guild's channel to fail")
+	}
+	if _, err := dc.FetchChannelMessages("g1", "unknown", 10); err == nil {
+		t.Error("Expected fetching an unknown channel to fail")
+	}
+
+	// Fetched history is only filtered in guilds with lists
+	if dc.readFilter("g2", "c9") != nil {
+		t.Error("Expected no filter for a guild without lists")
+	}
+
+	// Simulated messages go through the same filters as real ones
+	sims := []SimulatedMessage{
+		{ChannelID: "c3", AuthorID: "u1", AuthorName: "alice", Content: "simulated in an unread channel"},
+		{ChannelID: "c1", AuthorID: "u2", AuthorName: "bob", Content: "simulated from a muted member"},
+		{ChannelID: "c1", AuthorID: "u1", AuthorName: "alice", Content: "simulated from a member"},
+	}
+	for _, sim := range sims {
+		if _, err := dc.InjectMessage(ctx, "g1", sim); err != nil {
+			t.Fatalf("Failed to inject: %v", err)
+		}
+	}
+	select {
+	case event := <-received:
+		if !strings.Contains(event.Description, "simulated from a member") || strings.Contains(event.Description, "muted") {
+			t.Errorf("Expected only the member's simulated message forwarded, got %s", event.Description)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the member's simulated message to reach the agent")
+	}
+	select {
+	case extra := <-received:
+		t.Errorf("Expected nothing else forwarded, got %s", extra.Description)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+)
+
+// maxDebounceWindows caps how long a batch is held back: once it is this many
+// windows old it is sent, even if the channel never goes quiet
+const maxDebounceWindows = 4
+
+// batchedMessage is a message waiting to be sent to HUMA, with the context it
+// was received under and its classification
+type batchedMessage struct {
+	ctx   context.Context
+	msg   *discordgo.MessageCreate
+	class Classification
+}
+
+// debouncer holds back each channel's messages until the channel has been
+// quiet for a window, so a burst of lines reaches HUMA as one event. Add and
+// the flushes it schedules run on the guild's event pipeline, so batches are
+// taken and sent in order.
+type debouncer struct {
+	clock clock.Clock
+	// schedule runs a flush behind the events already queued for a guild
+	schedule func(guildID string, run func())
+	// send delivers a batch, oldest message first
+	send func(batch []batchedMessage)
+
+	mu       sync.Mutex
+	window   time.Duration
+	channels map[string]*channelBatch // channelID -> batch
+}
+
+// channelBatch is one channel's waiting messages
+type channelBatch struct {
+	messages   []batchedMessage
+	started    time.Time
+	generation uint64        // bumped by each message, so stale timers do nothing
+	stopTimer  chan struct{} // closed to stop the current timer
+}
+
+// newDebouncer creates a debouncer that sends every message at once until a
+// window is set
+func newDebouncer(c clock.Clock, schedule func(guildID string, run func()), send func([]batchedMessage)) *debouncer {
+	if c == nil {
+		c = clock.Real
+	}
+	return &debouncer{
+		clock:    c,
+		schedule: schedule,
+		send:     send,
+		channels: make(map[string]*channelBatch),
+	}
+}
+
+// SetWindow sets how long a channel must be quiet before its batch is sent.
+// Zero sends every message at once.
+func (d *debouncer) SetWindow(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = window
+}
+
+// Add puts msg in its channel's batch. The batch is sent once the channel
+// has been quiet for the window, or right away if msg is addressed to the
+// account.
+func (d *debouncer) Add(ctx context.Context, msg *discordgo.MessageCreate, class Classification) {
+	d.mu.Lock()
+	channelID := msg.ChannelID
+	now := d.clock.Now()
+	b, ok := d.channels[channelID]
+	if !ok {
+		b = &channelBatch{started: now}
+		d.channels[channelID] = b
+	}
+	b.messages = append(b.messages, batchedMessage{ctx: ctx, msg: msg, class: class})
+	b.generation++
+	b.stop()
+
+	wait := d.window
+	if remaining := b.started.Add(maxDebounceWindows * d.window).Sub(now); remaining < wait {
+		wait = remaining
+	}
+	if class.Direct() || wait <= 0 {
+		delete(d.channels, channelID)
+		d.mu.Unlock()
+		d.send(b.messages)
+		return
+	}
+
+	generation := b.generation
+	stop := make(chan struct{})
+	b.stopTimer = stop
+	timer := d.clock.NewTimer(wait)
+	d.mu.Unlock()
+
+	go func() {
+		select {
+		case <-timer.C():
+			d.schedule(msg.GuildID, func() { d.flush(channelID, generation) })
+		case <-stop:
+			timer.Stop()
+		}
+	}()
+}
+
+// flush sends a channel's batch if no message has joined it since generation
+func (d *debouncer) flush(channelID string, generation uint64) {
+	d.mu.Lock()
+	b, ok := d.channels[channelID]
+	if !ok || b.generation != generation {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.channels, channelID)
+	d.mu.Unlock()
+	d.send(b.messages)
+}
+
+// Discard drops every waiting batch and returns how many messages were in them
+func (d *debouncer) Discard() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	discarded := 0
+	for channelID, b := range d.channels {
+		discarded += len(b.messages)
+		b.stop()
+		delete(d.channels, channelID)
+	}
+	return discarded
+}
+
+// stop stops the batch's timer, if it has one. Callers hold d.mu.
+func (b *channelBatch) stop() {
+	if b.stopTimer != nil {
+		close(b.stopTimer)
+		b.stopTimer = nil
+	}
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+)
+
+// batchRecorder collects the batches a debouncer sends, as message contents
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (r *batchRecorder) send(batch []batchedMessage) {
+	var contents []string
+	for _, b := range batch {
+		contents = append(contents, b.msg.Content)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, contents)
+}
+
+func (r *batchRecorder) sent() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string(nil), r.batches...)
+}
+
+func newTestDebouncer(window time.Duration) (*debouncer, *clock.Virtual, *batchRecorder) {
+	c := clock.NewVirtual(time.Unix(0, 0))
+	recorder := &batchRecorder{}
+	d := newDebouncer(c, func(guildID string, run func()) { run() }, recorder.send)
+	d.SetWindow(window)
+	return d, c, recorder
+}
+
+func channelMessage(channelID, content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: "g1", ChannelID: channelID, Content: content}}
+}
+
+func TestDebouncer_BatchesUntilQuiet(t *testing.T) {
+	d, c, recorder := newTestDebouncer(time.Second)
+	ctx := context.Background()
+
+	d.Add(ctx, channelMessage("c1", "one"), Classification{})
+	c.Advance(500 * time.Millisecond)
+	d.Add(ctx, channelMessage("c1", "two"), Classification{})
+	d.Add(ctx, channelMessage("c2", "elsewhere"), Classification{})
+	c.Advance(700 * time.Millisecond)
+	if sent := recorder.sent(); len(sent) != 0 {
+		t.Fatalf("Expected nothing sent while c1 is busy, got %v", sent)
+	}
+
+	// c1's window ends a second after its last message, c2's a second after its only one
+	c.Advance(300 * time.Millisecond)
+	waitFor(t, "both batches", func() bool { return len(recorder.sent()) == 2 })
+	// The channels' timers fire together, so their batches may go out in either order
+	batches := map[string]bool{}
+	for _, batch := range recorder.sent() {
+		batches[strings.Join(batch, ",")] = true
+	}
+	if !batches["one,two"] || !batches["elsewhere"] {
+		t.Errorf("Expected batches [one two] and [elsewhere], got %v", recorder.sent())
+	}
+	if c.Pending() != 0 {
+		t.Errorf("Expected superseded timers to be stopped, %d pending", c.Pending())
+	}
+}
+
+func TestDebouncer_ImmediateFlushesBatch(t *testing.T) {
+	d, c, recorder := newTestDebouncer(time.Second)
+	ctx := context.Background()
+
+	d.Add(ctx, channelMessage("c1", "one"), Classification{})
+	d.Add(ctx, channelMessage("c1", "<@bot> two"), Classification{MentionsYou: true})
+	sent := recorder.sent()
+	if len(sent) != 1 || strings.Join(sent[0], ",") != "one,<@bot> two" {
+		t.Fatalf("Expected the mention to flush the batch at once, got %v", sent)
+	}
+
+	c.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if sent := recorder.sent(); len(sent) != 1 {
+		t.Errorf("Expected the flushed batch's timer to do nothing, got %v", sent)
+	}
+}
+
+func TestDebouncer_NoWindowSendsAtOnce(t *testing.T) {
+	d, _, recorder := newTestDebouncer(0)
+
+	d.Add(context.Background(), channelMessage("c1", "one"), Classification{})
+	d.Add(context.Background(), channelMessage("c1", "two"), Classification{})
+	if sent := recorder.sent(); len(sent) != 2 {
+		t.Errorf("Expected each message sent on its own, got %v", sent)
+	}
+}
+
+func TestDebouncer_CapsBatchAge(t *testing.T) {
+	d, c, recorder := newTestDebouncer(time.Second)
+
+	// A message every 900ms never leaves a second of quiet
+	for i := 0; i < 5; i++ {
+		if i > 0 {
+			c.Advance(900 * time.Millisecond)
+		}
+		d.Add(context.Background(), channelMessage("c1", "chatter"), Classification{})
+	}
+	if sent := recorder.sent(); len(sent) != 0 {
+		t.Fatalf("Expected nothing sent yet, got %v", sent)
+	}
+
+	// The batch started at 0s, so it goes out at 4s rather than 4.6s
+	c.Advance(400 * time.Millisecond)
+	waitFor(t, "a capped batch", func() bool { return len(recorder.sent()) > 0 })
+	if first := recorder.sent()[0]; len(first) != 5 {
+		t.Errorf("Expected the batch sent %d windows after it started, with 5 messages, got %d", maxDebounceWindows, len(first))
+	}
+}
+
+func TestDebouncer_Discard(t *testing.T) {
+	d, c, recorder := newTestDebouncer(time.Second)
+
+	d.Add(context.Background(), channelMessage("c1", "one"), Classification{})
+	d.Add(context.Background(), channelMessage("c2", "two"), Classification{})
+	if got := d.Discard(); got != 2 {
+		t.Errorf("Expected 2 discarded messages, got %d", got)
+	}
+	c.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if sent := recorder.sent(); len(sent) != 0 {
+		t.Errorf("Expected nothing sent after discarding, got %v", sent)
+	}
+}
+
+func TestDebounce_HUMAEvents(t *testing.T) {
+	server := humatest.NewServer("")
+	defer server.Close()
+	received := make(chan humatest.Event, 10)
+	server.OnEvent = func(event humatest.Event) {
+		if event.Name != "" {
+			received <- event
+		}
+	}
+
+	manager := huma.NewManager("test-key")
+	manager.SetBaseURL(server.URL())
+	defer manager.Shutdown(context.Background())
+
+	client, fake := attachFake(t, manager)
+	client.SetDebounceWindow(200 * time.Millisecond)
+	next := func() humatest.Event {
+		t.Helper()
+		select {
+		case event := <-received:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected an event to reach the agent")
+			return humatest.Event{}
+		}
+	}
+
+	// A burst arrives as one event
+	for _, line := range []string{"so I tried", "resetting my password", "and it failed"} {
+		if _, err := fake.Post("c1", "u1", line); err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+	}
+	event := next()
+	if event.Name != "new-messages" || !strings.Contains(event.Description, "3 new messages") || !strings.Contains(event.Description, `alice: "and it failed"`) {
+		t.Errorf("Expected one new-messages event for the burst, got %s: %s", event.Name, event.Description)
+	}
+	if event.Context["mentionsYou"] != false || event.Context["priority"] != "ambient" {
+		t.Errorf("Expected the burst classified as ambient, got mentionsYou=%v priority=%v", event.Context["mentionsYou"], event.Context["priority"])
+	}
+
+	// A mention goes out without waiting
+	start := time.Now()
+	if _, err := fake.Post("c1", "u1", "<@bot> any ideas?"); err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	event = next()
+	if event.Name != "new-message" || !strings.Contains(event.Description, "any ideas?") {
+		t.Errorf("Expected a new-message event for the mention, got %s: %s", event.Name, event.Description)
+	}
+	if event.Context["mentionsYou"] != true || event.Context["isReplyToYou"] != false || event.Context["priority"] != "mention" {
+		t.Errorf("Expected the mention classified in the context, got mentionsYou=%v isReplyToYou=%v priority=%v", event.Context["mentionsYou"], event.Context["isReplyToYou"], event.Context["priority"])
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("Expected the mention to skip the window, took %s", elapsed)
+	}
+}
@@ -2,15 +2,25 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
@@ -19,10 +29,14 @@ type ConfigProvider interface {
 	GetConfigForGuild(guildID string) (GuildConfigWithUser, bool)
 }
 
+// logger is used for gateway, message processing and send events
+var logger = logging.For("discord")
+
 // DiscordClient manages Discord connection and message processing
 type DiscordClient struct {
-	session           *discordgo.Session
+	session           Session
 	token             string
+	fingerprint       string
 	userID            string
 	userEmail         string
 	readyHandled      bool
@@ -36,37 +50,105 @@ type DiscordClient struct {
 	botUsername       string
 	humaManager       *huma.Manager
 	backendClient     *backend.Client
+	stats             *backend.StatsReporter
+	pauses            *pause.Store
+	recorder          *recording.Recorder
+
+	// Gateway events are processed in order per guild by a bounded pipeline,
+	// replaced with each new session
+	dispatch DispatchConfig
+	pipeline *dispatcher
+
+	// Bursts of messages in a channel are batched before they reach HUMA
+	batches *debouncer
+
+	// Per-user rate limits on what reaches HUMA
+	limits *userLimiter
+
+	// Messages the agent and operators sent, which are never owner commands
+	agentSends agentSends
 
 	// Multi-guild support
 	monitoredGuilds map[string]bool // guildID -> true
 	configProvider  ConfigProvider
 	mu              sync.RWMutex
+
+	// Set at shutdown; gateway events are dropped from then on
+	stopped bool
 }
 
 // NewDiscordClient creates a new Discord client (single-guild mode for backward compatibility)
 func NewDiscordClient(humaManager *huma.Manager, backendClient *backend.Client) *DiscordClient {
-	return &DiscordClient{
+	dc := &DiscordClient{
 		historyManager:  history.NewMessageHistoryManager(),
 		humaManager:     humaManager,
 		backendClient:   backendClient,
 		monitoredGuilds: make(map[string]bool),
+		dispatch:        DefaultDispatchConfig(),
 	}
+	dc.batches = newDebouncer(agentClock(humaManager), dc.schedule, dc.notifyHUMA)
+	dc.limits = newUserLimiter(agentClock(humaManager))
+	dc.historyManager.SetFilter(dc.historyFilter)
+	return dc
 }
 
 // NewMultiGuildDiscordClient creates a new Discord client with multi-guild support
 func NewMultiGuildDiscordClient(humaManager *huma.Manager, backendClient *backend.Client, configProvider ConfigProvider) *DiscordClient {
-	return &DiscordClient{
+	dc := &DiscordClient{
 		historyManager:  history.NewMessageHistoryManager(),
 		humaManager:     humaManager,
 		backendClient:   backendClient,
 		monitoredGuilds: make(map[string]bool),
 		configProvider:  configProvider,
+		dispatch:        DefaultDispatchConfig(),
 	}
+	dc.batches = newDebouncer(agentClock(humaManager), dc.schedule, dc.notifyHUMA)
+	dc.limits = newUserLimiter(agentClock(humaManager))
+	dc.historyManager.SetFilter(dc.historyFilter)
+	return dc
+}
+
+// agentClock returns the clock the manager's agents run on, so batching
+// follows the virtual clock in replays
+func agentClock(humaManager *huma.Manager) clock.Clock {
+	if humaManager == nil {
+		return clock.Real
+	}
+	return humaManager.Clock()
+}
+
+// SetStatsReporter sets the stats reporter used to count inbound messages and HUMA errors
+func (dc *DiscordClient) SetStatsReporter(stats *backend.StatsReporter) {
+	dc.stats = stats
+}
+
+// SetRecorder records the gateway events and REST calls of sessions opened
+// from now on
+func (dc *DiscordClient) SetRecorder(recorder *recording.Recorder) {
+	dc.recorder = recorder
+}
+
+// SetHistorySize sets how many messages are kept per channel, and fetched when
+// a channel is first seen
+func (dc *DiscordClient) SetHistorySize(size int) {
+	dc.historyManager.SetMaxMessages(size)
+}
+
+// SetDispatchConfig sizes the gateway event pipeline of sessions opened from now on
+func (dc *DiscordClient) SetDispatchConfig(config DispatchConfig) {
+	dc.dispatch = config
+}
+
+// SetDebounceWindow sets how long a channel must be quiet before its new
+// messages are sent to HUMA, together. Zero sends each message at once.
+func (dc *DiscordClient) SetDebounceWindow(window time.Duration) {
+	dc.batches.SetWindow(window)
 }
 
 // Connect establishes a connection to Discord
 func (dc *DiscordClient) Connect(config types.UserConfig) error {
 	dc.token = config.Token
+	dc.fingerprint = TokenFingerprint(config.Token)
 	dc.userID = config.UserID
 	dc.userEmail = config.Email
 	dc.selectedGuildID = config.SelectedGuildID
@@ -79,9 +161,9 @@ func (dc *DiscordClient) Connect(config types.UserConfig) error {
 
 	// Log configuration
 	if dc.selectedGuildID != "" {
-		log.Printf("User: %s, Monitoring all channels in server: %s", dc.userEmail, dc.selectedGuildName)
+		logger.Info("Monitoring all channels in server", "user", dc.userEmail, "guild_name", dc.selectedGuildName)
 	} else {
-		log.Printf("User: %s, No server selected", dc.userEmail)
+		logger.Info("No server selected", "user", dc.userEmail)
 	}
 
 	// Create Discord session
@@ -93,64 +175,54 @@ func (dc *DiscordClient) Connect(config types.UserConfig) error {
 	// Enable state tracking
 	session.StateEnabled = true
 
-	// Set EventHandler
+	// Set EventHandler. Ready is handled inline so no message overtakes it.
+	pipeline := dc.startPipeline()
 	session.EventHandler = func(rawEvt any) {
-		go func() {
-			switch evt := rawEvt.(type) {
-			case *discordgo.Ready:
-				if !dc.readyHandled {
-					dc.readyHandled = true
-					dc.botUsername = evt.User.Username
-
-					// Configure HUMA manager with Discord client as sender
-					if dc.humaManager != nil {
-						dc.humaManager.SetMessageSender(dc)
-						dc.humaManager.SetHistoryManager(dc.historyManager)
-						dc.humaManager.SetConfig(dc.personality, dc.rules, dc.information)
-						dc.humaManager.SetWebsites(dc.websites)
-					}
-
-					log.Printf("✓ Connected as: %s (for user: %s)", evt.User.Username, dc.userEmail)
-					log.Printf("✓ Bot username: %s", dc.botUsername)
-					if dc.selectedGuildID != "" {
-						log.Printf("✓ Monitoring all channels in server: %s (%s)", dc.selectedGuildName, dc.selectedGuildID)
-					} else {
-						log.Printf("⚠ No server selected - not monitoring any channels")
-					}
-					log.Printf("✓ HUMA integration enabled")
-					log.Println("✓ Listening for messages...")
-				}
-			case *discordgo.MessageCreate:
-				// Ignore our own messages
-				if evt.Author.ID == session.State.User.ID {
-					return
+		dc.recorder.DiscordEvent(dc.fingerprint, rawEvt)
+		metrics.DiscordEvents.Inc(eventTypeName(rawEvt))
+		switch evt := rawEvt.(type) {
+		case *discordgo.Ready:
+			if dc.markReady(evt.User.Username) {
+
+				// Configure HUMA manager with Discord client as sender
+				if dc.humaManager != nil {
+					dc.humaManager.SetMessageSender(dc)
+					dc.humaManager.SetHistoryManager(dc.historyManager)
+					dc.humaManager.SetConfig(dc.personality, dc.rules, dc.information)
+					dc.humaManager.SetWebsites(dc.websites)
 				}
 
-				// Check if message is from the selected guild
-				if dc.isFromSelectedGuild(evt.GuildID) {
-					dc.processMessageWithHUMA(evt)
+				logger.Info("Connected, listening for messages", "username", evt.User.Username, "user", dc.userEmail)
+				if dc.selectedGuildID != "" {
+					logger.Info("Monitoring all channels in server", logging.KeyGuildID, dc.selectedGuildID, "guild_name", dc.selectedGuildName)
+				} else {
+					logger.Warn("No server selected - not monitoring any channels")
 				}
 			}
-		}()
+		case *discordgo.MessageCreate:
+			dc.dispatchMessage(context.Background(), pipeline, session.State.User.ID, evt)
+		}
 	}
 
 	// Load main page (required for user tokens)
 	if session.IsUser {
-		log.Println("Loading Discord...")
+		logger.Debug("Loading Discord main page")
 		err = session.LoadMainPage(context.Background())
 		if err != nil {
-			log.Printf("Warning: Failed to load main page: %v", err)
+			logger.Warn("Failed to load main page", "error", err)
 		}
 	}
 
+	dc.recordCalls(session)
+
 	// Connect
-	log.Println("Connecting to Discord...")
+	logger.Info("Connecting to Discord")
 	err = session.Open()
 	if err != nil {
 		return fmt.Errorf("error connecting to Discord: %w", err)
 	}
 
-	dc.session = session
+	dc.session = NewGatewaySession(session)
 	return nil
 }
 
@@ -158,6 +230,7 @@ func (dc *DiscordClient) Connect(config types.UserConfig) error {
 // Used for multi-guild mode where config is fetched per-guild
 func (dc *DiscordClient) ConnectWithToken(token string) error {
 	dc.token = token
+	dc.fingerprint = TokenFingerprint(token)
 	dc.readyHandled = false
 
 	// Create Discord session
@@ -170,59 +243,154 @@ func (dc *DiscordClient) ConnectWithToken(token string) error {
 	session.StateEnabled = true
 
 	// Set EventHandler
-	session.EventHandler = func(rawEvt any) {
-		go func() {
-			switch evt := rawEvt.(type) {
-			case *discordgo.Ready:
-				if !dc.readyHandled {
-					dc.readyHandled = true
-					dc.botUsername = evt.User.Username
-
-					// Configure HUMA manager with Discord client as sender
-					if dc.humaManager != nil {
-						dc.humaManager.SetMessageSender(dc)
-						dc.humaManager.SetHistoryManager(dc.historyManager)
-					}
-
-					log.Printf("✓ Connected as: %s", evt.User.Username)
-					log.Printf("✓ Bot username: %s", dc.botUsername)
-					log.Printf("✓ HUMA integration enabled (multi-guild mode)")
-					log.Println("✓ Listening for messages...")
-				}
-			case *discordgo.MessageCreate:
-				// Ignore our own messages
-				if evt.Author.ID == session.State.User.ID {
-					return
-				}
-
-				// Check if message is from a monitored guild
-				if dc.isFromSelectedGuild(evt.GuildID) {
-					dc.processMessageWithHUMA(evt)
-				}
-			}
-		}()
-	}
+	gateway := NewGatewaySession(session)
+	session.EventHandler = dc.multiGuildEventHandler(gateway)
 
 	// Load main page (required for user tokens)
 	if session.IsUser {
-		log.Println("Loading Discord...")
+		logger.Debug("Loading Discord main page")
 		err = session.LoadMainPage(context.Background())
 		if err != nil {
-			log.Printf("Warning: Failed to load main page: %v", err)
+			logger.Warn("Failed to load main page", "error", err)
 		}
 	}
 
+	dc.recordCalls(session)
+
 	// Connect
-	log.Println("Connecting to Discord...")
+	logger.Info("Connecting to Discord")
 	err = session.Open()
 	if err != nil {
 		return fmt.Errorf("error connecting to Discord: %w", err)
 	}
 
-	dc.session = session
+	dc.session = gateway
 	return nil
 }
 
+// AttachSession makes the client use a session it did not open, in
+// multi-guild mode, and returns the handler the caller dispatches the
+// session's events to. Replay and discordtest use it to drive the client
+// without a gateway connection.
+func (dc *DiscordClient) AttachSession(session Session, fingerprint string) func(any) {
+	dc.fingerprint = fingerprint
+	dc.readyHandled = false
+	dc.session = session
+	return dc.multiGuildEventHandler(session)
+}
+
+// multiGuildEventHandler returns the gateway event handler for multi-guild
+// mode. Ready is handled inline so no message overtakes it.
+func (dc *DiscordClient) multiGuildEventHandler(session Session) func(any) {
+	pipeline := dc.startPipeline()
+	return func(rawEvt any) {
+		dc.recorder.DiscordEvent(dc.fingerprint, rawEvt)
+		metrics.DiscordEvents.Inc(eventTypeName(rawEvt))
+		switch evt := rawEvt.(type) {
+		case *discordgo.Ready:
+			if dc.markReady(evt.User.Username) {
+
+				// Configure HUMA manager with Discord client as sender
+				if dc.humaManager != nil {
+					dc.humaManager.SetMessageSender(dc)
+					dc.humaManager.SetHistoryManager(dc.historyManager)
+				}
+
+				logger.Info("Connected, listening for messages (multi-guild mode)", "account", dc.fingerprint, "username", evt.User.Username)
+			}
+		case *discordgo.MessageCreate:
+			dc.dispatchMessage(context.Background(), pipeline, session.State().User.ID, evt)
+		}
+	}
+}
+
+// startPipeline replaces the event pipeline for a new session, discarding
+// events still waiting in the old one
+func (dc *DiscordClient) startPipeline() *dispatcher {
+	pipeline := newDispatcher(dc.dispatch)
+	dc.mu.Lock()
+	previous := dc.pipeline
+	dc.pipeline = pipeline
+	dc.mu.Unlock()
+	previous.Close()
+	return pipeline
+}
+
+// schedule runs a function on a guild's event pipeline, behind the events
+// already queued there. It is queued at the highest priority, so the batches
+// it sends are never dropped for room. Without a pipeline it runs right away.
+func (dc *DiscordClient) schedule(guildID string, run func()) {
+	dc.mu.RLock()
+	pipeline := dc.pipeline
+	dc.mu.RUnlock()
+	if pipeline == nil {
+		run()
+		return
+	}
+	pipeline.Submit(guildID, PriorityMention, run)
+}
+
+// dispatchMessage classifies a message and queues it behind the others from
+// its guild. Messages the client would ignore are dropped here, before they
+// take up room. ctx carries the trace the message is handled under.
+func (dc *DiscordClient) dispatchMessage(ctx context.Context, pipeline *dispatcher, selfID string, msg *discordgo.MessageCreate) {
+	if dc.isStopped() || !dc.isFromSelectedGuild(msg.GuildID) {
+		return
+	}
+	// Channels the bot doesn't read and users it ignores never reach history
+	if msg.Author.ID != selfID {
+		access := dc.access(msg.GuildID)
+		if !access.canRead(msg.ChannelID) || access.ignores(msg.Author.ID, dc.memberRoles(msg.GuildID, msg.Message)) {
+			return
+		}
+	}
+	// The owner's commands always get through
+	class := Classification{MentionsYou: msg.Author.ID == selfID}
+	if msg.Author.ID != selfID {
+		class = dc.classify(msg)
+	}
+	pipeline.Submit(msg.GuildID, class.Priority(), func() {
+		if dc.isStopped() {
+			return
+		}
+
+		// Ignore our own messages, except pause/resume commands from the owner
+		if msg.Author.ID == selfID {
+			dc.handleOwnerCommand(msg)
+			return
+		}
+		dc.processMessageWithHUMA(ctx, msg, class)
+	})
+}
+
+// recordCalls routes the session's REST calls through the recorder. The main
+// page load happens before, so the recording doesn't carry Discord's HTML.
+func (dc *DiscordClient) recordCalls(session *discordgo.Session) {
+	if dc.recorder == nil {
+		return
+	}
+	session.Client.Transport = &recording.Transport{
+		Base:     session.Client.Transport,
+		Recorder: dc.recorder,
+		Account:  dc.fingerprint,
+	}
+}
+
+// StopAcceptingEvents makes the client ignore new messages from the gateway. The
+// session stays open so messages already being typed can still be sent.
+func (dc *DiscordClient) StopAcceptingEvents() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.stopped = true
+}
+
+// isStopped reports whether the client has stopped accepting events
+func (dc *DiscordClient) isStopped() bool {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	return dc.stopped
+}
+
 // Disconnect closes the Discord connection
 func (dc *DiscordClient) Disconnect() {
 	// Disconnect all HUMA agents
@@ -230,8 +398,19 @@ func (dc *DiscordClient) Disconnect() {
 		dc.humaManager.DisconnectAll()
 	}
 
+	dc.mu.Lock()
+	pipeline := dc.pipeline
+	dc.pipeline = nil
+	dc.mu.Unlock()
+	if discarded := pipeline.Close(); discarded > 0 {
+		logger.Warn("Discarded queued events", "account", dc.fingerprint, "count", discarded)
+	}
+	if discarded := dc.batches.Discard(); discarded > 0 {
+		logger.Warn("Discarded batched messages", "account", dc.fingerprint, "count", discarded)
+	}
+
 	if dc.session != nil {
-		log.Printf("Disconnecting Discord session for user: %s", dc.userEmail)
+		logger.Info("Disconnecting Discord session", "account", dc.fingerprint, "username", dc.GetBotUsername())
 		dc.session.Close()
 		dc.session = nil
 	}
@@ -253,9 +432,9 @@ func (dc *DiscordClient) UpdateConfig(config types.UserConfig) {
 	}
 
 	if dc.selectedGuildID != "" {
-		log.Printf("Updated: Monitoring all channels in server: %s", dc.selectedGuildName)
+		logger.Info("Updated: monitoring all channels in server", "guild_name", dc.selectedGuildName)
 	} else {
-		log.Printf("Updated: No server selected")
+		logger.Info("Updated: no server selected")
 	}
 }
 
@@ -304,107 +483,246 @@ func (dc *DiscordClient) UpdateMonitoredGuilds(guildIDs []string) {
 	}
 
 	if len(guildIDs) > 0 {
-		log.Printf("[Discord] Now monitoring %d guild(s)", len(guildIDs))
+		logger.Info("Now monitoring guilds", "count", len(guildIDs))
 	} else {
-		log.Printf("[Discord] No guilds to monitor")
+		logger.Info("No guilds to monitor")
 	}
 }
 
-// processMessageWithHUMA processes a Discord message using HUMA
-func (dc *DiscordClient) processMessageWithHUMA(msg *discordgo.MessageCreate) {
-	channelID := msg.ChannelID
-	guildID := msg.GuildID
+// GetMonitoredGuildIDs returns the guilds this client monitors, sorted
+func (dc *DiscordClient) GetMonitoredGuildIDs() []string {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
 
-	// Get channel info
-	channel, err := dc.session.Channel(channelID)
-	channelName := channelID
-	if err == nil && channel != nil {
-		channelName = channel.Name
+	guildIDs := make([]string, 0, len(dc.monitoredGuilds))
+	for guildID := range dc.monitoredGuilds {
+		guildIDs = append(guildIDs, guildID)
 	}
+	sort.Strings(guildIDs)
+	return guildIDs
+}
+
+// guildSettings is the agent configuration that applies to one guild
+type guildSettings struct {
+	guildName   string
+	personality string
+	rules       string
+	information string
+	websites    []types.WebsiteData
+	userID      string
+	userLimits  types.UserLimits
+}
 
-	// Get guild-specific config if available (multi-guild mode)
-	var personality, rules, information string
-	var websites []types.WebsiteData
-	var guildName string
-	var userID string
+// guildSettings resolves the config for a guild, falling back to the
+// single-guild values. Returns false if the bot should not act in the guild.
+func (dc *DiscordClient) guildSettings(guildID string) (guildSettings, bool) {
+	settings := guildSettings{userLimits: DefaultUserLimits()}
 
 	if dc.configProvider != nil {
 		config, exists := dc.configProvider.GetConfigForGuild(guildID)
 		if !exists {
 			// Guild not in our config, skip
-			return
+			return settings, false
 		}
 		if !config.BotActive {
 			// Bot is not active for this guild, skip
-			return
+			return settings, false
+		}
+		settings = guildSettings{
+			guildName:   config.GuildName,
+			personality: config.Personality,
+			rules:       config.Rules,
+			information: config.Information,
+			websites:    config.Websites,
+			userID:      config.UserID,
+			userLimits:  DefaultUserLimits(),
+		}
+		if config.UserLimits != nil {
+			settings.userLimits = *config.UserLimits
 		}
-		guildName = config.GuildName
-		personality = config.Personality
-		rules = config.Rules
-		information = config.Information
-		websites = config.Websites
-		userID = config.UserID
 	}
 
 	// Fallback to stored values (single-guild mode)
-	if guildName == "" {
-		guildName = dc.selectedGuildName
+	if settings.guildName == "" {
+		settings.guildName = dc.selectedGuildName
 	}
-	if guildName == "" {
+	if settings.guildName == "" {
 		guild, err := dc.session.Guild(guildID)
 		if err == nil && guild != nil {
-			guildName = guild.Name
+			settings.guildName = guild.Name
 		} else {
-			guildName = guildID
+			settings.guildName = guildID
 		}
 	}
-	if personality == "" {
-		personality = dc.personality
+	if settings.personality == "" {
+		settings.personality = dc.personality
 	}
-	if rules == "" {
-		rules = dc.rules
+	if settings.rules == "" {
+		settings.rules = dc.rules
 	}
-	if information == "" {
-		information = dc.information
+	if settings.information == "" {
+		settings.information = dc.information
 	}
-	if len(websites) == 0 {
-		websites = dc.websites
+	if len(settings.websites) == 0 {
+		settings.websites = dc.websites
 	}
-	if userID == "" {
-		userID = dc.userID
+	if settings.userID == "" {
+		settings.userID = dc.userID
 	}
+	return settings, true
+}
 
-	log.Printf("[HUMA] Message from #%s in %s - %s: %s", channelName, guildName, msg.Author.Username, msg.Content)
+// channelName returns a channel's name, or its ID if it can't be looked up
+func (dc *DiscordClient) channelName(channelID string) string {
+	channel, err := dc.session.Channel(channelID)
+	if err == nil && channel != nil {
+		return channel.Name
+	}
+	return channelID
+}
 
-	// Report message received to backend
-	if dc.backendClient != nil && userID != "" && guildID != "" {
-		go func() {
-			if err := dc.backendClient.ReportStats(userID, guildID, "message_received"); err != nil {
-				log.Printf("[Stats] Failed to report message_received: %v", err)
-			}
-		}()
+// processMessageWithHUMA records a Discord message in history and passes it
+// on to HUMA with its classification, batched with the channel's other new
+// messages. Spans are recorded under the trace in ctx, if any.
+func (dc *DiscordClient) processMessageWithHUMA(ctx context.Context, msg *discordgo.MessageCreate, class Classification) {
+	channelID := msg.ChannelID
+	guildID := msg.GuildID
+	channelName := dc.channelName(channelID)
+
+	settings, ok := dc.guildSettings(guildID)
+	if !ok {
+		return
 	}
+	guildName := settings.guildName
+	userID := settings.userID
+
+	// Root span for everything this message causes (HUMA events, tool calls, sends)
+	ctx, span := tracing.Start(ctx, "discord.message")
+	defer span.End()
+	span.SetAttribute("guild.id", guildID)
+	span.SetAttribute("channel.id", channelID)
+	span.SetAttribute("message.id", msg.ID)
+	span.SetAttribute("message.priority", class.Priority().String())
+
+	msgLogger := logger.With(logging.KeyGuildID, guildID, logging.KeyChannelID, channelID)
+	msgLogger.InfoContext(ctx, "Message received",
+		"channel_name", channelName,
+		"guild_name", guildName,
+		"author", msg.Author.Username,
+		"content", msg.Content,
+		"priority", class.Priority().String(),
+	)
+	metrics.MessagesProcessed.Inc(guildID)
+	events.Publish(events.Event{
+		Type:        events.TypeMessageReceived,
+		GuildID:     guildID,
+		ChannelID:   channelID,
+		ChannelName: channelName,
+		TraceID:     span.TraceID(),
+		Author:      msg.Author.Username,
+		Content:     msg.Content,
+		Priority:    class.Priority().String(),
+	})
+
+	// Count message received (flushed to backend in batches)
+	dc.stats.RecordMessageReceived(userID, guildID)
 
 	// Initialize channel history if needed
 	if !dc.historyManager.IsChannelInitialized(channelID) {
-		log.Printf("[HISTORY] Initializing channel %s", channelID)
-		if err := dc.historyManager.InitializeChannel(dc.session, channelID, 50); err != nil {
-			log.Printf("[HISTORY] Warning: Failed to initialize channel: %v", err)
+		msgLogger.DebugContext(ctx, "Initializing channel history")
+		_, historySpan := tracing.Start(ctx, "history.initialize")
+		if err := dc.historyManager.InitializeChannel(dc.session, channelID, dc.historyManager.MaxMessages()); err != nil {
+			msgLogger.WarnContext(ctx, "Failed to initialize channel history", "error", err)
+			historySpan.RecordError(err)
 		}
+		historySpan.End()
 	}
 
 	// Add message to history
 	dc.historyManager.AddMessage(msg)
 
+	// Paused guilds and channels keep their history but nothing reaches HUMA
+	if dc.isPaused(guildID, channelID) {
+		msgLogger.DebugContext(ctx, "Channel paused, not forwarding to HUMA")
+		return
+	}
+
+	// So do channels the bot only reads, and members it doesn't respond to
+	if access := dc.access(guildID); !access.canRespond(channelID) || !access.respondsTo(dc.memberRoles(msg.GuildID, msg.Message)) {
+		msgLogger.DebugContext(ctx, "Not responding to this channel or member, not forwarding to HUMA")
+		return
+	}
+
+	// The same goes for users over their rate limits, or ignored for abusing them
+	if result := dc.limits.check(guildID, msg.Author, class.Direct(), settings.userLimits); result.reason != "" {
+		metrics.UserMessagesLimited.Inc(result.reason)
+		span.SetAttribute("message.limited", result.reason)
+		if result.ignoring {
+			msgLogger.WarnContext(ctx, "Ignoring user for going over their rate limits", "author_id", msg.Author.ID, "author", msg.Author.Username, "until", result.until)
+		} else {
+			msgLogger.DebugContext(ctx, "User rate limited, not forwarding to HUMA", "author_id", msg.Author.ID, "reason", result.reason)
+		}
+		return
+	}
+
+	// Mentions of and replies to the account skip the debounce window
+	dc.batches.Add(ctx, msg, class)
+}
+
+// notifyHUMA sends a channel's batch of new messages to the guild's agent as
+// one event, under the trace of the newest message
+func (dc *DiscordClient) notifyHUMA(batch []batchedMessage) {
+	if len(batch) == 0 || dc.isStopped() {
+		return
+	}
+	last := batch[len(batch)-1]
+	guildID := last.msg.GuildID
+	channelID := last.msg.ChannelID
+
+	// The guild may have been turned off or paused while the batch waited
+	settings, ok := dc.guildSettings(guildID)
+	if !ok || dc.isPaused(guildID, channelID) {
+		return
+	}
+	guildName := settings.guildName
+	personality := settings.personality
+	rules := settings.rules
+	information := settings.information
+	websites := settings.websites
+	userID := settings.userID
+	channelName := dc.channelName(channelID)
+
+	ctx, span := tracing.Start(last.ctx, "huma.notify")
+	defer span.End()
+	span.SetAttribute("messages", len(batch))
+	msgLogger := logger.With(logging.KeyGuildID, guildID, logging.KeyChannelID, channelID)
+
+	messages := make([]huma.NewMessage, 0, len(batch))
+	for _, b := range batch {
+		messages = append(messages, huma.NewMessage{
+			ID:             b.msg.ID,
+			AuthorID:       b.msg.Author.ID,
+			AuthorName:     b.msg.Author.Username,
+			Content:        b.msg.Content,
+			MentionsYou:    b.class.MentionsYou,
+			IsReplyToYou:   b.class.IsReplyToYou,
+			NameMentioned:  b.class.NameMentioned,
+			SupportChannel: b.class.SupportChannel,
+		})
+	}
+
 	// Get or create HUMA agent for this guild
 	if dc.humaManager == nil {
-		log.Printf("[HUMA] No HUMA manager available")
+		msgLogger.ErrorContext(ctx, "No HUMA manager available")
 		return
 	}
 
 	agent, err := dc.humaManager.GetOrCreateAgent(guildID, guildName, userID)
 	if err != nil {
-		log.Printf("[HUMA] Error getting/creating agent: %v", err)
+		msgLogger.ErrorContext(ctx, "Error getting/creating agent", "error", err)
+		dc.stats.RecordHumaError(userID, guildID)
+		span.RecordError(err)
+		events.Publish(events.Event{Type: events.TypeError, GuildID: guildID, ChannelID: channelID, ChannelName: channelName, TraceID: span.TraceID(), Reason: "huma.create_agent", Error: err.Error()})
 		return
 	}
 
@@ -412,16 +730,10 @@ func (dc *DiscordClient) processMessageWithHUMA(msg *discordgo.MessageCreate) {
 	agent.UpdateConfig(dc, dc.historyManager, personality, rules, information, websites)
 
 	// Send message event to HUMA
-	err = agent.SendNewMessage(
-		channelID,
-		channelName,
-		msg.Author.ID,
-		msg.Author.Username,
-		msg.Content,
-		msg.ID,
-	)
+	err = agent.SendNewMessages(ctx, channelID, channelName, messages)
 	if err != nil {
-		log.Printf("[HUMA] Error sending message to HUMA: %v", err)
+		msgLogger.ErrorContext(ctx, "Error sending message to HUMA", "error", err)
+		dc.stats.RecordHumaError(userID, guildID)
 		// Connection might be dead - reconnect and retry
 		// Covers: "websocket: close", "connection reset", "i/o timeout", "EOF", etc.
 		errStr := err.Error()
@@ -432,29 +744,27 @@ func (dc *DiscordClient) processMessageWithHUMA(msg *discordgo.MessageCreate) {
 			strings.Contains(errStr, "EOF") ||
 			strings.Contains(errStr, "broken pipe")
 		if isConnectionError {
-			log.Printf("[HUMA] Connection dead, reconnecting...")
+			msgLogger.WarnContext(ctx, "HUMA connection dead, reconnecting")
+			metrics.HumaReconnects.Inc()
 			dc.humaManager.RemoveAgent(guildID)
 
 			// Reconnect and retry once
 			agent, err = dc.humaManager.GetOrCreateAgent(guildID, guildName, userID)
 			if err != nil {
-				log.Printf("[HUMA] Failed to reconnect: %v", err)
+				msgLogger.ErrorContext(ctx, "Failed to reconnect to HUMA", "error", err)
+				dc.stats.RecordHumaError(userID, guildID)
+				events.Publish(events.Event{Type: events.TypeError, GuildID: guildID, ChannelID: channelID, ChannelName: channelName, TraceID: span.TraceID(), Reason: "huma.reconnect", Error: err.Error()})
 				return
 			}
 			agent.UpdateConfig(dc, dc.historyManager, personality, rules, information, websites)
 
-			err = agent.SendNewMessage(
-				channelID,
-				channelName,
-				msg.Author.ID,
-				msg.Author.Username,
-				msg.Content,
-				msg.ID,
-			)
+			err = agent.SendNewMessages(ctx, channelID, channelName, messages)
 			if err != nil {
-				log.Printf("[HUMA] Retry failed: %v", err)
+				msgLogger.ErrorContext(ctx, "Retry failed", "error", err)
+				dc.stats.RecordHumaError(userID, guildID)
+				span.RecordError(err)
 			} else {
-				log.Printf("[HUMA] Reconnected and sent message successfully")
+				msgLogger.InfoContext(ctx, "Reconnected and sent message successfully")
 			}
 		}
 	}
@@ -463,41 +773,17 @@ func (dc *DiscordClient) processMessageWithHUMA(msg *discordgo.MessageCreate) {
 // SendMessage implements the huma.MessageSender interface
 func (dc *DiscordClient) SendMessage(channelID, content string) error {
 	if dc.session == nil {
+		metrics.DiscordSendFailures.Inc("no_session")
 		return fmt.Errorf("no active Discord session")
 	}
 
-	_, err := dc.session.ChannelMessageSend(channelID, content)
+	_, err := dc.sendAsAccount(channelID, content)
 	if err != nil {
+		metrics.DiscordSendFailures.Inc(sendFailureReason(err))
 		return fmt.Errorf("error sending message to Discord: %w", err)
 	}
 
-	log.Printf("[Discord] Message sent to channel %s", channelID)
-
-	// Report message sent to backend - find the correct user for this channel's guild
-	if dc.backendClient != nil {
-		go func() {
-			// Determine which user and guild to report stats for
-			userID := dc.userID // fallback to default
-			guildID := ""
-
-			// In multi-guild mode, look up the user from the channel's guild
-			if dc.configProvider != nil {
-				channel, err := dc.session.Channel(channelID)
-				if err == nil && channel != nil {
-					guildID = channel.GuildID
-					if config, exists := dc.configProvider.GetConfigForGuild(channel.GuildID); exists {
-						userID = config.UserID
-					}
-				}
-			}
-
-			if userID != "" && guildID != "" {
-				if err := dc.backendClient.ReportStats(userID, guildID, "message_sent"); err != nil {
-					log.Printf("[Stats] Failed to report message_sent: %v", err)
-				}
-			}
-		}()
-	}
+	logger.Debug("Message sent", logging.KeyChannelID, channelID)
 
 	return nil
 }
@@ -516,16 +802,36 @@ func (dc *DiscordClient) SendTypingIndicator(channelID string) error {
 	return nil
 }
 
+// markReady records the account's username from the first Ready of a session.
+// Returns false if Ready was already handled.
+func (dc *DiscordClient) markReady(username string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.readyHandled {
+		return false
+	}
+	dc.readyHandled = true
+	dc.botUsername = username
+	return true
+}
+
 // GetBotUsername implements the huma.MessageSender interface
 func (dc *DiscordClient) GetBotUsername() string {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
 	if dc.botUsername != "" {
 		return dc.botUsername
 	}
 	return "Bot"
 }
 
-// GetSession returns the Discord session (for HTTP handlers)
-func (dc *DiscordClient) GetSession() *discordgo.Session {
+// GetFingerprint returns the token fingerprint that identifies this account
+func (dc *DiscordClient) GetFingerprint() string {
+	return dc.fingerprint
+}
+
+// GetSession returns the Discord session (for HTTP handlers), nil when not connected
+func (dc *DiscordClient) GetSession() Session {
 	return dc.session
 }
 
@@ -569,12 +875,12 @@ func (dc *DiscordClient) GetWebsites() []types.WebsiteData {
 	return dc.websites
 }
 
-// GetMonitoredChannelsForGuild returns all text channels in the selected guild
+// GetMonitoredChannelsForGuild returns the text channels the bot reads in a
+// monitored guild, and whether it may respond in each
 func (dc *DiscordClient) GetMonitoredChannelsForGuild(guildID string) []huma.MonitoredChannel {
 	var channels []huma.MonitoredChannel
 
-	// Only return channels if this is the selected guild
-	if dc.selectedGuildID == "" || guildID != dc.selectedGuildID {
+	if !dc.isFromSelectedGuild(guildID) {
 		return channels
 	}
 
@@ -585,16 +891,18 @@ func (dc *DiscordClient) GetMonitoredChannelsForGuild(guildID string) []huma.Mon
 	// Get all channels from the guild
 	guildChannels, err := dc.session.GuildChannels(guildID)
 	if err != nil {
-		log.Printf("[Discord] Failed to get guild channels: %v", err)
+		logger.Error("Failed to get guild channels", logging.KeyGuildID, guildID, "error", err)
 		return channels
 	}
 
 	// Filter to text channels only (type 0 = text channel)
+	access := dc.access(guildID)
 	for _, ch := range guildChannels {
-		if ch.Type == discordgo.ChannelTypeGuildText {
+		if ch.Type == discordgo.ChannelTypeGuildText && access.canRead(ch.ID) {
 			channels = append(channels, huma.MonitoredChannel{
-				ID:   ch.ID,
-				Name: ch.Name,
+				ID:         ch.ID,
+				Name:       ch.Name,
+				CanRespond: access.canRespond(ch.ID),
 			})
 		}
 	}
@@ -602,7 +910,8 @@ func (dc *DiscordClient) GetMonitoredChannelsForGuild(guildID string) []huma.Mon
 	return channels
 }
 
-// GetAllChannelsForGuild returns all channels in the guild with their types
+// GetAllChannelsForGuild returns the channels the bot reads in the guild,
+// with their types
 func (dc *DiscordClient) GetAllChannelsForGuild(guildID string) []huma.ChannelInfo {
 	var channels []huma.ChannelInfo
 
@@ -613,11 +922,15 @@ func (dc *DiscordClient) GetAllChannelsForGuild(guildID string) []huma.ChannelIn
 	// Get all channels from the guild
 	guildChannels, err := dc.session.GuildChannels(guildID)
 	if err != nil {
-		log.Printf("[Discord] Failed to get guild channels: %v", err)
+		logger.Error("Failed to get guild channels", logging.KeyGuildID, guildID, "error", err)
 		return channels
 	}
 
+	access := dc.access(guildID)
 	for _, ch := range guildChannels {
+		if !access.canRead(ch.ID) {
+			continue
+		}
 		channelType := "unknown"
 		switch ch.Type {
 		case discordgo.ChannelTypeGuildText:
@@ -644,12 +957,26 @@ func (dc *DiscordClient) GetAllChannelsForGuild(guildID string) []huma.ChannelIn
 	return channels
 }
 
-// FetchChannelMessages fetches messages from a specific channel
-func (dc *DiscordClient) FetchChannelMessages(channelID string, limit int) ([]history.Message, error) {
+// FetchChannelMessages fetches messages from a channel of the agent's guild.
+// Channels of other guilds, or that can't be looked up, are refused so the
+// guild's channel lists can't be bypassed.
+func (dc *DiscordClient) FetchChannelMessages(guildID, channelID string, limit int) ([]history.Message, error) {
 	if dc.session == nil {
 		return nil, fmt.Errorf("no active Discord session")
 	}
 
+	channelGuildID, err := dc.resolveChannelGuild(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channelGuildID != guildID {
+		return nil, fmt.Errorf("channel %s is not in guild %s", channelID, guildID)
+	}
+	if !dc.CanRead(guildID, channelID) {
+		return nil, fmt.Errorf("channel %s is not one the bot reads", channelID)
+	}
+	keep := dc.readFilter(guildID, channelID)
+
 	if limit <= 0 {
 		limit = 50
 	}
@@ -666,6 +993,9 @@ func (dc *DiscordClient) FetchChannelMessages(channelID string, limit int) ([]hi
 	result := make([]history.Message, 0, len(messages))
 	for i := len(messages) - 1; i >= 0; i-- {
 		msg := messages[i]
+		if keep != nil && !keep(msg) {
+			continue
+		}
 		result = append(result, history.Message{
 			ID:        msg.ID,
 			ChannelID: msg.ChannelID,
@@ -678,3 +1008,39 @@ func (dc *DiscordClient) FetchChannelMessages(channelID string, limit int) ([]hi
 
 	return result, nil
 }
+
+// eventTypeName returns the discordgo event type name (e.g. "MessageCreate")
+func eventTypeName(evt any) string {
+	name := fmt.Sprintf("%T", evt)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// sendFailureReason classifies a Discord send error for metrics
+func sendFailureReason(err error) string {
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return "rate_limited"
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		switch restErr.Response.StatusCode {
+		case http.StatusTooManyRequests:
+			return "rate_limited"
+		case http.StatusForbidden:
+			return "forbidden"
+		case http.StatusNotFound:
+			return "not_found"
+		case http.StatusUnauthorized:
+			return "unauthorized"
+		}
+		if restErr.Response.StatusCode >= 500 {
+			return "discord_error"
+		}
+		return "rejected"
+	}
+	return "network"
+}
@@ -5,87 +5,37 @@ import (
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client/discordtest"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
-// MockSession is a mock Discord session for testing
-type MockSession struct {
-	messages      map[string][]*discordgo.Message
-	typingCalls   int
-	sentMessages  []string
-	currentUserID string
-}
-
-func NewMockSession() *MockSession {
-	return &MockSession{
-		messages:      make(map[string][]*discordgo.Message),
-		typingCalls:   0,
-		sentMessages:  []string{},
-		currentUserID: "bot123",
-	}
-}
-
-func (m *MockSession) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
-	if msgs, exists := m.messages[channelID]; exists {
-		// Discord returns messages in reverse order (newest first)
-		// Get the messages to return
-		var toReturn []*discordgo.Message
-		if len(msgs) > limit {
-			toReturn = msgs[len(msgs)-limit:]
-		} else {
-			toReturn = msgs
-		}
-
-		// Reverse them (newest first)
-		reversed := make([]*discordgo.Message, len(toReturn))
-		for i, msg := range toReturn {
-			reversed[len(toReturn)-1-i] = msg
-		}
-		return reversed, nil
-	}
-	return []*discordgo.Message{}, nil
-}
-
-func (m *MockSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
-	m.sentMessages = append(m.sentMessages, content)
-	return &discordgo.Message{
-		ID:        "msg_sent",
-		ChannelID: channelID,
-		Content:   content,
-	}, nil
-}
-
-func (m *MockSession) ChannelTyping(channelID string) error {
-	m.typingCalls++
-	return nil
-}
-
-func (m *MockSession) AddMockMessage(channelID, msgID, content, authorID, authorName string) {
-	if _, exists := m.messages[channelID]; !exists {
-		m.messages[channelID] = []*discordgo.Message{}
-	}
-	m.messages[channelID] = append(m.messages[channelID], &discordgo.Message{
-		ID:        msgID,
-		ChannelID: channelID,
-		Content:   content,
-		Author: &discordgo.User{
-			ID:       authorID,
-			Username: authorName,
-		},
-		Timestamp: time.Now(),
-	})
+// newHistorySession creates a fake Discord with channel1 and the members the
+// history tests post as
+func newHistorySession() *discordtest.Session {
+	session := discordtest.NewSession(&discordgo.User{ID: "bot123", Username: "TestBot"})
+	session.AddGuild("guild1", "Guild")
+	session.AddChannel("guild1", "channel1", "general")
+	for _, user := range []*discordgo.User{
+		{ID: "user1", Username: "Alice"},
+		{ID: "user2", Username: "Bob"},
+		{ID: "user3", Username: "Charlie"},
+		{ID: "user4", Username: "TestUser"},
+	} {
+		session.AddMember("guild1", user)
+	}
+	return session
 }
 
 // TestChannelInitialization tests that channels are properly initialized on first message
 func TestChannelInitialization(t *testing.T) {
 	manager := history.NewMessageHistoryManager()
-	mockSession := NewMockSession()
+	mockSession := newHistorySession()
 
 	// Add some historical messages
-	mockSession.AddMockMessage("channel1", "msg1", "Hello!", "user1", "Alice")
-	mockSession.AddMockMessage("channel1", "msg2", "Hi there!", "user2", "Bob")
-	mockSession.AddMockMessage("channel1", "msg3", "How are you?", "user1", "Alice")
+	mockSession.PostMessage(&discordgo.Message{ID: "msg1", ChannelID: "channel1", Content: "Hello!", Author: &discordgo.User{ID: "user1"}})
+	mockSession.PostMessage(&discordgo.Message{ID: "msg2", ChannelID: "channel1", Content: "Hi there!", Author: &discordgo.User{ID: "user2"}})
+	mockSession.PostMessage(&discordgo.Message{ID: "msg3", ChannelID: "channel1", Content: "How are you?", Author: &discordgo.User{ID: "user1"}})
 
 	// Channel should not be initialized yet
 	if manager.IsChannelInitialized("channel1") {
@@ -121,17 +71,16 @@ func TestChannelInitialization(t *testing.T) {
 // TestHistoryLimit tests that history maintains only last 50 messages
 func TestHistoryLimit(t *testing.T) {
 	manager := history.NewMessageHistoryManager()
-	mockSession := NewMockSession()
+	mockSession := newHistorySession()
 
 	// Add 55 messages to mock session
 	for i := 0; i < 55; i++ {
-		mockSession.AddMockMessage(
-			"channel1",
-			"msg"+string(rune(i)),
-			"Message "+string(rune(i)),
-			"user1",
-			"TestUser",
-		)
+		mockSession.PostMessage(&discordgo.Message{
+			ID:        "msg" + string(rune(i)),
+			ChannelID: "channel1",
+			Content:   "Message " + string(rune(i)),
+			Author:    &discordgo.User{ID: "user4"},
+		})
 	}
 
 	// Initialize with limit of 50
@@ -169,81 +118,6 @@ func TestHistoryLimit(t *testing.T) {
 	}
 }
 
-// TestThirdPersonPromptGeneration tests the third-person prompt building
-func TestThirdPersonPromptGeneration(t *testing.T) {
-	dc := NewDiscordClient()
-	dc.botUsername = "TestBot"
-	dc.userPrompt = "Be friendly and helpful"
-
-	// Add some conversation history
-	dc.historyManager.AddMessage(&discordgo.MessageCreate{
-		Message: &discordgo.Message{
-			ID:        "msg1",
-			ChannelID: "channel1",
-			Content:   "What is AI?",
-			Author:    &discordgo.User{ID: "user1", Username: "Alice"},
-			Timestamp: time.Now(),
-		},
-	})
-
-	dc.historyManager.AddMessage(&discordgo.MessageCreate{
-		Message: &discordgo.Message{
-			ID:        "msg2",
-			ChannelID: "channel1",
-			Content:   "AI stands for Artificial Intelligence.",
-			Author:    &discordgo.User{ID: "bot123", Username: "TestBot"},
-			Timestamp: time.Now(),
-		},
-	})
-
-	dc.historyManager.AddMessage(&discordgo.MessageCreate{
-		Message: &discordgo.Message{
-			ID:        "msg3",
-			ChannelID: "channel1",
-			Content:   "Can you tell me more?",
-			Author:    &discordgo.User{ID: "user1", Username: "Alice"},
-			Timestamp: time.Now(),
-		},
-	})
-
-	// Build the prompt
-	conversationHistory := dc.historyManager.FormatConversation("channel1", dc.botUsername)
-	prompt := dc.buildThirdPersonPrompt(conversationHistory, "general")
-
-	// Verify prompt contains key elements
-	if !contains(prompt, "TestBot is a helpful AI assistant") {
-		t.Error("Prompt should describe bot in third person")
-	}
-
-	if !contains(prompt, "channel #general") {
-		t.Error("Prompt should mention channel name")
-	}
-
-	if !contains(prompt, "Be friendly and helpful") {
-		t.Error("Prompt should include custom user instructions")
-	}
-
-	if !contains(prompt, "Alice: What is AI?") {
-		t.Error("Prompt should contain conversation history")
-	}
-
-	if !contains(prompt, "TestBot: AI stands for Artificial Intelligence.") {
-		t.Error("Prompt should contain bot's previous response")
-	}
-
-	if !contains(prompt, "Alice: Can you tell me more?") {
-		t.Error("Prompt should contain latest user message")
-	}
-
-	if !contains(prompt, "Generate the next message that TestBot would send") {
-		t.Error("Prompt should instruct to generate next message")
-	}
-
-	if !contains(prompt, "You MUST format your response as:") {
-		t.Error("Prompt should require username prefix format")
-	}
-}
-
 // TestConversationFormatting tests that conversation is formatted correctly
 func TestConversationFormatting(t *testing.T) {
 	manager := history.NewMessageHistoryManager()
@@ -330,8 +204,8 @@ func TestMultipleChannels(t *testing.T) {
 	}
 
 	// Initialize one channel
-	mockSession := NewMockSession()
-	mockSession.AddMockMessage("channel1", "hist1", "History 1", "user3", "Charlie")
+	mockSession := newHistorySession()
+	mockSession.PostMessage(&discordgo.Message{ID: "hist1", ChannelID: "channel1", Content: "History 1", Author: &discordgo.User{ID: "user3"}})
 
 	err := manager.InitializeChannel(mockSession, "channel1", 50)
 	if err != nil {
@@ -394,30 +268,9 @@ func TestBotResponseTracking(t *testing.T) {
 	}
 }
 
-// TestEmptyConversationHistory tests handling of empty history
-func TestEmptyConversationHistory(t *testing.T) {
-	dc := NewDiscordClient()
-	dc.botUsername = "TestBot"
-	dc.userPrompt = ""
-
-	// Build prompt with no history
-	conversationHistory := dc.historyManager.FormatConversation("nonexistent", dc.botUsername)
-	prompt := dc.buildThirdPersonPrompt(conversationHistory, "general")
-
-	// Should still have basic structure
-	if !contains(prompt, "TestBot is a helpful AI assistant") {
-		t.Error("Prompt should describe bot even with no history")
-	}
-
-	// Should not have conversation section if empty
-	if conversationHistory != "" {
-		t.Error("Expected empty conversation history for nonexistent channel")
-	}
-}
-
 // TestConfigUpdate tests that updating config doesn't affect history
 func TestConfigUpdate(t *testing.T) {
-	dc := NewDiscordClient()
+	dc := NewDiscordClient(nil, nil)
 
 	// Add some history
 	dc.historyManager.AddMessage(&discordgo.MessageCreate{
@@ -432,12 +285,10 @@ func TestConfigUpdate(t *testing.T) {
 
 	// Update config
 	newConfig := types.UserConfig{
-		Token: "new_token",
-		Email: "newemail@example.com",
-		SelectedChannels: []types.ChannelInfo{
-			{ChannelID: "channel2", GuildID: "guild1"},
-		},
-		Prompt: "New prompt",
+		Token:           "new_token",
+		Email:           "newemail@example.com",
+		SelectedGuildID: "guild2",
+		Personality:     "New personality",
 	}
 
 	dc.UpdateConfig(newConfig)
@@ -449,61 +300,11 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 	// New config values should be set
-	if dc.userPrompt != "New prompt" {
-		t.Error("User prompt should be updated")
-	}
-	if len(dc.selectedChannels) != 1 || dc.selectedChannels[0].ChannelID != "channel2" {
-		t.Error("Selected channels should be updated")
-	}
-}
-
-// TestStripUsernamePrefix tests that username prefix is correctly stripped
-func TestStripUsernamePrefix(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		username string
-		expected string
-	}{
-		{
-			name:     "Standard prefix",
-			input:    "TestBot: Hello, how can I help?",
-			username: "TestBot",
-			expected: "Hello, how can I help?",
-		},
-		{
-			name:     "No prefix",
-			input:    "Hello, how can I help?",
-			username: "TestBot",
-			expected: "Hello, how can I help?",
-		},
-		{
-			name:     "Different username",
-			input:    "OtherBot: Hello",
-			username: "TestBot",
-			expected: "OtherBot: Hello", // Not stripped because wrong username
-		},
-		{
-			name:     "Username in content",
-			input:    "TestBot: I am TestBot, nice to meet you",
-			username: "TestBot",
-			expected: "I am TestBot, nice to meet you",
-		},
-		{
-			name:     "Empty message",
-			input:    "TestBot: ",
-			username: "TestBot",
-			expected: "",
-		},
+	if dc.personality != "New personality" {
+		t.Error("Personality should be updated")
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := stripUsernamePrefix(tt.input, tt.username)
-			if result != tt.expected {
-				t.Errorf("stripUsernamePrefix() = %q, want %q", result, tt.expected)
-			}
-		})
+	if dc.selectedGuildID != "guild2" {
+		t.Error("Selected guild should be updated")
 	}
 }
 
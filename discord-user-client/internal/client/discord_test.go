@@ -1,68 +1,74 @@
 package client
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client/discordtest"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
-func TestIsChannelSelected(t *testing.T) {
-	dc := NewDiscordClient()
+// staticConfigs is a ConfigProvider with fixed guild configs
+type staticConfigs map[string]GuildConfigWithUser
 
+func (c staticConfigs) GetConfigForGuild(guildID string) (GuildConfigWithUser, bool) {
+	config, ok := c[guildID]
+	return config, ok
+}
+
+// newFakeDiscord creates a guild g1 with a #general channel c1 and a member alice
+func newFakeDiscord() *discordtest.Session {
+	fake := discordtest.NewSession(&discordgo.User{ID: "bot", Username: "helper"})
+	fake.AddGuild("g1", "Guild")
+	fake.AddChannel("g1", "c1", "general")
+	fake.AddMember("g1", &discordgo.User{ID: "u1", Username: "alice"})
+	return fake
+}
+
+// attachFake connects a multi-guild client to a fake Discord, active in g1
+func attachFake(t *testing.T, humaManager *huma.Manager) (*DiscordClient, *discordtest.Session) {
+	t.Helper()
+	fake := newFakeDiscord()
+	configs := staticConfigs{"g1": {
+		ServerConfig: types.ServerConfig{GuildID: "g1", GuildName: "Guild", BotActive: true, Personality: "friendly"},
+		UserID:       "owner",
+	}}
+	dc := NewMultiGuildDiscordClient(humaManager, nil, configs)
+	fake.SetEventHandler(dc.AttachSession(fake, "acct"))
+	dc.UpdateMonitoredGuilds([]string{"g1"})
+	fake.Connect()
+	return dc, fake
+}
+
+func TestIsFromSelectedGuild(t *testing.T) {
 	tests := []struct {
-		name             string
-		selectedChannels []types.ChannelInfo
-		testChannelID    string
-		expected         bool
+		name            string
+		selectedGuildID string
+		monitoredGuilds []string
+		guildID         string
+		expected        bool
 	}{
-		{
-			name:             "No channels selected",
-			selectedChannels: []types.ChannelInfo{},
-			testChannelID:    "channel1",
-			expected:         false,
-		},
-		{
-			name: "Channel is selected",
-			selectedChannels: []types.ChannelInfo{
-				{ChannelID: "channel1", GuildID: "guild1"},
-				{ChannelID: "channel2", GuildID: "guild1"},
-			},
-			testChannelID: "channel1",
-			expected:      true,
-		},
-		{
-			name: "Channel not in list",
-			selectedChannels: []types.ChannelInfo{
-				{ChannelID: "channel1", GuildID: "guild1"},
-				{ChannelID: "channel2", GuildID: "guild1"},
-			},
-			testChannelID: "channel3",
-			expected:      false,
-		},
-		{
-			name: "Single channel selected - match",
-			selectedChannels: []types.ChannelInfo{
-				{ChannelID: "only-channel", GuildID: "guild1"},
-			},
-			testChannelID: "only-channel",
-			expected:      true,
-		},
-		{
-			name: "Single channel selected - no match",
-			selectedChannels: []types.ChannelInfo{
-				{ChannelID: "only-channel", GuildID: "guild1"},
-			},
-			testChannelID: "other-channel",
-			expected:      false,
-		},
+		{name: "No guild selected", guildID: "guild1", expected: false},
+		{name: "Selected guild", selectedGuildID: "guild1", guildID: "guild1", expected: true},
+		{name: "Other guild", selectedGuildID: "guild1", guildID: "guild2", expected: false},
+		{name: "Monitored guild", monitoredGuilds: []string{"guild1", "guild2"}, guildID: "guild2", expected: true},
+		{name: "Monitored list overrides selection", selectedGuildID: "guild3", monitoredGuilds: []string{"guild1"}, guildID: "guild3", expected: false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			dc.selectedChannels = tt.selectedChannels
-			result := dc.isChannelSelected(tt.testChannelID)
+			dc := NewDiscordClient(nil, nil)
+			dc.selectedGuildID = tt.selectedGuildID
+			dc.UpdateMonitoredGuilds(tt.monitoredGuilds)
 
-			if result != tt.expected {
+			if result := dc.isFromSelectedGuild(tt.guildID); result != tt.expected {
 				t.Errorf("Expected %v, got %v", tt.expected, result)
 			}
 		})
@@ -70,105 +76,333 @@ func TestIsChannelSelected(t *testing.T) {
 }
 
 func TestUpdateConfig(t *testing.T) {
-	dc := NewDiscordClient()
+	dc := NewDiscordClient(nil, nil)
+	dc.token = "token1"
+	dc.userEmail = "test@example.com"
+	dc.personality = "Be helpful"
+
+	dc.UpdateConfig(types.UserConfig{
+		Token:             "token2",
+		Email:             "other@example.com",
+		SelectedGuildID:   "guild1",
+		SelectedGuildName: "Guild",
+		Personality:       "Be very helpful",
+		Rules:             "No spoilers",
+	})
 
-	// Initial config
-	initialConfig := types.UserConfig{
-		Token:            "token1",
-		Email:            "test@example.com",
-		SelectedChannels: []types.ChannelInfo{
-			{ChannelID: "channel1", GuildID: "guild1"},
-		},
-		Prompt: "Be helpful",
+	if dc.GetSelectedGuildID() != "guild1" || dc.GetSelectedGuildName() != "Guild" {
+		t.Errorf("Expected guild1/Guild, got %s/%s", dc.GetSelectedGuildID(), dc.GetSelectedGuildName())
+	}
+	if dc.GetPersonality() != "Be very helpful" || dc.GetRules() != "No spoilers" {
+		t.Errorf("Expected updated personality and rules, got %q and %q", dc.GetPersonality(), dc.GetRules())
 	}
 
-	dc.token = initialConfig.Token
-	dc.userEmail = initialConfig.Email
-	dc.selectedChannels = initialConfig.SelectedChannels
-	dc.userPrompt = initialConfig.Prompt
+	// Token and email are set by Connect, not UpdateConfig
+	if dc.GetToken() != "token1" || dc.GetUserEmail() != "test@example.com" {
+		t.Errorf("Token and email should not be changed by UpdateConfig")
+	}
+}
 
-	// Update config
-	newConfig := types.UserConfig{
-		Token:            "token1", // Same token
-		Email:            "test@example.com",
-		SelectedChannels: []types.ChannelInfo{
-			{ChannelID: "channel1", GuildID: "guild1"},
-			{ChannelID: "channel2", GuildID: "guild1"},
-		},
-		Prompt: "Be very helpful",
+func TestSendMessage_NoSession(t *testing.T) {
+	dc := NewDiscordClient(nil, nil)
+
+	err := dc.SendMessage("channel1", "test message")
+	if err == nil {
+		t.Error("Expected error when sending message without session, got nil")
 	}
+}
 
-	dc.UpdateConfig(newConfig)
+func TestSendMessage(t *testing.T) {
+	dc, fake := attachFake(t, nil)
 
-	// Verify updates
-	if len(dc.selectedChannels) != 2 {
-		t.Errorf("Expected 2 selected channels, got %d", len(dc.selectedChannels))
+	if err := dc.SendMessage("c1", "hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := dc.SendTypingIndicator("c1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
 	}
 
-	if dc.userPrompt != "Be very helpful" {
-		t.Errorf("Expected prompt 'Be very helpful', got '%s'", dc.userPrompt)
+	sent := fake.Sent()
+	if len(sent) != 1 || sent[0].Content != "hello" || sent[0].ChannelID != "c1" {
+		t.Errorf("Expected hello to be sent to c1, got %+v", sent)
 	}
+	if typing := fake.Typing(); len(typing) != 1 || typing[0] != "c1" {
+		t.Errorf("Expected a typing indicator in c1, got %v", typing)
+	}
+}
 
-	// Verify token and email weren't changed by UpdateConfig
-	if dc.token != "token1" {
-		t.Errorf("Token should not be changed by UpdateConfig")
+func TestSendMessage_CountsFailureReason(t *testing.T) {
+	dc, fake := attachFake(t, nil)
+	fake.FailSends(discordtest.Error(403, discordgo.ErrCodeMissingPermissions, "Missing Permissions"))
+
+	before := metrics.DiscordSendFailures.Value("forbidden")
+	if err := dc.SendMessage("c1", "hello"); err == nil {
+		t.Fatal("Expected the send to fail")
+	}
+	if got := metrics.DiscordSendFailures.Value("forbidden") - before; got != 1 {
+		t.Errorf("Expected one forbidden send failure, got %v", got)
+	}
+
+	if err := dc.SendMessage("unknown", "hello"); err == nil {
+		t.Error("Expected sending to an unknown channel to fail")
+	}
+}
+
+func TestProcessMessageWithHUMA(t *testing.T) {
+	server := humatest.NewServer("")
+	defer server.Close()
+	received := make(chan humatest.Event, 10)
+	server.OnEvent = func(event humatest.Event) {
+		if event.Name != "" {
+			received <- event
+		}
+	}
+
+	manager := huma.NewManager("test-key")
+	manager.SetBaseURL(server.URL())
+	manager.SetTuning(huma.Tuning{TypingWPM: 10000, MaxTypingDelay: time.Second})
+	defer manager.Shutdown(context.Background())
+
+	_, fake := attachFake(t, manager)
+	if _, err := fake.Post("c1", "u1", "how do I reset my password?"); err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	agentID, err := server.WaitForAgent(ctx)
+	if err != nil {
+		t.Fatalf("Expected an agent to be created: %v", err)
+	}
+	if created := server.Created(); len(created) != 1 || !strings.Contains(created[0].Name, "Guild") {
+		t.Errorf("Expected one agent for Guild, got %+v", created)
+	}
+
+	select {
+	case event := <-received:
+		if event.Name != "new-message" || !strings.Contains(event.Description, "alice") || !strings.Contains(event.Description, "#general") {
+			t.Errorf("Unexpected event: %s: %s", event.Name, event.Description)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the message to reach the agent")
+	}
+
+	// The agent answers; the reply goes out through the fake session
+	callID, err := server.CallTool(agentID, "send_message", map[string]interface{}{"channel_id": "c1", "message": "Use the reset link"})
+	if err != nil {
+		t.Fatalf("Failed to call tool: %v", err)
+	}
+	result, err := server.WaitToolResult(ctx, callID)
+	if err != nil || !result.Success {
+		t.Fatalf("Expected the send to succeed, got %+v (%v)", result, err)
+	}
+	sent := fake.Sent()
+	if len(sent) != 1 || sent[0].Content != "Use the reset link" {
+		t.Errorf("Expected the reply in Discord, got %+v", sent)
+	}
+	if len(fake.Typing()) == 0 {
+		t.Error("Expected a typing indicator before the reply")
 	}
 }
 
-func TestGetters(t *testing.T) {
-	dc := NewDiscordClient()
+func TestSendsSupersedeOnlyWithinChannel(t *testing.T) {
+	server := humatest.NewServer("")
+	defer server.Close()
+	received := make(chan humatest.Event, 10)
+	server.OnEvent = func(event humatest.Event) {
+		if event.Name != "" {
+			received <- event
+		}
+	}
 
-	testConfig := types.UserConfig{
-		Token:            "test-token-123",
-		Email:            "user@example.com",
-		SelectedChannels: []types.ChannelInfo{
-			{ChannelID: "ch1", GuildID: "g1"},
-		},
-		Prompt: "Test prompt",
+	manager := huma.NewManager("test-key")
+	manager.SetBaseURL(server.URL())
+	manager.SetTuning(huma.Tuning{TypingWPM: 10000, MaxTypingDelay: 500 * time.Millisecond})
+	defer manager.Shutdown(context.Background())
+
+	_, fake := attachFake(t, manager)
+	fake.AddChannel("g1", "c2", "support")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, channelID := range []string{"c1", "c2"} {
+		if _, err := fake.Post(channelID, "u1", "question in "+channelID); err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatal("Expected the message to reach the agent")
+		}
+	}
+	agentID, err := server.WaitForAgent(ctx)
+	if err != nil {
+		t.Fatalf("Expected an agent to be created: %v", err)
 	}
 
-	dc.token = testConfig.Token
-	dc.userEmail = testConfig.Email
-	dc.selectedChannels = testConfig.SelectedChannels
-	dc.userPrompt = testConfig.Prompt
+	// Answers to both channels are typed at once; a second answer in c1 replaces the first
+	call := func(channelID, message string) string {
+		t.Helper()
+		callID, err := server.CallTool(agentID, "send_message", map[string]interface{}{"channel_id": channelID, "message": message})
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		return callID
+	}
+	first := call("c1", "first answer")
+	support := call("c2", "support answer")
+	second := call("c1", "second answer")
 
-	// Test getters
-	if dc.GetToken() != "test-token-123" {
-		t.Errorf("GetToken() = %s, want test-token-123", dc.GetToken())
+	if result, err := server.WaitToolResult(ctx, first); err != nil || !result.Canceled() {
+		t.Errorf("Expected the first c1 answer to be superseded, got %+v (%v)", result, err)
+	}
+	for _, callID := range []string{support, second} {
+		if result, err := server.WaitToolResult(ctx, callID); err != nil || !result.Success {
+			t.Errorf("Expected %s to be sent, got %+v (%v)", callID, result, err)
+		}
+	}
+	sent := map[string]string{}
+	for _, msg := range fake.Sent() {
+		sent[msg.ChannelID] = msg.Content
 	}
+	if len(fake.Sent()) != 2 || sent["c1"] != "second answer" || sent["c2"] != "support answer" {
+		t.Errorf("Expected one answer per channel, got %+v", sent)
+	}
+}
 
-	if dc.GetUserEmail() != "user@example.com" {
-		t.Errorf("GetUserEmail() = %s, want user@example.com", dc.GetUserEmail())
+func TestOwnerCommandsIgnoreAgentSends(t *testing.T) {
+	dc, fake := attachFake(t, nil)
+	store, err := pause.NewStore("")
+	if err != nil {
+		t.Fatalf("Failed to create pause store: %v", err)
 	}
+	dc.SetPauseStore(store)
 
-	if dc.GetUserPrompt() != "Test prompt" {
-		t.Errorf("GetUserPrompt() = %s, want Test prompt", dc.GetUserPrompt())
+	waitForReactions := func(n int) []discordtest.Reaction {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(fake.Reactions()) < n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return fake.Reactions()
 	}
 
-	channels := dc.GetSelectedChannels()
-	if len(channels) != 1 || channels[0].ChannelID != "ch1" {
-		t.Errorf("GetSelectedChannels() returned unexpected result")
+	// The agent posting a command, e.g. because a user asked it to, is not the owner
+	if err := dc.SendMessage("c1", "!neonrain pause guild"); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	resume, err := fake.Post("c1", "bot", "!neonrain resume guild")
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	reactions := waitForReactions(1)
+	if len(reactions) != 1 || reactions[0].MessageID != resume.ID || reactions[0].Emoji != "❌" {
+		t.Errorf("Expected only the owner's resume to be handled, and to fail, got %+v", reactions)
+	}
+	if store.IsPaused("g1", "c1") {
+		t.Error("Expected the agent's pause command to be ignored")
+	}
+
+	if _, err := fake.Post("c1", "bot", "!neonrain pause guild"); err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	if reactions := waitForReactions(2); len(reactions) != 2 || reactions[1].Emoji != "✅" {
+		t.Errorf("Expected the owner's pause to be acknowledged, got %+v", reactions)
+	}
+	if !store.IsPaused("g1", "c1") {
+		t.Error("Expected the owner's pause command to pause the guild")
 	}
 }
 
-func TestSendMessage_NoSession(t *testing.T) {
-	dc := NewDiscordClient()
+func TestSendPolicyBlocksUnansweredSends(t *testing.T) {
+	server := humatest.NewServer("")
+	defer server.Close()
+	received := make(chan humatest.Event, 10)
+	server.OnEvent = func(event humatest.Event) {
+		if event.Name != "" {
+			received <- event
+		}
+	}
 
-	err := dc.SendMessage("channel1", "test message")
-	if err == nil {
-		t.Error("Expected error when sending message without session, got nil")
+	manager := huma.NewManager("test-key")
+	manager.SetBaseURL(server.URL())
+	manager.SetTuning(huma.Tuning{TypingWPM: 10000, MaxTypingDelay: 10 * time.Millisecond})
+	manager.SetSendPolicy(huma.SendPolicy{RequireHumanMessage: true})
+	defer manager.Shutdown(context.Background())
+
+	_, fake := attachFake(t, manager)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	post := func(content string) {
+		t.Helper()
+		if _, err := fake.Post("c1", "u1", content); err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatal("Expected the message to reach the agent")
+		}
+	}
+	send := func(agentID, message string) humatest.ToolResult {
+		t.Helper()
+		callID, err := server.CallTool(agentID, "send_message", map[string]interface{}{"channel_id": "c1", "message": message})
+		if err != nil {
+			t.Fatalf("Failed to call tool: %v", err)
+		}
+		result, err := server.WaitToolResult(ctx, callID)
+		if err != nil {
+			t.Fatalf("Expected a tool result: %v", err)
+		}
+		return result
+	}
+
+	post("anyone around?")
+	agentID, err := server.WaitForAgent(ctx)
+	if err != nil {
+		t.Fatalf("Expected an agent to be created: %v", err)
+	}
+	if result := send(agentID, "I am!"); !result.Success {
+		t.Fatalf("Expected the first answer to be sent, got %+v", result)
+	}
+
+	// A follow-up before anyone answers is refused with the rule that blocked it
+	result := send(agentID, "hello?")
+	reason, _ := result.Result.(map[string]interface{})
+	if result.Success || reason["rule"] != huma.RuleHumanMessage || !strings.Contains(result.Error, "send policy") {
+		t.Errorf("Expected the follow-up to be blocked by %s, got %+v", huma.RuleHumanMessage, result)
+	}
+
+	post("oh hi")
+	if result := send(agentID, "hello!"); !result.Success {
+		t.Errorf("Expected an answer after a reply to be sent, got %+v", result)
+	}
+	if len(fake.Sent()) != 2 {
+		t.Errorf("Expected two messages sent, got %+v", fake.Sent())
+	}
+
+	// A new agent for the guild, as after its connection died, still knows
+	// nobody has answered
+	if _, err := manager.RecreateAgent("g1"); err != nil {
+		t.Fatalf("Failed to recreate agent: %v", err)
+	}
+	newAgentID, err := server.WaitForAgent(ctx)
+	if err != nil {
+		t.Fatalf("Expected a new agent to connect: %v", err)
+	}
+	if result := send(newAgentID, "still there?"); result.Success {
+		t.Errorf("Expected the new agent's follow-up to be blocked, got %+v", result)
 	}
 }
 
 func TestNewDiscordClient(t *testing.T) {
-	dc := NewDiscordClient()
+	dc := NewDiscordClient(nil, nil)
 
 	if dc == nil {
 		t.Fatal("NewDiscordClient() returned nil")
 	}
 
 	// Verify initial state
-	if dc.session != nil {
+	if dc.GetSession() != nil {
 		t.Error("New client should not have an active session")
 	}
 
@@ -176,11 +410,7 @@ func TestNewDiscordClient(t *testing.T) {
 		t.Error("readyHandled should be false initially")
 	}
 
-	if len(dc.selectedChannels) != 0 {
-		t.Error("selectedChannels should be empty initially")
+	if len(dc.GetMonitoredGuildIDs()) != 0 {
+		t.Error("monitoredGuilds should be empty initially")
 	}
 }
-
-// Note: Testing Connect() requires mocking the discordgo library,
-// which is complex and would require dependency injection.
-// In a production environment, you might use interfaces to make this testable.
@@ -0,0 +1,425 @@
+// Package discordtest is an in-memory Discord for tests and local simulation.
+// A Session holds the guilds, channels and members one account can see and a
+// message log per channel. It answers the REST calls the client makes from
+// them, records what the client sends and delivers posted messages to the
+// client as gateway events, so no token or network is needed.
+package discordtest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Reaction is a reaction the client added to a message
+type Reaction struct {
+	ChannelID string
+	MessageID string
+	Emoji     string
+}
+
+// Session is an in-memory Discord session for one account. Give it to the
+// client with DiscordClient.AttachSession and pass the returned handler to
+// SetEventHandler.
+type Session struct {
+	state *discordgo.State
+
+	mu        sync.Mutex
+	handler   func(any)
+	messages  map[string][]*discordgo.Message // channelID -> log, oldest first
+	sent      []*discordgo.Message
+	typing    []string
+	reactions []Reaction
+	sendErr   error
+	sendWait  []chan struct{}
+	ready     bool
+	closed    bool
+	nextID    int
+}
+
+// mentionPattern matches user mentions in message content
+var mentionPattern = regexp.MustCompile(`<@!?(\w+)>`)
+
+// NewSession creates a session logged in as self, with no guilds
+func NewSession(self *discordgo.User) *Session {
+	state := discordgo.NewState()
+	state.User = self
+	return &Session{
+		state:    state,
+		messages: make(map[string][]*discordgo.Message),
+	}
+}
+
+// SetEventHandler sets the function gateway events are delivered to. Events
+// are delivered synchronously, as discordgo does.
+func (s *Session) SetEventHandler(handler func(any)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = handler
+}
+
+// dispatch delivers an event to the handler. Must be called without s.mu held.
+func (s *Session) dispatch(event any) {
+	s.mu.Lock()
+	handler := s.handler
+	s.mu.Unlock()
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// AddGuild adds a guild the account is a member of
+func (s *Session) AddGuild(guildID, name string) {
+	s.state.GuildAdd(&discordgo.Guild{
+		ID:      guildID,
+		Name:    name,
+		OwnerID: s.state.User.ID,
+		Members: []*discordgo.Member{{GuildID: guildID, User: s.state.User}},
+	})
+}
+
+// AddChannel adds a text channel to a guild
+func (s *Session) AddChannel(guildID, channelID, name string) {
+	s.state.ChannelAdd(&discordgo.Channel{
+		ID:      channelID,
+		GuildID: guildID,
+		Name:    name,
+		Type:    discordgo.ChannelTypeGuildText,
+	})
+}
+
+// AddMember adds a user to a guild, with optional role IDs
+func (s *Session) AddMember(guildID string, user *discordgo.User, roles ...string) {
+	s.state.MemberAdd(&discordgo.Member{
+		GuildID:  guildID,
+		User:     user,
+		Roles:    roles,
+		JoinedAt: time.Now(),
+	})
+}
+
+// Connect delivers Ready and a GuildCreate for every guild, as the gateway
+// does when a session opens
+func (s *Session) Connect() {
+	s.state.RLock()
+	guilds := append([]*discordgo.Guild(nil), s.state.Guilds...)
+	s.state.RUnlock()
+
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+
+	s.dispatch(&discordgo.Ready{Version: 9, SessionID: "discordtest", User: s.state.User, Guilds: guilds})
+	for _, guild := range guilds {
+		s.dispatch(&discordgo.GuildCreate{Guild: guild})
+	}
+}
+
+// Post has a member send a message and delivers it as MessageCreate. Mentions
+// of known members in the content (<@id>) are filled in.
+func (s *Session) Post(channelID, authorID, content string) (*discordgo.Message, error) {
+	return s.PostMessage(&discordgo.Message{
+		ChannelID: channelID,
+		Author:    &discordgo.User{ID: authorID},
+		Content:   content,
+	})
+}
+
+// PostMessage adds a message to its channel's log and delivers it as
+// MessageCreate. The ID, guild, timestamp and author details are filled in
+// when missing; set Mentions or MessageReference for replies.
+func (s *Session) PostMessage(message *discordgo.Message) (*discordgo.Message, error) {
+	channel, err := s.state.Channel(message.ChannelID)
+	if err != nil {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	message.GuildID = channel.GuildID
+	if message.Author == nil {
+		return nil, fmt.Errorf("message has no author")
+	}
+	if member, err := s.state.Member(channel.GuildID, message.Author.ID); err == nil {
+		message.Author = member.User
+		message.Member = member
+	} else if message.Author.ID != s.state.User.ID {
+		return nil, fmt.Errorf("%s is not a member of guild %s", message.Author.ID, channel.GuildID)
+	}
+	if message.Mentions == nil {
+		message.Mentions = s.mentions(channel.GuildID, message.Content)
+	}
+
+	s.mu.Lock()
+	s.log(message)
+	s.mu.Unlock()
+
+	s.dispatch(&discordgo.MessageCreate{Message: message})
+	return message, nil
+}
+
+// mentions returns the known members mentioned in content
+func (s *Session) mentions(guildID, content string) []*discordgo.User {
+	var users []*discordgo.User
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if member, err := s.state.Member(guildID, match[1]); err == nil {
+			users = append(users, member.User)
+		}
+	}
+	return users
+}
+
+// log appends a message to its channel, assigning an ID and timestamp. Must
+// be called with s.mu held.
+func (s *Session) log(message *discordgo.Message) {
+	if message.ID == "" {
+		s.nextID++
+		message.ID = strconv.Itoa(1000 + s.nextID)
+	}
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+	s.messages[message.ChannelID] = append(s.messages[message.ChannelID], message)
+}
+
+// Messages returns a channel's message log, oldest first
+func (s *Session) Messages(channelID string) []*discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.Message(nil), s.messages[channelID]...)
+}
+
+// Sent returns the messages the client sent, oldest first
+func (s *Session) Sent() []*discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.Message(nil), s.sent...)
+}
+
+// WaitForSent waits until the client has sent n messages, returning false on timeout
+func (s *Session) WaitForSent(n int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		if len(s.sent) >= n {
+			s.mu.Unlock()
+			return true
+		}
+		wait := make(chan struct{})
+		s.sendWait = append(s.sendWait, wait)
+		s.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// Typing returns the channels the client sent a typing indicator to, in order
+func (s *Session) Typing() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.typing...)
+}
+
+// Reactions returns the reactions the client added, in order
+func (s *Session) Reactions() []Reaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Reaction(nil), s.reactions...)
+}
+
+// FailSends makes sends fail with err until called with nil. Use Error to
+// build the error Discord would return.
+func (s *Session) FailSends(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendErr = err
+}
+
+// Error returns the error discordgo reports for a failed REST call
+func Error(status, code int, message string) *discordgo.RESTError {
+	body := fmt.Sprintf(`{"message":%q,"code":%d}`, message, code)
+	return &discordgo.RESTError{
+		Response: &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		},
+		ResponseBody: []byte(body),
+		Message:      &discordgo.APIErrorMessage{Code: code, Message: message},
+	}
+}
+
+// notFound returns the error for an unknown ID
+func notFound(code int, message string) *discordgo.RESTError {
+	return Error(http.StatusNotFound, code, message)
+}
+
+// ChannelMessages implements client.Session. Like Discord, it returns the
+// newest messages first.
+func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	if _, err := s.state.Channel(channelID); err != nil {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log := s.messages[channelID]
+	if beforeID != "" {
+		for i, message := range log {
+			if message.ID == beforeID {
+				log = log[:i]
+				break
+			}
+		}
+	}
+	if limit > 0 && len(log) > limit {
+		log = log[len(log)-limit:]
+	}
+	newestFirst := make([]*discordgo.Message, len(log))
+	for i, message := range log {
+		newestFirst[len(log)-1-i] = message
+	}
+	return newestFirst, nil
+}
+
+// UserGuilds implements client.Session
+func (s *Session) UserGuilds(limit int, beforeID, afterID string, withCounts bool, options ...discordgo.RequestOption) ([]*discordgo.UserGuild, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	guilds := make([]*discordgo.UserGuild, 0, len(s.state.Guilds))
+	for _, guild := range s.state.Guilds {
+		guilds = append(guilds, &discordgo.UserGuild{ID: guild.ID, Name: guild.Name, Icon: guild.Icon, Owner: guild.OwnerID == s.state.User.ID})
+	}
+	sort.Slice(guilds, func(i, j int) bool { return guilds[i].ID < guilds[j].ID })
+	if limit > 0 && len(guilds) > limit {
+		guilds = guilds[:limit]
+	}
+	return guilds, nil
+}
+
+// Guild implements client.Session
+func (s *Session) Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	guild, err := s.state.Guild(guildID)
+	if err != nil {
+		return nil, notFound(discordgo.ErrCodeUnknownGuild, "Unknown Guild")
+	}
+	s.state.RLock()
+	defer s.state.RUnlock()
+	copied := *guild
+	return &copied, nil
+}
+
+// GuildChannels implements client.Session
+func (s *Session) GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+	guild, err := s.state.Guild(guildID)
+	if err != nil {
+		return nil, notFound(discordgo.ErrCodeUnknownGuild, "Unknown Guild")
+	}
+	s.state.RLock()
+	defer s.state.RUnlock()
+	channels := make([]*discordgo.Channel, 0, len(guild.Channels))
+	for _, channel := range guild.Channels {
+		copied := *channel
+		channels = append(channels, &copied)
+	}
+	return channels, nil
+}
+
+// Channel implements client.Session
+func (s *Session) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	channel, err := s.state.Channel(channelID)
+	if err != nil {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	s.state.RLock()
+	defer s.state.RUnlock()
+	copied := *channel
+	return &copied, nil
+}
+
+// ChannelMessageSend implements client.Session. The message is logged,
+// recorded as sent and echoed back as MessageCreate, as the gateway does.
+func (s *Session) ChannelMessageSend(channelID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	channel, err := s.state.Channel(channelID)
+	if err != nil {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+
+	s.mu.Lock()
+	if s.sendErr != nil {
+		err := s.sendErr
+		s.mu.Unlock()
+		return nil, err
+	}
+	message := &discordgo.Message{
+		ChannelID: channelID,
+		GuildID:   channel.GuildID,
+		Content:   content,
+		Author:    s.state.User,
+	}
+	s.log(message)
+	s.sent = append(s.sent, message)
+	for _, wait := range s.sendWait {
+		close(wait)
+	}
+	s.sendWait = nil
+	s.mu.Unlock()
+
+	s.dispatch(&discordgo.MessageCreate{Message: message})
+	return message, nil
+}
+
+// ChannelTyping implements client.Session
+func (s *Session) ChannelTyping(channelID string, options ...discordgo.RequestOption) error {
+	if _, err := s.state.Channel(channelID); err != nil {
+		return notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.typing = append(s.typing, channelID)
+	return nil
+}
+
+// MessageReactionAdd implements client.Session
+func (s *Session) MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, message := range s.messages[channelID] {
+		if message.ID == messageID {
+			s.reactions = append(s.reactions, Reaction{ChannelID: channelID, MessageID: messageID, Emoji: emojiID})
+			return nil
+		}
+	}
+	return notFound(discordgo.ErrCodeUnknownMessage, "Unknown Message")
+}
+
+// State implements client.Session
+func (s *Session) State() *discordgo.State {
+	return s.state
+}
+
+// GatewayReady implements client.Session. It is true once Connect was called.
+func (s *Session) GatewayReady() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ready && !s.closed
+}
+
+// LastHeartbeat implements client.Session. There is no gateway, so no heartbeats.
+func (s *Session) LastHeartbeat() (sent, ack time.Time) {
+	return time.Time{}, time.Time{}
+}
+
+// Close implements client.Session
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
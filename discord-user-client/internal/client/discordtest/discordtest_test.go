@@ -0,0 +1,109 @@
+package discordtest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newTestSession() *Session {
+	session := NewSession(&discordgo.User{ID: "bot", Username: "helper"})
+	session.AddGuild("g1", "Guild")
+	session.AddChannel("g1", "c1", "general")
+	session.AddMember("g1", &discordgo.User{ID: "u1", Username: "alice"})
+	return session
+}
+
+func TestPostDeliversMessageCreate(t *testing.T) {
+	session := newTestSession()
+	var events []any
+	session.SetEventHandler(func(event any) { events = append(events, event) })
+
+	session.Connect()
+	message, err := session.Post("c1", "u1", "hi <@bot>")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected Ready, GuildCreate and MessageCreate, got %d events", len(events))
+	}
+	created, ok := events[2].(*discordgo.MessageCreate)
+	if !ok || created.Message != message {
+		t.Fatalf("Expected the posted message as MessageCreate, got %T", events[2])
+	}
+	if message.GuildID != "g1" || message.Author.Username != "alice" || message.ID == "" {
+		t.Errorf("Expected guild, author and ID to be filled in, got %+v", message)
+	}
+	if len(message.Mentions) != 1 || message.Mentions[0].ID != "bot" {
+		t.Errorf("Expected the bot to be mentioned, got %+v", message.Mentions)
+	}
+
+	if _, err := session.Post("c1", "stranger", "hi"); err == nil {
+		t.Error("Expected a post from a non-member to fail")
+	}
+}
+
+func TestChannelMessagesNewestFirst(t *testing.T) {
+	session := newTestSession()
+	for _, content := range []string{"one", "two", "three"} {
+		session.Post("c1", "u1", content)
+	}
+
+	messages, err := session.ChannelMessages("c1", 2, "", "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Content != "three" || messages[1].Content != "two" {
+		t.Errorf("Expected the two newest messages, newest first, got %+v", messages)
+	}
+}
+
+func TestSendsAreRecordedAndEchoed(t *testing.T) {
+	session := newTestSession()
+	var echoed int
+	session.SetEventHandler(func(event any) {
+		if _, ok := event.(*discordgo.MessageCreate); ok {
+			echoed++
+		}
+	})
+
+	if _, err := session.ChannelMessageSend("c1", "hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !session.WaitForSent(1, time.Second) {
+		t.Fatal("Expected one sent message")
+	}
+	if echoed != 1 {
+		t.Errorf("Expected the send to be echoed, got %d events", echoed)
+	}
+	if log := session.Messages("c1"); len(log) != 1 || log[0].Author.ID != "bot" {
+		t.Errorf("Expected the send in the channel log, got %+v", log)
+	}
+
+	session.FailSends(Error(http.StatusForbidden, discordgo.ErrCodeMissingPermissions, "Missing Permissions"))
+	_, err := session.ChannelMessageSend("c1", "again")
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a 403 REST error, got %v", err)
+	}
+}
+
+func TestUnknownIDsAreNotFound(t *testing.T) {
+	session := newTestSession()
+
+	_, err := session.Channel("nope")
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404 REST error, got %v", err)
+	}
+	if _, err := session.GuildChannels("nope"); err == nil {
+		t.Error("Expected an unknown guild to fail")
+	}
+	if err := session.MessageReactionAdd("c1", "nope", "✅"); err == nil {
+		t.Error("Expected reacting to an unknown message to fail")
+	}
+}
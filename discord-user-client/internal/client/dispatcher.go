@@ -0,0 +1,328 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+)
+
+// OverflowPolicy says what happens to a gateway event whose guild queue is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest drops the oldest waiting event to make room, so the
+	// agent sees the latest messages after a burst
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowDropNewest drops the incoming event
+	OverflowDropNewest OverflowPolicy = "drop-newest"
+	// OverflowBlock makes the gateway wait for room. This stalls every guild
+	// on the connection, not just the busy one.
+	OverflowBlock OverflowPolicy = "block"
+)
+
+// Event pipeline defaults
+const (
+	DefaultEventWorkers       = 4
+	DefaultEventQueueSize     = 100
+	DefaultEventAmbientSample = 4
+)
+
+// DispatchConfig sizes the gateway event pipeline of a Discord client
+type DispatchConfig struct {
+	// Workers is how many events are processed at once, across guilds
+	Workers int
+	// QueueSize is how many events may wait per guild
+	QueueSize int
+	// Overflow applies when a guild's queue is full
+	Overflow OverflowPolicy
+	// AmbientSample keeps one in this many ambient events once a guild's
+	// queue is half full. 1 keeps them all.
+	AmbientSample int
+}
+
+// DefaultDispatchConfig returns the pipeline used unless configured otherwise
+func DefaultDispatchConfig() DispatchConfig {
+	return DispatchConfig{
+		Workers:       DefaultEventWorkers,
+		QueueSize:     DefaultEventQueueSize,
+		Overflow:      OverflowDropOldest,
+		AmbientSample: DefaultEventAmbientSample,
+	}
+}
+
+// dispatcher runs gateway events on a fixed pool of workers. Events with the
+// same key (a guild ID) run one at a time, in the order they arrived; events
+// with different keys run in parallel. A free worker takes the key with the
+// highest-priority waiting event; keys with equal priority take turns, so a
+// busy guild can't starve the others.
+type dispatcher struct {
+	config DispatchConfig
+
+	mu     sync.Mutex
+	work   *sync.Cond // signaled when a key becomes ready, or on close
+	room   *sync.Cond // broadcast when an event leaves a queue, or on close
+	queues map[string]*eventQueue
+	ready  []string // keys with waiting events and no worker, oldest first
+	closed bool
+}
+
+// eventQueue holds one key's waiting events
+type eventQueue struct {
+	events    []queuedEvent
+	waiting   [numPriorities]int // events waiting at each priority
+	scheduled bool               // in ready, or being run by a worker
+	dropped   int                // dropped since the queue was last empty
+	ambient   int                // ambient events offered while sampling
+}
+
+// queuedEvent is an event waiting for a worker
+type queuedEvent struct {
+	run      func()
+	priority Priority
+	queued   time.Time
+}
+
+// newDispatcher starts a dispatcher's workers
+func newDispatcher(config DispatchConfig) *dispatcher {
+	if config.Workers < 1 {
+		config.Workers = DefaultEventWorkers
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = DefaultEventQueueSize
+	}
+	if config.AmbientSample < 1 {
+		config.AmbientSample = 1
+	}
+	d := &dispatcher{
+		config: config,
+		queues: make(map[string]*eventQueue),
+	}
+	d.work = sync.NewCond(&d.mu)
+	d.room = sync.NewCond(&d.mu)
+	for i := 0; i < config.Workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Submit queues run behind the events already waiting for key. Returns false
+// if the event was dropped, by ambient sampling, by the overflow policy or
+// because the dispatcher is closed. With OverflowBlock it waits for room.
+func (d *dispatcher) Submit(key string, priority Priority, run func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q := d.queue(key)
+	if priority == PriorityAmbient && !d.sampled(q) {
+		d.dropped(key, q, priority)
+		return false
+	}
+	for !d.closed && len(q.events) >= d.config.QueueSize {
+		switch d.config.Overflow {
+		case OverflowBlock:
+			d.room.Wait()
+			// The queue may have drained and been removed meanwhile
+			q = d.queue(key)
+		case OverflowDropNewest:
+			// Only an event that outranks one already waiting gets in
+			victim := q.newest(priority - 1)
+			if victim < 0 {
+				d.dropped(key, q, priority)
+				return false
+			}
+			d.dropped(key, q, q.remove(victim))
+		default:
+			// Make room by dropping the oldest of the lowest-priority events,
+			// unless they all outrank the incoming one
+			victim := q.oldest(priority)
+			if victim < 0 {
+				d.dropped(key, q, priority)
+				return false
+			}
+			d.dropped(key, q, q.remove(victim))
+		}
+	}
+	if d.closed {
+		return false
+	}
+
+	q.events = append(q.events, queuedEvent{run: run, priority: priority, queued: time.Now()})
+	q.waiting[priority]++
+	metrics.EventQueueDepth.Set(float64(len(q.events)), key)
+	if !q.scheduled {
+		q.scheduled = true
+		d.ready = append(d.ready, key)
+		d.work.Signal()
+	}
+	return true
+}
+
+// sampled reports whether an ambient event should be kept. Once the queue is
+// half full only one in AmbientSample is. Callers hold d.mu.
+func (d *dispatcher) sampled(q *eventQueue) bool {
+	if d.config.AmbientSample <= 1 || len(q.events) < d.config.QueueSize/2 {
+		q.ambient = 0
+		return true
+	}
+	q.ambient++
+	return q.ambient%d.config.AmbientSample == 1
+}
+
+// queue returns key's queue, creating it if needed. Callers hold d.mu.
+func (d *dispatcher) queue(key string) *eventQueue {
+	q, ok := d.queues[key]
+	if !ok {
+		q = &eventQueue{}
+		d.queues[key] = q
+	}
+	return q
+}
+
+// dropped counts a dropped event, warning once per burst. Callers hold d.mu.
+func (d *dispatcher) dropped(key string, q *eventQueue, priority Priority) {
+	metrics.EventsDropped.Inc(key, priority.String())
+	if q.dropped == 0 {
+		logger.Warn("Event queue busy, dropping events", logging.KeyGuildID, key, "queue_size", d.config.QueueSize, "policy", d.config.Overflow, "priority", priority.String())
+	}
+	q.dropped++
+}
+
+// top returns the highest priority waiting in the queue
+func (q *eventQueue) top() Priority {
+	for p := numPriorities - 1; p > PriorityAmbient; p-- {
+		if q.waiting[p] > 0 {
+			return p
+		}
+	}
+	return PriorityAmbient
+}
+
+// lowest returns the lowest priority waiting in the queue, or -1 if it is empty
+func (q *eventQueue) lowest() Priority {
+	for p := PriorityAmbient; p < numPriorities; p++ {
+		if q.waiting[p] > 0 {
+			return p
+		}
+	}
+	return -1
+}
+
+// oldest returns the index of the oldest event at the lowest waiting
+// priority, if that is at most max, and -1 otherwise
+func (q *eventQueue) oldest(max Priority) int {
+	low := q.lowest()
+	if low < 0 || low > max {
+		return -1
+	}
+	for i, event := range q.events {
+		if event.priority == low {
+			return i
+		}
+	}
+	return -1
+}
+
+// newest returns the index of the newest event at the lowest waiting
+// priority, if that is at most max, and -1 otherwise
+func (q *eventQueue) newest(max Priority) int {
+	low := q.lowest()
+	if low < 0 || low > max {
+		return -1
+	}
+	for i := len(q.events) - 1; i >= 0; i-- {
+		if q.events[i].priority == low {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove takes the event at i out of the queue and returns its priority
+func (q *eventQueue) remove(i int) Priority {
+	priority := q.events[i].priority
+	copy(q.events[i:], q.events[i+1:])
+	q.events[len(q.events)-1] = queuedEvent{}
+	q.events = q.events[:len(q.events)-1]
+	q.waiting[priority]--
+	return priority
+}
+
+// next takes the ready key with the highest-priority waiting event, the one
+// that has been ready longest among equals. Callers hold d.mu.
+func (d *dispatcher) next() string {
+	best := 0
+	for i := 1; i < len(d.ready); i++ {
+		if d.queues[d.ready[i]].top() > d.queues[d.ready[best]].top() {
+			best = i
+		}
+	}
+	key := d.ready[best]
+	d.ready = append(d.ready[:best], d.ready[best+1:]...)
+	return key
+}
+
+// worker runs one event at a time, from the key next picks
+func (d *dispatcher) worker() {
+	for {
+		d.mu.Lock()
+		for len(d.ready) == 0 && !d.closed {
+			d.work.Wait()
+		}
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		key := d.next()
+		q := d.queues[key]
+		event := q.events[0]
+		q.remove(0)
+		metrics.EventQueueDepth.Set(float64(len(q.events)), key)
+		d.room.Broadcast()
+		d.mu.Unlock()
+
+		metrics.EventQueueWait.Observe(time.Since(event.queued).Seconds())
+		event.run()
+
+		// Requeue the key behind the others, or retire its queue
+		d.mu.Lock()
+		if len(q.events) > 0 {
+			d.ready = append(d.ready, key)
+			d.work.Signal()
+		} else {
+			if q.dropped > 0 {
+				logger.Info("Event queue drained", logging.KeyGuildID, key, "dropped", q.dropped)
+			}
+			delete(d.queues, key)
+		}
+		d.mu.Unlock()
+	}
+}
+
+// Close stops the workers after the events they are running and discards the
+// waiting ones. Returns how many were discarded. A nil dispatcher discards
+// nothing.
+func (d *dispatcher) Close() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0
+	}
+	d.closed = true
+
+	discarded := 0
+	for key, q := range d.queues {
+		discarded += len(q.events)
+		q.events = nil
+		q.waiting = [numPriorities]int{}
+		metrics.EventQueueDepth.Set(0, key)
+	}
+	d.ready = nil
+	d.work.Broadcast()
+	d.room.Broadcast()
+	return discarded
+}
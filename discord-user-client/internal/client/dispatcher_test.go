@@ -0,0 +1,322 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+)
+
+// gate blocks events until opened, to hold a queue's worker busy
+type gate chan struct{}
+
+func (g gate) wait() { <-g }
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcher_KeepsOrderPerKey(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 4, QueueSize: 1000})
+	defer d.Close()
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		for _, key := range []string{"g1", "g2", "g3"} {
+			i, key := i, key
+			wg.Add(1)
+			d.Submit(key, PriorityAmbient, func() {
+				defer wg.Done()
+				mu.Lock()
+				seen[key] = append(seen[key], i)
+				mu.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+
+	for key, order := range seen {
+		for i, n := range order {
+			if n != i {
+				t.Fatalf("Expected %s events in order, got %v", key, order)
+			}
+		}
+	}
+}
+
+func TestDispatcher_KeysRunInParallel(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 2, QueueSize: 10})
+	defer d.Close()
+
+	blocked := make(gate)
+	defer close(blocked)
+	d.Submit("busy", PriorityAmbient, blocked.wait)
+
+	done := make(chan struct{})
+	d.Submit("quiet", PriorityAmbient, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a busy guild not to hold up another guild")
+	}
+}
+
+func TestDispatcher_Overflow(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   []string
+	}{
+		{OverflowDropOldest, []string{"c", "d"}},
+		{OverflowDropNewest, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			key := "overflow-" + string(tt.policy)
+			d := newDispatcher(DispatchConfig{Workers: 1, QueueSize: 2, Overflow: tt.policy})
+			defer d.Close()
+
+			blocked := make(gate)
+			started := make(chan struct{})
+			d.Submit(key, PriorityAmbient, func() { close(started); blocked.wait() })
+			<-started
+
+			before := metrics.EventsDropped.Value(key, "ambient")
+			var mu sync.Mutex
+			var ran []string
+			for _, name := range []string{"a", "b", "c", "d"} {
+				name := name
+				d.Submit(key, PriorityAmbient, func() {
+					mu.Lock()
+					ran = append(ran, name)
+					mu.Unlock()
+				})
+			}
+			if got := metrics.EventsDropped.Value(key, "ambient") - before; got != 2 {
+				t.Errorf("Expected 2 drops counted, got %v", got)
+			}
+			if got := metrics.EventQueueDepth.Value(key); got != 2 {
+				t.Errorf("Expected a queue depth of 2, got %v", got)
+			}
+
+			// Once the queue drains, a last event runs after the survivors
+			close(blocked)
+			waitFor(t, "the queue to drain", func() bool { return metrics.EventQueueDepth.Value(key) == 0 })
+			done := make(chan struct{})
+			d.Submit(key, PriorityAmbient, func() { close(done) })
+			<-done
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(ran) != 2 || ran[0] != tt.want[0] || ran[1] != tt.want[1] {
+				t.Errorf("Expected %v to run, got %v", tt.want, ran)
+			}
+		})
+	}
+}
+
+func TestDispatcher_OverflowBlock(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 1, QueueSize: 1, Overflow: OverflowBlock})
+	defer d.Close()
+
+	blocked := make(gate)
+	started := make(chan struct{})
+	d.Submit("g1", PriorityAmbient, func() { close(started); blocked.wait() })
+	<-started
+	d.Submit("g1", PriorityAmbient, func() {})
+
+	submitted := make(chan bool)
+	go func() { submitted <- d.Submit("g1", PriorityAmbient, func() {}) }()
+	select {
+	case <-submitted:
+		t.Fatal("Expected Submit to wait for room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(blocked)
+	if !<-submitted {
+		t.Error("Expected the event to be queued once there was room")
+	}
+}
+
+func TestDispatcher_OverflowByPriority(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		ambient float64 // ambient events dropped
+	}{
+		// a and b make room, then c and d, and e ranks below everything left
+		{OverflowDropOldest, 5},
+		// c, d and e can't get in, while s and x push out b and a
+		{OverflowDropNewest, 5},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			key := "priority-" + string(tt.policy)
+			d := newDispatcher(DispatchConfig{Workers: 1, QueueSize: 3, Overflow: tt.policy})
+			defer d.Close()
+
+			blocked := make(gate)
+			started := make(chan struct{})
+			d.Submit(key, PriorityMention, func() { close(started); blocked.wait() })
+			<-started
+
+			before := metrics.EventsDropped.Value(key, "ambient")
+			var mu sync.Mutex
+			var ran []string
+			submit := func(name string, priority Priority) {
+				d.Submit(key, priority, func() {
+					mu.Lock()
+					ran = append(ran, name)
+					mu.Unlock()
+				})
+			}
+			submit("a", PriorityAmbient)
+			submit("m", PriorityMention)
+			submit("b", PriorityAmbient)
+			submit("c", PriorityAmbient)
+			submit("s", PrioritySupport)
+			submit("d", PriorityAmbient)
+			submit("x", PriorityMention)
+			submit("e", PriorityAmbient)
+			if got := metrics.EventsDropped.Value(key, "ambient") - before; got != tt.ambient {
+				t.Errorf("Expected %v ambient drops, got %v", tt.ambient, got)
+			}
+
+			close(blocked)
+			waitFor(t, "the queue to drain", func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(ran) == 3
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(ran, "") != "msx" {
+				t.Errorf("Expected only m, s and x to run, in order, got %v", ran)
+			}
+		})
+	}
+}
+
+func TestDispatcher_HighestPriorityKeyFirst(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 1, QueueSize: 10})
+	defer d.Close()
+
+	blocked := make(gate)
+	started := make(chan struct{})
+	d.Submit("busy", PriorityAmbient, func() { close(started); blocked.wait() })
+	<-started
+
+	order := make(chan string, 3)
+	d.Submit("chatty", PriorityAmbient, func() { order <- "chatty" })
+	d.Submit("support", PrioritySupport, func() { order <- "support" })
+	d.Submit("mentioned", PriorityMention, func() { order <- "mentioned" })
+	close(blocked)
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		got = append(got, <-order)
+	}
+	if strings.Join(got, " ") != "mentioned support chatty" {
+		t.Errorf("Expected guilds served by priority, got %v", got)
+	}
+}
+
+func TestDispatcher_SamplesAmbientUnderLoad(t *testing.T) {
+	key := "sampled"
+	d := newDispatcher(DispatchConfig{Workers: 1, QueueSize: 8, AmbientSample: 4})
+	defer d.Close()
+
+	blocked := make(gate)
+	defer close(blocked)
+	started := make(chan struct{})
+	d.Submit(key, PriorityAmbient, func() { close(started); blocked.wait() })
+	<-started
+
+	before := metrics.EventsDropped.Value(key, "ambient")
+	kept := 0
+	// The first 4 fill half the queue; after that 1 in 4 ambient events is kept
+	for i := 0; i < 12; i++ {
+		if d.Submit(key, PriorityAmbient, func() {}) {
+			kept++
+		}
+	}
+	if kept != 6 {
+		t.Errorf("Expected 6 ambient events kept, got %d", kept)
+	}
+	if got := metrics.EventsDropped.Value(key, "ambient") - before; got != 6 {
+		t.Errorf("Expected 6 sampled-out events counted, got %v", got)
+	}
+	if !d.Submit(key, PriorityMention, func() {}) {
+		t.Error("Expected a mention to be kept under load")
+	}
+}
+
+func TestDispatcher_Close(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 1, QueueSize: 10, Overflow: OverflowBlock})
+
+	blocked := make(gate)
+	started := make(chan struct{})
+	d.Submit("g1", PriorityAmbient, func() { close(started); blocked.wait() })
+	<-started
+	for i := 0; i < 3; i++ {
+		d.Submit("g1", PriorityAmbient, func() { t.Error("Expected waiting events to be discarded") })
+	}
+
+	if got := d.Close(); got != 3 {
+		t.Errorf("Expected 3 discarded events, got %d", got)
+	}
+	if d.Submit("g1", PriorityAmbient, func() {}) {
+		t.Error("Expected a closed dispatcher to refuse events")
+	}
+	close(blocked)
+
+	var nilDispatcher *dispatcher
+	if got := nilDispatcher.Close(); got != 0 {
+		t.Errorf("Expected a nil dispatcher to discard nothing, got %d", got)
+	}
+}
+
+func TestEventPipeline_OrderAndFiltering(t *testing.T) {
+	client, fake := attachFake(t, nil)
+	defer client.Disconnect()
+
+	// The first message initializes the channel from Discord's history
+	if _, err := fake.Post("c1", "u1", "first"); err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	waitFor(t, "the first message", func() bool { return len(client.historyManager.GetMessages("c1")) == 1 })
+
+	// Messages from guilds the client doesn't monitor are never queued
+	client.UpdateMonitoredGuilds(nil)
+	for i := 0; i < 20; i++ {
+		fake.Post("c1", "u1", "ignored")
+	}
+	client.UpdateMonitoredGuilds([]string{"g1"})
+
+	var want []string
+	for i := 0; i < 30; i++ {
+		content := fmt.Sprintf("m%d", i)
+		want = append(want, content)
+		fake.Post("c1", "u1", content)
+	}
+	waitFor(t, "every message", func() bool { return len(client.historyManager.GetMessages("c1")) >= 31 })
+
+	var got []string
+	for _, msg := range client.historyManager.GetMessages("c1")[1:] {
+		got = append(got, msg.Content)
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected messages in the order they were sent, got %v", got)
+	}
+}
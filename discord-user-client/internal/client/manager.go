@@ -1,32 +1,53 @@
 package client
 
 import (
-	"log"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
 // GuildConfigWithUser combines server config with user info for internal tracking
 type GuildConfigWithUser struct {
 	types.ServerConfig
-	UserID string
-	Token  string
+	UserID           string
+	TokenFingerprint string
 }
 
+// TokenFingerprint returns an opaque, stable identifier for a Discord token.
+// It is what logs and the HTTP API use to refer to an account; the token
+// itself never leaves the client.
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "tok_" + hex.EncodeToString(sum[:8])
+}
+
+// managerLogger is used for token sync events
+var managerLogger = logging.For("client-manager")
+
 // ClientManager manages multiple Discord clients with token deduplication
 // Multiple users can share the same Discord token, but we only create one
 // Discord connection per unique token.
 type ClientManager struct {
 	mu sync.RWMutex
 
-	// One Discord client per unique token
-	clients map[string]*DiscordClient // token -> client
+	// One Discord client per unique token, keyed by token fingerprint
+	clients map[string]*DiscordClient // fingerprint -> client
 
 	// Track which users have which token
-	tokenUsers map[string][]string // token -> []userID
+	tokenUsers map[string][]string // fingerprint -> []userID
+
+	// Reverse lookup for addressing accounts by user ID
+	userTokens map[string]string // userID -> fingerprint
 
 	// Track config per guild (guildID -> config with user info)
 	// This allows different users to select different guilds with the same token
@@ -35,17 +56,99 @@ type ClientManager struct {
 	// Dependencies
 	humaManager   *huma.Manager
 	backendClient *backend.Client
+	configSource  backend.ConfigSource
+	stats         *backend.StatsReporter
+	pauses        *pause.Store
+	historySize   int // messages kept per channel; 0 keeps the history default
+	dispatch      DispatchConfig
+	debounce      time.Duration
+	recorder      *recording.Recorder
+	connect       Connector
+
+	// When token configs were last applied, for health checks
+	lastSync time.Time
+
+	// Set at shutdown; config syncs and gateway events are ignored from then on
+	stopped bool
 }
 
 // NewClientManager creates a new client manager
 func NewClientManager(humaManager *huma.Manager, backendClient *backend.Client) *ClientManager {
-	return &ClientManager{
+	m := &ClientManager{
 		clients:       make(map[string]*DiscordClient),
 		tokenUsers:    make(map[string][]string),
+		userTokens:    make(map[string]string),
 		guildConfigs:  make(map[string]GuildConfigWithUser),
 		humaManager:   humaManager,
 		backendClient: backendClient,
+		dispatch:      DefaultDispatchConfig(),
+		connect:       (*DiscordClient).ConnectWithToken,
+	}
+	if backendClient != nil {
+		m.configSource = backendClient
 	}
+	return m
+}
+
+// SetConfigSource sets where Resync fetches token configs from, replacing the backend
+func (m *ClientManager) SetConfigSource(source backend.ConfigSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configSource = source
+}
+
+// GetConfigSource returns where token configs come from, nil if nowhere
+func (m *ClientManager) GetConfigSource() backend.ConfigSource {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.configSource
+}
+
+// SetStatsReporter sets the stats reporter passed to new Discord clients
+func (m *ClientManager) SetStatsReporter(stats *backend.StatsReporter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = stats
+}
+
+// SetHistorySize sets how many messages new Discord clients keep per channel
+func (m *ClientManager) SetHistorySize(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.historySize = size
+}
+
+// SetDispatchConfig sizes the gateway event pipeline of new Discord clients
+func (m *ClientManager) SetDispatchConfig(config DispatchConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dispatch = config
+}
+
+// SetDebounceWindow sets how long new Discord clients batch a channel's
+// messages before sending them to HUMA
+func (m *ClientManager) SetDebounceWindow(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.debounce = window
+}
+
+// SetRecorder records guild configs and the traffic of new Discord clients
+func (m *ClientManager) SetRecorder(recorder *recording.Recorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recorder = recorder
+}
+
+// Connector connects a new client for a token. The default opens a gateway
+// session with ConnectWithToken; tests and the sandbox attach a fake session.
+type Connector func(client *DiscordClient, token string) error
+
+// SetConnector replaces how new clients are connected
+func (m *ClientManager) SetConnector(connect Connector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connect = connect
 }
 
 // SyncTokenConfigs synchronizes the manager state with the provided token configs
@@ -54,67 +157,196 @@ func (m *ClientManager) SyncTokenConfigs(tokenConfigs []types.TokenConfig) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Don't connect new accounts while shutting down
+	if m.stopped {
+		return
+	}
+
 	// Build maps of new state
-	newTokenUsers := make(map[string][]string)              // token -> []userID
+	newTokens := make(map[string]string)                    // fingerprint -> token
+	newTokenUsers := make(map[string][]string)              // fingerprint -> []userID
+	newUserTokens := make(map[string]string)                // userID -> fingerprint
 	newGuildConfigs := make(map[string]GuildConfigWithUser) // guildID -> config
-	tokenToGuilds := make(map[string][]string)              // token -> []guildID (active guilds only)
+	tokenToGuilds := make(map[string][]string)              // fingerprint -> []guildID (active guilds only)
 
 	for _, tc := range tokenConfigs {
 		if tc.DiscordToken == "" {
 			continue
 		}
+		fingerprint := TokenFingerprint(tc.DiscordToken)
+		newTokens[fingerprint] = tc.DiscordToken
 
 		// Track user->token mapping
-		newTokenUsers[tc.DiscordToken] = append(newTokenUsers[tc.DiscordToken], tc.UserID)
+		newTokenUsers[fingerprint] = append(newTokenUsers[fingerprint], tc.UserID)
+		newUserTokens[tc.UserID] = fingerprint
 
 		// Track guild->config mapping for all servers
 		for _, server := range tc.Servers {
 			newGuildConfigs[server.GuildID] = GuildConfigWithUser{
-				ServerConfig: server,
-				UserID:       tc.UserID,
-				Token:        tc.DiscordToken,
+				ServerConfig:     server,
+				UserID:           tc.UserID,
+				TokenFingerprint: fingerprint,
 			}
 
 			// Only add to monitored guilds if bot is active
 			if server.BotActive {
-				tokenToGuilds[tc.DiscordToken] = append(tokenToGuilds[tc.DiscordToken], server.GuildID)
+				tokenToGuilds[fingerprint] = append(tokenToGuilds[fingerprint], server.GuildID)
 			}
 		}
 	}
 
 	// Find tokens to remove (exist in current but not in new)
-	for token := range m.clients {
-		if _, exists := newTokenUsers[token]; !exists {
-			log.Printf("[ClientManager] Token removed, disconnecting...")
-			m.clients[token].Disconnect()
-			delete(m.clients, token)
+	for fingerprint, client := range m.clients {
+		if _, exists := newTokenUsers[fingerprint]; !exists {
+			managerLogger.Info("Token removed, disconnecting", "account", fingerprint)
+			client.Disconnect()
+			delete(m.clients, fingerprint)
+			logging.RemoveSecret(client.GetToken())
 		}
 	}
 
 	// Find tokens to add (exist in new but not in current)
-	for token, userIDs := range newTokenUsers {
-		if _, exists := m.clients[token]; !exists {
-			log.Printf("[ClientManager] New token detected (users: %v), connecting...", userIDs)
+	for fingerprint, userIDs := range newTokenUsers {
+		if _, exists := m.clients[fingerprint]; !exists {
+			token := newTokens[fingerprint]
+			logging.AddSecret(token)
+			managerLogger.Info("New token detected, connecting", "account", fingerprint, "users", userIDs)
 			client := NewMultiGuildDiscordClient(m.humaManager, m.backendClient, m)
-			if err := client.ConnectWithToken(token); err != nil {
-				log.Printf("[ClientManager] Error connecting: %v", err)
+			client.SetStatsReporter(m.stats)
+			client.SetPauseStore(m.pauses)
+			client.SetRecorder(m.recorder)
+			client.SetDispatchConfig(m.dispatch)
+			client.SetDebounceWindow(m.debounce)
+			if m.historySize > 0 {
+				client.SetHistorySize(m.historySize)
+			}
+			if err := m.connect(client, token); err != nil {
+				managerLogger.Error("Error connecting", "account", fingerprint, "users", userIDs, "error", err)
 				continue
 			}
-			m.clients[token] = client
+			m.clients[fingerprint] = client
 		}
 	}
 
 	// Update internal state
 	m.tokenUsers = newTokenUsers
+	m.userTokens = newUserTokens
 	m.guildConfigs = newGuildConfigs
+	m.lastSync = time.Now()
+	if m.recorder != nil {
+		m.recorder.Config(sortedGuildConfigs(newGuildConfigs))
+	}
 
 	// Update all active clients with their monitored guilds
-	for token, client := range m.clients {
-		guilds := tokenToGuilds[token]
+	for fingerprint, client := range m.clients {
+		guilds := tokenToGuilds[fingerprint]
 		client.UpdateMonitoredGuilds(guilds)
 	}
 }
 
+// Resync fetches token configs from the config source and applies them
+// immediately, instead of waiting for the next poll
+func (m *ClientManager) Resync() (int, error) {
+	source := m.GetConfigSource()
+	if source == nil {
+		return 0, fmt.Errorf("no config source configured")
+	}
+
+	tokenConfigs, err := source.FetchTokenConfigs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch token configs: %w", err)
+	}
+
+	m.SyncTokenConfigs(tokenConfigs)
+	return len(tokenConfigs), nil
+}
+
+// AccountState is a point-in-time view of a Discord connection for the admin API
+type AccountState struct {
+	Fingerprint        string     `json:"fingerprint"`
+	UserIDs            []string   `json:"userIds"`
+	Username           string     `json:"username,omitempty"`
+	Connected          bool       `json:"connected"`
+	GatewayReady       bool       `json:"gatewayReady"`
+	LastHeartbeatAck   *time.Time `json:"lastHeartbeatAck,omitempty"`
+	HeartbeatLatencyMs int64      `json:"heartbeatLatencyMs"`
+	MonitoredGuilds    []string   `json:"monitoredGuilds"`
+}
+
+// GetAccounts returns the state of every Discord connection, sorted by fingerprint
+func (m *ClientManager) GetAccounts() []AccountState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	accounts := make([]AccountState, 0, len(m.clients))
+	for fingerprint, client := range m.clients {
+		state := AccountState{
+			Fingerprint:     fingerprint,
+			UserIDs:         append([]string{}, m.tokenUsers[fingerprint]...),
+			Username:        client.GetBotUsername(),
+			MonitoredGuilds: client.GetMonitoredGuildIDs(),
+		}
+		if session := client.GetSession(); session != nil {
+			state.Connected = true
+			state.GatewayReady = session.GatewayReady()
+			if sent, ack := session.LastHeartbeat(); !ack.IsZero() {
+				state.LastHeartbeatAck = &ack
+				state.HeartbeatLatencyMs = ack.Sub(sent).Milliseconds()
+			}
+		}
+		accounts = append(accounts, state)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Fingerprint < accounts[j].Fingerprint
+	})
+	return accounts
+}
+
+// GetGuildConfigs returns all known guild configs, sorted by guild ID
+func (m *ClientManager) GetGuildConfigs() []GuildConfigWithUser {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return sortedGuildConfigs(m.guildConfigs)
+}
+
+// sortedGuildConfigs returns guild configs sorted by guild ID
+func sortedGuildConfigs(guildConfigs map[string]GuildConfigWithUser) []GuildConfigWithUser {
+	configs := make([]GuildConfigWithUser, 0, len(guildConfigs))
+	for _, config := range guildConfigs {
+		configs = append(configs, config)
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].GuildID < configs[j].GuildID
+	})
+	return configs
+}
+
+// GetLastSync returns when token configs were last applied, zero if never
+func (m *ClientManager) GetLastSync() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lastSync
+}
+
+// GetBackendClient returns the backend client, nil if none is configured
+func (m *ClientManager) GetBackendClient() *backend.Client {
+	return m.backendClient
+}
+
+// GetStatsReporter returns the stats reporter, nil if none is configured
+func (m *ClientManager) GetStatsReporter() *backend.StatsReporter {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stats
+}
+
+// GetHumaManager returns the HUMA manager shared by all clients
+func (m *ClientManager) GetHumaManager() *huma.Manager {
+	return m.humaManager
+}
+
 // GetConfigForGuild returns the config for a specific guild
 func (m *ClientManager) GetConfigForGuild(guildID string) (GuildConfigWithUser, bool) {
 	m.mu.RLock()
@@ -149,24 +381,65 @@ func (m *ClientManager) GetMonitoredGuildCount() int {
 	return len(m.guildConfigs)
 }
 
+// GetHistoryStats returns the number of cached channels and messages across all clients
+func (m *ClientManager) GetHistoryStats() (channels int, messages int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, client := range m.clients {
+		c, msgs := client.historyManager.GetCacheStats()
+		channels += c
+		messages += msgs
+	}
+	return channels, messages
+}
+
+// GetAgentCount returns the number of live HUMA agents
+func (m *ClientManager) GetAgentCount() int {
+	if m.humaManager == nil {
+		return 0
+	}
+	return m.humaManager.GetAgentCount()
+}
+
+// StopAcceptingEvents stops every client from taking new Discord messages and
+// ignores further config syncs. Sessions stay open until DisconnectAll.
+func (m *ClientManager) StopAcceptingEvents() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stopped = true
+	for _, client := range m.clients {
+		client.StopAcceptingEvents()
+	}
+}
+
 // DisconnectAll disconnects all clients
 func (m *ClientManager) DisconnectAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for token, client := range m.clients {
-		log.Printf("[ClientManager] Disconnecting client for token...")
+	for fingerprint, client := range m.clients {
+		managerLogger.Info("Disconnecting client", "account", fingerprint)
 		client.Disconnect()
-		delete(m.clients, token)
+		delete(m.clients, fingerprint)
 	}
 
 	m.tokenUsers = make(map[string][]string)
+	m.userTokens = make(map[string]string)
 	m.guildConfigs = make(map[string]GuildConfigWithUser)
 }
 
-// GetClientByToken returns the client for a specific Discord token
-func (m *ClientManager) GetClientByToken(token string) *DiscordClient {
+// GetClientByFingerprint returns the client for a token fingerprint
+func (m *ClientManager) GetClientByFingerprint(fingerprint string) *DiscordClient {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clients[fingerprint]
+}
+
+// GetClientByUserID returns the client for the Discord account a user connected
+func (m *ClientManager) GetClientByUserID(userID string) *DiscordClient {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.clients[token]
+	return m.clients[m.userTokens[userID]]
 }
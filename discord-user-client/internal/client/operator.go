@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+)
+
+// ErrInvalidTarget is returned when an operator action names a channel or guild
+// the account can't act in
+var ErrInvalidTarget = errors.New("invalid target")
+
+// SimulatedMessage is a synthetic inbound message injected by an operator
+type SimulatedMessage struct {
+	ChannelID  string
+	AuthorID   string
+	AuthorName string
+	Content    string
+}
+
+// GetClientForGuild returns the client of the account that monitors a guild
+func (m *ClientManager) GetClientForGuild(guildID string) *DiscordClient {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	config, exists := m.guildConfigs[guildID]
+	if !exists {
+		return nil
+	}
+	return m.clients[config.TokenFingerprint]
+}
+
+// checkGuildChannel verifies that a channel exists and belongs to the guild,
+// so operator actions can't land in another server
+func (dc *DiscordClient) checkGuildChannel(guildID, channelID string) error {
+	if dc.session == nil {
+		return fmt.Errorf("no active Discord session")
+	}
+	channel, err := dc.session.Channel(channelID)
+	if err != nil {
+		return fmt.Errorf("%w: failed to look up channel: %v", ErrInvalidTarget, err)
+	}
+	if channel.GuildID != guildID {
+		return fmt.Errorf("%w: channel %s is not in guild %s", ErrInvalidTarget, channelID, guildID)
+	}
+	return nil
+}
+
+// SendAsAgent posts a message as the account on an operator's behalf. The
+// message is added to history and reported like a message the agent sent.
+func (dc *DiscordClient) SendAsAgent(ctx context.Context, guildID, channelID, content string) (*discordgo.Message, error) {
+	if err := dc.checkGuildChannel(guildID, channelID); err != nil {
+		return nil, err
+	}
+	settings, ok := dc.guildSettings(guildID)
+	if !ok {
+		return nil, fmt.Errorf("%w: bot is not active in guild %s", ErrInvalidTarget, guildID)
+	}
+
+	ctx, span := tracing.Start(ctx, "operator.send")
+	defer span.End()
+	span.SetAttribute("guild.id", guildID)
+	span.SetAttribute("channel.id", channelID)
+
+	sent, err := dc.sendAsAccount(channelID, content)
+	if err != nil {
+		metrics.DiscordSendFailures.Inc(sendFailureReason(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("error sending message to Discord: %w", err)
+	}
+
+	// Our own messages never come back through the gateway handler
+	if dc.historyManager.IsChannelInitialized(channelID) {
+		sent.GuildID = guildID
+		dc.historyManager.AddMessage(&discordgo.MessageCreate{Message: sent})
+	}
+
+	if dc.humaManager == nil {
+		return sent, nil
+	}
+	agent, err := dc.humaManager.GetOrCreateAgent(guildID, settings.guildName, settings.userID)
+	if err != nil {
+		// The message is out; only the reporting is lost
+		logger.WarnContext(ctx, "Operator message sent but not recorded", logging.KeyGuildID, guildID, logging.KeyChannelID, channelID, "error", err)
+		return sent, nil
+	}
+	agent.UpdateConfig(dc, dc.historyManager, settings.personality, settings.rules, settings.information, settings.websites)
+	agent.RecordOperatorSend(ctx, channelID, dc.channelName(channelID), content)
+	return sent, nil
+}
+
+// newSimulatedMessage builds a MessageCreate event for a synthetic message
+func newSimulatedMessage(guildID string, sim SimulatedMessage) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        fmt.Sprintf("sim-%d", time.Now().UnixNano()),
+			ChannelID: sim.ChannelID,
+			GuildID:   guildID,
+			Content:   sim.Content,
+			Author: &discordgo.User{
+				ID:       sim.AuthorID,
+				Username: sim.AuthorName,
+			},
+			Timestamp: time.Now(),
+		},
+	}
+}
+
+// InjectMessage runs a synthetic message through the live pipeline as if it had
+// arrived from Discord: it is filtered, classified and queued behind the
+// guild's other events like a real one. The agent may respond for real.
+// Returns the trace ID.
+func (dc *DiscordClient) InjectMessage(ctx context.Context, guildID string, sim SimulatedMessage) (string, error) {
+	if err := dc.checkGuildChannel(guildID, sim.ChannelID); err != nil {
+		return "", err
+	}
+	if !dc.isFromSelectedGuild(guildID) {
+		return "", fmt.Errorf("%w: guild %s is not monitored", ErrInvalidTarget, guildID)
+	}
+	dc.mu.RLock()
+	pipeline := dc.pipeline
+	dc.mu.RUnlock()
+	state := dc.session.State()
+	if pipeline == nil || state == nil || state.User == nil {
+		return "", fmt.Errorf("no active Discord session")
+	}
+
+	// The message is handled after the request returns
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "operator.simulate")
+	defer span.End()
+
+	logger.InfoContext(ctx, "Injecting simulated message", logging.KeyGuildID, guildID, logging.KeyChannelID, sim.ChannelID)
+	dc.dispatchMessage(ctx, pipeline, state.User.ID, newSimulatedMessage(guildID, sim))
+	return span.TraceID(), nil
+}
+
+// DryRunMessage shows what the agent would do with a synthetic message without
+// posting anything. It uses the guild's dry-run HUMA agent and a copy of the history,
+// so live agents and the history cache are untouched.
+func (dc *DiscordClient) DryRunMessage(ctx context.Context, guildID string, sim SimulatedMessage, wait time.Duration) (*huma.SimulationResult, error) {
+	if err := dc.checkGuildChannel(guildID, sim.ChannelID); err != nil {
+		return nil, err
+	}
+	if dc.humaManager == nil {
+		return nil, fmt.Errorf("no HUMA manager available")
+	}
+	settings, ok := dc.guildSettings(guildID)
+	if !ok {
+		return nil, fmt.Errorf("%w: bot is not active in guild %s", ErrInvalidTarget, guildID)
+	}
+
+	ctx, span := tracing.Start(ctx, "operator.dry_run")
+	defer span.End()
+
+	history := dc.historyManager.Clone()
+	if !history.IsChannelInitialized(sim.ChannelID) {
+		if err := history.InitializeChannel(dc.session, sim.ChannelID, 50); err != nil {
+			logger.WarnContext(ctx, "Failed to initialize channel history for dry run", logging.KeyChannelID, sim.ChannelID, "error", err)
+		}
+	}
+	history.AddMessage(newSimulatedMessage(guildID, sim))
+
+	agent, release, err := dc.humaManager.NewDryRunAgent(guildID, settings.guildName, settings.userID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer release()
+	agent.UpdateConfig(dc, history, settings.personality, settings.rules, settings.information, settings.websites)
+
+	result, err := agent.DryRun(ctx, sim.ChannelID, dc.channelName(sim.ChannelID), sim.AuthorName, sim.Content, wait)
+	span.RecordError(err)
+	return result, err
+}
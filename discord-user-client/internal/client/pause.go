@@ -0,0 +1,219 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+)
+
+// ownerCommandPrefix starts a command typed by the account owner in Discord, e.g.
+// "!neonrain pause", "!neonrain pause guild spam wave", "!neonrain resume guild"
+const ownerCommandPrefix = "!neonrain"
+
+// agentSendsMax bounds how many sent message IDs are remembered
+const agentSendsMax = 1000
+
+// agentSends remembers the messages the agent and operators posted as the
+// account, so they can't pass for the owner's commands. The gateway may echo a
+// message before its send returns the ID, so sends still in flight are matched
+// by channel and content. The zero value is ready to use.
+type agentSends struct {
+	mu       sync.Mutex
+	ids      map[string]bool
+	order    []string       // oldest first
+	inFlight map[string]int // channelID + content -> sends not yet returned
+}
+
+func agentSendKey(channelID, content string) string {
+	return channelID + "\x00" + content
+}
+
+// start marks a send as in flight
+func (s *agentSends) start(channelID, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight == nil {
+		s.inFlight = make(map[string]int)
+	}
+	s.inFlight[agentSendKey(channelID, content)]++
+}
+
+// finish ends an in-flight send and remembers the message it posted, if any
+func (s *agentSends) finish(channelID, content string, sent *discordgo.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := agentSendKey(channelID, content)
+	if s.inFlight[key]--; s.inFlight[key] <= 0 {
+		delete(s.inFlight, key)
+	}
+	if sent == nil || sent.ID == "" {
+		return
+	}
+	if s.ids == nil {
+		s.ids = make(map[string]bool)
+	}
+	s.ids[sent.ID] = true
+	s.order = append(s.order, sent.ID)
+	if len(s.order) > agentSendsMax {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// contains reports whether a message was posted by the agent or an operator
+func (s *agentSends) contains(msg *discordgo.Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids[msg.ID] || s.inFlight[agentSendKey(msg.ChannelID, msg.Content)] > 0
+}
+
+// sendAsAccount posts a message for the agent or an operator, remembering it
+// so it is never taken for an owner command
+func (dc *DiscordClient) sendAsAccount(channelID, content string) (*discordgo.Message, error) {
+	dc.agentSends.start(channelID, content)
+	sent, err := dc.session.ChannelMessageSend(channelID, content)
+	dc.agentSends.finish(channelID, content, sent)
+	return sent, err
+}
+
+// applyPause records a pause and cancels any send already in flight for it
+func applyPause(store *pause.Store, humaManager *huma.Manager, guildID, channelID, reason, pausedBy string) (pause.Entry, error) {
+	entry, err := store.Pause(guildID, channelID, reason, pausedBy)
+	if err != nil {
+		return entry, err
+	}
+
+	logger.Info("Paused", logging.KeyGuildID, guildID, logging.KeyChannelID, channelID, "reason", reason, "by", pausedBy)
+	if humaManager != nil {
+		for _, canceled := range humaManager.CancelPaused(guildID) {
+			logger.Info("Canceled pending send for paused channel", logging.KeyGuildID, guildID, logging.KeyChannelID, canceled.ChannelID, "tool_call_id", canceled.ToolCallID)
+		}
+	}
+	return entry, nil
+}
+
+// SetPauseStore sets the kill switch shared by all clients and HUMA agents
+func (m *ClientManager) SetPauseStore(store *pause.Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauses = store
+	for _, client := range m.clients {
+		client.SetPauseStore(store)
+	}
+	if m.humaManager != nil {
+		m.humaManager.SetPauseChecker(store)
+	}
+}
+
+// Pause pauses a guild, or one channel in it, and cancels pending sends there
+func (m *ClientManager) Pause(guildID, channelID, reason, pausedBy string) (pause.Entry, error) {
+	if m.pauses == nil {
+		return pause.Entry{}, fmt.Errorf("no pause store configured")
+	}
+	return applyPause(m.pauses, m.humaManager, guildID, channelID, reason, pausedBy)
+}
+
+// Resume lifts a pause. Returns false if there was no such pause.
+func (m *ClientManager) Resume(guildID, channelID string) (bool, error) {
+	if m.pauses == nil {
+		return false, fmt.Errorf("no pause store configured")
+	}
+	resumed, err := m.pauses.Resume(guildID, channelID)
+	if resumed {
+		logger.Info("Resumed", logging.KeyGuildID, guildID, logging.KeyChannelID, channelID)
+	}
+	return resumed, err
+}
+
+// GetPauses returns all active pauses
+func (m *ClientManager) GetPauses() []pause.Entry {
+	return m.pauses.List()
+}
+
+// SetPauseStore sets the kill switch checked before forwarding messages to HUMA
+func (dc *DiscordClient) SetPauseStore(store *pause.Store) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.pauses = store
+}
+
+// isPaused reports whether messages in a channel should be kept from HUMA
+func (dc *DiscordClient) isPaused(guildID, channelID string) bool {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	return dc.pauses.IsPaused(guildID, channelID)
+}
+
+// handleOwnerCommand runs a pause/resume command typed by the account owner.
+// Messages the agent or an operator sent are never commands. Returns false if
+// the message is not a command.
+func (dc *DiscordClient) handleOwnerCommand(msg *discordgo.MessageCreate) bool {
+	fields := strings.Fields(msg.Content)
+	if len(fields) < 2 || fields[0] != ownerCommandPrefix || msg.GuildID == "" {
+		return false
+	}
+	if dc.agentSends.contains(msg.Message) {
+		logger.Warn("Ignoring owner command sent by the agent", logging.KeyGuildID, msg.GuildID, logging.KeyChannelID, msg.ChannelID)
+		return false
+	}
+	if !dc.isFromSelectedGuild(msg.GuildID) {
+		return false
+	}
+
+	dc.mu.RLock()
+	store := dc.pauses
+	dc.mu.RUnlock()
+
+	// Commands apply to the channel they are typed in unless "guild" follows
+	action := fields[1]
+	channelID := msg.ChannelID
+	rest := fields[2:]
+	if len(rest) > 0 && rest[0] == "guild" {
+		channelID = ""
+		rest = rest[1:]
+	}
+
+	cmdLogger := logger.With(logging.KeyGuildID, msg.GuildID, logging.KeyChannelID, msg.ChannelID)
+	var err error
+	switch action {
+	case "pause":
+		if store == nil {
+			err = fmt.Errorf("no pause store configured")
+			break
+		}
+		_, err = applyPause(store, dc.humaManager, msg.GuildID, channelID, strings.Join(rest, " "), "owner:"+dc.fingerprint)
+	case "resume":
+		if store == nil {
+			err = fmt.Errorf("no pause store configured")
+			break
+		}
+		var resumed bool
+		resumed, err = store.Resume(msg.GuildID, channelID)
+		if err == nil && !resumed {
+			err = fmt.Errorf("not paused")
+		}
+		if resumed {
+			cmdLogger.Info("Resumed", "guild_wide", channelID == "")
+		}
+	default:
+		return false
+	}
+
+	// Acknowledge with a reaction rather than a message so the channel stays quiet
+	reaction := "✅"
+	if err != nil {
+		cmdLogger.Warn("Owner command failed", "command", action, "error", err)
+		reaction = "❌"
+	}
+	if dc.session != nil {
+		if reactErr := dc.session.MessageReactionAdd(msg.ChannelID, msg.ID, reaction); reactErr != nil {
+			cmdLogger.Warn("Failed to acknowledge owner command", "error", reactErr)
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Priority ranks an inbound message by how directly it involves the account.
+// Higher priorities are scheduled first and dropped last under load.
+type Priority int
+
+const (
+	// PriorityAmbient is chatter that doesn't involve the account
+	PriorityAmbient Priority = iota
+	// PrioritySupport is a message in a support or help channel
+	PrioritySupport
+	// PriorityName is a message that uses the account's name without a mention
+	PriorityName
+	// PriorityReply is a Discord reply to one of the account's messages
+	PriorityReply
+	// PriorityMention is a message that @mentions the account
+	PriorityMention
+
+	// numPriorities is the number of priorities, for per-priority counts
+	numPriorities
+)
+
+// String returns the name used in logs, metrics and the HUMA context
+func (p Priority) String() string {
+	switch p {
+	case PrioritySupport:
+		return "support"
+	case PriorityName:
+		return "name"
+	case PriorityReply:
+		return "reply"
+	case PriorityMention:
+		return "mention"
+	default:
+		return "ambient"
+	}
+}
+
+// supportChannelKeywords mark channels where questions are expected
+var supportChannelKeywords = []string{"support", "help", "ticket", "question"}
+
+// Classification is what the client can tell about a message before HUMA
+// sees it
+type Classification struct {
+	MentionsYou    bool
+	IsReplyToYou   bool
+	NameMentioned  bool
+	SupportChannel bool
+}
+
+// Priority is the highest priority the classification qualifies for
+func (c Classification) Priority() Priority {
+	switch {
+	case c.MentionsYou:
+		return PriorityMention
+	case c.IsReplyToYou:
+		return PriorityReply
+	case c.NameMentioned:
+		return PriorityName
+	case c.SupportChannel:
+		return PrioritySupport
+	default:
+		return PriorityAmbient
+	}
+}
+
+// Direct reports whether the message is addressed to the account
+func (c Classification) Direct() bool {
+	return c.MentionsYou || c.IsReplyToYou
+}
+
+// classify works out how a message involves the account. It only reads
+// gateway state and the history cache, so it is cheap enough to run as events
+// arrive.
+func (dc *DiscordClient) classify(msg *discordgo.MessageCreate) Classification {
+	var c Classification
+	if dc.session == nil {
+		return c
+	}
+	state := dc.session.State()
+	if state == nil || state.User == nil {
+		return c
+	}
+	self := state.User
+
+	for _, user := range msg.Mentions {
+		if user != nil && user.ID == self.ID {
+			c.MentionsYou = true
+			break
+		}
+	}
+	c.IsReplyToYou = dc.isReplyTo(msg, self.ID)
+
+	names := []string{self.Username}
+	if member, err := state.Member(msg.GuildID, self.ID); err == nil && member.Nick != "" {
+		names = append(names, member.Nick)
+	}
+	for _, name := range names {
+		if mentionsName(msg.Content, name) {
+			c.NameMentioned = true
+			break
+		}
+	}
+
+	if channel, err := state.Channel(msg.ChannelID); err == nil {
+		name := strings.ToLower(channel.Name)
+		for _, keyword := range supportChannelKeywords {
+			if strings.Contains(name, keyword) {
+				c.SupportChannel = true
+				break
+			}
+		}
+	}
+	return c
+}
+
+// isReplyTo reports whether msg replies to a message by userID. Discord
+// usually includes the replied-to message; otherwise it is looked up in the
+// channel's history.
+func (dc *DiscordClient) isReplyTo(msg *discordgo.MessageCreate, userID string) bool {
+	if msg.ReferencedMessage != nil && msg.ReferencedMessage.Author != nil {
+		return msg.ReferencedMessage.Author.ID == userID
+	}
+	if msg.MessageReference == nil || msg.MessageReference.MessageID == "" {
+		return false
+	}
+	channelID := msg.MessageReference.ChannelID
+	if channelID == "" {
+		channelID = msg.ChannelID
+	}
+	for _, earlier := range dc.historyManager.GetMessages(channelID) {
+		if earlier.ID == msg.MessageReference.MessageID {
+			return earlier.AuthorID == userID
+		}
+	}
+	return false
+}
+
+// mentionsName reports whether text uses name as a whole word, ignoring case
+func mentionsName(text, name string) bool {
+	if name == "" {
+		return false
+	}
+	pattern := `(?i)(^|[^\pL\pN_])` + regexp.QuoteMeta(name) + `($|[^\pL\pN_])`
+	matched, _ := regexp.MatchString(pattern, text)
+	return matched
+}
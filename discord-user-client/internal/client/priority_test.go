@@ -0,0 +1,97 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestClassify(t *testing.T) {
+	client, fake := attachFake(t, nil)
+	defer client.Disconnect()
+	fake.AddChannel("g1", "c2", "help-desk")
+	fake.State().MemberAdd(&discordgo.Member{GuildID: "g1", User: fake.State().User, Nick: "Robo"})
+	bot := fake.State().User
+	alice := &discordgo.User{ID: "u1", Username: "alice"}
+
+	// A message of ours that only the history cache knows about
+	client.historyManager.AddMessage(&discordgo.MessageCreate{Message: &discordgo.Message{ID: "m-bot", ChannelID: "c1", Author: bot}})
+
+	tests := []struct {
+		name string
+		msg  *discordgo.Message
+		want Classification
+		pri  Priority
+	}{
+		{
+			name: "Ambient",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "anyone up for a game?"},
+			pri:  PriorityAmbient,
+		},
+		{
+			name: "Mention",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "<@bot> hi", Mentions: []*discordgo.User{bot}},
+			want: Classification{MentionsYou: true},
+			pri:  PriorityMention,
+		},
+		{
+			name: "Reply with the referenced message",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "thanks", ReferencedMessage: &discordgo.Message{Author: bot}},
+			want: Classification{IsReplyToYou: true},
+			pri:  PriorityReply,
+		},
+		{
+			name: "Reply found in history",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "thanks", MessageReference: &discordgo.MessageReference{MessageID: "m-bot"}},
+			want: Classification{IsReplyToYou: true},
+			pri:  PriorityReply,
+		},
+		{
+			name: "Reply to someone else",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "lol", ReferencedMessage: &discordgo.Message{Author: alice}},
+			pri:  PriorityAmbient,
+		},
+		{
+			name: "Username",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "Helper, what's the refund policy?"},
+			want: Classification{NameMentioned: true},
+			pri:  PriorityName,
+		},
+		{
+			name: "Nickname",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "ask robo"},
+			want: Classification{NameMentioned: true},
+			pri:  PriorityName,
+		},
+		{
+			name: "Name inside another word",
+			msg:  &discordgo.Message{ChannelID: "c1", Content: "such a helpers paradise"},
+			pri:  PriorityAmbient,
+		},
+		{
+			name: "Support channel",
+			msg:  &discordgo.Message{ChannelID: "c2", Content: "my login fails"},
+			want: Classification{SupportChannel: true},
+			pri:  PrioritySupport,
+		},
+		{
+			name: "Mention in a support channel",
+			msg:  &discordgo.Message{ChannelID: "c2", Content: "<@bot> my login fails", Mentions: []*discordgo.User{bot}},
+			want: Classification{MentionsYou: true, SupportChannel: true},
+			pri:  PriorityMention,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.GuildID = "g1"
+			tt.msg.Author = alice
+			got := client.classify(&discordgo.MessageCreate{Message: tt.msg})
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+			if got.Priority() != tt.pri {
+				t.Errorf("Expected priority %s, got %s", tt.pri, got.Priority())
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
+)
+
+// Session is the part of a Discord session the client uses. A gateway session
+// satisfies it through NewGatewaySession; discordtest provides an in-memory one.
+type Session interface {
+	history.SessionInterface
+	UserGuilds(limit int, beforeID, afterID string, withCounts bool, options ...discordgo.RequestOption) ([]*discordgo.UserGuild, error)
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error)
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelMessageSend(channelID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelTyping(channelID string, options ...discordgo.RequestOption) error
+	MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error
+
+	// State returns the session's cache of the user, guilds and channels
+	State() *discordgo.State
+	// GatewayReady reports whether the gateway has delivered the initial state
+	GatewayReady() bool
+	// LastHeartbeat returns when the last gateway heartbeat was sent and acknowledged
+	LastHeartbeat() (sent, ack time.Time)
+	Close() error
+}
+
+// gatewaySession adapts a discordgo session to Session
+type gatewaySession struct {
+	*discordgo.Session
+}
+
+// NewGatewaySession wraps a discordgo session as a Session
+func NewGatewaySession(session *discordgo.Session) Session {
+	return gatewaySession{session}
+}
+
+// State implements Session
+func (s gatewaySession) State() *discordgo.State {
+	return s.Session.State
+}
+
+// GatewayReady implements Session
+func (s gatewaySession) GatewayReady() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.DataReady
+}
+
+// LastHeartbeat implements Session
+func (s gatewaySession) LastHeartbeat() (sent, ack time.Time) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.LastHeartbeatSent, s.LastHeartbeatAck
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// Defaults for guilds whose config doesn't set UserLimits
+const (
+	DefaultMessageBurst      = 10
+	DefaultMessagesPerMinute = 10
+	DefaultMentionBurst      = 3
+	DefaultMentionsPerMinute = 2
+	DefaultIgnoreAfter       = 10
+	DefaultIgnoreMinutes     = 15
+)
+
+// Why a message was kept from HUMA by the user limiter
+const (
+	limitMessageRate = "message_rate"
+	limitMentionRate = "mention_rate"
+	limitIgnored     = "ignored"
+)
+
+// idleUserTTL is how long a user's buckets are kept after their last message.
+// Buckets refill long before, so forgetting them changes nothing.
+const idleUserTTL = time.Hour
+
+// DefaultUserLimits returns the limits of guilds that don't set their own
+func DefaultUserLimits() types.UserLimits {
+	return types.UserLimits{
+		MessageBurst:      DefaultMessageBurst,
+		MessagesPerMinute: DefaultMessagesPerMinute,
+		MentionBurst:      DefaultMentionBurst,
+		MentionsPerMinute: DefaultMentionsPerMinute,
+		IgnoreAfter:       DefaultIgnoreAfter,
+		IgnoreMinutes:     DefaultIgnoreMinutes,
+	}
+}
+
+// IgnoredUser is a user whose messages are kept from the agent until a time,
+// for going over their limits
+type IgnoredUser struct {
+	GuildID  string    `json:"guildId"`
+	UserID   string    `json:"userId"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+}
+
+// userLimitResult is the limiter's decision about one message
+type userLimitResult struct {
+	reason   string    // why the message is kept from HUMA; empty if it isn't
+	ignoring bool      // the user was just ignored for going over their limits
+	until    time.Time // when an ignored user is heard again
+}
+
+// userLimiter keeps per-user, per-guild token buckets that limit how often a
+// user's messages reach HUMA. Users who keep going over them are ignored for
+// a while.
+type userLimiter struct {
+	clock clock.Clock
+
+	mu        sync.Mutex
+	users     map[string]*userBuckets // guildID/userID -> buckets
+	lastSweep time.Time
+}
+
+// userBuckets is one user's allowance in one guild
+type userBuckets struct {
+	guildID  string
+	userID   string
+	username string
+
+	messages float64 // messages left
+	mentions float64 // mentions and replies left
+	updated  time.Time
+
+	strikes      int // limited direct messages since the buckets were last full
+	ignoredSince time.Time
+	ignoredUntil time.Time
+}
+
+// newUserLimiter creates a limiter that refills buckets on clock c
+func newUserLimiter(c clock.Clock) *userLimiter {
+	if c == nil {
+		c = clock.Real
+	}
+	return &userLimiter{
+		clock: c,
+		users: make(map[string]*userBuckets),
+	}
+}
+
+// check counts a message from author against the guild's limits. direct
+// messages (mentions of and replies to the account) also use the mention
+// bucket.
+func (l *userLimiter) check(guildID string, author *discordgo.User, direct bool, limits types.UserLimits) userLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweepLocked(now)
+
+	key := guildID + "/" + author.ID
+	b, ok := l.users[key]
+	if !ok {
+		b = &userBuckets{
+			guildID:  guildID,
+			userID:   author.ID,
+			messages: float64(limits.MessageBurst),
+			mentions: float64(limits.MentionBurst),
+			updated:  now,
+		}
+		l.users[key] = b
+	}
+	b.username = author.Username
+	if now.Before(b.ignoredUntil) {
+		return userLimitResult{reason: limitIgnored, until: b.ignoredUntil}
+	}
+
+	// Refill for the time since the last message; a user whose allowance is
+	// back in full starts over
+	minutes := now.Sub(b.updated).Minutes()
+	b.updated = now
+	b.messages = min(b.messages+minutes*limits.MessagesPerMinute, float64(limits.MessageBurst))
+	b.mentions = min(b.mentions+minutes*limits.MentionsPerMinute, float64(limits.MentionBurst))
+	if b.messages == float64(limits.MessageBurst) && b.mentions == float64(limits.MentionBurst) {
+		b.strikes = 0
+	}
+
+	var reason string
+	switch {
+	case limits.MessageBurst > 0 && b.messages < 1:
+		reason = limitMessageRate
+	case direct && limits.MentionBurst > 0 && b.mentions < 1:
+		reason = limitMentionRate
+	default:
+		if limits.MessageBurst > 0 {
+			b.messages--
+		}
+		if direct && limits.MentionBurst > 0 {
+			b.mentions--
+		}
+		return userLimitResult{}
+	}
+
+	// Only limited mentions and replies count toward ignoring the user: someone
+	// chatting fast without addressing the account is just rate limited
+	if !direct {
+		return userLimitResult{reason: reason}
+	}
+	b.strikes++
+	if limits.IgnoreAfter <= 0 || b.strikes < limits.IgnoreAfter {
+		return userLimitResult{reason: reason}
+	}
+	b.strikes = 0
+	b.ignoredSince = now
+	b.ignoredUntil = now.Add(time.Duration(limits.IgnoreMinutes) * time.Minute)
+	return userLimitResult{reason: reason, ignoring: true, until: b.ignoredUntil}
+}
+
+// sweepLocked forgets users who have been quiet for idleUserTTL, at most once
+// a minute. Callers hold l.mu.
+func (l *userLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.users {
+		if now.Sub(b.updated) >= idleUserTTL && !now.Before(b.ignoredUntil) {
+			delete(l.users, key)
+		}
+	}
+}
+
+// ignored returns the users being ignored, by guild then user
+func (l *userLimiter) ignored() []IgnoredUser {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	var users []IgnoredUser
+	for _, b := range l.users {
+		if now.Before(b.ignoredUntil) {
+			users = append(users, IgnoredUser{
+				GuildID:  b.guildID,
+				UserID:   b.userID,
+				Username: b.username,
+				Since:    b.ignoredSince,
+				Until:    b.ignoredUntil,
+			})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].GuildID != users[j].GuildID {
+			return users[i].GuildID < users[j].GuildID
+		}
+		return users[i].UserID < users[j].UserID
+	})
+	return users
+}
+
+// unignore stops ignoring a user and refills their buckets. Returns false if
+// the user wasn't ignored.
+func (l *userLimiter) unignore(guildID, userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.users[guildID+"/"+userID]
+	if !ok || !l.clock.Now().Before(b.ignoredUntil) {
+		return false
+	}
+	delete(l.users, guildID+"/"+userID)
+	return true
+}
+
+// IgnoredUsers returns the users this client is ignoring, by guild then user
+func (dc *DiscordClient) IgnoredUsers() []IgnoredUser {
+	return dc.limits.ignored()
+}
+
+// Unignore stops ignoring a user in a guild. Returns false if the user wasn't
+// ignored.
+func (dc *DiscordClient) Unignore(guildID, userID string) bool {
+	return dc.limits.unignore(guildID, userID)
+}
+
+// GetIgnoredUsers returns the users ignored across all accounts, by guild then user
+func (m *ClientManager) GetIgnoredUsers() []IgnoredUser {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := []IgnoredUser{}
+	for _, client := range m.clients {
+		users = append(users, client.IgnoredUsers()...)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].GuildID != users[j].GuildID {
+			return users[i].GuildID < users[j].GuildID
+		}
+		return users[i].UserID < users[j].UserID
+	})
+	return users
+}
+
+// Unignore stops ignoring a user in a guild. Returns false if the user wasn't
+// ignored.
+func (m *ClientManager) Unignore(guildID, userID string) bool {
+	client := m.GetClientForGuild(guildID)
+	if client == nil {
+		return false
+	}
+	unignored := client.Unignore(guildID, userID)
+	if unignored {
+		logger.Info("Stopped ignoring user", logging.KeyGuildID, guildID, "user_id", userID)
+	}
+	return unignored
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+func TestUserLimiter_Buckets(t *testing.T) {
+	c := clock.NewVirtual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := newUserLimiter(c)
+	limits := types.UserLimits{MessageBurst: 3, MessagesPerMinute: 1, MentionBurst: 1, MentionsPerMinute: 0.5}
+	alice := &discordgo.User{ID: "u1", Username: "alice"}
+	bob := &discordgo.User{ID: "u2", Username: "bob"}
+
+	var got []string
+	for _, direct := range []bool{true, true, false, false, false} {
+		got = append(got, l.check("g1", alice, direct, limits).reason)
+	}
+	want := []string{"", limitMentionRate, "", "", limitMessageRate}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %q, got %q", want, got)
+		}
+	}
+
+	// Other users, and the same user in other guilds, have their own buckets
+	if result := l.check("g1", bob, true, limits); result.reason != "" {
+		t.Errorf("Expected bob's mention through, got %q", result.reason)
+	}
+	if result := l.check("g2", alice, true, limits); result.reason != "" {
+		t.Errorf("Expected alice's mention in another guild through, got %q", result.reason)
+	}
+
+	// A minute refills one message but only half a mention
+	c.Advance(time.Minute)
+	if result := l.check("g1", alice, true, limits); result.reason != limitMentionRate {
+		t.Errorf("Expected the mention still limited, got %q", result.reason)
+	}
+	if result := l.check("g1", alice, false, limits); result.reason != "" {
+		t.Errorf("Expected a message through after a minute, got %q", result.reason)
+	}
+
+	// A zero burst turns a bucket off
+	for i := 0; i < 10; i++ {
+		if result := l.check("g3", alice, true, types.UserLimits{}); result.reason != "" {
+			t.Fatalf("Expected no limits, got %q", result.reason)
+		}
+	}
+}
+
+func TestUserLimiter_IgnoresAbusers(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := clock.NewVirtual(start)
+	l := newUserLimiter(c)
+	limits := types.UserLimits{MentionBurst: 1, MentionsPerMinute: 1, IgnoreAfter: 3, IgnoreMinutes: 10}
+	spammer := &discordgo.User{ID: "u1", Username: "spammer"}
+
+	var ignoring userLimitResult
+	for i := 0; i < 4; i++ {
+		ignoring = l.check("g1", spammer, true, limits)
+	}
+	if !ignoring.ignoring || !ignoring.until.Equal(start.Add(10*time.Minute)) {
+		t.Fatalf("Expected the third limited mention to get the user ignored for 10m, got %+v", ignoring)
+	}
+
+	// Ignored users are kept out even once their buckets refill
+	c.Advance(5 * time.Minute)
+	if result := l.check("g1", spammer, false, limits); result.reason != limitIgnored {
+		t.Errorf("Expected the user ignored, got %q", result.reason)
+	}
+	ignored := l.ignored()
+	if len(ignored) != 1 || ignored[0].UserID != "u1" || ignored[0].Username != "spammer" || !ignored[0].Since.Equal(start) {
+		t.Errorf("Expected the spammer listed as ignored, got %+v", ignored)
+	}
+
+	c.Advance(5 * time.Minute)
+	if result := l.check("g1", spammer, true, limits); result.reason != "" {
+		t.Errorf("Expected the user heard again after 10m, got %q", result.reason)
+	}
+	if ignored := l.ignored(); len(ignored) != 0 {
+		t.Errorf("Expected nobody ignored, got %+v", ignored)
+	}
+}
+
+func TestUserLimiter_ChattyUserNotIgnored(t *testing.T) {
+	c := clock.NewVirtual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := newUserLimiter(c)
+	chatty := &discordgo.User{ID: "u1", Username: "chatty"}
+
+	// A busy channel with the defaults: far over the message rate, never
+	// addressing the account
+	for i := 0; i < 100; i++ {
+		if result := l.check("g1", chatty, false, DefaultUserLimits()); result.ignoring || result.reason == limitIgnored {
+			t.Fatalf("Expected ambient message %d only rate limited, got %+v", i, result)
+		}
+		c.Advance(time.Second)
+	}
+	if ignored := l.ignored(); len(ignored) != 0 {
+		t.Errorf("Expected nobody ignored, got %+v", ignored)
+	}
+}
+
+func TestUserLimiter_Unignore(t *testing.T) {
+	c := clock.NewVirtual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := newUserLimiter(c)
+	limits := types.UserLimits{MessageBurst: 1, MessagesPerMinute: 1, IgnoreAfter: 1, IgnoreMinutes: 60}
+	spammer := &discordgo.User{ID: "u1", Username: "spammer"}
+
+	l.check("g1", spammer, true, limits)
+	l.check("g1", spammer, true, limits)
+	if l.unignore("g1", "u2") {
+		t.Error("Expected unignoring a user who isn't ignored to fail")
+	}
+	if !l.unignore("g1", "u1") {
+		t.Fatal("Expected the spammer to be unignored")
+	}
+	if result := l.check("g1", spammer, false, limits); result.reason != "" {
+		t.Errorf("Expected an unignored user to start over, got %q", result.reason)
+	}
+}
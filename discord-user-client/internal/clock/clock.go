@@ -0,0 +1,184 @@
+// Package clock abstracts time so agent timing (typing delays, typing
+// indicator refreshes) can run against a virtual clock when replaying.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a one-shot timer, like time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker fires repeatedly, like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// Virtual is a clock that only moves when told to. Timers and tickers fire, in
+// deadline order, as AdvanceTo passes their deadline.
+type Virtual struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a pending virtual timer or ticker
+type waiter struct {
+	clock    *Virtual
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration // 0 for timers
+}
+
+// NewVirtual creates a virtual clock that reads start
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+// Now returns the virtual time
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+// NewTimer creates a timer that fires once the clock reaches now+d
+func (v *Virtual) NewTimer(d time.Duration) Timer {
+	return v.add(d, 0)
+}
+
+// NewTicker creates a ticker that fires every d of virtual time
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return virtualTicker{v.add(d, d)}
+}
+
+func (v *Virtual) add(d, period time.Duration) *waiter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	w := &waiter{clock: v, c: make(chan time.Time, 1), deadline: v.now.Add(d), period: period}
+	v.waiters = append(v.waiters, w)
+	return w
+}
+
+// Next returns the earliest pending deadline, false if nothing is waiting
+func (v *Virtual) Next() (time.Time, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.waiters) == 0 {
+		return time.Time{}, false
+	}
+	next := v.waiters[0].deadline
+	for _, w := range v.waiters[1:] {
+		if w.deadline.Before(next) {
+			next = w.deadline
+		}
+	}
+	return next, true
+}
+
+// Pending returns the number of timers and tickers waiting to fire
+func (v *Virtual) Pending() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.waiters)
+}
+
+// AdvanceTo moves the clock to t, firing everything due on the way, and
+// returns how many fired. The clock never moves backwards.
+func (v *Virtual) AdvanceTo(t time.Time) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fired := 0
+	for {
+		sort.SliceStable(v.waiters, func(i, j int) bool {
+			return v.waiters[i].deadline.Before(v.waiters[j].deadline)
+		})
+		if len(v.waiters) == 0 || v.waiters[0].deadline.After(t) {
+			break
+		}
+		w := v.waiters[0]
+		if w.deadline.After(v.now) {
+			v.now = w.deadline
+		}
+		// Like the runtime, a tick nobody has received yet is dropped
+		select {
+		case w.c <- v.now:
+		default:
+		}
+		fired++
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			v.waiters = v.waiters[1:]
+		}
+	}
+	if t.After(v.now) {
+		v.now = t
+	}
+	return fired
+}
+
+// Advance moves the clock forward by d
+func (v *Virtual) Advance(d time.Duration) int {
+	return v.AdvanceTo(v.Now().Add(d))
+}
+
+// remove drops a waiter, reporting whether it was still pending
+func (v *Virtual) remove(w *waiter) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, other := range v.waiters {
+		if other == w {
+			v.waiters = append(v.waiters[:i], v.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *waiter) C() <-chan time.Time { return w.c }
+func (w *waiter) Stop() bool          { return w.clock.remove(w) }
+
+// virtualTicker adapts a periodic waiter to Ticker
+type virtualTicker struct{ w *waiter }
+
+func (t virtualTicker) C() <-chan time.Time { return t.w.c }
+func (t virtualTicker) Stop()               { t.w.clock.remove(t.w) }
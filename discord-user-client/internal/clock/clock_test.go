@@ -0,0 +1,61 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtual_FiresInOrder(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	v := NewVirtual(start)
+
+	late := v.NewTimer(3 * time.Second)
+	early := v.NewTimer(time.Second)
+	ticker := v.NewTicker(2 * time.Second)
+
+	if next, ok := v.Next(); !ok || !next.Equal(start.Add(time.Second)) {
+		t.Fatalf("Expected next deadline at +1s, got %v %v", next, ok)
+	}
+
+	if fired := v.AdvanceTo(start.Add(1500 * time.Millisecond)); fired != 1 {
+		t.Errorf("Expected 1 timer to fire, got %d", fired)
+	}
+	select {
+	case at := <-early.C():
+		if !at.Equal(start.Add(time.Second)) {
+			t.Errorf("Expected timer to fire at +1s, got %v", at)
+		}
+	default:
+		t.Error("Expected early timer to have fired")
+	}
+	if !v.Now().Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("Expected clock at +1.5s, got %v", v.Now())
+	}
+
+	// Ticker at +2s, timer at +3s; the ticker re-arms for +4s
+	if fired := v.Advance(1500 * time.Millisecond); fired != 2 {
+		t.Errorf("Expected 2 to fire, got %d", fired)
+	}
+	<-ticker.C()
+	<-late.C()
+	if v.Pending() != 1 {
+		t.Errorf("Expected only the ticker to be pending, got %d", v.Pending())
+	}
+
+	ticker.Stop()
+	if v.Pending() != 0 {
+		t.Errorf("Expected nothing pending after Stop, got %d", v.Pending())
+	}
+	if late.Stop() {
+		t.Error("Expected Stop on a fired timer to report false")
+	}
+}
+
+func TestVirtual_NeverGoesBack(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	v := NewVirtual(start)
+	v.AdvanceTo(start.Add(-time.Hour))
+	if !v.Now().Equal(start) {
+		t.Errorf("Expected clock to stay at start, got %v", v.Now())
+	}
+}
@@ -0,0 +1,478 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML or JSON file, environment variables and command-line flags, in that
+// order of precedence, and validates it before anything starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultInternalKey is the placeholder key shared by the sample backend setup.
+// It is refused unless Dev is set.
+const DefaultInternalKey = "default-internal-key"
+
+// redacted replaces secrets in Print output
+const redacted = "[REDACTED]"
+
+// Config is the full service configuration. Logging is configured separately
+// from LOG_* variables so that config errors can be logged.
+type Config struct {
+	// Dev allows development defaults such as DefaultInternalKey
+	Dev bool `yaml:"dev"`
+
+	Backend    Backend    `yaml:"backend"`
+	Huma       Huma       `yaml:"huma"`
+	HTTP       HTTP       `yaml:"http"`
+	History    History    `yaml:"history"`
+	Events     Events     `yaml:"events"`
+	SendPolicy SendPolicy `yaml:"sendPolicy"`
+	Shutdown   Shutdown   `yaml:"shutdown"`
+	Standalone Standalone `yaml:"standalone"`
+	Tracing    Tracing    `yaml:"tracing"`
+
+	// PauseStateFile is where guild and channel pauses are persisted
+	PauseStateFile string `yaml:"pauseStateFile"`
+
+	// RecordFile, when set, is where Discord events, Discord calls and HUMA
+	// frames are recorded for replay
+	RecordFile string `yaml:"recordFile"`
+}
+
+// Backend configures the Node backend connection
+type Backend struct {
+	URL                string        `yaml:"url"`
+	InternalAPIKey     string        `yaml:"internalApiKey"`
+	Timeout            time.Duration `yaml:"timeout"`
+	PollInterval       time.Duration `yaml:"pollInterval"`
+	StatsFlushInterval time.Duration `yaml:"statsFlushInterval"`
+}
+
+// Huma configures the HUMA agents
+type Huma struct {
+	URL            string        `yaml:"url"`
+	APIKey         string        `yaml:"apiKey"`
+	Timeout        time.Duration `yaml:"timeout"`
+	TypingWPM      int           `yaml:"typingWpm"`
+	MaxTypingDelay time.Duration `yaml:"maxTypingDelay"`
+	FetchLimit     int           `yaml:"fetchLimit"`
+}
+
+// HTTP configures the admin and health HTTP server
+type HTTP struct {
+	Port            string `yaml:"port"`
+	TLSCertFile     string `yaml:"tlsCertFile"`
+	TLSKeyFile      string `yaml:"tlsKeyFile"`
+	TLSClientCAFile string `yaml:"tlsClientCaFile"`
+}
+
+// History configures per-channel message history
+type History struct {
+	// Size is how many messages are kept per channel, and fetched when a
+	// channel is first seen
+	Size int `yaml:"size"`
+}
+
+// Events sizes the per-guild gateway event pipeline of each Discord account
+type Events struct {
+	Workers   int `yaml:"workers"`
+	QueueSize int `yaml:"queueSize"`
+	// Overflow is what happens when a guild's queue is full: drop-oldest,
+	// drop-newest or block
+	Overflow string `yaml:"overflow"`
+	// AmbientSample keeps one in this many ambient messages (ones that don't
+	// involve the account) once a guild's queue is half full. 1 keeps them all.
+	AmbientSample int `yaml:"ambientSample"`
+	// DebounceWindow is how long a channel must be quiet before its new
+	// messages are sent to HUMA together. Zero sends each message at once.
+	DebounceWindow time.Duration `yaml:"debounceWindow"`
+}
+
+// OverflowPolicies are the accepted Events.Overflow values
+var OverflowPolicies = []string{"drop-oldest", "drop-newest", "block"}
+
+// SendPolicy limits when the agents may send, whatever HUMA decides. Zero
+// turns a limit off.
+type SendPolicy struct {
+	// Cooldown is how long an agent stays quiet in a channel after sending there
+	Cooldown time.Duration `yaml:"cooldown"`
+	// RequireHumanMessage blocks a send until someone else has spoken in the
+	// channel since the agent's last send there
+	RequireHumanMessage bool `yaml:"requireHumanMessage"`
+	// ChannelHourlySends and GuildHourlySends cap sends in any hour
+	ChannelHourlySends int `yaml:"channelHourlySends"`
+	GuildHourlySends   int `yaml:"guildHourlySends"`
+	// DuplicateWindow blocks sending the same text in a channel twice within it
+	DuplicateWindow time.Duration `yaml:"duplicateWindow"`
+}
+
+// Shutdown bounds graceful shutdown
+type Shutdown struct {
+	Timeout      time.Duration `yaml:"timeout"`
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+}
+
+// Standalone runs without the backend when ConfigFile is set
+type Standalone struct {
+	ConfigFile string `yaml:"configFile"`
+	SinkFile   string `yaml:"sinkFile"`
+}
+
+// Tracing exports spans when Endpoint is set
+type Tracing struct {
+	Endpoint    string `yaml:"endpoint"`
+	Headers     string `yaml:"headers"`
+	ServiceName string `yaml:"serviceName"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Backend: Backend{
+			URL:                "http://localhost:3000",
+			InternalAPIKey:     DefaultInternalKey,
+			Timeout:            10 * time.Second,
+			PollInterval:       2 * time.Second,
+			StatsFlushInterval: 30 * time.Second,
+		},
+		Huma: Huma{
+			URL:            "https://api.humalike.tech",
+			Timeout:        30 * time.Second,
+			TypingWPM:      90,
+			MaxTypingDelay: 30 * time.Second,
+			FetchLimit:     50,
+		},
+		HTTP:    HTTP{Port: "8080"},
+		History: History{Size: 50},
+		Events:  Events{Workers: 4, QueueSize: 100, Overflow: "drop-oldest", AmbientSample: 4, DebounceWindow: 2 * time.Second},
+		SendPolicy: SendPolicy{
+			Cooldown:            20 * time.Second,
+			RequireHumanMessage: true,
+			ChannelHourlySends:  20,
+			GuildHourlySends:    60,
+			DuplicateWindow:     time.Hour,
+		},
+		Shutdown: Shutdown{Timeout: 25 * time.Second, DrainTimeout: 15 * time.Second},
+		Standalone: Standalone{
+			SinkFile: "standalone-reports.jsonl",
+		},
+		Tracing:        Tracing{ServiceName: "discord-user-client"},
+		PauseStateFile: "pause-state.json",
+	}
+}
+
+// setting binds one config field to its env var and flag
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	value  flag.Value
+	secret bool
+}
+
+// settings lists every field that can be set from env or flags
+func (c *Config) settings() []setting {
+	return []setting{
+		{"DEV", "dev", "allow development defaults such as the default internal API key", (*boolValue)(&c.Dev), false},
+
+		{"BACKEND_URL", "backend-url", "backend API URL", (*stringValue)(&c.Backend.URL), false},
+		{"INTERNAL_API_KEY", "internal-api-key", "key shared with the backend and required by the HTTP API", (*stringValue)(&c.Backend.InternalAPIKey), true},
+		{"BACKEND_TIMEOUT", "backend-timeout", "timeout for backend requests", (*durationValue)(&c.Backend.Timeout), false},
+		{"POLL_INTERVAL", "poll-interval", "how often token configs are fetched", (*durationValue)(&c.Backend.PollInterval), false},
+		{"STATS_FLUSH_INTERVAL", "stats-flush-interval", "how often stats are reported", (*durationValue)(&c.Backend.StatsFlushInterval), false},
+
+		{"HUMA_URL", "huma-url", "HUMA API URL", (*stringValue)(&c.Huma.URL), false},
+		{"HUMA_API_KEY", "huma-api-key", "HUMA API key (required)", (*stringValue)(&c.Huma.APIKey), true},
+		{"HUMA_TIMEOUT", "huma-timeout", "timeout for HUMA requests and WebSocket handshakes", (*durationValue)(&c.Huma.Timeout), false},
+		{"TYPING_WPM", "typing-wpm", "simulated typing speed in words per minute", (*intValue)(&c.Huma.TypingWPM), false},
+		{"MAX_TYPING_DELAY", "max-typing-delay", "longest typing delay for one message", (*durationValue)(&c.Huma.MaxTypingDelay), false},
+		{"FETCH_LIMIT", "fetch-limit", "messages returned by fetch_channel_messages when the agent gives no limit", (*intValue)(&c.Huma.FetchLimit), false},
+
+		{"HTTP_PORT", "http-port", "HTTP server port", (*stringValue)(&c.HTTP.Port), false},
+		{"HTTP_TLS_CERT_FILE", "http-tls-cert-file", "serve HTTPS with this certificate", (*stringValue)(&c.HTTP.TLSCertFile), false},
+		{"HTTP_TLS_KEY_FILE", "http-tls-key-file", "private key for the certificate", (*stringValue)(&c.HTTP.TLSKeyFile), false},
+		{"HTTP_TLS_CLIENT_CA_FILE", "http-tls-client-ca-file", "require client certificates signed by this CA", (*stringValue)(&c.HTTP.TLSClientCAFile), false},
+
+		{"HISTORY_SIZE", "history-size", "messages kept per channel", (*intValue)(&c.History.Size), false},
+
+		{"EVENT_WORKERS", "event-workers", "gateway events processed at once per Discord account", (*intValue)(&c.Events.Workers), false},
+		{"EVENT_QUEUE_SIZE", "event-queue-size", "gateway events that may wait per guild", (*intValue)(&c.Events.QueueSize), false},
+		{"EVENT_OVERFLOW", "event-overflow", "when a guild's queue is full: drop-oldest, drop-newest or block", (*stringValue)(&c.Events.Overflow), false},
+		{"EVENT_AMBIENT_SAMPLE", "event-ambient-sample", "keep one in this many ambient messages once a guild's queue is half full; 1 keeps them all", (*intValue)(&c.Events.AmbientSample), false},
+		{"DEBOUNCE_WINDOW", "debounce-window", "quiet time before a channel's new messages are sent to HUMA together; 0 sends each at once", (*durationValue)(&c.Events.DebounceWindow), false},
+
+		{"SEND_COOLDOWN", "send-cooldown", "how long an agent stays quiet in a channel after sending there; 0 turns it off", (*durationValue)(&c.SendPolicy.Cooldown), false},
+		{"SEND_REQUIRE_HUMAN", "send-require-human", "only send in a channel again after someone else has spoken there", (*boolValue)(&c.SendPolicy.RequireHumanMessage), false},
+		{"SEND_CHANNEL_HOURLY", "send-channel-hourly", "most sends per channel in any hour; 0 turns it off", (*intValue)(&c.SendPolicy.ChannelHourlySends), false},
+		{"SEND_GUILD_HOURLY", "send-guild-hourly", "most sends per guild in any hour; 0 turns it off", (*intValue)(&c.SendPolicy.GuildHourlySends), false},
+		{"SEND_DUPLICATE_WINDOW", "send-duplicate-window", "block sending the same text in a channel twice within this long; 0 turns it off", (*durationValue)(&c.SendPolicy.DuplicateWindow), false},
+
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "total time allowed for graceful shutdown", (*durationValue)(&c.Shutdown.Timeout), false},
+		{"SHUTDOWN_DRAIN_TIMEOUT", "shutdown-drain-timeout", "part of it pending messages get to finish typing", (*durationValue)(&c.Shutdown.DrainTimeout), false},
+
+		{"STANDALONE_CONFIG_FILE", "standalone-config-file", "run without the backend, from this YAML/JSON file", (*stringValue)(&c.Standalone.ConfigFile), false},
+		{"STANDALONE_SINK_FILE", "standalone-sink-file", "where standalone mode writes stats and agent reports", (*stringValue)(&c.Standalone.SinkFile), false},
+
+		{"OTEL_EXPORTER_OTLP_ENDPOINT", "otlp-endpoint", "OTLP/HTTP trace collector", (*stringValue)(&c.Tracing.Endpoint), false},
+		{"OTEL_EXPORTER_OTLP_HEADERS", "otlp-headers", "extra collector headers, k=v,k=v", (*stringValue)(&c.Tracing.Headers), true},
+		{"OTEL_SERVICE_NAME", "otel-service-name", "service name on spans", (*stringValue)(&c.Tracing.ServiceName), false},
+
+		{"PAUSE_STATE_FILE", "pause-state-file", "where guild and channel pauses are persisted", (*stringValue)(&c.PauseStateFile), false},
+		{"RECORD_FILE", "record-file", "record Discord and HUMA traffic to this gzipped JSONL file for replay", (*stringValue)(&c.RecordFile), false},
+	}
+}
+
+// Load builds the config from defaults, the file given by -config or
+// CONFIG_FILE, environment variables and flags, then validates it
+func Load(name string, args []string, output io.Writer) (*Config, error) {
+	cfg, err := load(name, args, output)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load is Load without validation
+func load(name string, args []string, output io.Writer) (*Config, error) {
+	// Flags are parsed into a scratch config first: they win over the file
+	// and env, but the file to read is itself a flag
+	var flagged Config
+	fs := NewFlagSet(name, &flagged)
+	fs.SetOutput(output)
+	configFile := fs.Lookup("config").Value
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	cfg := Default()
+
+	path := configFile.String()
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range cfg.settings() {
+		v, ok := os.LookupEnv(s.env)
+		if !ok || v == "" {
+			continue
+		}
+		if err := s.value.Set(v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+		}
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range cfg.settings() {
+		if set[s.flag] {
+			s.value.Set(fs.Lookup(s.flag).Value.String())
+		}
+	}
+
+	return &cfg, nil
+}
+
+// NewFlagSet returns a flag set with a flag for every setting, bound to cfg,
+// plus -config for the config file. cfg is reset to the defaults so -help
+// shows them.
+func NewFlagSet(name string, cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var configFile string
+	fs.StringVar(&configFile, "config", "", "YAML or JSON config file (env CONFIG_FILE)")
+
+	*cfg = Default()
+	for _, s := range cfg.settings() {
+		fs.Var(s.value, s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	return fs
+}
+
+// readFile merges a YAML or JSON file into the config. Unknown keys are
+// rejected so typos don't silently fall back to defaults.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	// JSON is valid YAML, so one decoder handles both
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every problem at once, so one restart fixes them all
+func (c *Config) Validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Huma.APIKey == "" {
+		fail("HUMA_API_KEY is required")
+	}
+	switch c.Backend.InternalAPIKey {
+	case "":
+		fail("INTERNAL_API_KEY is required")
+	case DefaultInternalKey:
+		if !c.Dev {
+			fail("INTERNAL_API_KEY is the default %q; set a real key, or DEV=true for local development", DefaultInternalKey)
+		}
+	}
+
+	if c.Standalone.ConfigFile == "" {
+		if u, err := url.Parse(c.Backend.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("BACKEND_URL %q must be an http(s) URL", c.Backend.URL)
+		}
+	}
+	if u, err := url.Parse(c.Huma.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("HUMA_URL %q must be an http(s) URL", c.Huma.URL)
+	}
+	if c.Standalone.ConfigFile != "" && c.Standalone.SinkFile == "" {
+		fail("STANDALONE_SINK_FILE is required in standalone mode")
+	}
+
+	if port, err := strconv.Atoi(c.HTTP.Port); err != nil || port < 1 || port > 65535 {
+		fail("HTTP_PORT %q must be a port number", c.HTTP.Port)
+	}
+	if (c.HTTP.TLSCertFile == "") != (c.HTTP.TLSKeyFile == "") {
+		fail("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+	}
+	if c.HTTP.TLSClientCAFile != "" && c.HTTP.TLSCertFile == "" {
+		fail("HTTP_TLS_CLIENT_CA_FILE requires HTTP_TLS_CERT_FILE")
+	}
+
+	positive := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"BACKEND_TIMEOUT", c.Backend.Timeout},
+		{"POLL_INTERVAL", c.Backend.PollInterval},
+		{"STATS_FLUSH_INTERVAL", c.Backend.StatsFlushInterval},
+		{"HUMA_TIMEOUT", c.Huma.Timeout},
+		{"MAX_TYPING_DELAY", c.Huma.MaxTypingDelay},
+		{"SHUTDOWN_TIMEOUT", c.Shutdown.Timeout},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			fail("%s must be positive, got %s", p.name, p.value)
+		}
+	}
+	if c.Shutdown.DrainTimeout < 0 || c.Shutdown.DrainTimeout > c.Shutdown.Timeout {
+		fail("SHUTDOWN_DRAIN_TIMEOUT must be between 0 and SHUTDOWN_TIMEOUT (%s), got %s", c.Shutdown.Timeout, c.Shutdown.DrainTimeout)
+	}
+
+	if c.Huma.TypingWPM < 1 {
+		fail("TYPING_WPM must be at least 1, got %d", c.Huma.TypingWPM)
+	}
+	if c.Huma.FetchLimit < 1 || c.Huma.FetchLimit > 100 {
+		fail("FETCH_LIMIT must be between 1 and 100 (Discord's page size), got %d", c.Huma.FetchLimit)
+	}
+	if c.History.Size < 1 || c.History.Size > 100 {
+		fail("HISTORY_SIZE must be between 1 and 100 (Discord's page size), got %d", c.History.Size)
+	}
+
+	if c.Events.Workers < 1 {
+		fail("EVENT_WORKERS must be at least 1, got %d", c.Events.Workers)
+	}
+	if c.Events.QueueSize < 1 {
+		fail("EVENT_QUEUE_SIZE must be at least 1, got %d", c.Events.QueueSize)
+	}
+	if !slices.Contains(OverflowPolicies, c.Events.Overflow) {
+		fail("EVENT_OVERFLOW must be one of %s, got %q", strings.Join(OverflowPolicies, ", "), c.Events.Overflow)
+	}
+	if c.Events.AmbientSample < 1 {
+		fail("EVENT_AMBIENT_SAMPLE must be at least 1, got %d", c.Events.AmbientSample)
+	}
+	if c.Events.DebounceWindow < 0 {
+		fail("DEBOUNCE_WINDOW must not be negative, got %s", c.Events.DebounceWindow)
+	}
+
+	if c.SendPolicy.Cooldown < 0 {
+		fail("SEND_COOLDOWN must not be negative, got %s", c.SendPolicy.Cooldown)
+	}
+	if c.SendPolicy.ChannelHourlySends < 0 {
+		fail("SEND_CHANNEL_HOURLY must not be negative, got %d", c.SendPolicy.ChannelHourlySends)
+	}
+	if c.SendPolicy.GuildHourlySends < 0 {
+		fail("SEND_GUILD_HOURLY must not be negative, got %d", c.SendPolicy.GuildHourlySends)
+	}
+	if c.SendPolicy.DuplicateWindow < 0 {
+		fail("SEND_DUPLICATE_WINDOW must not be negative, got %s", c.SendPolicy.DuplicateWindow)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+// Secrets returns the secret values set in the config, for log redaction
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, s := range c.settings() {
+		if v := s.value.String(); s.secret && v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// Redacted returns a copy with secrets replaced
+func (c *Config) Redacted() Config {
+	out := *c
+	for _, s := range out.settings() {
+		if s.secret && s.value.String() != "" {
+			s.value.Set(redacted)
+		}
+	}
+	return out
+}
+
+// Print writes the config as YAML with secrets redacted. The output can be
+// used as a config file once the secrets are filled back in.
+func (c *Config) Print(w io.Writer) error {
+	redactedCfg := c.Redacted()
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&redactedCfg); err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// LogValue logs the config as flag=value pairs with secrets redacted
+func (c *Config) LogValue() slog.Value {
+	redactedCfg := c.Redacted()
+	settings := redactedCfg.settings()
+	attrs := make([]slog.Attr, 0, len(settings))
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(s.flag, s.value.String()))
+	}
+	return slog.GroupValue(attrs...)
+}
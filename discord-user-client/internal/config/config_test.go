@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validArgs is the least needed to pass validation
+var validArgs = []string{"-huma-api-key", "huma-secret-key", "-internal-api-key", "internal-secret-key"}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load("test", validArgs, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := Default()
+	if cfg.Backend.PollInterval != want.Backend.PollInterval || cfg.Huma.TypingWPM != want.Huma.TypingWPM || cfg.History.Size != want.History.Size {
+		t.Errorf("Expected defaults, got %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("backend:\n  pollInterval: 5s\n  url: http://file:3000\nhuma:\n  typingWpm: 60\nhistory:\n  size: 20\n"), 0o600)
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("POLL_INTERVAL", "7s")
+	t.Setenv("TYPING_WPM", "70")
+
+	cfg, err := Load("test", append([]string{"-typing-wpm", "80"}, validArgs...), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.History.Size != 20 || cfg.Backend.URL != "http://file:3000" {
+		t.Errorf("Expected file values, got %+v", cfg)
+	}
+	if cfg.Backend.PollInterval != 7*time.Second {
+		t.Errorf("Expected env to override file, got %s", cfg.Backend.PollInterval)
+	}
+	if cfg.Huma.TypingWPM != 80 {
+		t.Errorf("Expected flag to override env, got %d", cfg.Huma.TypingWPM)
+	}
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"http":{"port":"9090"},"shutdown":{"timeout":"40s"}}`), 0o600)
+
+	cfg, err := Load("test", append([]string{"-config", path}, validArgs...), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.HTTP.Port != "9090" || cfg.Shutdown.Timeout != 40*time.Second {
+		t.Errorf("Expected JSON file values, got %+v", cfg)
+	}
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("huma:\n  typingWPM: 60\n"), 0o600)
+
+	if _, err := Load("test", append([]string{"-config", path}, validArgs...), nil); err == nil || !strings.Contains(err.Error(), "typingWPM") {
+		t.Errorf("Expected unknown file key to be rejected, got %v", err)
+	}
+
+	t.Setenv("POLL_INTERVAL", "2")
+	if _, err := Load("test", validArgs, nil); err == nil || !strings.Contains(err.Error(), "POLL_INTERVAL") {
+		t.Errorf("Expected bad env value to name the variable, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected missing HUMA key and default internal key to fail")
+	}
+	for _, want := range []string{"HUMA_API_KEY is required", "INTERNAL_API_KEY is the default"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+
+	cfg.Huma.APIKey = "huma-secret-key"
+	cfg.Dev = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected dev mode to allow the default key, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"bad backend url", func(c *Config) { c.Backend.URL = "localhost:3000" }, "BACKEND_URL"},
+		{"bad port", func(c *Config) { c.HTTP.Port = "http" }, "HTTP_PORT"},
+		{"key without cert", func(c *Config) { c.HTTP.TLSKeyFile = "key.pem" }, "must be set together"},
+		{"zero poll", func(c *Config) { c.Backend.PollInterval = 0 }, "POLL_INTERVAL must be positive"},
+		{"drain too long", func(c *Config) { c.Shutdown.DrainTimeout = time.Minute }, "SHUTDOWN_DRAIN_TIMEOUT"},
+		{"zero wpm", func(c *Config) { c.Huma.TypingWPM = 0 }, "TYPING_WPM"},
+		{"huge history", func(c *Config) { c.History.Size = 500 }, "HISTORY_SIZE"},
+		{"no event workers", func(c *Config) { c.Events.Workers = 0 }, "EVENT_WORKERS"},
+		{"no ambient sample", func(c *Config) { c.Events.AmbientSample = 0 }, "EVENT_AMBIENT_SAMPLE"},
+		{"negative debounce", func(c *Config) { c.Events.DebounceWindow = -time.Second }, "DEBOUNCE_WINDOW"},
+		{"negative send cooldown", func(c *Config) { c.SendPolicy.Cooldown = -time.Second }, "SEND_COOLDOWN"},
+		{"negative guild budget", func(c *Config) { c.SendPolicy.GuildHourlySends = -1 }, "SEND_GUILD_HOURLY"},
+		{"unknown overflow", func(c *Config) { c.Events.Overflow = "drop-all" }, "EVENT_OVERFLOW must be one of drop-oldest, drop-newest, block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfg
+			tt.modify(&c)
+			if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Standalone mode doesn't use the backend URL
+	c := cfg
+	c.Backend.URL = ""
+	c.Standalone.ConfigFile = "standalone.yaml"
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected standalone mode to ignore BACKEND_URL, got %v", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, err := Load("test", append([]string{"-otlp-headers", "authorization=Bearer otlp-secret"}, validArgs...), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, secret := range []string{"huma-secret-key", "internal-secret-key", "otlp-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("Secret %q leaked into printed config:\n%s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "pollInterval: 2s") {
+		t.Errorf("Expected readable durations, got:\n%s", out.String())
+	}
+	if cfg.Huma.APIKey != "huma-secret-key" {
+		t.Error("Expected Print not to modify the config")
+	}
+
+	if got := cfg.LogValue().String(); strings.Contains(got, "huma-secret-key") {
+		t.Errorf("Secret leaked into log value: %s", got)
+	}
+
+	if len(cfg.Secrets()) != 3 {
+		t.Errorf("Expected 3 secrets, got %d", len(cfg.Secrets()))
+	}
+}
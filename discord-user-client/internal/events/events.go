@@ -0,0 +1,138 @@
+// Package events is an in-process bus of live agent activity. Publishers never
+// block: subscribers that fall behind lose events rather than slowing down
+// message processing.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+)
+
+// Event types
+const (
+	TypeMessageReceived = "message_received"
+	TypeContextSent     = "context_sent"
+	TypeToolCall        = "tool_call"
+	TypeTypingStarted   = "typing_started"
+	TypeMessageSent     = "message_sent"
+	TypeMessageCanceled = "message_canceled"
+	TypeError           = "error"
+)
+
+// DefaultBufferSize is how many events a subscriber can fall behind before events are dropped
+const DefaultBufferSize = 256
+
+// Event is one thing an agent did or saw
+type Event struct {
+	ID          uint64    `json:"id"`
+	Type        string    `json:"type"`
+	Timestamp   time.Time `json:"timestamp"`
+	GuildID     string    `json:"guildId"`
+	ChannelID   string    `json:"channelId,omitempty"`
+	ChannelName string    `json:"channelName,omitempty"`
+	TraceID     string    `json:"traceId,omitempty"`
+	ToolCallID  string    `json:"toolCallId,omitempty"`
+	ToolName    string    `json:"toolName,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Content     string    `json:"content,omitempty"`
+	Priority    string    `json:"priority,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DelayMs     int64     `json:"delayMs,omitempty"`
+}
+
+// Bus fans events out to subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	nextID      atomic.Uint64
+}
+
+// Subscription receives events for a set of guilds (all guilds if empty)
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	guilds map[string]bool
+	bus    *Bus
+	once   sync.Once
+}
+
+// Default is the bus the agent publishes to and /events streams from
+var Default = NewBus()
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish stamps an event with an ID and time and delivers it to matching subscribers
+func (b *Bus) Publish(event Event) {
+	event.ID = b.nextID.Add(1)
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if len(sub.guilds) > 0 && !sub.guilds[event.GuildID] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			metrics.ActivityEventsDropped.Inc()
+		}
+	}
+}
+
+// Subscribe starts receiving events for the given guilds, or all guilds if none
+// are given. Close the subscription when done.
+func (b *Bus) Subscribe(guildIDs []string, bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	ch := make(chan Event, bufferSize)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		guilds: make(map[string]bool, len(guildIDs)),
+		bus:    b,
+	}
+	for _, guildID := range guildIDs {
+		sub.guilds[guildID] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// SubscriberCount returns the number of open subscriptions
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Close stops delivery and closes C. Safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// Publish publishes an event on the default bus
+func Publish(event Event) {
+	Default.Publish(event)
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestGuildFilter(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(nil, 10)
+	defer all.Close()
+	guild1 := bus.Subscribe([]string{"guild1"}, 10)
+	defer guild1.Close()
+
+	bus.Publish(Event{Type: TypeMessageReceived, GuildID: "guild1"})
+	bus.Publish(Event{Type: TypeMessageReceived, GuildID: "guild2"})
+
+	if len(all.C) != 2 {
+		t.Errorf("Expected unfiltered subscriber to get 2 events, got %d", len(all.C))
+	}
+	if len(guild1.C) != 1 {
+		t.Fatalf("Expected filtered subscriber to get 1 event, got %d", len(guild1.C))
+	}
+
+	event := <-guild1.C
+	if event.GuildID != "guild1" || event.ID == 0 || event.Timestamp.IsZero() {
+		t.Errorf("Expected stamped guild1 event, got %+v", event)
+	}
+}
+
+func TestSlowSubscriberDropsEvents(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(nil, 1)
+	defer sub.Close()
+
+	// Must not block even though nobody is reading
+	bus.Publish(Event{Type: TypeToolCall, GuildID: "guild1"})
+	bus.Publish(Event{Type: TypeToolCall, GuildID: "guild1"})
+
+	if len(sub.C) != 1 {
+		t.Errorf("Expected buffer of 1 to hold 1 event, got %d", len(sub.C))
+	}
+}
+
+func TestClose(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(nil, 1)
+	if bus.SubscriberCount() != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", bus.SubscriberCount())
+	}
+
+	sub.Close()
+	sub.Close()
+	if bus.SubscriberCount() != 0 {
+		t.Errorf("Expected 0 subscribers after close, got %d", bus.SubscriberCount())
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected channel to be closed")
+	}
+
+	// Publishing after close must not panic
+	bus.Publish(Event{Type: TypeError, GuildID: "guild1"})
+}
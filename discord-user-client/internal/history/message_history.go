@@ -2,12 +2,15 @@ package history
 
 import (
 	"fmt"
-	"log"
 	"sync"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
 )
 
+// logger is used for history cache events
+var logger = logging.For("history")
+
 // SessionInterface defines the Discord session methods needed for history management
 type SessionInterface interface {
 	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
@@ -32,19 +35,48 @@ type ChannelHistory struct {
 	mu           sync.RWMutex
 }
 
+// DefaultMaxMessages is how many messages are kept per channel by default
+const DefaultMaxMessages = 50
+
 // MessageHistoryManager manages message history for multiple channels
 type MessageHistoryManager struct {
-	channels map[string]*ChannelHistory
-	mu       sync.RWMutex
+	channels    map[string]*ChannelHistory
+	maxMessages int
+	filter      func(channelID string) func(*discordgo.Message) bool
+	mu          sync.RWMutex
 }
 
 // NewMessageHistoryManager creates a new message history manager
 func NewMessageHistoryManager() *MessageHistoryManager {
 	return &MessageHistoryManager{
-		channels: make(map[string]*ChannelHistory),
+		channels:    make(map[string]*ChannelHistory),
+		maxMessages: DefaultMaxMessages,
 	}
 }
 
+// SetMaxMessages sets how many messages are kept for channels created from now on
+func (m *MessageHistoryManager) SetMaxMessages(maxMessages int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxMessages = maxMessages
+}
+
+// MaxMessages returns how many messages are kept per channel
+func (m *MessageHistoryManager) MaxMessages() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.maxMessages
+}
+
+// SetFilter sets which messages fetched from Discord are kept when a channel
+// is initialized. filter is called once per channel and returns the test for
+// its messages, or nil to keep them all.
+func (m *MessageHistoryManager) SetFilter(filter func(channelID string) func(*discordgo.Message) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filter = filter
+}
+
 // GetOrCreateChannel gets existing channel history or creates a new one
 func (m *MessageHistoryManager) GetOrCreateChannel(channelID string) *ChannelHistory {
 	m.mu.Lock()
@@ -56,9 +88,9 @@ func (m *MessageHistoryManager) GetOrCreateChannel(channelID string) *ChannelHis
 
 	ch := &ChannelHistory{
 		ChannelID:   channelID,
-		Messages:    make([]Message, 0, 50),
+		Messages:    make([]Message, 0, m.maxMessages),
 		Initialized: false,
-		MaxMessages: 50,
+		MaxMessages: m.maxMessages,
 	}
 	m.channels[channelID] = ch
 	return ch
@@ -80,16 +112,23 @@ func (m *MessageHistoryManager) IsChannelInitialized(channelID string) bool {
 // InitializeChannel fetches the last N messages from Discord and initializes the channel
 func (m *MessageHistoryManager) InitializeChannel(session SessionInterface, channelID string, limit int) error {
 	ch := m.GetOrCreateChannel(channelID)
+	m.mu.RLock()
+	filter := m.filter
+	m.mu.RUnlock()
+	var keep func(*discordgo.Message) bool
+	if filter != nil {
+		keep = filter(channelID)
+	}
 
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
 	if ch.Initialized {
-		log.Printf("[HISTORY] Channel %s already initialized", channelID)
+		logger.Debug("Channel already initialized", logging.KeyChannelID, channelID)
 		return nil
 	}
 
-	log.Printf("[HISTORY] Initializing channel %s, fetching last %d messages", channelID, limit)
+	logger.Debug("Initializing channel", logging.KeyChannelID, channelID, "limit", limit)
 
 	// Fetch messages from Discord
 	messages, err := session.ChannelMessages(channelID, limit, "", "", "")
@@ -100,6 +139,9 @@ func (m *MessageHistoryManager) InitializeChannel(session SessionInterface, chan
 	// Messages come in reverse order (newest first), so reverse them
 	for i := len(messages) - 1; i >= 0; i-- {
 		msg := messages[i]
+		if keep != nil && !keep(msg) {
+			continue
+		}
 		ch.Messages = append(ch.Messages, Message{
 			ID:        msg.ID,
 			ChannelID: msg.ChannelID,
@@ -111,7 +153,7 @@ func (m *MessageHistoryManager) InitializeChannel(session SessionInterface, chan
 	}
 
 	ch.Initialized = true
-	log.Printf("[HISTORY] Channel %s initialized with %d messages", channelID, len(ch.Messages))
+	logger.Info("Channel initialized", logging.KeyChannelID, channelID, "messages", len(ch.Messages))
 	return nil
 }
 
@@ -147,7 +189,7 @@ func (m *MessageHistoryManager) AddMessage(msg *discordgo.MessageCreate) {
 		ch.Messages = ch.Messages[len(ch.Messages)-ch.MaxMessages:]
 	}
 
-	log.Printf("[HISTORY] Added message to %s (total: %d)", msg.ChannelID, len(ch.Messages))
+	logger.Debug("Added message", logging.KeyGuildID, msg.GuildID, logging.KeyChannelID, msg.ChannelID, "total", len(ch.Messages))
 }
 
 // GetMessages returns a copy of messages for a channel
@@ -200,3 +242,41 @@ func (m *MessageHistoryManager) GetChannelStats(channelID string) (initialized b
 
 	return ch.Initialized, len(ch.Messages)
 }
+
+// GetCacheStats returns the number of cached channels and messages
+func (m *MessageHistoryManager) GetCacheStats() (channels int, messages int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, ch := range m.channels {
+		ch.mu.RLock()
+		messages += len(ch.Messages)
+		ch.mu.RUnlock()
+	}
+
+	return len(m.channels), messages
+}
+
+// Clone returns an independent copy of all channel histories, so callers can
+// add messages (e.g. for a dry run) without affecting the live cache
+func (m *MessageHistoryManager) Clone() *MessageHistoryManager {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clone := NewMessageHistoryManager()
+	clone.maxMessages = m.maxMessages
+	clone.filter = m.filter
+	for channelID, ch := range m.channels {
+		ch.mu.RLock()
+		messages := make([]Message, len(ch.Messages))
+		copy(messages, ch.Messages)
+		clone.channels[channelID] = &ChannelHistory{
+			ChannelID:   ch.ChannelID,
+			Messages:    messages,
+			Initialized: ch.Initialized,
+			MaxMessages: ch.MaxMessages,
+		}
+		ch.mu.RUnlock()
+	}
+	return clone
+}
@@ -137,6 +137,31 @@ func TestAddMessage_MaxLimit(t *testing.T) {
 	}
 }
 
+func TestSetMaxMessages(t *testing.T) {
+	manager := NewMessageHistoryManager()
+	manager.SetMaxMessages(5)
+
+	for i := 0; i < 8; i++ {
+		manager.AddMessage(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ID:        fmt.Sprintf("msg%d", i),
+				ChannelID: "channel1",
+				Content:   fmt.Sprintf("Message %d", i),
+				Author:    &discordgo.User{ID: "user1", Username: "TestUser"},
+				Timestamp: time.Now(),
+			},
+		})
+	}
+
+	messages := manager.GetMessages("channel1")
+	if len(messages) != 5 {
+		t.Errorf("Expected 5 messages (max), got %d", len(messages))
+	}
+	if clone := manager.Clone(); clone.MaxMessages() != 5 {
+		t.Errorf("Expected clone to keep max of 5, got %d", clone.MaxMessages())
+	}
+}
+
 func TestGetMessages_EmptyChannel(t *testing.T) {
 	manager := NewMessageHistoryManager()
 
@@ -225,3 +250,58 @@ func TestGetChannelStats(t *testing.T) {
 		t.Errorf("Expected count 1, got %d", count)
 	}
 }
+
+func TestGetCacheStats(t *testing.T) {
+	manager := NewMessageHistoryManager()
+
+	channels, messages := manager.GetCacheStats()
+	if channels != 0 || messages != 0 {
+		t.Errorf("Expected empty cache, got %d channels, %d messages", channels, messages)
+	}
+
+	for i, channelID := range []string{"channel1", "channel1", "channel2"} {
+		manager.AddMessage(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ID:        fmt.Sprintf("msg%d", i),
+				ChannelID: channelID,
+				Content:   "Test",
+				Author:    &discordgo.User{ID: "user1", Username: "User"},
+				Timestamp: time.Now(),
+			},
+		})
+	}
+
+	channels, messages = manager.GetCacheStats()
+	if channels != 2 {
+		t.Errorf("Expected 2 channels, got %d", channels)
+	}
+	if messages != 3 {
+		t.Errorf("Expected 3 messages, got %d", messages)
+	}
+}
+
+func TestClone(t *testing.T) {
+	manager := NewMessageHistoryManager()
+	newMessage := func(id string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ID:        id,
+				ChannelID: "channel1",
+				Content:   "Message " + id,
+				Author:    &discordgo.User{ID: "user1", Username: "TestUser"},
+				Timestamp: time.Now(),
+			},
+		}
+	}
+	manager.AddMessage(newMessage("msg1"))
+
+	clone := manager.Clone()
+	clone.AddMessage(newMessage("msg2"))
+
+	if len(manager.GetMessages("channel1")) != 1 {
+		t.Error("Adding to the clone should not affect the original")
+	}
+	if len(clone.GetMessages("channel1")) != 2 {
+		t.Error("Expected clone to keep the original messages")
+	}
+}
@@ -0,0 +1,277 @@
+package huma
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// activityIdleTimeout is how long a decision trail stays open without new activity
+const activityIdleTimeout = 60 * time.Second
+
+// activityResultMaxLen limits how much of a tool result is kept in the trail
+const activityResultMaxLen = 500
+
+// activityTrail collects everything the agent did in response to one trigger
+type activityTrail struct {
+	channelID          string
+	channelName        string
+	triggerDescription string
+	traceID            string
+	startedAt          time.Time
+	entries            []types.AgentActivityEntry
+	responded          bool
+
+	toolCalls  map[string]bool // tool calls started in this trail and not finished yet
+	superseded bool            // a newer trigger took over the channel
+	closed     bool
+	timer      clock.Timer   // idle timer
+	stopTimer  chan struct{} // closed to stop the idle timer's goroutine
+}
+
+// activityLog tracks the agent's decision trails, one per channel, and hands
+// finished trails to onClose. Entries for a tool call go to the trail it was
+// started in, so a send still being typed when the next trigger arrives stays
+// with the trigger that asked for it. A trail ends when a newer trigger in its
+// channel arrives and its tool calls have finished, or after idleTimeout
+// without activity; a trail without a sent message is reported as silent.
+// All methods are no-ops on a nil log.
+type activityLog struct {
+	mu          sync.Mutex
+	trails      map[string]*activityTrail // channelID -> trail new activity goes to
+	toolCalls   map[string]*activityTrail // toolCallID -> trail the call started in
+	latest      *activityTrail            // most recent trail, for entries without a channel
+	clock       clock.Clock
+	idleTimeout time.Duration
+	onClose     func(types.AgentActivityPayload)
+}
+
+// newActivityLog creates an activity log that reports finished trails to
+// onClose and times them out on clock c (nil means the wall clock)
+func newActivityLog(c clock.Clock, idleTimeout time.Duration, onClose func(types.AgentActivityPayload)) *activityLog {
+	if c == nil {
+		c = clock.Real
+	}
+	return &activityLog{
+		trails:      make(map[string]*activityTrail),
+		toolCalls:   make(map[string]*activityTrail),
+		clock:       c,
+		idleTimeout: idleTimeout,
+		onClose:     onClose,
+	}
+}
+
+// Trigger starts a new trail for an inbound event in a channel. The channel's
+// previous trail ends once its tool calls have finished.
+func (l *activityLog) Trigger(channelID, channelName, description, traceID string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	var finished *types.AgentActivityPayload
+	if previous := l.trails[channelID]; previous != nil {
+		previous.superseded = true
+		delete(l.trails, channelID)
+		if len(previous.toolCalls) == 0 {
+			finished = l.closeLocked(previous)
+		}
+	}
+	trail := l.startLocked(channelID)
+	trail.channelName = channelName
+	trail.triggerDescription = description
+	trail.traceID = traceID
+	l.appendLocked(trail, types.AgentActivityEntry{
+		Kind:      types.ActivityKindTrigger,
+		ChannelID: channelID,
+		Message:   description,
+	})
+	l.mu.Unlock()
+
+	l.report(finished)
+}
+
+// Record appends an entry to the trail of its tool call, or else to its
+// channel's trail
+func (l *activityLog) Record(entry types.AgentActivityEntry) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	trail := l.trailForLocked(entry)
+	switch entry.Kind {
+	case types.ActivityKindToolCall:
+		if entry.ToolCallID != "" {
+			trail.toolCalls[entry.ToolCallID] = true
+			l.toolCalls[entry.ToolCallID] = trail
+		}
+	case types.ActivityKindSent:
+		trail.responded = true
+	}
+	l.appendLocked(trail, entry)
+
+	// A result or cancellation finishes the tool call; a superseded trail
+	// ends with its last one
+	var finished *types.AgentActivityPayload
+	if entry.ToolCallID != "" && (entry.Kind == types.ActivityKindToolResult || entry.Kind == types.ActivityKindCanceled) {
+		delete(trail.toolCalls, entry.ToolCallID)
+		delete(l.toolCalls, entry.ToolCallID)
+		if trail.superseded && len(trail.toolCalls) == 0 {
+			finished = l.closeLocked(trail)
+		}
+	}
+	l.mu.Unlock()
+
+	l.report(finished)
+}
+
+// Close ends every open trail and reports them, oldest first
+func (l *activityLog) Close() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	open := make(map[*activityTrail]bool)
+	for _, trail := range l.trails {
+		open[trail] = true
+	}
+	for _, trail := range l.toolCalls {
+		open[trail] = true
+	}
+	trails := make([]*activityTrail, 0, len(open))
+	for trail := range open {
+		trails = append(trails, trail)
+	}
+	sort.Slice(trails, func(i, j int) bool { return trails[i].startedAt.Before(trails[j].startedAt) })
+	var finished []*types.AgentActivityPayload
+	for _, trail := range trails {
+		finished = append(finished, l.closeLocked(trail))
+	}
+	l.mu.Unlock()
+
+	for _, payload := range finished {
+		l.report(payload)
+	}
+}
+
+// trailForLocked returns the trail an entry belongs to, starting one without
+// a trigger if there is none (e.g. a late tool call after its trail timed
+// out). Caller must hold l.mu.
+func (l *activityLog) trailForLocked(entry types.AgentActivityEntry) *activityTrail {
+	if trail, ok := l.toolCalls[entry.ToolCallID]; ok && entry.ToolCallID != "" {
+		return trail
+	}
+	if entry.ChannelID == "" && l.latest != nil && !l.latest.closed {
+		return l.latest
+	}
+	if trail, ok := l.trails[entry.ChannelID]; ok {
+		return trail
+	}
+	return l.startLocked(entry.ChannelID)
+}
+
+// startLocked opens a channel's trail. Caller must hold l.mu.
+func (l *activityLog) startLocked(channelID string) *activityTrail {
+	trail := &activityTrail{
+		channelID: channelID,
+		startedAt: l.clock.Now(),
+		toolCalls: make(map[string]bool),
+	}
+	l.trails[channelID] = trail
+	l.latest = trail
+	return trail
+}
+
+// appendLocked adds an entry to a trail and restarts its idle timer. Caller
+// must hold l.mu.
+func (l *activityLog) appendLocked(trail *activityTrail, entry types.AgentActivityEntry) {
+	if entry.Timestamp == "" {
+		entry.Timestamp = l.clock.Now().Format(time.RFC3339)
+	}
+	if len(entry.Result) > activityResultMaxLen {
+		entry.Result = truncateString(entry.Result, activityResultMaxLen)
+	}
+	trail.entries = append(trail.entries, entry)
+
+	l.stopTimerLocked(trail)
+	if l.idleTimeout <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	timer := l.clock.NewTimer(l.idleTimeout)
+	trail.timer, trail.stopTimer = timer, stop
+	go func() {
+		select {
+		case <-timer.C():
+		case <-stop:
+			return
+		}
+		l.mu.Lock()
+		var finished *types.AgentActivityPayload
+		if trail.stopTimer == stop {
+			finished = l.closeLocked(trail)
+		}
+		l.mu.Unlock()
+		l.report(finished)
+	}()
+}
+
+// stopTimerLocked stops a trail's idle timer, if it has one. Caller must hold
+// l.mu.
+func (l *activityLog) stopTimerLocked(trail *activityTrail) {
+	if trail.timer == nil {
+		return
+	}
+	trail.timer.Stop()
+	close(trail.stopTimer)
+	trail.timer, trail.stopTimer = nil, nil
+}
+
+// closeLocked detaches a trail and builds its payload. Returns nil if the
+// trail was already closed. Caller must hold l.mu.
+func (l *activityLog) closeLocked(trail *activityTrail) *types.AgentActivityPayload {
+	if trail.closed {
+		return nil
+	}
+	trail.closed = true
+	l.stopTimerLocked(trail)
+	if l.trails[trail.channelID] == trail {
+		delete(l.trails, trail.channelID)
+	}
+	for toolCallID := range trail.toolCalls {
+		delete(l.toolCalls, toolCallID)
+	}
+	if l.latest == trail {
+		l.latest = nil
+	}
+
+	outcome := types.ActivityOutcomeSilent
+	if trail.responded {
+		outcome = types.ActivityOutcomeResponded
+	}
+
+	return &types.AgentActivityPayload{
+		ActionType:         types.AgentActionTypeActivity,
+		ChannelID:          trail.channelID,
+		ChannelName:        trail.channelName,
+		TriggerDescription: trail.triggerDescription,
+		TraceID:            trail.traceID,
+		Outcome:            outcome,
+		StartedAt:          trail.startedAt.Format(time.RFC3339),
+		EndedAt:            l.clock.Now().Format(time.RFC3339),
+		Entries:            trail.entries,
+	}
+}
+
+// report hands a finished trail to onClose
+func (l *activityLog) report(payload *types.AgentActivityPayload) {
+	if payload == nil || l.onClose == nil {
+		return
+	}
+	l.onClose(*payload)
+}
@@ -0,0 +1,234 @@
+package huma
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// collectActivity returns an onClose callback that stores reported trails
+func collectActivity() (func(types.AgentActivityPayload), func() []types.AgentActivityPayload) {
+	var mu sync.Mutex
+	var reported []types.AgentActivityPayload
+
+	onClose := func(p types.AgentActivityPayload) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, p)
+	}
+	get := func() []types.AgentActivityPayload {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]types.AgentActivityPayload(nil), reported...)
+	}
+	return onClose, get
+}
+
+func TestActivityLog_SilentTrigger(t *testing.T) {
+	onClose, reported := collectActivity()
+	log := newActivityLog(nil, 0, onClose)
+
+	log.Trigger("ch1", "general", "User alice in #general: hi all", "")
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolCall, ToolName: "fetch_channel_messages"})
+	log.Trigger("ch1", "general", "User bob in #general: hello", "")
+
+	got := reported()
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 finished trail, got %d", len(got))
+	}
+	if got[0].Outcome != types.ActivityOutcomeSilent {
+		t.Errorf("Expected outcome %q, got %q", types.ActivityOutcomeSilent, got[0].Outcome)
+	}
+	if got[0].ActionType != types.AgentActionTypeActivity {
+		t.Errorf("Expected action type %q, got %q", types.AgentActionTypeActivity, got[0].ActionType)
+	}
+	if len(got[0].Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(got[0].Entries))
+	}
+	if got[0].Entries[0].Kind != types.ActivityKindTrigger || got[0].Entries[1].Kind != types.ActivityKindToolCall {
+		t.Errorf("Unexpected entry kinds: %+v", got[0].Entries)
+	}
+}
+
+func TestActivityLog_RespondedTrail(t *testing.T) {
+	onClose, reported := collectActivity()
+	log := newActivityLog(nil, 0, onClose)
+
+	log.Trigger("ch1", "general", "User alice in #general: @bot help", "trace-1")
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindCanceled, ToolCallID: "t1", Reason: "Superseded by newer message"})
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindSent, ToolCallID: "t2", Message: "Sure!"})
+	log.Close()
+
+	got := reported()
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 finished trail, got %d", len(got))
+	}
+	if got[0].Outcome != types.ActivityOutcomeResponded {
+		t.Errorf("Expected outcome %q, got %q", types.ActivityOutcomeResponded, got[0].Outcome)
+	}
+	if got[0].ChannelName != "general" {
+		t.Errorf("Expected channel name 'general', got %q", got[0].ChannelName)
+	}
+	if got[0].TraceID != "trace-1" {
+		t.Errorf("Expected trace ID 'trace-1', got %q", got[0].TraceID)
+	}
+
+	// Closing again without a trail reports nothing
+	log.Close()
+	if len(reported()) != 1 {
+		t.Error("Expected no extra trail after closing an empty log")
+	}
+}
+
+func TestActivityLog_TrailsPerChannel(t *testing.T) {
+	onClose, reported := collectActivity()
+	log := newActivityLog(nil, 0, onClose)
+
+	log.Trigger("support", "support", "User alice in #support: how do I log in?", "")
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolCall, ChannelID: "support", ToolCallID: "t1", ToolName: "send_message"})
+	log.Trigger("general", "general", "User bob in #general: hi all", "")
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindSent, ChannelID: "support", ToolCallID: "t1", Message: "Use the reset link"})
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolResult, ToolCallID: "t1"})
+	log.Trigger("general", "general", "User bob in #general: anyone?", "")
+	log.Close()
+
+	// A trigger in #general leaves the #support trail open, and the send
+	// stays with it
+	outcomes := map[string][]string{}
+	for _, trail := range reported() {
+		outcomes[trail.ChannelID] = append(outcomes[trail.ChannelID], trail.Outcome)
+		if trail.ChannelID == "support" && len(trail.Entries) != 4 {
+			t.Errorf("Expected the tool call, send and result in the #support trail, got %+v", trail.Entries)
+		}
+	}
+	if len(outcomes["support"]) != 1 || outcomes["support"][0] != types.ActivityOutcomeResponded {
+		t.Errorf("Expected #support to have responded, got %v", outcomes["support"])
+	}
+	if len(outcomes["general"]) != 2 || outcomes["general"][0] != types.ActivityOutcomeSilent || outcomes["general"][1] != types.ActivityOutcomeSilent {
+		t.Errorf("Expected two silent #general trails, got %v", outcomes["general"])
+	}
+}
+
+func TestActivityLog_ToolCallOutlivesTrigger(t *testing.T) {
+	onClose, reported := collectActivity()
+	log := newActivityLog(nil, 0, onClose)
+
+	log.Trigger("general", "general", "User alice in #general: @helper help", "")
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolCall, ChannelID: "general", ToolCallID: "t1", ToolName: "send_message"})
+
+	// The next message arrives while the reply is still being typed
+	log.Trigger("general", "general", "User alice in #general: anyone?", "")
+	if len(reported()) != 0 {
+		t.Fatalf("Expected the first trail to stay open while its send is typed, got %+v", reported())
+	}
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindSent, ChannelID: "general", ToolCallID: "t1", Message: "Sure!"})
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolResult, ToolCallID: "t1"})
+
+	got := reported()
+	if len(got) != 1 || got[0].Outcome != types.ActivityOutcomeResponded || !strings.Contains(got[0].TriggerDescription, "help") || len(got[0].Entries) != 4 {
+		t.Fatalf("Expected the first trail to end with its send, got %+v", got)
+	}
+	log.Close()
+	if got := reported(); len(got) != 2 || got[1].Outcome != types.ActivityOutcomeSilent {
+		t.Errorf("Expected the second trail silent, got %+v", got)
+	}
+}
+
+func TestActivityLog_SuccessfulSendClosesTrail(t *testing.T) {
+	onClose, reported := collectActivity()
+	agent := newTestAgent(onClose)
+	agent.sender = stubSender{}
+	agent.tuning = Tuning{TypingWPM: 100000, MaxTypingDelay: time.Millisecond}
+	agent.bg = &background{}
+
+	agent.activity.Trigger("c1", "general", "User alice in #general: hi", "")
+	agent.handleToolCall("t1", "send_message", map[string]interface{}{"channel_id": "c1", "message": "hello"})
+	agent.bg.typing.wait(context.Background())
+
+	// The send's result finished its tool call, so the next trigger ends the trail
+	agent.activity.Trigger("c1", "general", "User bob in #general: thanks", "")
+	got := reported()
+	if len(got) != 1 {
+		t.Fatalf("Expected the trail to close with its send's result, got %d trails", len(got))
+	}
+	last := got[0].Entries[len(got[0].Entries)-1]
+	if got[0].Outcome != types.ActivityOutcomeResponded || last.Kind != types.ActivityKindToolResult || last.Success == nil || !*last.Success {
+		t.Errorf("Expected a responded trail ending with the send's result, got %+v", got[0])
+	}
+}
+
+func TestActivityLog_AgentClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := clock.NewVirtual(start)
+	onClose, reported := collectActivity()
+	log := newActivityLog(c, time.Minute, onClose)
+
+	log.Trigger("general", "general", "User alice in #general: hi", "")
+	c.Advance(30 * time.Second)
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolCall, ChannelID: "general", ToolCallID: "t1"})
+	c.Advance(59 * time.Second)
+	if len(reported()) != 0 {
+		t.Fatal("Expected the trail open until a minute without activity")
+	}
+	c.Advance(time.Second)
+
+	deadline := time.Now().Add(time.Second)
+	for len(reported()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	got := reported()
+	if len(got) != 1 || got[0].StartedAt != start.Format(time.RFC3339) || got[0].EndedAt != start.Add(90*time.Second).Format(time.RFC3339) {
+		t.Fatalf("Expected a trail timed on the virtual clock, got %+v", got)
+	}
+	if got[0].Entries[1].Timestamp != start.Add(30*time.Second).Format(time.RFC3339) {
+		t.Errorf("Expected entries stamped with virtual time, got %+v", got[0].Entries)
+	}
+}
+
+func TestActivityLog_IdleTimeout(t *testing.T) {
+	onClose, reported := collectActivity()
+	log := newActivityLog(nil, 20*time.Millisecond, onClose)
+
+	log.Trigger("ch1", "general", "User alice in #general: anyone here?", "")
+
+	deadline := time.Now().Add(time.Second)
+	for len(reported()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	got := reported()
+	if len(got) != 1 {
+		t.Fatalf("Expected trail to close after idle timeout, got %d", len(got))
+	}
+	if got[0].Outcome != types.ActivityOutcomeSilent {
+		t.Errorf("Expected outcome %q, got %q", types.ActivityOutcomeSilent, got[0].Outcome)
+	}
+}
+
+func TestActivityLog_TruncatesResults(t *testing.T) {
+	onClose, reported := collectActivity()
+	log := newActivityLog(nil, 0, onClose)
+
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindToolResult, Result: strings.Repeat("x", activityResultMaxLen*2)})
+	log.Close()
+
+	got := reported()
+	if len(got) != 1 || len(got[0].Entries) != 1 {
+		t.Fatalf("Expected 1 trail with 1 entry, got %+v", got)
+	}
+	if n := len(got[0].Entries[0].Result); n > activityResultMaxLen+3 {
+		t.Errorf("Expected result to be truncated, got %d chars", n)
+	}
+}
+
+func TestActivityLog_NilSafe(t *testing.T) {
+	var log *activityLog
+	log.Trigger("ch1", "general", "trigger", "")
+	log.Record(types.AgentActivityEntry{Kind: types.ActivityKindSent})
+	log.Close()
+}
@@ -0,0 +1,153 @@
+package huma
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// trigger is an event the agent was sent about a channel: what it was told,
+// when, and the span the tool calls it causes are parented to
+type trigger struct {
+	description string
+	at          time.Time
+	span        tracing.SpanContext
+}
+
+// channelState is what an agent tracks for one channel. Each channel has its
+// own pending send, so a send_message only supersedes an earlier one in the
+// same channel.
+type channelState struct {
+	name    string
+	trigger trigger
+	pending *PendingMessage
+	cancel  chan struct{} // closed to stop the pending message's typing
+}
+
+// channel returns a channel's state, creating it if needed. Callers hold
+// channelsMu.
+func (a *GuildAgent) channel(channelID string) *channelState {
+	if a.channels == nil {
+		a.channels = make(map[string]*channelState)
+	}
+	ch, ok := a.channels[channelID]
+	if !ok {
+		ch = &channelState{}
+		a.channels[channelID] = ch
+	}
+	return ch
+}
+
+// setCurrentChannel records that the agent is about to be told about a
+// channel, making it the one tool calls respond to by default
+func (a *GuildAgent) setCurrentChannel(channelID, channelName string) {
+	a.channelsMu.Lock()
+	defer a.channelsMu.Unlock()
+	a.channel(channelID).name = channelName
+	a.currentChannelID = channelID
+}
+
+// setTrigger records the event sent about a channel
+func (a *GuildAgent) setTrigger(channelID string, t trigger) {
+	a.channelsMu.Lock()
+	defer a.channelsMu.Unlock()
+	a.channel(channelID).trigger = t
+}
+
+// respondingTo returns the channel a tool call responds to and the event that
+// prompted it. A send_message responds to the channel it targets, if the agent
+// was told about that channel; anything else responds to the current channel.
+func (a *GuildAgent) respondingTo(toolName, targetChannelID string) (string, trigger) {
+	a.channelsMu.Lock()
+	defer a.channelsMu.Unlock()
+	if toolName == "send_message" {
+		if ch, ok := a.channels[targetChannelID]; ok && !ch.trigger.at.IsZero() {
+			return targetChannelID, ch.trigger
+		}
+	}
+	if ch, ok := a.channels[a.currentChannelID]; ok {
+		return a.currentChannelID, ch.trigger
+	}
+	return a.currentChannelID, trigger{}
+}
+
+// channelNameFor returns the name of a channel the agent has been told about
+func (a *GuildAgent) channelNameFor(channelID string) string {
+	a.channelsMu.Lock()
+	defer a.channelsMu.Unlock()
+	if ch, ok := a.channels[channelID]; ok {
+		return ch.name
+	}
+	return ""
+}
+
+// GetPendingMessages returns copies of the messages being typed, oldest first
+func (a *GuildAgent) GetPendingMessages() []PendingMessage {
+	a.channelsMu.Lock()
+	defer a.channelsMu.Unlock()
+
+	var pending []PendingMessage
+	for _, ch := range a.channels {
+		if ch.pending != nil {
+			pending = append(pending, *ch.pending)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].StartTime.Before(pending[j].StartTime) })
+	return pending
+}
+
+// CancelPendingMessages cancels the message being typed in a channel, or in
+// every channel if channelID is empty. Returns the canceled messages.
+func (a *GuildAgent) CancelPendingMessages(channelID, reason string) []PendingMessage {
+	return a.cancelPendingWhere(reason, func(pending *PendingMessage) bool {
+		return channelID == "" || pending.ChannelID == channelID
+	})
+}
+
+// cancelPendingWhere cancels the pending messages match selects and returns them
+func (a *GuildAgent) cancelPendingWhere(reason string, match func(*PendingMessage) bool) []PendingMessage {
+	a.channelsMu.Lock()
+	defer a.channelsMu.Unlock()
+
+	var canceled []PendingMessage
+	for _, ch := range a.channels {
+		if ch.pending == nil || !match(ch.pending) {
+			continue
+		}
+		a.logger().Info("Canceling pending message", logging.KeyChannelID, ch.pending.ChannelID, "tool_call_id", ch.pending.ToolCallID, "reason", reason)
+		canceled = append(canceled, *ch.pending)
+		a.cancelPendingLocked(ch, reason)
+	}
+	sort.Slice(canceled, func(i, j int) bool { return canceled[i].StartTime.Before(canceled[j].StartTime) })
+	return canceled
+}
+
+// cancelPendingLocked stops a channel's typing goroutine, records the
+// cancellation and tells HUMA the tool call was canceled. Caller must hold
+// channelsMu and the channel must have a pending message.
+func (a *GuildAgent) cancelPendingLocked(ch *channelState, reason string) {
+	pending := ch.pending
+	close(ch.cancel)
+	ch.pending = nil
+	ch.cancel = nil
+
+	a.activity.Record(types.AgentActivityEntry{
+		Kind:       types.ActivityKindCanceled,
+		ChannelID:  pending.ChannelID,
+		ToolCallID: pending.ToolCallID,
+		Reason:     reason,
+		Message:    pending.Message,
+	})
+	a.publish(events.Event{Type: events.TypeMessageCanceled, ChannelID: pending.ChannelID, ChannelName: ch.name, TraceID: a.toolCallSpan(pending.ToolCallID).TraceID(), ToolCallID: pending.ToolCallID, Reason: reason})
+	a.stats.RecordSuppressedResponse(a.userID, a.GuildID)
+
+	// Send canceled result
+	a.toolCallFinished(pending.ToolCallID)
+	if err := a.Client.SendToolCanceled(pending.ToolCallID, reason); err != nil {
+		a.logger().Warn("Error sending tool canceled", "tool_call_id", pending.ToolCallID, "error", err)
+	}
+}
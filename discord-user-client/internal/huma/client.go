@@ -5,19 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
 )
 
-const (
-	humaAPIURL = "https://api.humalike.tech"
-	humaWSURL  = "wss://api.humalike.tech"
-)
+// logger is used for Socket.IO protocol and connection events
+var logger = logging.For("huma")
+
+// DefaultBaseURL is the HUMA API used unless SetBaseURL changes it
+const DefaultBaseURL = "https://api.humalike.tech"
 
 // ToolCallHandler is called when HUMA requests a tool execution
 type ToolCallHandler func(toolCallID, toolName string, args map[string]interface{})
@@ -25,7 +29,9 @@ type ToolCallHandler func(toolCallID, toolName string, args map[string]interface
 // Client manages connection to HUMA API
 type Client struct {
 	apiKey           string
+	baseURL          string // REST API; the WebSocket URL is derived from it
 	agentID          string
+	timeout          time.Duration // REST calls and the WebSocket handshake
 	conn             *websocket.Conn
 	mu               sync.RWMutex
 	writeMu          sync.Mutex // Protects websocket writes
@@ -37,17 +43,59 @@ type Client struct {
 	stopChan         chan struct{}
 	doneChan         chan struct{}
 	readyChan        chan struct{} // Signals namespace is ready
+	recorder         *recording.Recorder
+	recordGuildID    string // guild the recorded frames belong to
 }
 
 // NewClient creates a new HUMA client
 func NewClient(apiKey string) *Client {
 	return &Client{
 		apiKey:   apiKey,
+		baseURL:  DefaultBaseURL,
+		timeout:  DefaultRequestTimeout,
 		stopChan: make(chan struct{}),
 		doneChan: make(chan struct{}),
 	}
 }
 
+// newClient creates a client for the manager's endpoint and tuning
+func newClient(apiKey, baseURL string, tuning Tuning) *Client {
+	client := NewClient(apiKey)
+	client.baseURL = baseURL
+	client.timeout = tuning.RequestTimeout
+	return client
+}
+
+// CheckAPIKey makes a read-only request to find out whether HUMA accepts the
+// API key, without creating an agent
+func (c *Client) CheckAPIKey() error {
+	req, err := http.NewRequest("GET", c.baseURL+"/api/agents", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	client := &http.Client{Timeout: c.timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach HUMA: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("API key rejected (status %d)", resp.StatusCode)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("HUMA unavailable (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// SetBaseURL points the client at another HUMA API. Call before use.
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 // CreateAgent creates a new HUMA agent via REST API
 func (c *Client) CreateAgent(name string, metadata AgentMetadata) (*CreateAgentResponse, error) {
 	reqBody := CreateAgentRequest{
@@ -61,7 +109,7 @@ func (c *Client) CreateAgent(name string, metadata AgentMetadata) (*CreateAgentR
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", humaAPIURL+"/api/agents", bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequest("POST", c.baseURL+"/api/agents", bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -69,7 +117,7 @@ func (c *Client) CreateAgent(name string, metadata AgentMetadata) (*CreateAgentR
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-API-Key", c.apiKey)
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: c.timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -91,7 +139,7 @@ func (c *Client) CreateAgent(name string, metadata AgentMetadata) (*CreateAgentR
 	}
 
 	c.agentID = agentResp.ID
-	log.Printf("[HUMA] Created agent: %s (ID: %s)", agentResp.Name, agentResp.ID)
+	logger.Info("Created agent", "name", agentResp.Name, "agent_id", agentResp.ID)
 	return &agentResp, nil
 }
 
@@ -107,11 +155,18 @@ func (c *Client) Connect(agentID string) error {
 	c.agentID = agentID
 
 	// Build WebSocket URL with query params
-	u, err := url.Parse(humaWSURL + "/socket.io/")
+	u, err := url.Parse(c.baseURL + "/socket.io/")
 	if err != nil {
 		c.mu.Unlock()
 		return fmt.Errorf("failed to parse URL: %w", err)
 	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	endpoint := u.String()
 
 	q := u.Query()
 	q.Set("agentId", agentID)
@@ -120,10 +175,11 @@ func (c *Client) Connect(agentID string) error {
 	q.Set("transport", "websocket")
 	u.RawQuery = q.Encode()
 
-	log.Printf("[HUMA] Connecting to WebSocket: %s", u.String())
+	// The query carries the API key, so only log the endpoint
+	logger.Info("Connecting to WebSocket", "endpoint", endpoint, "agent_id", agentID)
 
 	dialer := websocket.Dialer{
-		HandshakeTimeout: 30 * time.Second,
+		HandshakeTimeout: c.timeout,
 	}
 
 	conn, _, err := dialer.Dial(u.String(), nil)
@@ -147,7 +203,8 @@ func (c *Client) Connect(agentID string) error {
 	// Wait for namespace connection (with timeout)
 	select {
 	case <-c.readyChan:
-		log.Printf("[HUMA] Connected to agent %s (namespace ready)", agentID)
+		logger.Info("Connected to agent (namespace ready)", "agent_id", agentID)
+		metrics.HumaConnects.Inc()
 	case <-time.After(10 * time.Second):
 		c.Disconnect()
 		return fmt.Errorf("timeout waiting for namespace connection")
@@ -166,15 +223,16 @@ func (c *Client) Disconnect() {
 	}
 
 	close(c.stopChan)
-	<-c.doneChan
-
+	// Closing the socket unblocks a pending read, otherwise the reader only
+	// notices stopChan after the read deadline
 	if c.conn != nil {
 		c.conn.Close()
-		c.conn = nil
 	}
+	<-c.doneChan
+	c.conn = nil
 
 	c.connected = false
-	log.Printf("[HUMA] Disconnected from agent %s", c.agentID)
+	logger.Info("Disconnected from agent", "agent_id", c.agentID)
 }
 
 // IsConnected returns whether the client is connected
@@ -184,10 +242,42 @@ func (c *Client) IsConnected() bool {
 	return c.connected
 }
 
+// IsNamespaceReady returns whether events can be sent right now: the Socket.IO
+// namespace handshake has completed and the reader is still running. It never
+// blocks, a client that is busy connecting or disconnecting is not ready.
+func (c *Client) IsNamespaceReady() bool {
+	if !c.mu.TryRLock() {
+		return false
+	}
+	defer c.mu.RUnlock()
+
+	if !c.connected || c.conn == nil || !c.namespaceReady {
+		return false
+	}
+	// The reader exits on read errors without clearing connected
+	select {
+	case <-c.doneChan:
+		return false
+	default:
+		return true
+	}
+}
+
+// SetRecorder records the frames sent and received for the given guild.
+// Call it before Connect.
+func (c *Client) SetRecorder(recorder *recording.Recorder, guildID string) {
+	c.recorder = recorder
+	c.recordGuildID = guildID
+}
+
 // writeMessage safely writes a message to the websocket
 func (c *Client) writeMessage(data []byte) error {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	c.recorder.HumaSent(c.recordGuildID, c.agentID, data)
 	return c.conn.WriteMessage(websocket.TextMessage, data)
 }
 
@@ -231,7 +321,8 @@ func (c *Client) SendContextUpdate(eventName, description string, context map[st
 	}
 
 	message := "42" + string(jsonData)
-	log.Printf("[HUMA] Sending context update: %s", eventName)
+	logger.Debug("Sending context update", "event", eventName, "agent_id", c.agentID, "bytes", len(message))
+	metrics.HumaContextUpdateBytes.Observe(float64(len(message)), eventName)
 
 	if err := c.writeMessage([]byte(message)); err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
@@ -269,7 +360,9 @@ func (c *Client) SendToolResultWithOptions(toolCallID string, success bool, resu
 	if success {
 		content.Result = result
 	} else {
+		// A failure may carry a structured reason as its result
 		content.Error = errMsg
+		content.Result = result
 	}
 
 	if options != nil {
@@ -292,7 +385,7 @@ func (c *Client) SendToolResultWithOptions(toolCallID string, success bool, resu
 	}
 
 	message := "42" + string(jsonData)
-	log.Printf("[HUMA] Sending tool result for %s (success: %v)", toolCallID, success)
+	logger.Debug("Sending tool result", "tool_call_id", toolCallID, "success", success)
 
 	if err := c.writeMessage([]byte(message)); err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
@@ -330,7 +423,7 @@ func (c *Client) SendToolCanceled(toolCallID, reason string) error {
 	}
 
 	message := "42" + string(jsonData)
-	log.Printf("[HUMA] Sending tool canceled for %s: %s", toolCallID, reason)
+	logger.Debug("Sending tool canceled", "tool_call_id", toolCallID, "reason", reason)
 
 	if err := c.writeMessage([]byte(message)); err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
@@ -351,11 +444,17 @@ func (c *Client) readMessages() {
 			c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 			_, message, err := c.conn.ReadMessage()
 			if err != nil {
+				select {
+				case <-c.stopChan:
+					// Disconnect closed the socket
+					return
+				default:
+				}
 				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					log.Printf("[HUMA] WebSocket closed normally")
+					logger.Info("WebSocket closed normally", "agent_id", c.agentID)
 					return
 				}
-				log.Printf("[HUMA] Error reading message: %v", err)
+				logger.Warn("Error reading message", "agent_id", c.agentID, "error", err)
 				// Notify that connection is dead (timeout, reset, etc.)
 				if c.onConnectionDead != nil {
 					go c.onConnectionDead()
@@ -363,6 +462,7 @@ func (c *Client) readMessages() {
 				return
 			}
 
+			c.recorder.HumaReceived(c.recordGuildID, c.agentID, message)
 			c.handleMessage(message)
 		}
 	}
@@ -403,15 +503,15 @@ func (c *Client) handleMessage(message []byte) {
 	case msgStr[0] == '0':
 		// Engine.IO OPEN - server sent session info
 		// We need to send "40" to connect to the default namespace
-		log.Printf("[HUMA] Socket.IO handshake received, connecting to namespace...")
+		logger.Debug("Socket.IO handshake received, connecting to namespace")
 		if err := c.writeMessage([]byte("40")); err != nil {
-			log.Printf("[HUMA] Error sending namespace connect: %v", err)
+			logger.Error("Error sending namespace connect", "error", err)
 		}
 		return
 
 	case len(msgStr) >= 2 && msgStr[:2] == "40":
 		// Socket.IO CONNECT response - connected to namespace
-		log.Printf("[HUMA] Connected to Socket.IO namespace")
+		logger.Debug("Connected to Socket.IO namespace")
 		c.mu.Lock()
 		c.namespaceReady = true
 		// Signal that namespace is ready
@@ -426,7 +526,7 @@ func (c *Client) handleMessage(message []byte) {
 
 	case len(msgStr) >= 2 && msgStr[:2] == "41":
 		// Socket.IO DISCONNECT
-		log.Printf("[HUMA] Disconnected from Socket.IO namespace")
+		logger.Info("Disconnected from Socket.IO namespace", "agent_id", c.agentID)
 		return
 
 	case len(msgStr) >= 2 && msgStr[:2] == "42":
@@ -436,11 +536,11 @@ func (c *Client) handleMessage(message []byte) {
 
 	case len(msgStr) >= 2 && msgStr[:2] == "44":
 		// Socket.IO ERROR
-		log.Printf("[HUMA] Socket.IO error: %s", msgStr[2:])
+		logger.Error("Socket.IO error", "payload", msgStr[2:])
 		return
 
 	default:
-		log.Printf("[HUMA] Unknown message: %s", msgStr[:min(100, len(msgStr))])
+		logger.Warn("Unknown message", "payload", msgStr[:min(100, len(msgStr))])
 	}
 }
 
@@ -448,7 +548,7 @@ func (c *Client) handleMessage(message []byte) {
 func (c *Client) handleEventMessage(jsonStr string) {
 	var eventData []json.RawMessage
 	if err := json.Unmarshal([]byte(jsonStr), &eventData); err != nil {
-		log.Printf("[HUMA] Failed to parse event: %v", err)
+		logger.Error("Failed to parse event", "error", err)
 		return
 	}
 
@@ -467,31 +567,31 @@ func (c *Client) handleEventMessage(jsonStr string) {
 
 	var serverEvent ServerEvent
 	if err := json.Unmarshal(eventData[1], &serverEvent); err != nil {
-		log.Printf("[HUMA] Failed to parse server event: %v", err)
+		logger.Error("Failed to parse server event", "error", err)
 		return
 	}
 
 	switch serverEvent.Type {
 	case "status":
-		log.Printf("[HUMA] Agent status: %s", serverEvent.Status)
+		logger.Debug("Agent status", "agent_id", c.agentID, "status", serverEvent.Status)
 
 	case "tool-call":
-		log.Printf("[HUMA] Tool call received: %s (ID: %s)", serverEvent.ToolName, serverEvent.ToolCallID)
+		logger.Debug("Tool call received", "tool", serverEvent.ToolName, "tool_call_id", serverEvent.ToolCallID)
 		if c.onToolCall != nil {
 			c.onToolCall(serverEvent.ToolCallID, serverEvent.ToolName, serverEvent.Arguments)
 		}
 
 	case "cancel-tool-call":
-		log.Printf("[HUMA] Tool call canceled: %s (reason: %s)", serverEvent.ToolCallID, serverEvent.Reason)
+		logger.Debug("Tool call canceled", "tool_call_id", serverEvent.ToolCallID, "reason", serverEvent.Reason)
 		if c.onCancelToolCall != nil {
 			c.onCancelToolCall(serverEvent.ToolCallID, serverEvent.Reason)
 		}
 
 	case "error":
-		log.Printf("[HUMA] Error from server: %s (code: %s)", serverEvent.Message, serverEvent.Code)
+		logger.Error("Error from server", "agent_id", c.agentID, "message", serverEvent.Message, "code", serverEvent.Code)
 
 	default:
-		log.Printf("[HUMA] Unknown event type: %s", serverEvent.Type)
+		logger.Warn("Unknown event type", "type", serverEvent.Type)
 	}
 }
 
@@ -0,0 +1,358 @@
+// Package humatest is a fake HUMA API for tests and local simulation. It
+// creates agents, speaks the subset of Socket.IO the huma client uses, records
+// every event the client sends and lets the caller issue tool calls.
+package humatest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+)
+
+// Event is something the client sent to an agent: a context update
+// (Name is set) or a tool result (ToolResult is set)
+type Event struct {
+	AgentID     string
+	Name        string
+	Description string
+	Context     map[string]interface{}
+	ToolResult  *ToolResult
+}
+
+// ToolResult is the client's answer to a tool call
+type ToolResult struct {
+	ToolCallID string      `json:"toolCallId"`
+	Status     string      `json:"status,omitempty"`
+	Success    bool        `json:"success"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Canceled reports whether the tool call was canceled rather than completed
+func (r ToolResult) Canceled() bool {
+	return r.Status == "canceled"
+}
+
+// Server is a fake HUMA API. Point a huma.Manager at URL() with SetBaseURL.
+type Server struct {
+	server *httptest.Server
+	apiKey string
+
+	// OnEvent, if set, is called for every event the client sends, from the
+	// connection's read goroutine. Set it before agents connect.
+	OnEvent func(Event)
+
+	// OnFrame, if set, is called with every raw frame an agent sends, before
+	// it is handled. Set it before agents connect.
+	OnFrame func(agentID, frame string)
+
+	mu         sync.Mutex
+	agents     map[string]*agentConn // agentID -> live connection
+	created    []huma.CreateAgentRequest
+	events     []Event
+	results    map[string]ToolResult // toolCallID -> result
+	waiters    map[string][]chan ToolResult
+	connWaiter chan string
+	nextAgent  int
+	nextCall   int
+}
+
+// agentConn is one agent's Socket.IO connection
+type agentConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+// write sends one Engine.IO frame
+func (c *agentConn) write(frame string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, []byte(frame))
+}
+
+// NewServer starts a fake HUMA API that accepts requests with apiKey, or any
+// key if apiKey is empty. Close it when done.
+func NewServer(apiKey string) *Server {
+	s := &Server{
+		apiKey:     apiKey,
+		agents:     make(map[string]*agentConn),
+		results:    make(map[string]ToolResult),
+		waiters:    make(map[string][]chan ToolResult),
+		connWaiter: make(chan string, 64),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/agents", s.handleAgents)
+	mux.HandleFunc("/socket.io/", s.handleSocket)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL is the base URL to pass to huma.Manager.SetBaseURL
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close disconnects all agents and stops the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, agent := range s.agents {
+		agent.conn.Close()
+	}
+	s.mu.Unlock()
+	s.server.Close()
+}
+
+// authorized checks the API key the huma client sends
+func (s *Server) authorized(key string) bool {
+	return s.apiKey == "" || key == s.apiKey
+}
+
+// handleAgents answers GET (list, used to check the key) and POST (create)
+// on /api/agents
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r.Header.Get("X-API-Key")) {
+		http.Error(w, `{"error":"invalid API key"}`, http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[]"))
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req huma.CreateAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.nextAgent++
+	id := fmt.Sprintf("fake-agent-%d", s.nextAgent)
+	s.created = append(s.created, req)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(huma.CreateAgentResponse{
+		ID:        id,
+		Name:      req.Name,
+		AgentType: req.AgentType,
+		Metadata:  req.Metadata,
+		State:     "idle",
+	})
+}
+
+// handleSocket runs one agent's Socket.IO session
+func (s *Server) handleSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r.URL.Query().Get("apiKey")) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+	agentID := r.URL.Query().Get("agentId")
+
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	agent := &agentConn{conn: conn}
+	defer conn.Close()
+
+	// Engine.IO OPEN; the client answers with a namespace connect
+	if err := agent.write(`0{"sid":"fake","upgrades":[],"pingInterval":25000,"pingTimeout":20000}`); err != nil {
+		return
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			s.mu.Lock()
+			if s.agents[agentID] == agent {
+				delete(s.agents, agentID)
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		frame := string(data)
+		if s.OnFrame != nil {
+			s.OnFrame(agentID, frame)
+		}
+		switch {
+		case frame == "40":
+			if err := agent.write(`40{"sid":"fake"}`); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.agents[agentID] = agent
+			s.mu.Unlock()
+			select {
+			case s.connWaiter <- agentID:
+			default:
+			}
+		case strings.HasPrefix(frame, "42"):
+			s.handleEvent(agentID, frame[2:])
+		}
+	}
+}
+
+// clientEvent is the union of context updates and tool results
+type clientEvent struct {
+	Type    string `json:"type"`
+	Content struct {
+		ToolResult
+		Type        string                 `json:"type"`
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Context     map[string]interface{} `json:"context"`
+	} `json:"content"`
+}
+
+// handleEvent records a "message" event from the client
+func (s *Server) handleEvent(agentID, payload string) {
+	var frame []json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &frame); err != nil || len(frame) < 2 {
+		return
+	}
+	var decoded clientEvent
+	if err := json.Unmarshal(frame[1], &decoded); err != nil {
+		return
+	}
+
+	event := Event{AgentID: agentID}
+	if decoded.Content.Type == "tool-result" {
+		result := decoded.Content.ToolResult
+		event.ToolResult = &result
+	} else {
+		event.Name = decoded.Content.Name
+		event.Description = decoded.Content.Description
+		event.Context = decoded.Content.Context
+	}
+
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	var waiters []chan ToolResult
+	if event.ToolResult != nil {
+		s.results[event.ToolResult.ToolCallID] = *event.ToolResult
+		waiters = s.waiters[event.ToolResult.ToolCallID]
+		delete(s.waiters, event.ToolResult.ToolCallID)
+	}
+	onEvent := s.OnEvent
+	s.mu.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- *event.ToolResult
+	}
+	if onEvent != nil {
+		onEvent(event)
+	}
+}
+
+// WaitForAgent blocks until an agent connects and returns its ID
+func (s *Server) WaitForAgent(ctx context.Context) (string, error) {
+	select {
+	case agentID := <-s.connWaiter:
+		return agentID, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("no agent connected: %w", ctx.Err())
+	}
+}
+
+// Created returns the agent creation requests received so far
+func (s *Server) Created() []huma.CreateAgentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]huma.CreateAgentRequest(nil), s.created...)
+}
+
+// Events returns everything the client has sent so far
+func (s *Server) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// emit sends a server event to a connected agent
+func (s *Server) emit(agentID string, event huma.ServerEvent) error {
+	s.mu.Lock()
+	agent := s.agents[agentID]
+	s.mu.Unlock()
+	if agent == nil {
+		return fmt.Errorf("agent %s is not connected", agentID)
+	}
+
+	data, err := json.Marshal([]interface{}{"event", event})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return agent.write("42" + string(data))
+}
+
+// SendFrame sends a raw Engine.IO frame to a connected agent, such as a
+// recorded "42" event
+func (s *Server) SendFrame(agentID, frame string) error {
+	s.mu.Lock()
+	agent := s.agents[agentID]
+	s.mu.Unlock()
+	if agent == nil {
+		return fmt.Errorf("agent %s is not connected", agentID)
+	}
+	return agent.write(frame)
+}
+
+// CallTool asks the agent to run a tool and returns the tool call ID
+func (s *Server) CallTool(agentID, toolName string, args map[string]interface{}) (string, error) {
+	s.mu.Lock()
+	s.nextCall++
+	toolCallID := fmt.Sprintf("fake-call-%d", s.nextCall)
+	s.mu.Unlock()
+
+	err := s.emit(agentID, huma.ServerEvent{
+		Type:       "tool-call",
+		ToolCallID: toolCallID,
+		ToolName:   toolName,
+		Arguments:  args,
+	})
+	return toolCallID, err
+}
+
+// CancelToolCall tells the agent to cancel a tool call
+func (s *Server) CancelToolCall(agentID, toolCallID, reason string) error {
+	return s.emit(agentID, huma.ServerEvent{
+		Type:       "cancel-tool-call",
+		ToolCallID: toolCallID,
+		Reason:     reason,
+	})
+}
+
+// WaitToolResult blocks until the client answers a tool call
+func (s *Server) WaitToolResult(ctx context.Context, toolCallID string) (ToolResult, error) {
+	s.mu.Lock()
+	if result, ok := s.results[toolCallID]; ok {
+		s.mu.Unlock()
+		return result, nil
+	}
+	waiter := make(chan ToolResult, 1)
+	s.waiters[toolCallID] = append(s.waiters[toolCallID], waiter)
+	s.mu.Unlock()
+
+	select {
+	case result := <-waiter:
+		return result, nil
+	case <-ctx.Done():
+		return ToolResult{}, fmt.Errorf("no result for tool call %s: %w", toolCallID, ctx.Err())
+	}
+}
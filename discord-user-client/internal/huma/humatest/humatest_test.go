@@ -0,0 +1,114 @@
+package humatest_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+)
+
+// recordingSender captures what the agent posts
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *recordingSender) SendMessage(channelID, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, content)
+	return nil
+}
+
+func (s *recordingSender) SendTypingIndicator(channelID string) error { return nil }
+func (s *recordingSender) GetBotUsername() string                     { return "bot" }
+func (s *recordingSender) GetMonitoredChannelsForGuild(guildID string) []huma.MonitoredChannel {
+	return []huma.MonitoredChannel{{ID: "c1", Name: "general", CanRespond: true}}
+}
+func (s *recordingSender) GetAllChannelsForGuild(guildID string) []huma.ChannelInfo { return nil }
+func (s *recordingSender) FetchChannelMessages(guildID, channelID string, limit int) ([]history.Message, error) {
+	return nil, nil
+}
+
+func (s *recordingSender) Sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent...)
+}
+
+func TestServer_RoundTrip(t *testing.T) {
+	server := humatest.NewServer("key")
+	defer server.Close()
+
+	manager := huma.NewManager("key")
+	manager.SetBaseURL(server.URL())
+	manager.SetTuning(huma.Tuning{TypingWPM: 100000})
+
+	agent, err := manager.GetOrCreateAgent("g1", "Guild", "u1")
+	if err != nil {
+		t.Fatalf("GetOrCreateAgent: %v", err)
+	}
+	defer manager.DisconnectAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	agentID, err := server.WaitForAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agentID != agent.AgentID {
+		t.Errorf("Expected agent %s to connect, got %s", agent.AgentID, agentID)
+	}
+
+	sender := &recordingSender{}
+	agent.UpdateConfig(sender, history.NewMessageHistoryManager(), "", "", "", nil)
+	if err := agent.SendNewMessage(ctx, "c1", "general", "u2", "alice", "hi bot", "m1"); err != nil {
+		t.Fatalf("SendNewMessage: %v", err)
+	}
+
+	toolCallID, err := server.CallTool(agentID, "send_message", map[string]interface{}{"channel_id": "c1", "message": "hello"})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	result, err := server.WaitToolResult(ctx, toolCallID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Canceled() {
+		t.Errorf("Expected a successful result, got %+v", result)
+	}
+	if sent := sender.Sent(); len(sent) != 1 || sent[0] != "hello" {
+		t.Errorf("Expected hello to be sent, got %v", sent)
+	}
+
+	var sawMessage bool
+	for _, event := range server.Events() {
+		if event.Name == "new-message" {
+			sawMessage = true
+		}
+	}
+	if !sawMessage {
+		t.Error("Expected a new-message context update")
+	}
+}
+
+func TestServer_RejectsWrongKey(t *testing.T) {
+	server := humatest.NewServer("key")
+	defer server.Close()
+
+	client := huma.NewClient("wrong")
+	client.SetBaseURL(server.URL())
+	if err := client.CheckAPIKey(); err == nil {
+		t.Error("Expected the wrong key to be rejected")
+	}
+
+	client = huma.NewClient("key")
+	client.SetBaseURL(server.URL())
+	if err := client.CheckAPIKey(); err != nil {
+		t.Errorf("Expected the key to be accepted, got %v", err)
+	}
+}
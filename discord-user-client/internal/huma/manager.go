@@ -1,14 +1,22 @@
 package huma
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
@@ -17,6 +25,15 @@ import (
 const internalRules = `## Environment Description
 You are inside a Discord server with multiple channels and users. Most messages are NOT directed at you. Your default behavior is to STAY SILENT.
 
+## Message Classification
+Every new-message event carries fields that say whether the message involves you. They are computed from Discord data, so trust them over your own reading of the text:
+- "mentionsYou": true if you were @mentioned
+- "isReplyToYou": true if the message is a Discord reply to one of your messages
+- "nameMentioned": true if your name appears in the text without an @mention
+- "supportChannel": true if the channel is a support, help or ticket channel
+- "priority": the strongest of these - "mention", "reply", "name", "support" or "ambient"
+If "priority" is "ambient", the message is not for you: stay silent unless it breaks the server rules or asks a question about the server you can answer.
+
 ## When to Respond (ONLY these situations)
 You may ONLY use the send_message tool if ONE of these conditions is met:
 1. **Support tickets** - User is asking for help in a support channel or ticket
@@ -66,8 +83,9 @@ In ALL other situations, do NOT respond. This includes:
 
 // MonitoredChannel represents a channel the bot monitors
 type MonitoredChannel struct {
-	ID   string
-	Name string
+	ID         string
+	Name       string
+	CanRespond bool // the agent may send messages here
 }
 
 // ChannelInfo represents a Discord channel with its details
@@ -84,9 +102,37 @@ type MessageSender interface {
 	GetBotUsername() string
 	GetMonitoredChannelsForGuild(guildID string) []MonitoredChannel
 	GetAllChannelsForGuild(guildID string) []ChannelInfo
-	FetchChannelMessages(channelID string, limit int) ([]history.Message, error)
+	FetchChannelMessages(guildID, channelID string, limit int) ([]history.Message, error)
+}
+
+// ResponseChannels is implemented by senders that limit which channels the
+// agent may respond in. Senders without it allow every channel.
+type ResponseChannels interface {
+	CanRespondIn(guildID, channelID string) bool
+}
+
+// PauseChecker reports whether the agent is paused for a guild or channel
+type PauseChecker interface {
+	IsPaused(guildID, channelID string) bool
 }
 
+// pausedReason is the tool result and cancel reason used while a channel is paused
+const pausedReason = "Paused by operator"
+
+// supersededReason cancels a message still being typed when HUMA sends another
+const supersededReason = "Superseded by newer message"
+
+// IsClientCancelReason reports whether a message was canceled by this client
+// (superseded, paused or shutting down) rather than by HUMA
+func IsClientCancelReason(reason string) bool {
+	return reason == supersededReason || reason == pausedReason || reason == shutdownReason
+}
+
+var (
+	managerLogger = logging.For("huma-manager")
+	agentLogger   = logging.For("huma-agent")
+)
+
 // GuildAgent represents a HUMA agent for a specific guild
 type GuildAgent struct {
 	GuildID     string
@@ -102,33 +148,91 @@ type GuildAgent struct {
 	information string
 	websites    []types.WebsiteData
 
-	// Current channel being responded to (set when new message arrives)
-	currentChannelID   string
-	currentChannelName string
-	currentMu          sync.RWMutex
+	// Per-channel triggers and pending sends, and the channel of the latest
+	// event, which tool calls respond to unless they target another channel
+	channels         map[string]*channelState // channelID -> state
+	currentChannelID string
+	channelsMu       sync.Mutex
 
-	// Message queue for typing simulation
-	pendingMessage    *PendingMessage
-	pendingMu         sync.Mutex
-	cancelChan        chan struct{}
+	// Limits on sends, enforced whatever HUMA decides, and the guild's sends
+	// they are checked against
+	policy SendPolicy
+	sends  *sendLedger
 
 	// For reporting agent actions
-	backendClient          *backend.Client
-	userID                 string
-	lastTriggerDescription string
+	reporter backend.Reporter
+	stats    *backend.StatsReporter
+	pauses   PauseChecker
+	userID   string
+	activity *activityLog
+
+	// Tool call start times for latency metrics
+	toolCalls     map[string]toolCallStart // toolCallID -> start
+	toolCallsIdle chan struct{}            // closed once no tool call is in flight
+	toolCallsMu   sync.Mutex
+
+	// Dry-run agents are detached from the manager and never type or report
+	dryRun bool
+
+	// Typing speed and fetch defaults
+	tuning Tuning
+
+	// Time source for typing and latency; nil means the wall clock
+	clock clock.Clock
+
+	// Typing and report goroutines, shared with the manager for shutdown
+	bg *background
+}
+
+// clk returns the agent's clock
+func (a *GuildAgent) clk() clock.Clock {
+	if a.clock == nil {
+		return clock.Real
+	}
+	return a.clock
+}
+
+// logger returns the agent's logger, tagged with its guild for per-guild verbosity
+func (a *GuildAgent) logger() *slog.Logger {
+	return agentLogger.With(logging.KeyGuildID, a.GuildID)
+}
+
+// toolCallStart records when a tool call was received, and the channel and
+// event it responds to
+type toolCallStart struct {
+	toolName  string
+	startedAt time.Time
+	span      *tracing.Span
+	channelID string
+	trigger   trigger
 }
 
 // PendingMessage represents a message being typed
 type PendingMessage struct {
-	ToolCallID string
-	ChannelID  string
-	Message    string
-	StartTime  time.Time
+	ToolCallID string    `json:"toolCallId"`
+	ChannelID  string    `json:"channelId"`
+	Message    string    `json:"message"`
+	StartTime  time.Time `json:"startTime"`
+}
+
+// AgentState is a point-in-time view of an agent for the admin API
+type AgentState struct {
+	GuildID            string           `json:"guildId"`
+	GuildName          string           `json:"guildName"`
+	AgentID            string           `json:"agentId"`
+	UserID             string           `json:"userId"`
+	Connected          bool             `json:"connected"`
+	CurrentChannelID   string           `json:"currentChannelId,omitempty"`
+	CurrentChannelName string           `json:"currentChannelName,omitempty"`
+	LastTriggerAt      *time.Time       `json:"lastTriggerAt,omitempty"`
+	InFlightToolCalls  int              `json:"inFlightToolCalls"`
+	PendingMessages    []PendingMessage `json:"pendingMessages,omitempty"`
 }
 
 // Manager manages HUMA agents for multiple guilds
 type Manager struct {
 	apiKey        string
+	baseURL       string
 	agents        map[string]*GuildAgent // guildID -> agent
 	mu            sync.RWMutex
 	sender        MessageSender
@@ -137,17 +241,68 @@ type Manager struct {
 	rules         string
 	information   string
 	websites      []types.WebsiteData
-	backendClient *backend.Client
+	reporter      backend.Reporter
+	stats         *backend.StatsReporter
+	pauses        PauseChecker
+	tuning        Tuning
+	policy        SendPolicy
+	sends         map[string]*sendLedger // guildID -> send policy state, kept across agents
+	dryRunAgents  map[string][]string    // guildID -> idle dry-run HUMA agent IDs
+	clock         clock.Clock
+	recorder      *recording.Recorder
+	bg            *background
 }
 
 // NewManager creates a new HUMA manager
 func NewManager(apiKey string) *Manager {
 	return &Manager{
-		apiKey: apiKey,
-		agents: make(map[string]*GuildAgent),
+		apiKey:  apiKey,
+		baseURL: DefaultBaseURL,
+		agents:  make(map[string]*GuildAgent),
+		tuning:  DefaultTuning(),
+		clock:   clock.Real,
+		bg:      &background{},
 	}
 }
 
+// SetBaseURL points agents created from now on at another HUMA API, such as a
+// local fake
+func (m *Manager) SetBaseURL(baseURL string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetSendPolicy sets the send policy of agents created from now on. Until it
+// is called, every send is allowed.
+func (m *Manager) SetSendPolicy(policy SendPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = policy
+}
+
+// SetClock sets the time source of agents created from now on. Replay uses a
+// virtual clock so typing delays don't take real time.
+func (m *Manager) SetClock(c clock.Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = c
+}
+
+// Clock returns the time source agents are created with
+func (m *Manager) Clock() clock.Clock {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clock
+}
+
+// SetRecorder records the HUMA traffic of agents created from now on
+func (m *Manager) SetRecorder(recorder *recording.Recorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recorder = recorder
+}
+
 // SetMessageSender sets the message sender (Discord client)
 func (m *Manager) SetMessageSender(sender MessageSender) {
 	m.mu.Lock()
@@ -178,11 +333,38 @@ func (m *Manager) SetWebsites(websites []types.WebsiteData) {
 	m.websites = websites
 }
 
-// SetBackendClient sets the backend client for reporting agent actions
-func (m *Manager) SetBackendClient(client *backend.Client) {
+// SetReporter sets where agent actions and decision trails are reported: the
+// backend client, or a local sink in standalone mode
+func (m *Manager) SetReporter(reporter backend.Reporter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reporter = reporter
+}
+
+// SetStatsReporter sets the stats reporter used to aggregate agent metrics
+func (m *Manager) SetStatsReporter(stats *backend.StatsReporter) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.backendClient = client
+	m.stats = stats
+}
+
+// SetPauseChecker sets the kill switch consulted before every send
+func (m *Manager) SetPauseChecker(pauses PauseChecker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauses = pauses
+}
+
+// CancelPaused cancels the pending messages of a guild's agent that target
+// paused channels. Returns the canceled messages.
+func (m *Manager) CancelPaused(guildID string) []PendingMessage {
+	agent := m.GetAgent(guildID)
+	if agent == nil {
+		return nil
+	}
+	return agent.cancelPendingWhere(pausedReason, func(pending *PendingMessage) bool {
+		return agent.isPaused(pending.ChannelID)
+	})
 }
 
 // RemoveAgent removes an agent (called when connection is dead)
@@ -191,7 +373,8 @@ func (m *Manager) RemoveAgent(guildID string) {
 	defer m.mu.Unlock()
 
 	if agent, exists := m.agents[guildID]; exists {
-		log.Printf("[HUMA-Manager] Removing dead agent for guild %s", guildID)
+		managerLogger.Info("Removing dead agent", logging.KeyGuildID, guildID)
+		agent.activity.Close()
 		agent.Client.Disconnect()
 		delete(m.agents, guildID)
 	}
@@ -209,7 +392,8 @@ func (m *Manager) GetOrCreateAgent(guildID, guildName, userID string) (*GuildAge
 	}
 
 	// Create new HUMA client for this guild
-	client := NewClient(m.apiKey)
+	client := newClient(m.apiKey, m.baseURL, m.tuning)
+	client.SetRecorder(m.recorder, guildID)
 
 	// Build agent metadata
 	metadata := m.buildAgentMetadata(guildName)
@@ -217,11 +401,15 @@ func (m *Manager) GetOrCreateAgent(guildID, guildName, userID string) (*GuildAge
 	// Create agent via REST API
 	agentResp, err := client.CreateAgent(fmt.Sprintf("Discord-%s", guildName), metadata)
 	if err != nil {
+		metrics.HumaConnectErrors.Inc()
 		return nil, fmt.Errorf("failed to create agent: %w", err)
 	}
 
+	m.recorder.HumaAgent(guildID, agentResp.ID)
+
 	// Connect via WebSocket
 	if err := client.Connect(agentResp.ID); err != nil {
+		metrics.HumaConnectErrors.Inc()
 		return nil, fmt.Errorf("failed to connect to agent: %w", err)
 	}
 
@@ -236,11 +424,23 @@ func (m *Manager) GetOrCreateAgent(guildID, guildName, userID string) (*GuildAge
 		rules:         m.rules,
 		information:   m.information,
 		websites:      m.websites,
-		cancelChan:    make(chan struct{}),
-		backendClient: m.backendClient,
+		reporter:      m.reporter,
+		stats:         m.stats,
+		pauses:        m.pauses,
 		userID:        userID,
+		toolCalls:     make(map[string]toolCallStart),
+		tuning:        m.tuning,
+		policy:        m.policy,
+		sends:         m.sendLedgerLocked(guildID),
+		clock:         m.clock,
+		bg:            m.bg,
 	}
 
+	// Report each finished decision trail to the backend
+	agent.activity = newActivityLog(m.clock, activityIdleTimeout, func(payload types.AgentActivityPayload) {
+		agent.bg.goReport(func() { agent.reportAgentActivity(payload) })
+	})
+
 	// Set up tool call handlers
 	client.SetToolCallHandler(func(toolCallID, toolName string, args map[string]interface{}) {
 		agent.handleToolCall(toolCallID, toolName, args)
@@ -252,12 +452,12 @@ func (m *Manager) GetOrCreateAgent(guildID, guildName, userID string) (*GuildAge
 
 	// Handle connection death (timeout, reset, etc.) - remove agent so next message triggers reconnect
 	client.SetConnectionDeadHandler(func() {
-		log.Printf("[HUMA-Manager] Connection dead for guild %s, removing agent", guildID)
+		managerLogger.Warn("Connection dead, removing agent", logging.KeyGuildID, guildID)
 		m.RemoveAgent(guildID)
 	})
 
 	m.agents[guildID] = agent
-	log.Printf("[HUMA-Manager] Created agent for guild %s (%s)", guildName, guildID)
+	managerLogger.Info("Created agent", logging.KeyGuildID, guildID, "guild_name", guildName)
 
 	return agent, nil
 }
@@ -269,13 +469,65 @@ func (m *Manager) GetAgent(guildID string) *GuildAgent {
 	return m.agents[guildID]
 }
 
+// GetAgents returns all live agents
+func (m *Manager) GetAgents() []*GuildAgent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	agents := make([]*GuildAgent, 0, len(m.agents))
+	for _, agent := range m.agents {
+		agents = append(agents, agent)
+	}
+	return agents
+}
+
+// ReconnectAgent drops and re-establishes the WebSocket of a guild's agent,
+// keeping the same HUMA agent. If the reconnect fails the agent is removed so
+// the next message creates a fresh one.
+func (m *Manager) ReconnectAgent(guildID string) error {
+	agent := m.GetAgent(guildID)
+	if agent == nil {
+		return fmt.Errorf("no agent for guild %s", guildID)
+	}
+
+	managerLogger.Info("Reconnecting agent", logging.KeyGuildID, guildID, "agent_id", agent.AgentID)
+	metrics.HumaReconnects.Inc()
+	agent.Client.Disconnect()
+	if err := agent.Client.Connect(agent.AgentID); err != nil {
+		metrics.HumaConnectErrors.Inc()
+		m.RemoveAgent(guildID)
+		return fmt.Errorf("failed to reconnect agent: %w", err)
+	}
+	return nil
+}
+
+// RecreateAgent removes a guild's agent and creates a new HUMA agent in its place
+func (m *Manager) RecreateAgent(guildID string) (*GuildAgent, error) {
+	agent := m.GetAgent(guildID)
+	if agent == nil {
+		return nil, fmt.Errorf("no agent for guild %s", guildID)
+	}
+
+	managerLogger.Info("Recreating agent", logging.KeyGuildID, guildID, "agent_id", agent.AgentID)
+	m.RemoveAgent(guildID)
+	return m.GetOrCreateAgent(guildID, agent.GuildName, agent.userID)
+}
+
+// GetAgentCount returns the number of live agents
+func (m *Manager) GetAgentCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.agents)
+}
+
 // DisconnectAll disconnects all agents
 func (m *Manager) DisconnectAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	for guildID, agent := range m.agents {
-		log.Printf("[HUMA-Manager] Disconnecting agent for guild %s", guildID)
+		managerLogger.Info("Disconnecting agent", logging.KeyGuildID, guildID)
+		agent.activity.Close()
 		agent.Client.Disconnect()
 	}
 
@@ -327,12 +579,14 @@ IMPORTANT: When you receive a new-message event, you will see:
   - "name": Channel name (e.g., "general")
   - "conversationHistory": Full history of the last 50 messages in format "[timestamp] author: message"
   - The NEW message that triggered this event is always the LAST message in conversationHistory
-- "monitoredChannels": Array of ALL channels you have access to in this server, each with:
+- "monitoredChannels": Array of ALL channels you read in this server, each with:
   - "id": Channel ID
   - "name": Channel name
+  - "canRespond": Whether you may send messages there. NEVER send in a channel where it is false
   - "recentMessages": (optional) Last 5 messages from that channel if you've seen activity there
 
 ALWAYS read the currentChannel.conversationHistory to understand what was discussed. The last message is the one you're responding to.
+Several messages sent in quick succession in one channel arrive together as a single new-messages event instead. They are the LAST messages in conversationHistory, and are also listed oldest first in "newMessages" (each with "id", "authorId", "author", "content" and its own classification fields). The top-level classification fields then cover the whole batch. Treat them as one turn and respond at most once.
 You can also check monitoredChannels to see what channels exist and any recent activity in other channels.
 
 ## Rules
@@ -377,6 +631,7 @@ You can also check monitoredChannels to see what channels exist and any recent a
 - IMPORTANT: Never respond in a different channel than where the user asked you
 - IMPORTANT: Do NOT use this tool unless you are directly addressed or have valuable input
 - IMPORTANT: If you just sent a message, do NOT send another until a human responds
+- If a send fails as "Blocked by send policy", the message was NOT sent. The result's rule and reason say why; do not resend it before retryAfterSeconds, and usually just stay silent
 
 ### fetch_channel_messages
 - Use to READ conversation history from other channels (not to respond there!)
@@ -469,24 +724,141 @@ Always check these fields and follow any instructions in customRules strictly.`,
 	}
 }
 
-// SendNewMessage sends a new message event to HUMA
-func (a *GuildAgent) SendNewMessage(channelID, channelName, authorID, authorName, content, messageID string) error {
-	// Store current channel for tool handlers to reference
-	a.currentMu.Lock()
-	a.currentChannelID = channelID
-	a.currentChannelName = channelName
-	a.currentMu.Unlock()
+// NewMessage is a Discord message the agent is told about, with how the
+// client classified it
+type NewMessage struct {
+	ID         string
+	AuthorID   string
+	AuthorName string
+	Content    string
+
+	MentionsYou    bool // @mentions the agent's account
+	IsReplyToYou   bool // a Discord reply to one of the account's messages
+	NameMentioned  bool // uses the account's name without a mention
+	SupportChannel bool // sent in a support, help or ticket channel
+}
+
+// priority names the most direct way the message involves the account
+func (m NewMessage) priority() string {
+	switch {
+	case m.MentionsYou:
+		return "mention"
+	case m.IsReplyToYou:
+		return "reply"
+	case m.NameMentioned:
+		return "name"
+	case m.SupportChannel:
+		return "support"
+	default:
+		return "ambient"
+	}
+}
+
+// addClassification sets the context's classification fields. For a batch,
+// each flag is set if any message has it.
+func addClassification(humaContext map[string]interface{}, messages []NewMessage) {
+	var combined NewMessage
+	for _, msg := range messages {
+		combined.MentionsYou = combined.MentionsYou || msg.MentionsYou
+		combined.IsReplyToYou = combined.IsReplyToYou || msg.IsReplyToYou
+		combined.NameMentioned = combined.NameMentioned || msg.NameMentioned
+		combined.SupportChannel = combined.SupportChannel || msg.SupportChannel
+	}
+	humaContext["mentionsYou"] = combined.MentionsYou
+	humaContext["isReplyToYou"] = combined.IsReplyToYou
+	humaContext["nameMentioned"] = combined.NameMentioned
+	humaContext["supportChannel"] = combined.SupportChannel
+	humaContext["priority"] = combined.priority()
+}
+
+// SendNewMessage sends a new message event to HUMA. Spans are recorded under
+// the trace in ctx, and later tool calls are parented to it.
+func (a *GuildAgent) SendNewMessage(ctx context.Context, channelID, channelName, authorID, authorName, content, messageID string) error {
+	return a.SendNewMessages(ctx, channelID, channelName, []NewMessage{{ID: messageID, AuthorID: authorID, AuthorName: authorName, Content: content}})
+}
+
+// SendNewMessages tells HUMA about messages that arrived in a channel since
+// its last event, oldest first. One message is sent as a new-message event,
+// several as one new-messages event listing them all.
+func (a *GuildAgent) SendNewMessages(ctx context.Context, channelID, channelName string, messages []NewMessage) error {
+	_, err := a.sendNewMessages(ctx, channelID, channelName, messages)
+	return err
+}
+
+// sendNewMessages sends a new-message or new-messages event and returns the
+// context that was sent
+func (a *GuildAgent) sendNewMessages(ctx context.Context, channelID, channelName string, messages []NewMessage) (map[string]interface{}, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no new messages to send")
+	}
+
+	// Store current channel for tool handlers to reference. The messages are
+	// from other people, so the agent may speak there again.
+	a.setCurrentChannel(channelID, channelName)
+	a.recordHumanMessage(channelID)
 
 	// Build context with current state
-	context := a.buildContext(channelID, channelName)
+	_, buildSpan := tracing.Start(ctx, "huma.build_context")
+	humaContext := a.buildContext(channelID, channelName)
+	buildSpan.End()
+	if a.logger().Enabled(ctx, slog.LevelDebug) {
+		a.logger().DebugContext(ctx, "Built context", logging.KeyChannelID, channelID, "context", MarshalContext(humaContext))
+	}
 
-	description := fmt.Sprintf("User %s sent a new message in channel #%s: \"%s\". Review the conversationHistory field to see the full conversation context before responding.",
-		authorName, channelName, truncateString(content, 100))
+	addClassification(humaContext, messages)
 
-	// Store trigger description for agent action reporting
-	a.lastTriggerDescription = fmt.Sprintf("User %s in #%s: %s", authorName, channelName, truncateString(content, 200))
+	eventName := "new-message"
+	var description, triggerDescription string
+	if len(messages) == 1 {
+		msg := messages[0]
+		description = fmt.Sprintf("User %s sent a new message in channel #%s: \"%s\". Review the conversationHistory field to see the full conversation context before responding.",
+			msg.AuthorName, channelName, truncateString(msg.Content, 100))
+		triggerDescription = fmt.Sprintf("User %s in #%s: %s", msg.AuthorName, channelName, truncateString(msg.Content, 200))
+	} else {
+		eventName = "new-messages"
+		quoted := make([]string, 0, len(messages))
+		lines := make([]string, 0, len(messages))
+		newMessages := make([]map[string]interface{}, 0, len(messages))
+		for _, msg := range messages {
+			quoted = append(quoted, fmt.Sprintf("%s: \"%s\"", msg.AuthorName, truncateString(msg.Content, 100)))
+			lines = append(lines, fmt.Sprintf("%s: %s", msg.AuthorName, msg.Content))
+			newMessages = append(newMessages, map[string]interface{}{
+				"id":       msg.ID,
+				"authorId": msg.AuthorID,
+				"author":   msg.AuthorName,
+				"content":  msg.Content,
+				// Per-message classification, since the top-level fields cover the batch
+				"mentionsYou":    msg.MentionsYou,
+				"isReplyToYou":   msg.IsReplyToYou,
+				"nameMentioned":  msg.NameMentioned,
+				"supportChannel": msg.SupportChannel,
+				"priority":       msg.priority(),
+			})
+		}
+		humaContext["newMessages"] = newMessages
+		description = fmt.Sprintf("%d new messages were sent in channel #%s: %s. They are the last %d messages in the conversationHistory field; review it to see the full conversation context before responding.",
+			len(messages), channelName, strings.Join(quoted, "; "), len(messages))
+		triggerDescription = fmt.Sprintf("%d messages in #%s: %s", len(messages), channelName, truncateString(strings.Join(lines, " / "), 200))
+	}
 
-	return a.Client.SendContextUpdate("new-message", description, context)
+	// Store the trigger for the tool calls it causes
+	a.setTrigger(channelID, trigger{
+		description: triggerDescription,
+		at:          a.clk().Now(),
+		span:        tracing.SpanContextFromContext(ctx),
+	})
+	a.activity.Trigger(channelID, channelName, triggerDescription, tracing.TraceIDFromContext(ctx))
+
+	_, sendSpan := tracing.Start(ctx, "huma.send_context")
+	defer sendSpan.End()
+	err := a.Client.SendContextUpdate(eventName, description, humaContext)
+	sendSpan.RecordError(err)
+	if err != nil {
+		a.publish(events.Event{Type: events.TypeError, ChannelID: channelID, ChannelName: channelName, TraceID: tracing.TraceIDFromContext(ctx), Reason: "huma.send_context", Error: err.Error()})
+	} else {
+		a.publish(events.Event{Type: events.TypeContextSent, ChannelID: channelID, ChannelName: channelName, TraceID: tracing.TraceIDFromContext(ctx)})
+	}
+	return humaContext, err
 }
 
 // buildContext builds the context object for HUMA
@@ -511,8 +883,9 @@ func (a *GuildAgent) buildContext(currentChannelID, currentChannelName string) m
 		channels := a.sender.GetMonitoredChannelsForGuild(a.GuildID)
 		for _, ch := range channels {
 			channelInfo := map[string]interface{}{
-				"id":   ch.ID,
-				"name": ch.Name,
+				"id":         ch.ID,
+				"name":       ch.Name,
+				"canRespond": ch.CanRespond,
 			}
 
 			// For non-current channels, include last 5 messages
@@ -602,66 +975,192 @@ func (a *GuildAgent) buildContext(currentChannelID, currentChannelName string) m
 
 // handleToolCall handles tool calls from HUMA
 func (a *GuildAgent) handleToolCall(toolCallID, toolName string, args map[string]interface{}) {
+	a.stats.RecordToolCall(a.userID, a.GuildID)
+	channelID, _ := args["channel_id"].(string)
+	a.toolCallStarted(toolCallID, toolName, channelID)
+	respondToChannelID, _ := a.toolCallTrigger(toolCallID)
+	a.activity.Record(types.AgentActivityEntry{
+		Kind:       types.ActivityKindToolCall,
+		ChannelID:  respondToChannelID,
+		ToolCallID: toolCallID,
+		ToolName:   toolName,
+		Arguments:  args,
+	})
+	a.publish(events.Event{
+		Type:        events.TypeToolCall,
+		ChannelID:   channelID,
+		ChannelName: a.channelNameFor(channelID),
+		TraceID:     a.toolCallSpan(toolCallID).TraceID(),
+		ToolCallID:  toolCallID,
+		ToolName:    toolName,
+	})
+
 	switch toolName {
 	case "send_message":
 		a.handleSendMessage(toolCallID, args)
 	case "fetch_channel_messages":
 		a.handleFetchChannelMessages(toolCallID, args)
 	default:
-		log.Printf("[HUMA-Agent] Unknown tool: %s", toolName)
-		a.Client.SendToolResult(toolCallID, false, nil, fmt.Sprintf("Unknown tool: %s", toolName))
+		a.logger().Warn("Unknown tool", "tool", toolName)
+		a.sendToolResult(toolCallID, false, nil, fmt.Sprintf("Unknown tool: %s", toolName))
 	}
 }
 
+// sendToolResult sends a tool result to HUMA and records it in the activity log
+func (a *GuildAgent) sendToolResult(toolCallID string, success bool, result interface{}, errMsg string) error {
+	return a.sendToolResultWithOptions(toolCallID, success, result, errMsg, nil)
+}
+
+// sendToolResultWithOptions is sendToolResult with additional options
+func (a *GuildAgent) sendToolResultWithOptions(toolCallID string, success bool, result interface{}, errMsg string, options *ToolResultOptions) error {
+	entry := types.AgentActivityEntry{
+		Kind:       types.ActivityKindToolResult,
+		ToolCallID: toolCallID,
+		Success:    &success,
+		Reason:     errMsg,
+	}
+	if text, ok := result.(string); ok {
+		entry.Result = text
+	}
+	a.activity.Record(entry)
+	if !success {
+		a.toolCallSpan(toolCallID).RecordError(fmt.Errorf("%s", errMsg))
+	}
+	a.toolCallFinished(toolCallID)
+
+	return a.Client.SendToolResultWithOptions(toolCallID, success, result, errMsg, options)
+}
+
+// toolCallStarted records the start time of a tool call and the event it
+// responds to, and opens its span under that event's trace. targetChannelID
+// is the channel_id argument, if any.
+func (a *GuildAgent) toolCallStarted(toolCallID, toolName, targetChannelID string) {
+	channelID, trig := a.respondingTo(toolName, targetChannelID)
+
+	_, span := tracing.StartWithParent(context.Background(), "huma.tool_call", trig.span)
+	span.SetAttribute("tool.name", toolName)
+	span.SetAttribute("tool_call.id", toolCallID)
+	span.SetAttribute("guild.id", a.GuildID)
+
+	a.toolCallsMu.Lock()
+	defer a.toolCallsMu.Unlock()
+	if a.toolCalls == nil {
+		a.toolCalls = make(map[string]toolCallStart)
+	}
+	a.toolCalls[toolCallID] = toolCallStart{toolName: toolName, startedAt: a.clk().Now(), span: span, channelID: channelID, trigger: trig}
+}
+
+// toolCallTrigger returns the channel an in-flight tool call responds to and
+// the event that prompted it
+func (a *GuildAgent) toolCallTrigger(toolCallID string) (string, trigger) {
+	a.toolCallsMu.Lock()
+	defer a.toolCallsMu.Unlock()
+	start := a.toolCalls[toolCallID]
+	return start.channelID, start.trigger
+}
+
+// toolCallSpan returns the span of an in-flight tool call, or nil
+func (a *GuildAgent) toolCallSpan(toolCallID string) *tracing.Span {
+	a.toolCallsMu.Lock()
+	defer a.toolCallsMu.Unlock()
+	return a.toolCalls[toolCallID].span
+}
+
+// toolCallFinished observes the latency of a completed or canceled tool call
+// and ends its span
+func (a *GuildAgent) toolCallFinished(toolCallID string) {
+	a.toolCallsMu.Lock()
+	start, ok := a.toolCalls[toolCallID]
+	delete(a.toolCalls, toolCallID)
+	if len(a.toolCalls) == 0 && a.toolCallsIdle != nil {
+		close(a.toolCallsIdle)
+		a.toolCallsIdle = nil
+	}
+	a.toolCallsMu.Unlock()
+
+	if ok {
+		metrics.HumaToolCallDuration.Observe(a.clk().Now().Sub(start.startedAt).Seconds(), start.toolName)
+		start.span.End()
+	}
+}
+
+// idle returns a channel that is closed once no tool call is in flight
+func (a *GuildAgent) idle() <-chan struct{} {
+	a.toolCallsMu.Lock()
+	defer a.toolCallsMu.Unlock()
+	if len(a.toolCalls) == 0 {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	if a.toolCallsIdle == nil {
+		a.toolCallsIdle = make(chan struct{})
+	}
+	return a.toolCallsIdle
+}
+
 // handleSendMessage handles the send_message tool call
 func (a *GuildAgent) handleSendMessage(toolCallID string, args map[string]interface{}) {
 	// Parse arguments
 	channelID, ok := args["channel_id"].(string)
 	if !ok {
-		a.Client.SendToolResult(toolCallID, false, nil, "Missing or invalid channel_id")
+		a.sendToolResult(toolCallID, false, nil, "Missing or invalid channel_id")
 		return
 	}
 
 	message, ok := args["message"].(string)
 	if !ok {
-		a.Client.SendToolResult(toolCallID, false, nil, "Missing or invalid message")
+		a.sendToolResult(toolCallID, false, nil, "Missing or invalid message")
 		return
 	}
 
-	log.Printf("[HUMA-Agent] send_message called: channel=%s, message=%s", channelID, truncateString(message, 50))
-
-	a.pendingMu.Lock()
+	a.logger().Info("send_message called", logging.KeyChannelID, channelID, "content", truncateString(message, 50))
 
-	// If there's already a pending message, cancel it
-	if a.pendingMessage != nil {
-		log.Printf("[HUMA-Agent] Canceling previous pending message (ID: %s)", a.pendingMessage.ToolCallID)
+	if a.isPaused(channelID) {
+		a.logger().Info("Dropping send_message for paused channel", logging.KeyChannelID, channelID, "tool_call_id", toolCallID)
+		a.stats.RecordSuppressedResponse(a.userID, a.GuildID)
+		a.publish(events.Event{Type: events.TypeMessageCanceled, ChannelID: channelID, ChannelName: a.channelNameFor(channelID), TraceID: a.toolCallSpan(toolCallID).TraceID(), ToolCallID: toolCallID, Reason: pausedReason})
+		a.sendToolResult(toolCallID, false, nil, pausedReason)
+		return
+	}
+	if a.refuseWhileDraining(toolCallID, channelID) {
+		return
+	}
+	if responder, ok := a.sender.(ResponseChannels); ok && !responder.CanRespondIn(a.GuildID, channelID) {
+		a.logger().Info("Refusing send_message to a channel the agent may not respond in", logging.KeyChannelID, channelID, "tool_call_id", toolCallID)
+		a.stats.RecordSuppressedResponse(a.userID, a.GuildID)
+		a.sendToolResult(toolCallID, false, nil, fmt.Sprintf("You may not respond in channel %s; only send in monitoredChannels with canRespond", channelID))
+		return
+	}
 
-		// Send canceled result for previous message
-		go a.Client.SendToolCanceled(a.pendingMessage.ToolCallID, "Superseded by newer message")
+	if violation := a.checkSend(channelID, message); violation != nil {
+		a.blockSend(toolCallID, channelID, violation)
+		return
+	}
 
-		// Signal cancellation
-		select {
-		case a.cancelChan <- struct{}{}:
-		default:
-		}
+	a.channelsMu.Lock()
+	ch := a.channel(channelID)
 
-		// Create new cancel channel
-		a.cancelChan = make(chan struct{})
+	// A message already being typed in this channel is superseded; other
+	// channels' messages are left alone
+	if ch.pending != nil {
+		a.logger().Info("Canceling previous pending message", logging.KeyChannelID, channelID, "tool_call_id", ch.pending.ToolCallID)
+		a.cancelPendingLocked(ch, supersededReason)
 	}
 
 	// Set new pending message
-	a.pendingMessage = &PendingMessage{
+	ch.pending = &PendingMessage{
 		ToolCallID: toolCallID,
 		ChannelID:  channelID,
 		Message:    message,
-		StartTime:  time.Now(),
+		StartTime:  a.clk().Now(),
 	}
-
-	cancelChan := a.cancelChan
-	a.pendingMu.Unlock()
+	cancel := make(chan struct{})
+	ch.cancel = cancel
+	a.channelsMu.Unlock()
 
 	// Process message with typing simulation in goroutine
-	go a.processMessageWithTyping(toolCallID, channelID, message, cancelChan)
+	a.bg.goTyping(func() { a.processMessageWithTyping(toolCallID, channelID, message, cancel) })
 }
 
 // handleFetchChannelMessages handles the fetch_channel_messages tool call
@@ -669,36 +1168,34 @@ func (a *GuildAgent) handleFetchChannelMessages(toolCallID string, args map[stri
 	// Parse channel_id
 	channelID, ok := args["channel_id"].(string)
 	if !ok {
-		a.Client.SendToolResult(toolCallID, false, nil, "Missing or invalid channel_id")
+		a.sendToolResult(toolCallID, false, nil, "Missing or invalid channel_id")
 		return
 	}
 
-	// Parse limit (optional, default 50)
-	limit := 50
+	// Parse limit (optional, default from tuning)
+	limit := a.tuning.withDefaults().FetchLimit
 	if limitVal, ok := args["limit"].(float64); ok {
 		limit = int(limitVal)
 	}
 
-	log.Printf("[HUMA-Agent] fetch_channel_messages called: channel=%s, limit=%d", channelID, limit)
+	a.logger().Info("fetch_channel_messages called", logging.KeyChannelID, channelID, "limit", limit)
 
 	if a.sender == nil {
-		a.Client.SendToolResult(toolCallID, false, nil, "No message sender available")
+		a.sendToolResult(toolCallID, false, nil, "No message sender available")
 		return
 	}
 
 	// Fetch messages from Discord
-	messages, err := a.sender.FetchChannelMessages(channelID, limit)
+	messages, err := a.sender.FetchChannelMessages(a.GuildID, channelID, limit)
 	if err != nil {
-		log.Printf("[HUMA-Agent] Error fetching messages: %v", err)
-		a.Client.SendToolResult(toolCallID, false, nil, fmt.Sprintf("Failed to fetch messages: %v", err))
+		a.logger().Error("Error fetching messages", logging.KeyChannelID, channelID, "error", err)
+		a.sendToolResult(toolCallID, false, nil, fmt.Sprintf("Failed to fetch messages: %v", err))
 		return
 	}
 
-	// Get the current channel that we should respond to
-	a.currentMu.RLock()
-	respondToChannelID := a.currentChannelID
-	respondToChannelName := a.currentChannelName
-	a.currentMu.RUnlock()
+	// Get the channel this tool call responds to
+	respondToChannelID, _ := a.toolCallTrigger(toolCallID)
+	respondToChannelName := a.channelNameFor(respondToChannelID)
 
 	// Format messages as readable text with clear markers
 	var result string
@@ -715,67 +1212,132 @@ func (a *GuildAgent) handleFetchChannelMessages(toolCallID string, args map[stri
 	result += fmt.Sprintf("## END OF FETCHED MESSAGES\n")
 	result += fmt.Sprintf("## IMPORTANT: Use send_message with channel_id=\"%s\" (channel #%s) - NOT %s\n", respondToChannelID, respondToChannelName, channelID)
 
-	log.Printf("[HUMA-Agent] Fetched %d messages from channel %s", len(messages), channelID)
-	a.Client.SendToolResult(toolCallID, true, result, "")
+	a.logger().Debug("Fetched messages", logging.KeyChannelID, channelID, "count", len(messages))
+	a.sendToolResult(toolCallID, true, result, "")
 }
 
 // processMessageWithTyping sends a message with typing simulation
-func (a *GuildAgent) processMessageWithTyping(toolCallID, channelID, message string, cancelChan chan struct{}) {
+func (a *GuildAgent) processMessageWithTyping(toolCallID, channelID, message string, cancel chan struct{}) {
 	if a.sender == nil {
-		a.Client.SendToolResult(toolCallID, false, nil, "No message sender available")
+		a.sendToolResult(toolCallID, false, nil, "No message sender available")
 		return
 	}
+	_, trig := a.toolCallTrigger(toolCallID)
+
+	// Calculate typing delay from the configured WPM; dry runs don't wait
+	tuning := a.tuning.withDefaults()
+	delay := tuning.typingDelay(message)
+	if a.dryRun {
+		delay = 0
+	} else {
+		metrics.TypingDelay.Observe(delay.Seconds())
+	}
+	a.logger().Debug("Simulating typing", logging.KeyChannelID, channelID, "wpm", tuning.TypingWPM, "delay", delay)
 
-	// Calculate typing delay for 90 WPM
-	delay := calculateTypingDelay(message)
-	log.Printf("[HUMA-Agent] Simulating typing for %v at 90 WPM", delay)
+	toolSpan := a.toolCallSpan(toolCallID)
+	traceCtx := tracing.ContextWithSpan(context.Background(), toolSpan)
+	_, typingSpan := tracing.Start(traceCtx, "agent.typing")
+	typingSpan.SetAttribute("typing.delay_ms", delay.Milliseconds())
+	defer typingSpan.End()
 
 	// Start typing indicator
 	if err := a.sender.SendTypingIndicator(channelID); err != nil {
-		log.Printf("[HUMA-Agent] Error sending typing indicator: %v", err)
+		a.logger().Warn("Error sending typing indicator", logging.KeyChannelID, channelID, "error", err)
 	}
+	a.publish(events.Event{
+		Type:        events.TypeTypingStarted,
+		ChannelID:   channelID,
+		ChannelName: a.channelNameFor(channelID),
+		TraceID:     toolSpan.TraceID(),
+		ToolCallID:  toolCallID,
+		Content:     message,
+		DelayMs:     delay.Milliseconds(),
+	})
 
 	// Typing indicator loop
-	typingTicker := time.NewTicker(8 * time.Second)
+	typingTicker := a.clk().NewTicker(8 * time.Second)
 	defer typingTicker.Stop()
 
 	// Wait for typing delay or cancellation
-	delayTimer := time.NewTimer(delay)
+	delayTimer := a.clk().NewTimer(delay)
 	defer delayTimer.Stop()
 
 	for {
 		select {
-		case <-cancelChan:
-			log.Printf("[HUMA-Agent] Message sending canceled (ID: %s)", toolCallID)
+		case <-cancel:
+			a.logger().Info("Message sending canceled", "tool_call_id", toolCallID)
+			typingSpan.SetAttribute("canceled", true)
 			return
 
-		case <-delayTimer.C:
+		case <-delayTimer.C():
 			// Delay complete, send message
-			a.pendingMu.Lock()
-			// Double-check this is still the current pending message
-			if a.pendingMessage == nil || a.pendingMessage.ToolCallID != toolCallID {
-				a.pendingMu.Unlock()
-				log.Printf("[HUMA-Agent] Message no longer pending, skipping (ID: %s)", toolCallID)
+			a.channelsMu.Lock()
+			ch := a.channel(channelID)
+			// Double-check this is still the channel's pending message
+			if ch.pending == nil || ch.pending.ToolCallID != toolCallID {
+				a.channelsMu.Unlock()
+				a.logger().Debug("Message no longer pending, skipping", "tool_call_id", toolCallID)
+				return
+			}
+			// A pause may have landed while typing
+			if a.isPaused(channelID) {
+				a.logger().Info("Channel paused while typing, dropping message", logging.KeyChannelID, channelID, "tool_call_id", toolCallID)
+				a.cancelPendingLocked(ch, pausedReason)
+				a.channelsMu.Unlock()
+				typingSpan.SetAttribute("canceled", true)
+				return
+			}
+			ch.pending = nil
+			ch.cancel = nil
+			// Another send may have gone out while this one was typed
+			a.channelsMu.Unlock()
+			record, violation := a.checkAndRecordSend(channelID, message)
+			if violation != nil {
+				typingSpan.SetAttribute("canceled", true)
+				a.blockSend(toolCallID, channelID, violation)
 				return
 			}
-			a.pendingMessage = nil
-			a.pendingMu.Unlock()
+			typingSpan.End()
 
 			// Send the message
-			if err := a.sender.SendMessage(channelID, message); err != nil {
-				log.Printf("[HUMA-Agent] Error sending message: %v", err)
-				a.Client.SendToolResult(toolCallID, false, nil, fmt.Sprintf("Failed to send message: %v", err))
+			_, sendSpan := tracing.Start(traceCtx, "discord.send")
+			sendSpan.SetAttribute("channel.id", channelID)
+			err := a.sender.SendMessage(channelID, message)
+			sendSpan.RecordError(err)
+			sendSpan.End()
+			if err != nil {
+				a.takeBackSend(channelID, record)
+				a.logger().Error("Error sending message", logging.KeyChannelID, channelID, "tool_call_id", toolCallID, "error", err)
+				a.publish(events.Event{Type: events.TypeError, ChannelID: channelID, ChannelName: a.channelNameFor(channelID), TraceID: toolSpan.TraceID(), ToolCallID: toolCallID, Reason: "discord.send", Error: err.Error()})
+				a.sendToolResult(toolCallID, false, nil, fmt.Sprintf("Failed to send message: %v", err))
 				return
 			}
 
-			log.Printf("[HUMA-Agent] Message sent successfully (ID: %s)", toolCallID)
+			a.logger().Info("Message sent successfully", logging.KeyChannelID, channelID, "tool_call_id", toolCallID)
+			a.publish(events.Event{Type: events.TypeMessageSent, ChannelID: channelID, ChannelName: a.channelNameFor(channelID), TraceID: toolSpan.TraceID(), ToolCallID: toolCallID, Content: message})
+
+			a.stats.RecordMessageSent(a.userID, a.GuildID)
+			a.activity.Record(types.AgentActivityEntry{
+				Kind:       types.ActivityKindSent,
+				ChannelID:  channelID,
+				ToolCallID: toolCallID,
+				Message:    message,
+			})
+			a.stats.RecordTypingTime(a.userID, a.GuildID, delay)
+			if !trig.at.IsZero() {
+				a.stats.RecordResponseLatency(a.userID, a.GuildID, a.clk().Now().Sub(trig.at))
+			}
 
 			// Report agent action to backend (async)
-			go a.reportAgentAction(channelID, message)
+			if !a.dryRun {
+				triggerDescription := trig.description
+				traceID := toolSpan.TraceID()
+				a.bg.goReport(func() { a.reportAgentAction(channelID, message, triggerDescription, traceID) })
+			}
 
 			// Build updated conversation history including the new bot message
 			updatedHistory := a.buildUpdatedConversationHistory(channelID, message)
-			a.Client.SendToolResultWithOptions(toolCallID, true, "Message sent successfully", "", &ToolResultOptions{
+			a.sendToolResultWithOptions(toolCallID, true, "Message sent successfully", "", &ToolResultOptions{
 				SkipImmediateProcessing: true,
 				Context: map[string]interface{}{
 					"conversationHistory": updatedHistory,
@@ -783,10 +1345,10 @@ func (a *GuildAgent) processMessageWithTyping(toolCallID, channelID, message str
 			})
 			return
 
-		case <-typingTicker.C:
+		case <-typingTicker.C():
 			// Refresh typing indicator
 			if err := a.sender.SendTypingIndicator(channelID); err != nil {
-				log.Printf("[HUMA-Agent] Error refreshing typing indicator: %v", err)
+				a.logger().Warn("Error refreshing typing indicator", logging.KeyChannelID, channelID, "error", err)
 			}
 		}
 	}
@@ -794,24 +1356,52 @@ func (a *GuildAgent) processMessageWithTyping(toolCallID, channelID, message str
 
 // handleCancelToolCall handles tool cancellation from HUMA
 func (a *GuildAgent) handleCancelToolCall(toolCallID, reason string) {
-	a.pendingMu.Lock()
-	defer a.pendingMu.Unlock()
+	a.cancelPendingWhere(reason, func(pending *PendingMessage) bool {
+		return pending.ToolCallID == toolCallID
+	})
+}
 
-	if a.pendingMessage != nil && a.pendingMessage.ToolCallID == toolCallID {
-		log.Printf("[HUMA-Agent] Canceling tool call %s: %s", toolCallID, reason)
+// publish sends a live activity event for this guild. Dry-run agents stay silent.
+func (a *GuildAgent) publish(event events.Event) {
+	if a.dryRun {
+		return
+	}
+	event.GuildID = a.GuildID
+	events.Publish(event)
+}
 
-		// Signal cancellation
-		select {
-		case a.cancelChan <- struct{}{}:
-		default:
-		}
+// isPaused reports whether sends to channelID are currently blocked
+func (a *GuildAgent) isPaused(channelID string) bool {
+	return a.pauses != nil && a.pauses.IsPaused(a.GuildID, channelID)
+}
 
-		a.pendingMessage = nil
-		a.cancelChan = make(chan struct{})
+// State returns a snapshot of the agent for inspection
+func (a *GuildAgent) State() AgentState {
+	state := AgentState{
+		GuildID:         a.GuildID,
+		GuildName:       a.GuildName,
+		AgentID:         a.AgentID,
+		UserID:          a.userID,
+		Connected:       a.Client != nil && a.Client.IsConnected(),
+		PendingMessages: a.GetPendingMessages(),
+	}
 
-		// Send canceled result
-		a.Client.SendToolCanceled(toolCallID, reason)
+	a.channelsMu.Lock()
+	state.CurrentChannelID = a.currentChannelID
+	if ch, ok := a.channels[a.currentChannelID]; ok {
+		state.CurrentChannelName = ch.name
+		if !ch.trigger.at.IsZero() {
+			lastTriggerAt := ch.trigger.at
+			state.LastTriggerAt = &lastTriggerAt
+		}
 	}
+	a.channelsMu.Unlock()
+
+	a.toolCallsMu.Lock()
+	state.InFlightToolCalls = len(a.toolCalls)
+	a.toolCallsMu.Unlock()
+
+	return state
 }
 
 // buildUpdatedConversationHistory builds the conversation history string including a new bot message
@@ -831,7 +1421,7 @@ func (a *GuildAgent) buildUpdatedConversationHistory(channelID, newBotMessage st
 	}
 
 	// Add the new bot message
-	now := time.Now().Format(time.RFC3339)
+	now := a.clk().Now().Format(time.RFC3339)
 	historyStr += fmt.Sprintf("[%s] %s: %s\n", now, botName, newBotMessage)
 
 	return historyStr
@@ -847,42 +1437,6 @@ func (a *GuildAgent) UpdateConfig(sender MessageSender, history *history.Message
 	a.websites = websites
 }
 
-// calculateTypingDelay calculates delay for 90 WPM typing speed
-func calculateTypingDelay(text string) time.Duration {
-	const targetWPM = 90.0
-	const secondsPerMinute = 60.0
-
-	// Count words
-	wordCount := 0
-	inWord := false
-	for _, r := range text {
-		if r == ' ' || r == '\n' || r == '\t' {
-			inWord = false
-		} else if !inWord {
-			inWord = true
-			wordCount++
-		}
-	}
-
-	if wordCount == 0 {
-		return 500 * time.Millisecond // Minimum delay
-	}
-
-	// Calculate delay
-	seconds := (float64(wordCount) / targetWPM) * secondsPerMinute
-	delay := time.Duration(seconds * float64(time.Second))
-
-	// Minimum 500ms, maximum 30s
-	if delay < 500*time.Millisecond {
-		delay = 500 * time.Millisecond
-	}
-	if delay > 30*time.Second {
-		delay = 30 * time.Second
-	}
-
-	return delay
-}
-
 // truncateString truncates a string to maxLen characters
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -901,21 +1455,18 @@ func MarshalContext(ctx map[string]interface{}) string {
 }
 
 // reportAgentAction reports an agent action to the backend
-func (a *GuildAgent) reportAgentAction(channelID, agentMessage string) {
-	if a.backendClient == nil {
-		log.Printf("[HUMA-Agent] Cannot report agent action: no backend client")
+func (a *GuildAgent) reportAgentAction(channelID, agentMessage, triggerDescription, traceID string) {
+	if a.reporter == nil {
+		a.logger().Warn("Cannot report agent action: no reporter")
 		return
 	}
 
 	if a.userID == "" {
-		log.Printf("[HUMA-Agent] Cannot report agent action: no userID")
+		a.logger().Warn("Cannot report agent action: no userID")
 		return
 	}
 
-	// Get current channel name
-	a.currentMu.RLock()
-	channelName := a.currentChannelName
-	a.currentMu.RUnlock()
+	channelName := a.channelNameFor(channelID)
 
 	// Get message history (last 10 messages before the agent's response)
 	var precedingMessages []types.MessageHistoryEntry
@@ -950,22 +1501,40 @@ func (a *GuildAgent) reportAgentAction(channelID, agentMessage string) {
 		ChannelID:          channelID,
 		ChannelName:        channelName,
 		AgentMessage:       agentMessage,
-		TriggerDescription: a.lastTriggerDescription,
+		ActionType:         types.AgentActionTypeMessage,
+		TriggerDescription: triggerDescription,
+		TraceID:            traceID,
 		MessageHistory: types.AgentActionMessageHistory{
 			Preceding: precedingMessages,
 			AgentResponse: types.MessageHistoryEntry{
 				Author:    botName,
 				AuthorID:  "", // We don't have the bot's user ID readily available
 				Content:   agentMessage,
-				Timestamp: time.Now().Format(time.RFC3339),
+				Timestamp: a.clk().Now().Format(time.RFC3339),
 			},
 		},
 	}
 
 	// Send to backend
-	if err := a.backendClient.ReportAgentAction(payload); err != nil {
-		log.Printf("[HUMA-Agent] Error reporting agent action: %v", err)
+	if err := a.reporter.ReportAgentAction(payload); err != nil {
+		a.logger().Error("Error reporting agent action", "error", err)
+	} else {
+		a.logger().Debug("Reported agent action", logging.KeyChannelID, channelID)
+	}
+}
+
+// reportAgentActivity reports a finished decision trail to the backend
+func (a *GuildAgent) reportAgentActivity(payload types.AgentActivityPayload) {
+	if a.reporter == nil || a.userID == "" {
+		return
+	}
+
+	payload.UserID = a.userID
+	payload.GuildID = a.GuildID
+
+	if err := a.reporter.ReportAgentActivity(payload); err != nil {
+		a.logger().Error("Error reporting agent activity", "error", err)
 	} else {
-		log.Printf("[HUMA-Agent] Reported agent action for guild %s, channel %s", a.GuildID, channelID)
+		a.logger().Debug("Reported agent activity", "outcome", payload.Outcome, "entries", len(payload.Entries))
 	}
 }
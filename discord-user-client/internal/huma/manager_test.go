@@ -0,0 +1,282 @@
+package huma
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// newTestAgent creates an agent with an unconnected HUMA client
+func newTestAgent(onActivity func(types.AgentActivityPayload)) *GuildAgent {
+	return &GuildAgent{
+		GuildID:   "guild1",
+		GuildName: "Test Guild",
+		AgentID:   "agent1",
+		Client:    NewClient("test-key"),
+		toolCalls: make(map[string]toolCallStart),
+		activity:  newActivityLog(nil, 0, onActivity),
+		sends:     newSendLedger(),
+	}
+}
+
+// stubSender accepts every send and knows no channels
+type stubSender struct{}
+
+func (stubSender) SendMessage(channelID, content string) error            { return nil }
+func (stubSender) SendTypingIndicator(channelID string) error             { return nil }
+func (stubSender) GetBotUsername() string                                 { return "bot" }
+func (stubSender) GetMonitoredChannelsForGuild(string) []MonitoredChannel { return nil }
+func (stubSender) GetAllChannelsForGuild(string) []ChannelInfo            { return nil }
+func (stubSender) FetchChannelMessages(guildID, channelID string, limit int) ([]history.Message, error) {
+	return nil, nil
+}
+
+// setPending puts a message in a channel as if it were being typed, and
+// returns the channel its typing goroutine would wait on
+func setPending(agent *GuildAgent, pending PendingMessage) <-chan struct{} {
+	agent.channelsMu.Lock()
+	defer agent.channelsMu.Unlock()
+	ch := agent.channel(pending.ChannelID)
+	ch.pending = &pending
+	ch.cancel = make(chan struct{})
+	return ch.cancel
+}
+
+func TestCancelPendingMessage(t *testing.T) {
+	onClose, reported := collectActivity()
+	agent := newTestAgent(onClose)
+
+	if canceled := agent.CancelPendingMessages("", "nothing pending"); len(canceled) != 0 {
+		t.Errorf("Expected nothing canceled when no message is pending, got %+v", canceled)
+	}
+
+	agent.toolCallStarted("t1", "send_message", "c1")
+	cancel := setPending(agent, PendingMessage{ToolCallID: "t1", ChannelID: "c1", Message: "hello", StartTime: time.Now()})
+
+	// Simulate the typing goroutine waiting for cancellation
+	signaled := make(chan struct{})
+	go func() {
+		<-cancel
+		close(signaled)
+	}()
+
+	canceled := agent.CancelPendingMessages("", "Canceled by operator")
+	if len(canceled) != 1 || canceled[0].ToolCallID != "t1" {
+		t.Fatalf("Expected pending message t1 to be canceled, got %+v", canceled)
+	}
+
+	select {
+	case <-signaled:
+	case <-time.After(time.Second):
+		t.Error("Expected typing goroutine to be signaled")
+	}
+
+	if pending := agent.GetPendingMessages(); len(pending) != 0 {
+		t.Errorf("Expected no pending message after cancel, got %+v", pending)
+	}
+	if agent.State().InFlightToolCalls != 0 {
+		t.Error("Expected tool call to be finished after cancel")
+	}
+
+	agent.activity.Close()
+	trails := reported()
+	if len(trails) != 1 || trails[0].Entries[len(trails[0].Entries)-1].Reason != "Canceled by operator" {
+		t.Errorf("Expected cancellation in activity trail, got %+v", trails)
+	}
+}
+
+func TestAgentState(t *testing.T) {
+	agent := newTestAgent(nil)
+	agent.userID = "user1"
+	agent.setCurrentChannel("c1", "general")
+	setPending(agent, PendingMessage{ToolCallID: "t1", ChannelID: "c1", Message: "hi"})
+
+	state := agent.State()
+	if state.GuildID != "guild1" || state.AgentID != "agent1" || state.UserID != "user1" {
+		t.Errorf("Unexpected identity in state: %+v", state)
+	}
+	if state.Connected {
+		t.Error("Expected unconnected client to report disconnected")
+	}
+	if state.CurrentChannelName != "general" || state.LastTriggerAt != nil {
+		t.Errorf("Unexpected channel state: %+v", state)
+	}
+	if len(state.PendingMessages) != 1 || state.PendingMessages[0].Message != "hi" {
+		t.Errorf("Expected pending message in state, got %+v", state.PendingMessages)
+	}
+
+	// The snapshot must not alias the live pending message
+	state.PendingMessages[0].Message = "changed"
+	if agent.GetPendingMessages()[0].Message != "hi" {
+		t.Error("Expected state to hold a copy of the pending message")
+	}
+}
+
+func TestToolCallTriggers(t *testing.T) {
+	agent := newTestAgent(nil)
+	agent.setCurrentChannel("c1", "support")
+	agent.setTrigger("c1", trigger{description: "User alice in #support: help", at: time.Now()})
+	agent.setCurrentChannel("c2", "general")
+	agent.setTrigger("c2", trigger{description: "User bob in #general: hi", at: time.Now()})
+
+	tests := []struct {
+		name        string
+		toolName    string
+		channelID   string
+		wantChannel string
+	}{
+		{"Send to an earlier channel", "send_message", "c1", "c1"},
+		{"Send to the current channel", "send_message", "c2", "c2"},
+		{"Send to an unknown channel", "send_message", "c9", "c2"},
+		{"Fetch from another channel", "fetch_channel_messages", "c1", "c2"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toolCallID := fmt.Sprintf("t%d", i)
+			agent.toolCallStarted(toolCallID, tt.toolName, tt.channelID)
+			defer agent.toolCallFinished(toolCallID)
+
+			channelID, trig := agent.toolCallTrigger(toolCallID)
+			if channelID != tt.wantChannel || !strings.Contains(trig.description, "#"+agent.channelNameFor(tt.wantChannel)) {
+				t.Errorf("Expected the tool call to respond to %s, got %s (%q)", tt.wantChannel, channelID, trig.description)
+			}
+		})
+	}
+	agent.activity.Close()
+}
+
+// pausedChannels is a PauseChecker for a fixed set of guild/channel pauses
+type pausedChannels map[string]bool
+
+func (p pausedChannels) IsPaused(guildID, channelID string) bool {
+	return p[guildID+"/"] || p[guildID+"/"+channelID]
+}
+
+func TestSendMessageRejectedWhilePaused(t *testing.T) {
+	agent := newTestAgent(nil)
+	agent.pauses = pausedChannels{"guild1/c1": true}
+
+	agent.handleToolCall("t1", "send_message", map[string]interface{}{"channel_id": "c1", "message": "hello"})
+
+	if len(agent.GetPendingMessages()) != 0 {
+		t.Error("Expected no pending message for paused channel")
+	}
+	if agent.State().InFlightToolCalls != 0 {
+		t.Error("Expected rejected tool call to be finished")
+	}
+	agent.activity.Close()
+}
+
+func TestCancelPaused(t *testing.T) {
+	agent := newTestAgent(nil)
+	pauses := pausedChannels{}
+	agent.pauses = pauses
+	setPending(agent, PendingMessage{ToolCallID: "t1", ChannelID: "c1", Message: "hello", StartTime: time.Now()})
+	setPending(agent, PendingMessage{ToolCallID: "t2", ChannelID: "c2", Message: "hi", StartTime: time.Now().Add(time.Second)})
+
+	manager := NewManager("test-key")
+	manager.agents[agent.GuildID] = agent
+
+	pauses["guild1/c2"] = true
+	if canceled := manager.CancelPaused("guild1"); len(canceled) != 1 || canceled[0].ToolCallID != "t2" {
+		t.Errorf("Expected a channel pause to cancel only t2, got %+v", canceled)
+	}
+
+	pauses["guild1/"] = true
+	if canceled := manager.CancelPaused("guild1"); len(canceled) != 1 || canceled[0].ToolCallID != "t1" {
+		t.Errorf("Expected guild pause to cancel t1, got %+v", canceled)
+	}
+	if pending := agent.GetPendingMessages(); len(pending) != 0 {
+		t.Errorf("Expected no pending message after pause, got %+v", pending)
+	}
+	agent.activity.Close()
+}
+
+func TestRecordOperatorSend(t *testing.T) {
+	onClose, reported := collectActivity()
+	agent := newTestAgent(onClose)
+
+	agent.RecordOperatorSend(context.Background(), "c1", "general", "hello from ops")
+	agent.activity.Close()
+
+	trails := reported()
+	if len(trails) != 1 {
+		t.Fatalf("Expected 1 trail, got %d", len(trails))
+	}
+	trail := trails[0]
+	if trail.Outcome != types.ActivityOutcomeResponded || trail.TriggerDescription != operatorTrigger {
+		t.Errorf("Expected responded operator trail, got %+v", trail)
+	}
+	last := trail.Entries[len(trail.Entries)-1]
+	if last.Kind != types.ActivityKindSent || last.Message != "hello from ops" {
+		t.Errorf("Expected sent entry, got %+v", last)
+	}
+}
+
+func TestDryRunSender(t *testing.T) {
+	capture := &dryRunSender{sent: make(chan struct{}, 1)}
+	capture.SendMessage("c1", "first")
+	capture.SendMessage("c1", "second")
+
+	if len(capture.sends) != 2 || capture.sends[1].Message != "second" {
+		t.Errorf("Expected both sends captured, got %+v", capture.sends)
+	}
+	select {
+	case <-capture.sent:
+	default:
+		t.Error("Expected send to be signaled")
+	}
+
+	if _, err := newTestAgent(nil).DryRun(context.Background(), "c1", "general", "alice", "hi", time.Second); err == nil {
+		t.Error("Expected DryRun to refuse a live agent")
+	}
+}
+
+func TestAgentIdle(t *testing.T) {
+	agent := newTestAgent(nil)
+	select {
+	case <-agent.idle():
+	default:
+		t.Error("Expected an agent without tool calls to be idle")
+	}
+
+	agent.toolCallStarted("t1", "send_message", "c1")
+	agent.toolCallStarted("t2", "fetch_channel_messages", "c1")
+	idle := agent.idle()
+	agent.toolCallFinished("t1")
+	select {
+	case <-idle:
+		t.Error("Expected the agent busy while a tool call is in flight")
+	default:
+	}
+
+	agent.toolCallFinished("t2")
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Error("Expected the agent idle once its tool calls finished")
+	}
+}
+
+func TestDryRunAgentsAreReused(t *testing.T) {
+	m := NewManager("test-key")
+	if id := m.takeDryRunAgentLocked("g1"); id != "" {
+		t.Errorf("Expected no idle dry-run agent, got %q", id)
+	}
+
+	m.releaseDryRunAgent("g1", "agent-1")
+	if id := m.takeDryRunAgentLocked("g2"); id != "" {
+		t.Errorf("Expected dry-run agents to stay with their guild, got %q", id)
+	}
+	if id := m.takeDryRunAgentLocked("g1"); id != "agent-1" {
+		t.Errorf("Expected the released agent to be reused, got %q", id)
+	}
+	if id := m.takeDryRunAgentLocked("g1"); id != "" {
+		t.Errorf("Expected a dry-run agent to be taken only once, got %q", id)
+	}
+}
@@ -0,0 +1,245 @@
+package huma
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// operatorTrigger is the trigger description of messages posted from the admin API
+const operatorTrigger = "Sent by operator"
+
+// dryRunResultWait bounds how long a dry run waits for a send's tool result
+// once the send is captured
+const dryRunResultWait = 2 * time.Second
+
+// SimulatedSend is a message a dry-run agent would have posted
+type SimulatedSend struct {
+	ChannelID string `json:"channelId"`
+	Message   string `json:"message"`
+}
+
+// SimulationResult is what a dry-run agent did in response to a synthetic message
+type SimulationResult struct {
+	TraceID   string                     `json:"traceId,omitempty"`
+	Context   json.RawMessage            `json:"context"`
+	Outcome   string                     `json:"outcome"`
+	Entries   []types.AgentActivityEntry `json:"entries"`
+	WouldSend []SimulatedSend            `json:"wouldSend"`
+	TimedOut  bool                       `json:"timedOut"`
+}
+
+// dryRunSender reads through to the real sender but captures sends instead of posting them
+type dryRunSender struct {
+	MessageSender
+	mu    sync.Mutex
+	sends []SimulatedSend
+	sent  chan struct{}
+}
+
+// SendMessage records the message instead of posting it
+func (s *dryRunSender) SendMessage(channelID, content string) error {
+	s.mu.Lock()
+	s.sends = append(s.sends, SimulatedSend{ChannelID: channelID, Message: content})
+	s.mu.Unlock()
+
+	select {
+	case s.sent <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SendTypingIndicator does nothing in a dry run
+func (s *dryRunSender) SendTypingIndicator(channelID string) error {
+	return nil
+}
+
+// CanRespondIn follows the real sender's channel lists
+func (s *dryRunSender) CanRespondIn(guildID, channelID string) bool {
+	if responder, ok := s.MessageSender.(ResponseChannels); ok {
+		return responder.CanRespondIn(guildID, channelID)
+	}
+	return true
+}
+
+// RecordOperatorSend records a message an operator posted as the account, so it
+// shows up in stats, the decision trail and agent actions like an agent send
+func (a *GuildAgent) RecordOperatorSend(ctx context.Context, channelID, channelName, message string) {
+	traceID := tracing.TraceIDFromContext(ctx)
+	a.activity.Trigger(channelID, channelName, operatorTrigger, traceID)
+	a.activity.Record(types.AgentActivityEntry{
+		Kind:      types.ActivityKindSent,
+		ChannelID: channelID,
+		Message:   message,
+		Reason:    operatorTrigger,
+	})
+	a.stats.RecordMessageSent(a.userID, a.GuildID)
+	a.publish(events.Event{Type: events.TypeMessageSent, ChannelID: channelID, ChannelName: channelName, TraceID: traceID, Content: message, Reason: operatorTrigger})
+
+	// The account spoke, so the send policy counts it like an agent send
+	a.recordSend(channelID, message)
+
+	a.logger().InfoContext(ctx, "Operator sent message", logging.KeyChannelID, channelID, "content", truncateString(message, 50))
+	a.bg.goReport(func() { a.reportAgentAction(channelID, message, operatorTrigger, traceID) })
+}
+
+// NewDryRunAgent returns a HUMA agent for a guild that is not registered with the
+// manager: it never types and never reports to the backend. Configure it with
+// UpdateConfig before use. Call release when done with it: the agent is
+// disconnected and kept on HUMA for the guild's next dry run.
+func (m *Manager) NewDryRunAgent(guildID, guildName, userID string) (agent *GuildAgent, release func(), err error) {
+	m.mu.RLock()
+	apiKey := m.apiKey
+	baseURL := m.baseURL
+	metadata := m.buildAgentMetadata(guildName)
+	pauses := m.pauses
+	tuning := m.tuning
+	policy := m.policy
+	bg := m.bg
+	m.mu.RUnlock()
+
+	// Dry runs are checked against the guild's real sends but don't add to them
+	m.mu.Lock()
+	sends := m.sendLedgerLocked(guildID).clone()
+	agentID := m.takeDryRunAgentLocked(guildID)
+	m.mu.Unlock()
+
+	client := newClient(apiKey, baseURL, tuning)
+	if agentID != "" {
+		if err := client.Connect(agentID); err != nil {
+			managerLogger.Warn("Failed to reconnect dry-run agent, creating a new one", logging.KeyGuildID, guildID, "agent_id", agentID, "error", err)
+			agentID = ""
+		}
+	}
+	if agentID == "" {
+		agentResp, err := client.CreateAgent(fmt.Sprintf("Discord-%s-dryrun", guildName), metadata)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create agent: %w", err)
+		}
+		if err := client.Connect(agentResp.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to agent: %w", err)
+		}
+		agentID = agentResp.ID
+		managerLogger.Info("Created dry-run agent", logging.KeyGuildID, guildID, "agent_id", agentID)
+	}
+
+	agent = &GuildAgent{
+		GuildID:   guildID,
+		GuildName: guildName,
+		Client:    client,
+		AgentID:   agentID,
+		pauses:    pauses,
+		userID:    userID,
+		toolCalls: make(map[string]toolCallStart),
+		dryRun:    true,
+		tuning:    tuning,
+		policy:    policy,
+		sends:     sends,
+		bg:        bg,
+	}
+
+	client.SetToolCallHandler(func(toolCallID, toolName string, args map[string]interface{}) {
+		agent.handleToolCall(toolCallID, toolName, args)
+	})
+	client.SetCancelToolCallHandler(func(toolCallID, reason string) {
+		agent.handleCancelToolCall(toolCallID, reason)
+	})
+
+	release = func() {
+		// Disconnect can block until the next read deadline, don't hold up the caller
+		go func() {
+			client.Disconnect()
+			m.releaseDryRunAgent(guildID, agentID)
+		}()
+	}
+	return agent, release, nil
+}
+
+// takeDryRunAgentLocked takes an idle dry-run agent ID for a guild, or returns
+// "" if there is none. Caller must hold m.mu.
+func (m *Manager) takeDryRunAgentLocked(guildID string) string {
+	idle := m.dryRunAgents[guildID]
+	if len(idle) == 0 {
+		return ""
+	}
+	agentID := idle[len(idle)-1]
+	m.dryRunAgents[guildID] = idle[:len(idle)-1]
+	return agentID
+}
+
+// releaseDryRunAgent makes a disconnected dry-run agent available to the
+// guild's next dry run
+func (m *Manager) releaseDryRunAgent(guildID, agentID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dryRunAgents == nil {
+		m.dryRunAgents = make(map[string][]string)
+	}
+	m.dryRunAgents[guildID] = append(m.dryRunAgents[guildID], agentID)
+}
+
+// DryRun sends a synthetic message to a dry-run agent and collects its decision
+// trail until it sends something or wait elapses
+func (a *GuildAgent) DryRun(ctx context.Context, channelID, channelName, authorName, content string, wait time.Duration) (*SimulationResult, error) {
+	if !a.dryRun {
+		return nil, fmt.Errorf("agent for guild %s is not a dry-run agent", a.GuildID)
+	}
+
+	capture := &dryRunSender{MessageSender: a.sender, sent: make(chan struct{}, 1)}
+	a.sender = capture
+
+	var trail types.AgentActivityPayload
+	a.activity = newActivityLog(nil, 0, func(payload types.AgentActivityPayload) {
+		trail = payload
+	})
+
+	humaContext, err := a.sendNewMessages(ctx, channelID, channelName, []NewMessage{{AuthorName: authorName, Content: content}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message to HUMA: %w", err)
+	}
+
+	result := &SimulationResult{
+		TraceID:   tracing.TraceIDFromContext(ctx),
+		Context:   json.RawMessage(MarshalContext(humaContext)),
+		WouldSend: []SimulatedSend{},
+	}
+	if !json.Valid(result.Context) {
+		// MarshalContext reports failures as plain text
+		result.Context, _ = json.Marshal(string(result.Context))
+	}
+
+	timer := a.clk().NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-capture.sent:
+		// Let the send's tool result land in the trail
+		resultTimer := a.clk().NewTimer(dryRunResultWait)
+		defer resultTimer.Stop()
+		select {
+		case <-a.idle():
+		case <-resultTimer.C():
+		case <-ctx.Done():
+		}
+	case <-timer.C():
+		result.TimedOut = true
+	case <-ctx.Done():
+		result.TimedOut = true
+	}
+
+	a.activity.Close()
+	result.Outcome = trail.Outcome
+	result.Entries = trail.Entries
+
+	capture.mu.Lock()
+	result.WouldSend = append(result.WouldSend, capture.sends...)
+	capture.mu.Unlock()
+	return result, nil
+}
@@ -0,0 +1,334 @@
+package huma
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
+)
+
+// Send policy rules, as reported to HUMA when a send is blocked
+const (
+	RuleDuplicate     = "duplicate"
+	RuleHumanMessage  = "human_message"
+	RuleCooldown      = "cooldown"
+	RuleChannelBudget = "channel_budget"
+	RuleGuildBudget   = "guild_budget"
+)
+
+// Defaults for SendPolicy
+const (
+	DefaultSendCooldown       = 20 * time.Second
+	DefaultChannelHourlySends = 20
+	DefaultGuildHourlySends   = 60
+	DefaultDuplicateWindow    = time.Hour
+)
+
+// budgetWindow is the period the hourly budgets count sends over
+const budgetWindow = time.Hour
+
+// SendPolicy limits when and what the agent may send, whatever HUMA decides.
+// A zero field turns its rule off, so the zero SendPolicy allows every send.
+type SendPolicy struct {
+	// Cooldown is how long the agent stays quiet in a channel after sending there
+	Cooldown time.Duration
+	// RequireHumanMessage blocks a send until someone else has spoken in the
+	// channel since the agent's last send there
+	RequireHumanMessage bool
+	// ChannelHourlySends caps sends per channel in any hour
+	ChannelHourlySends int
+	// GuildHourlySends caps sends across the guild in any hour
+	GuildHourlySends int
+	// DuplicateWindow blocks sending the same text in a channel twice within it
+	DuplicateWindow time.Duration
+}
+
+// DefaultSendPolicy returns the policy the service runs with unless configured
+// otherwise
+func DefaultSendPolicy() SendPolicy {
+	return SendPolicy{
+		Cooldown:            DefaultSendCooldown,
+		RequireHumanMessage: true,
+		ChannelHourlySends:  DefaultChannelHourlySends,
+		GuildHourlySends:    DefaultGuildHourlySends,
+		DuplicateWindow:     DefaultDuplicateWindow,
+	}
+}
+
+// lookback is how long sends must be remembered to apply the policy
+func (p SendPolicy) lookback() time.Duration {
+	return max(budgetWindow, p.DuplicateWindow, p.Cooldown)
+}
+
+// PolicyViolation is why a send was blocked. It is sent to HUMA as the
+// result of the failed send_message.
+type PolicyViolation struct {
+	Rule              string `json:"rule"`
+	Reason            string `json:"reason"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds,omitempty"`
+}
+
+// Error is the tool result's error message
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("Blocked by send policy (%s): %s", v.Rule, v.Reason)
+}
+
+// violation builds a PolicyViolation that may be retried after wait
+func violation(rule string, wait time.Duration, format string, args ...interface{}) *PolicyViolation {
+	v := &PolicyViolation{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+	if wait > 0 {
+		v.RetryAfterSeconds = int(math.Ceil(wait.Seconds()))
+	}
+	return v
+}
+
+// sentMessage is a send the policy remembers
+type sentMessage struct {
+	id      uint64 // unique in its ledger; sends can share a time
+	at      time.Time
+	content string // normalized, for the duplicate check
+}
+
+// sendRecord is a send counted against the policy, kept so a send that fails
+// can be taken back
+type sendRecord struct {
+	id         uint64
+	humanSince bool // the channel's humanSince before the send
+}
+
+// sendLedger is what the send policy remembers about a guild. The Manager
+// keeps one per guild, so it outlives agents dropped and recreated when their
+// connection dies.
+type sendLedger struct {
+	mu        sync.Mutex
+	channels  map[string]*channelSends // channelID -> sends
+	guildSent []sentMessage            // sends in the last hour, for the guild budget
+	lastID    uint64
+}
+
+// channelSends is what the send policy remembers about one channel
+type channelSends struct {
+	sent       []sentMessage // the account's recent sends, oldest first
+	humanSince bool          // someone else spoke since the last send
+}
+
+// newSendLedger creates an empty ledger
+func newSendLedger() *sendLedger {
+	return &sendLedger{channels: make(map[string]*channelSends)}
+}
+
+// channelLocked returns a channel's sends, creating them if needed. Callers
+// hold l.mu.
+func (l *sendLedger) channelLocked(channelID string) *channelSends {
+	ch, ok := l.channels[channelID]
+	if !ok {
+		ch = &channelSends{}
+		l.channels[channelID] = ch
+	}
+	return ch
+}
+
+// clone returns a copy of the ledger, for dry runs to check sends against
+// without counting them
+func (l *sendLedger) clone() *sendLedger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clone := newSendLedger()
+	clone.guildSent = append([]sentMessage(nil), l.guildSent...)
+	clone.lastID = l.lastID
+	for channelID, ch := range l.channels {
+		clone.channels[channelID] = &channelSends{
+			sent:       append([]sentMessage(nil), ch.sent...),
+			humanSince: ch.humanSince,
+		}
+	}
+	return clone
+}
+
+// normalizeContent makes the duplicate check ignore case and spacing
+func normalizeContent(content string) string {
+	return strings.ToLower(strings.Join(strings.Fields(content), " "))
+}
+
+// recordHumanMessage notes that someone else spoke in a channel, allowing the
+// agent's next send there
+func (a *GuildAgent) recordHumanMessage(channelID string) {
+	a.sends.mu.Lock()
+	defer a.sends.mu.Unlock()
+	a.sends.channelLocked(channelID).humanSince = true
+}
+
+// checkSend applies the send policy to content about to be sent in a channel.
+// Returns nil if the send is allowed.
+func (a *GuildAgent) checkSend(channelID, content string) *PolicyViolation {
+	a.sends.mu.Lock()
+	defer a.sends.mu.Unlock()
+	return a.checkSendLocked(channelID, content)
+}
+
+// checkAndRecordSend applies the send policy and, if the send is allowed,
+// counts it
+func (a *GuildAgent) checkAndRecordSend(channelID, content string) (sendRecord, *PolicyViolation) {
+	a.sends.mu.Lock()
+	defer a.sends.mu.Unlock()
+	if v := a.checkSendLocked(channelID, content); v != nil {
+		return sendRecord{}, v
+	}
+	return a.recordSendLocked(channelID, content), nil
+}
+
+// recordSend counts a send against the policy whether or not it allows it
+func (a *GuildAgent) recordSend(channelID, content string) sendRecord {
+	a.sends.mu.Lock()
+	defer a.sends.mu.Unlock()
+	return a.recordSendLocked(channelID, content)
+}
+
+// checkSendLocked is checkSend for callers holding a.sends.mu
+func (a *GuildAgent) checkSendLocked(channelID, content string) *PolicyViolation {
+	p := a.policy
+	now := a.clk().Now()
+	ch := a.sends.channelLocked(channelID)
+	a.pruneSendsLocked(ch, now)
+
+	if p.DuplicateWindow > 0 {
+		normalized := normalizeContent(content)
+		for _, sent := range ch.sent {
+			if sent.content == normalized && now.Sub(sent.at) < p.DuplicateWindow {
+				return violation(RuleDuplicate, 0, "you already sent this message in this channel %s ago; say something new or stay silent", now.Sub(sent.at).Round(time.Second))
+			}
+		}
+	}
+	if len(ch.sent) == 0 {
+		return a.checkGuildBudgetLocked(now)
+	}
+	last := ch.sent[len(ch.sent)-1].at
+	if p.RequireHumanMessage && !ch.humanSince {
+		return violation(RuleHumanMessage, 0, "nobody has spoken in this channel since your last message; wait for a reply")
+	}
+	if wait := last.Add(p.Cooldown).Sub(now); p.Cooldown > 0 && wait > 0 {
+		return violation(RuleCooldown, wait, "you spoke in this channel %s ago; wait %s", now.Sub(last).Round(time.Second), wait.Round(time.Second))
+	}
+	if p.ChannelHourlySends > 0 {
+		if wait := budgetWait(sentTimes(ch.sent), p.ChannelHourlySends, now); wait > 0 {
+			return violation(RuleChannelBudget, wait, "you sent %d messages in this channel in the last hour, the most allowed", p.ChannelHourlySends)
+		}
+	}
+	return a.checkGuildBudgetLocked(now)
+}
+
+// checkGuildBudgetLocked applies the guild-wide hourly budget. Callers hold
+// a.sends.mu.
+func (a *GuildAgent) checkGuildBudgetLocked(now time.Time) *PolicyViolation {
+	if a.policy.GuildHourlySends <= 0 {
+		return nil
+	}
+	if wait := budgetWait(sentTimes(a.sends.guildSent), a.policy.GuildHourlySends, now); wait > 0 {
+		return violation(RuleGuildBudget, wait, "you sent %d messages in this server in the last hour, the most allowed", a.policy.GuildHourlySends)
+	}
+	return nil
+}
+
+// budgetWait returns how long until a budget of limit sends per hour has
+// room, given the times of earlier sends, oldest first
+func budgetWait(sent []time.Time, limit int, now time.Time) time.Duration {
+	var recent []time.Time
+	for _, at := range sent {
+		if now.Sub(at) < budgetWindow {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) < limit {
+		return 0
+	}
+	return recent[len(recent)-limit].Add(budgetWindow).Sub(now)
+}
+
+// sentTimes returns when each message was sent
+func sentTimes(sent []sentMessage) []time.Time {
+	times := make([]time.Time, len(sent))
+	for i, s := range sent {
+		times[i] = s.at
+	}
+	return times
+}
+
+// recordSendLocked counts a send against the policy. Callers hold a.sends.mu.
+func (a *GuildAgent) recordSendLocked(channelID, content string) sendRecord {
+	ch := a.sends.channelLocked(channelID)
+	a.sends.lastID++
+	record := sendRecord{id: a.sends.lastID, humanSince: ch.humanSince}
+	sent := sentMessage{id: record.id, at: a.clk().Now()}
+	a.sends.guildSent = append(a.sends.guildSent, sent)
+	sent.content = normalizeContent(content)
+	ch.sent = append(ch.sent, sent)
+	ch.humanSince = false
+	return record
+}
+
+// takeBackSend uncounts a send that failed, so HUMA may retry it
+func (a *GuildAgent) takeBackSend(channelID string, record sendRecord) {
+	a.sends.mu.Lock()
+	defer a.sends.mu.Unlock()
+	ch := a.sends.channelLocked(channelID)
+	ch.sent = removeSent(ch.sent, record.id)
+	ch.humanSince = ch.humanSince || record.humanSince
+	a.sends.guildSent = removeSent(a.sends.guildSent, record.id)
+}
+
+// removeSent removes the send with an ID, if it is still remembered
+func removeSent(sent []sentMessage, id uint64) []sentMessage {
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].id == id {
+			return append(sent[:i], sent[i+1:]...)
+		}
+	}
+	return sent
+}
+
+// pruneSendsLocked forgets sends the policy no longer looks at. Callers hold
+// a.sends.mu.
+func (a *GuildAgent) pruneSendsLocked(ch *channelSends, now time.Time) {
+	cutoff := now.Add(-a.policy.lookback())
+	i := 0
+	for i < len(ch.sent) && !ch.sent[i].at.After(cutoff) {
+		i++
+	}
+	ch.sent = ch.sent[i:]
+
+	cutoff = now.Add(-budgetWindow)
+	i = 0
+	for i < len(a.sends.guildSent) && !a.sends.guildSent[i].at.After(cutoff) {
+		i++
+	}
+	a.sends.guildSent = a.sends.guildSent[i:]
+}
+
+// sendLedgerLocked returns a guild's send ledger, creating it if needed. Callers
+// hold m.mu.
+func (m *Manager) sendLedgerLocked(guildID string) *sendLedger {
+	if m.sends == nil {
+		m.sends = make(map[string]*sendLedger)
+	}
+	l, ok := m.sends[guildID]
+	if !ok {
+		l = newSendLedger()
+		m.sends[guildID] = l
+	}
+	return l
+}
+
+// blockSend fails a send_message the policy blocked, telling HUMA which rule
+// it broke
+func (a *GuildAgent) blockSend(toolCallID, channelID string, v *PolicyViolation) {
+	a.logger().Info("Send blocked by policy", logging.KeyChannelID, channelID, "tool_call_id", toolCallID, "rule", v.Rule, "reason", v.Reason)
+	metrics.SendsBlocked.Inc(v.Rule)
+	a.stats.RecordSuppressedResponse(a.userID, a.GuildID)
+	a.publish(events.Event{Type: events.TypeMessageCanceled, ChannelID: channelID, ChannelName: a.channelNameFor(channelID), TraceID: a.toolCallSpan(toolCallID).TraceID(), ToolCallID: toolCallID, Reason: v.Error()})
+	a.sendToolResult(toolCallID, false, v, v.Error())
+}
@@ -0,0 +1,111 @@
+package huma
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+)
+
+// newPolicyAgent returns a test agent with a send policy and a virtual clock
+func newPolicyAgent(policy SendPolicy) (*GuildAgent, *clock.Virtual) {
+	clk := clock.NewVirtual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	agent := newTestAgent(nil)
+	agent.policy = policy
+	agent.clock = clk
+	return agent, clk
+}
+
+// trySend applies the policy to a send and counts it if allowed
+func trySend(agent *GuildAgent, channelID, content string) *PolicyViolation {
+	_, v := agent.checkAndRecordSend(channelID, content)
+	return v
+}
+
+// expectRule checks a send was blocked by rule, or allowed if rule is empty
+func expectRule(t *testing.T, v *PolicyViolation, rule string, retryAfter int) {
+	t.Helper()
+	switch {
+	case rule == "" && v != nil:
+		t.Errorf("Expected the send to be allowed, got %+v", v)
+	case rule != "" && v == nil:
+		t.Errorf("Expected the send to be blocked by %s", rule)
+	case rule != "" && (v.Rule != rule || v.RetryAfterSeconds != retryAfter):
+		t.Errorf("Expected %s retryable after %ds, got %+v", rule, retryAfter, v)
+	}
+}
+
+func TestSendPolicy_ChannelRules(t *testing.T) {
+	agent, clk := newPolicyAgent(DefaultSendPolicy())
+
+	expectRule(t, trySend(agent, "c1", "Hello there"), "", 0)
+	expectRule(t, trySend(agent, "c1", "anyone?"), RuleHumanMessage, 0)
+
+	agent.recordHumanMessage("c1")
+	clk.Advance(5 * time.Second)
+	expectRule(t, trySend(agent, "c1", "hi!"), RuleCooldown, 15)
+
+	clk.Advance(15 * time.Second)
+	expectRule(t, trySend(agent, "c1", "  hello   THERE "), RuleDuplicate, 0)
+	expectRule(t, trySend(agent, "c1", "hi!"), "", 0)
+
+	// Other channels have their own cooldown and human-message state
+	expectRule(t, trySend(agent, "c2", "Hello there"), "", 0)
+
+	// The same text is allowed again once the duplicate window has passed
+	clk.Advance(time.Hour)
+	agent.recordHumanMessage("c1")
+	expectRule(t, trySend(agent, "c1", "Hello there"), "", 0)
+}
+
+func TestSendPolicy_Budgets(t *testing.T) {
+	agent, clk := newPolicyAgent(SendPolicy{ChannelHourlySends: 2, GuildHourlySends: 3})
+
+	expectRule(t, trySend(agent, "c1", "one"), "", 0)
+	clk.Advance(10 * time.Minute)
+	expectRule(t, trySend(agent, "c1", "two"), "", 0)
+	expectRule(t, trySend(agent, "c1", "three"), RuleChannelBudget, 50*60)
+	expectRule(t, trySend(agent, "c2", "three"), "", 0)
+	expectRule(t, trySend(agent, "c3", "four"), RuleGuildBudget, 50*60)
+
+	// The first send leaves both windows after an hour
+	clk.Advance(50 * time.Minute)
+	expectRule(t, trySend(agent, "c1", "three"), "", 0)
+	expectRule(t, trySend(agent, "c3", "four"), RuleGuildBudget, 10*60)
+}
+
+func TestSendPolicy_TakeBackFailedSend(t *testing.T) {
+	agent, _ := newPolicyAgent(DefaultSendPolicy())
+	agent.recordHumanMessage("c1")
+
+	record := agent.recordSend("c1", "hello")
+	agent.takeBackSend("c1", record)
+
+	// A retry of a send that never reached Discord is allowed
+	expectRule(t, trySend(agent, "c1", "hello"), "", 0)
+	if len(agent.sends.guildSent) != 1 {
+		t.Errorf("Expected one send counted for the guild, got %d", len(agent.sends.guildSent))
+	}
+}
+
+func TestSendPolicy_TakeBackSendAtSameTime(t *testing.T) {
+	agent, _ := newPolicyAgent(SendPolicy{DuplicateWindow: time.Hour})
+
+	// On a virtual clock that doesn't move, both sends share a time
+	first := agent.recordSend("c1", "first")
+	agent.recordSend("c1", "second")
+	agent.takeBackSend("c1", first)
+
+	expectRule(t, agent.checkSend("c1", "first"), "", 0)
+	expectRule(t, agent.checkSend("c1", "second"), RuleDuplicate, 0)
+	if len(agent.sends.guildSent) != 1 {
+		t.Errorf("Expected one send counted for the guild, got %d", len(agent.sends.guildSent))
+	}
+}
+
+func TestSendPolicy_ZeroAllowsEverything(t *testing.T) {
+	agent, _ := newPolicyAgent(SendPolicy{})
+	for i := 0; i < 100; i++ {
+		expectRule(t, trySend(agent, "c1", "same"), "", 0)
+	}
+}
@@ -0,0 +1,185 @@
+package huma
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+)
+
+// shutdownReason is the tool result and cancel reason for sends cut off by shutdown
+const shutdownReason = "Shutting down"
+
+// tracker counts running goroutines and lets shutdown wait for them. Unlike a
+// WaitGroup it can be waited on while new goroutines are still being started.
+type tracker struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops to zero
+}
+
+// start records a goroutine starting
+func (t *tracker) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.n++
+}
+
+// done records a goroutine finishing
+func (t *tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.n--
+	if t.n == 0 {
+		close(t.idle)
+	}
+}
+
+// count returns the number of running goroutines
+func (t *tracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.n
+}
+
+// wait blocks until no goroutines are running or ctx is done.
+// Returns false if ctx ended first.
+func (t *tracker) wait(ctx context.Context) bool {
+	for {
+		t.mu.Lock()
+		if t.n == 0 {
+			t.mu.Unlock()
+			return true
+		}
+		idle := t.idle
+		t.mu.Unlock()
+
+		select {
+		case <-idle:
+			// Something may have started since, check again
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// background tracks the goroutines agents leave running after a tool call
+// returns, so shutdown can let them finish. A nil background tracks nothing.
+type background struct {
+	typing   tracker
+	reports  tracker
+	draining atomic.Bool
+}
+
+// goTyping runs a typing simulation in a tracked goroutine
+func (b *background) goTyping(fn func()) {
+	if b == nil {
+		go fn()
+		return
+	}
+	b.typing.start()
+	go func() {
+		defer b.typing.done()
+		fn()
+	}()
+}
+
+// goReport runs a backend report in a tracked goroutine
+func (b *background) goReport(fn func()) {
+	if b == nil {
+		go fn()
+		return
+	}
+	b.reports.start()
+	go func() {
+		defer b.reports.done()
+		fn()
+	}()
+}
+
+// isDraining reports whether shutdown has started and new sends are refused
+func (b *background) isDraining() bool {
+	return b != nil && b.draining.Load()
+}
+
+// DrainResult is what happened to messages that were being typed when shutdown began
+type DrainResult struct {
+	Pending  int // messages being typed when the drain started
+	Finished int // of those, sent (or failed) before the deadline
+	Canceled int // of those, canceled at the deadline
+}
+
+// Drain stops agents from starting new sends and waits for messages already
+// being typed until ctx is done. Whatever is still pending then is canceled and
+// HUMA is told with a canceled tool result. Sockets stay open.
+func (m *Manager) Drain(ctx context.Context) DrainResult {
+	m.bg.draining.Store(true)
+
+	agents := m.GetAgents()
+	var result DrainResult
+	for _, agent := range agents {
+		result.Pending += len(agent.GetPendingMessages())
+	}
+	if result.Pending > 0 {
+		managerLogger.Info("Waiting for pending messages", "pending", result.Pending)
+	}
+
+	if !m.bg.typing.wait(ctx) {
+		for _, agent := range agents {
+			result.Canceled += len(agent.CancelPendingMessages("", shutdownReason))
+		}
+	}
+	result.Finished = result.Pending - result.Canceled
+	if result.Finished < 0 {
+		// A send that started after the count was refused, not finished
+		result.Finished = 0
+	}
+	return result
+}
+
+// Shutdown ends every agent's decision trail, waits for backend reports until
+// ctx is done, then closes all HUMA sockets. Call Drain first. Returns the number
+// of reports abandoned at the deadline.
+func (m *Manager) Shutdown(ctx context.Context) int {
+	m.bg.draining.Store(true)
+
+	m.mu.Lock()
+	agents := m.agents
+	m.agents = make(map[string]*GuildAgent)
+	m.mu.Unlock()
+
+	// Closing a trail reports it, so do this before waiting on reports
+	for _, agent := range agents {
+		agent.activity.Close()
+	}
+
+	abandoned := 0
+	if !m.bg.reports.wait(ctx) {
+		abandoned = m.bg.reports.count()
+		managerLogger.Warn("Shutdown deadline reached with backend reports in flight", "abandoned", abandoned)
+	}
+
+	for guildID, agent := range agents {
+		managerLogger.Info("Disconnecting agent", logging.KeyGuildID, guildID)
+		agent.Client.Disconnect()
+	}
+	return abandoned
+}
+
+// refuseWhileDraining rejects a send_message that arrives after shutdown began.
+// Returns true if the call was refused.
+func (a *GuildAgent) refuseWhileDraining(toolCallID, channelID string) bool {
+	if !a.bg.isDraining() {
+		return false
+	}
+	a.logger().Info("Refusing send_message during shutdown", logging.KeyChannelID, channelID, "tool_call_id", toolCallID)
+	a.stats.RecordSuppressedResponse(a.userID, a.GuildID)
+	a.publish(events.Event{Type: events.TypeMessageCanceled, ChannelID: channelID, ChannelName: a.channelNameFor(channelID), TraceID: a.toolCallSpan(toolCallID).TraceID(), ToolCallID: toolCallID, Reason: shutdownReason})
+	a.sendToolResult(toolCallID, false, nil, shutdownReason)
+	return true
+}
@@ -0,0 +1,129 @@
+package huma
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrackerWait(t *testing.T) {
+	var tr tracker
+	if !tr.wait(context.Background()) {
+		t.Fatal("Expected wait on an idle tracker to return immediately")
+	}
+
+	release := make(chan struct{})
+	tr.start()
+	go func() {
+		<-release
+		tr.done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if tr.wait(ctx) {
+		t.Fatal("Expected wait to time out while a goroutine is running")
+	}
+	if tr.count() != 1 {
+		t.Errorf("Expected 1 running, got %d", tr.count())
+	}
+
+	close(release)
+	if !tr.wait(context.Background()) {
+		t.Fatal("Expected wait to return once the goroutine finished")
+	}
+}
+
+// newDrainTestManager registers a test agent with a message that is being typed
+// until its cancel channel fires or finish is closed
+func newDrainTestManager(finish <-chan struct{}) (*Manager, *GuildAgent) {
+	manager := NewManager("test-key")
+	agent := newTestAgent(nil)
+	agent.bg = manager.bg
+	manager.agents[agent.GuildID] = agent
+
+	cancel := setPending(agent, PendingMessage{ToolCallID: "t1", ChannelID: "c1", Message: "hello"})
+	agent.bg.goTyping(func() {
+		select {
+		case <-cancel:
+		case <-finish:
+			agent.channelsMu.Lock()
+			agent.channel("c1").pending = nil
+			agent.channelsMu.Unlock()
+		}
+	})
+	return manager, agent
+}
+
+func TestDrainWaitsForTyping(t *testing.T) {
+	finish := make(chan struct{})
+	manager, agent := newDrainTestManager(finish)
+	time.AfterFunc(10*time.Millisecond, func() { close(finish) })
+
+	result := manager.Drain(context.Background())
+	if result.Pending != 1 || result.Finished != 1 || result.Canceled != 0 {
+		t.Errorf("Expected the pending message to finish, got %+v", result)
+	}
+	agent.activity.Close()
+}
+
+func TestDrainCancelsAtDeadline(t *testing.T) {
+	manager, agent := newDrainTestManager(make(chan struct{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := manager.Drain(ctx)
+
+	if result.Pending != 1 || result.Canceled != 1 || result.Finished != 0 {
+		t.Errorf("Expected the pending message to be canceled, got %+v", result)
+	}
+	if len(agent.GetPendingMessages()) != 0 {
+		t.Error("Expected no pending message after drain")
+	}
+	if !manager.bg.typing.wait(context.Background()) {
+		t.Error("Expected the typing goroutine to exit after cancel")
+	}
+	agent.activity.Close()
+}
+
+func TestSendMessageRefusedWhileDraining(t *testing.T) {
+	manager := NewManager("test-key")
+	agent := newTestAgent(nil)
+	agent.bg = manager.bg
+	manager.Drain(context.Background())
+
+	agent.handleToolCall("t1", "send_message", map[string]interface{}{"channel_id": "c1", "message": "hello"})
+
+	if len(agent.GetPendingMessages()) != 0 {
+		t.Error("Expected no pending message while draining")
+	}
+	if agent.State().InFlightToolCalls != 0 {
+		t.Error("Expected refused tool call to be finished")
+	}
+	agent.activity.Close()
+}
+
+func TestShutdownWaitsForReports(t *testing.T) {
+	manager := NewManager("test-key")
+	reported := make(chan struct{})
+	agent := newTestAgent(nil)
+	agent.bg = manager.bg
+	manager.agents[agent.GuildID] = agent
+
+	agent.bg.goReport(func() {
+		time.Sleep(10 * time.Millisecond)
+		close(reported)
+	})
+
+	if abandoned := manager.Shutdown(context.Background()); abandoned != 0 {
+		t.Errorf("Expected no abandoned reports, got %d", abandoned)
+	}
+	select {
+	case <-reported:
+	default:
+		t.Error("Expected Shutdown to wait for the report")
+	}
+	if manager.GetAgentCount() != 0 {
+		t.Error("Expected agents to be removed")
+	}
+}
@@ -0,0 +1,91 @@
+package huma
+
+import "time"
+
+// Defaults for Tuning
+const (
+	DefaultTypingWPM      = 90
+	DefaultMaxTypingDelay = 30 * time.Second
+	DefaultFetchLimit     = 50
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// minTypingDelay keeps even one-word replies from arriving instantly
+const minTypingDelay = 500 * time.Millisecond
+
+// Tuning holds the agent's timing and size settings
+type Tuning struct {
+	// TypingWPM is the simulated typing speed
+	TypingWPM int
+	// MaxTypingDelay caps the typing delay of one message
+	MaxTypingDelay time.Duration
+	// FetchLimit is used when fetch_channel_messages gives no limit
+	FetchLimit int
+	// RequestTimeout bounds HUMA REST calls and the WebSocket handshake
+	RequestTimeout time.Duration
+}
+
+// DefaultTuning returns the settings used unless SetTuning changes them
+func DefaultTuning() Tuning {
+	return Tuning{
+		TypingWPM:      DefaultTypingWPM,
+		MaxTypingDelay: DefaultMaxTypingDelay,
+		FetchLimit:     DefaultFetchLimit,
+		RequestTimeout: DefaultRequestTimeout,
+	}
+}
+
+// withDefaults fills unset fields with their defaults
+func (t Tuning) withDefaults() Tuning {
+	defaults := DefaultTuning()
+	if t.TypingWPM <= 0 {
+		t.TypingWPM = defaults.TypingWPM
+	}
+	if t.MaxTypingDelay <= 0 {
+		t.MaxTypingDelay = defaults.MaxTypingDelay
+	}
+	if t.FetchLimit <= 0 {
+		t.FetchLimit = defaults.FetchLimit
+	}
+	if t.RequestTimeout <= 0 {
+		t.RequestTimeout = defaults.RequestTimeout
+	}
+	return t
+}
+
+// SetTuning sets the settings for agents created from now on. Unset fields
+// keep their defaults.
+func (m *Manager) SetTuning(tuning Tuning) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tuning = tuning.withDefaults()
+}
+
+// typingDelay is how long typing text takes at TypingWPM, between
+// minTypingDelay and MaxTypingDelay
+func (t Tuning) typingDelay(text string) time.Duration {
+	const secondsPerMinute = 60.0
+
+	// Count words
+	wordCount := 0
+	inWord := false
+	for _, r := range text {
+		if r == ' ' || r == '\n' || r == '\t' {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			wordCount++
+		}
+	}
+
+	seconds := (float64(wordCount) / float64(t.TypingWPM)) * secondsPerMinute
+	delay := time.Duration(seconds * float64(time.Second))
+
+	if delay < minTypingDelay {
+		delay = minTypingDelay
+	}
+	if delay > t.MaxTypingDelay {
+		delay = t.MaxTypingDelay
+	}
+	return delay
+}
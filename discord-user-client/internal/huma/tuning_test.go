@@ -0,0 +1,33 @@
+package huma
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypingDelay(t *testing.T) {
+	tuning := Tuning{TypingWPM: 60, MaxTypingDelay: 10 * time.Second}
+
+	if got := tuning.typingDelay("one two three"); got != 3*time.Second {
+		t.Errorf("Expected 3s for 3 words at 60 WPM, got %s", got)
+	}
+	if got := tuning.typingDelay(""); got != minTypingDelay {
+		t.Errorf("Expected the minimum delay for empty text, got %s", got)
+	}
+	if got := tuning.typingDelay(strings.Repeat("word ", 100)); got != 10*time.Second {
+		t.Errorf("Expected delay capped at 10s, got %s", got)
+	}
+}
+
+func TestSetTuningKeepsDefaults(t *testing.T) {
+	manager := NewManager("test-key")
+	manager.SetTuning(Tuning{TypingWPM: 120})
+
+	if manager.tuning.TypingWPM != 120 {
+		t.Errorf("Expected 120 WPM, got %d", manager.tuning.TypingWPM)
+	}
+	if manager.tuning.FetchLimit != DefaultFetchLimit || manager.tuning.MaxTypingDelay != DefaultMaxTypingDelay {
+		t.Errorf("Expected unset fields to keep defaults, got %+v", manager.tuning)
+	}
+}
@@ -0,0 +1,362 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+)
+
+// Attribute keys that drive level selection and correlation
+const (
+	KeySubsystem = "subsystem"
+	KeyGuildID   = "guild_id"
+	KeyChannelID = "channel_id"
+	KeyTraceID   = "trace_id"
+)
+
+// Config controls log output, verbosity and redaction
+type Config struct {
+	// Level is the default level for subsystems without an override
+	Level slog.Level
+	// SubsystemLevels overrides the level per subsystem (e.g. "huma" => debug)
+	SubsystemLevels map[string]slog.Level
+	// JSON selects JSON output instead of logfmt-style text
+	JSON bool
+	// RedactContent replaces message content, prompts, AI responses and HUMA
+	// context with their length
+	RedactContent bool
+	// Output defaults to stderr
+	Output io.Writer
+}
+
+// ConfigFromEnv reads LOG_LEVEL, LOG_LEVELS ("huma=debug,discord=warn"),
+// LOG_FORMAT ("text" or "json") and LOG_REDACT_CONTENT
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Level: slog.LevelInfo}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		level, err := ParseLevel(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+		cfg.Level = level
+	}
+
+	if v := os.Getenv("LOG_LEVELS"); v != "" {
+		levels, err := ParseSubsystemLevels(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid LOG_LEVELS: %w", err)
+		}
+		cfg.SubsystemLevels = levels
+	}
+
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "", "text":
+	case "json":
+		cfg.JSON = true
+	default:
+		return cfg, fmt.Errorf("invalid LOG_FORMAT %q (expected text or json)", os.Getenv("LOG_FORMAT"))
+	}
+
+	if v := os.Getenv("LOG_REDACT_CONTENT"); v != "" {
+		redact, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid LOG_REDACT_CONTENT: %w", err)
+		}
+		cfg.RedactContent = redact
+	}
+
+	return cfg, nil
+}
+
+// ParseLevel parses debug, info, warn or error (case-insensitive)
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// ParseSubsystemLevels parses "subsystem=level,subsystem=level"
+func ParseSubsystemLevels(s string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected subsystem=level, got %q", pair)
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(name)] = level
+	}
+	return levels, nil
+}
+
+// state is the process-wide logging configuration shared by all loggers
+type state struct {
+	mu            sync.RWMutex
+	base          slog.Handler
+	level         slog.Level
+	subsystems    map[string]slog.Level
+	guilds        map[string]slog.Level
+	redactContent bool
+}
+
+var global = newState(Config{Level: slog.LevelInfo})
+
+func newState(cfg Config) *state {
+	st := &state{}
+	st.configure(cfg)
+	return st
+}
+
+func (st *state) configure(cfg Config) {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	// Level filtering happens in our handler, so the base handler accepts everything
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var base slog.Handler
+	if cfg.JSON {
+		base = slog.NewJSONHandler(out, opts)
+	} else {
+		base = slog.NewTextHandler(out, opts)
+	}
+
+	subsystems := make(map[string]slog.Level, len(cfg.SubsystemLevels))
+	for k, v := range cfg.SubsystemLevels {
+		subsystems[k] = v
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.base = base
+	st.level = cfg.Level
+	st.subsystems = subsystems
+	if st.guilds == nil {
+		st.guilds = make(map[string]slog.Level)
+	}
+	st.redactContent = cfg.RedactContent
+}
+
+// levelFor returns the effective level. A guild override wins over the
+// subsystem level so one noisy guild can be debugged in isolation.
+func (st *state) levelFor(subsystem, guildID string) slog.Level {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	if guildID != "" {
+		if level, ok := st.guilds[guildID]; ok {
+			return level
+		}
+	}
+	if level, ok := st.subsystems[subsystem]; ok {
+		return level
+	}
+	return st.level
+}
+
+// minLevel returns the most verbose level a record for subsystem could be logged at
+func (st *state) minLevel(subsystem string) slog.Level {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	min := st.level
+	if level, ok := st.subsystems[subsystem]; ok {
+		min = level
+	}
+	for _, level := range st.guilds {
+		if level < min {
+			min = level
+		}
+	}
+	return min
+}
+
+// Setup configures output, levels and redaction for all loggers and routes
+// the standard library logger (used by dependencies) through slog
+func Setup(cfg Config) {
+	global.configure(cfg)
+	slog.SetDefault(For("default"))
+}
+
+// For returns a logger for a subsystem. Loggers created before Setup pick up
+// the configuration once it is applied.
+func For(subsystem string) *slog.Logger {
+	return slog.New(&handler{st: global, subsystem: subsystem})
+}
+
+// SetSubsystemLevel changes the level of a subsystem at runtime
+func SetSubsystemLevel(subsystem string, level slog.Level) {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+	global.subsystems[subsystem] = level
+}
+
+// SetGuildLevel overrides the level for all records tagged with guildID
+func SetGuildLevel(guildID string, level slog.Level) {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+	global.guilds[guildID] = level
+}
+
+// ClearGuildLevel removes a guild override
+func ClearGuildLevel(guildID string) {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+	delete(global.guilds, guildID)
+}
+
+// Levels is a snapshot of the current verbosity configuration
+type Levels struct {
+	Default    string            `json:"default"`
+	Subsystems map[string]string `json:"subsystems"`
+	Guilds     map[string]string `json:"guilds"`
+}
+
+// GetLevels returns the current levels
+func GetLevels() Levels {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+
+	levels := Levels{
+		Default:    strings.ToLower(global.level.String()),
+		Subsystems: make(map[string]string, len(global.subsystems)),
+		Guilds:     make(map[string]string, len(global.guilds)),
+	}
+	for k, v := range global.subsystems {
+		levels.Subsystems[k] = strings.ToLower(v.String())
+	}
+	for k, v := range global.guilds {
+		levels.Guilds[k] = strings.ToLower(v.String())
+	}
+	return levels
+}
+
+// handler applies per-subsystem/guild levels, adds trace IDs from the context
+// and redacts secrets before handing records to the configured base handler
+type handler struct {
+	st        *state
+	subsystem string
+	guildID   string
+	ops       []handlerOp
+}
+
+// handlerOp is a WithAttrs or WithGroup call, replayed on the base handler
+type handlerOp struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.guildID != "" {
+		return level >= h.st.levelFor(h.subsystem, h.guildID)
+	}
+	// The guild may still be on the record, so only rule out levels no guild enables
+	return level >= h.st.minLevel(h.subsystem)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	guildID := h.guildID
+	hasTrace := false
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case KeyGuildID:
+			if guildID == "" {
+				guildID = a.Value.String()
+			}
+		case KeyTraceID:
+			hasTrace = true
+		}
+		return true
+	})
+	if r.Level < h.st.levelFor(h.subsystem, guildID) {
+		return nil
+	}
+
+	h.st.mu.RLock()
+	base := h.st.base
+	redactContent := h.st.redactContent
+	h.st.mu.RUnlock()
+
+	base = base.WithAttrs([]slog.Attr{slog.String(KeySubsystem, h.subsystem)})
+	for _, op := range h.ops {
+		if op.group != "" {
+			base = base.WithGroup(op.group)
+		} else {
+			base = base.WithAttrs(redactAttrs(op.attrs, redactContent))
+		}
+	}
+
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a, redactContent))
+		return true
+	})
+	if !hasTrace {
+		if traceID := tracing.TraceIDFromContext(ctx); traceID != "" {
+			out.AddAttrs(slog.String(KeyTraceID, traceID))
+		}
+	}
+
+	return base.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := h.clone()
+	for _, a := range attrs {
+		if a.Key == KeyGuildID && len(h.groups()) == 0 {
+			clone.guildID = a.Value.String()
+		}
+	}
+	clone.ops = append(clone.ops, handlerOp{attrs: attrs})
+	return clone
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := h.clone()
+	clone.ops = append(clone.ops, handlerOp{group: name})
+	return clone
+}
+
+func (h *handler) clone() *handler {
+	return &handler{
+		st:        h.st,
+		subsystem: h.subsystem,
+		guildID:   h.guildID,
+		ops:       append([]handlerOp(nil), h.ops...),
+	}
+}
+
+// groups returns the groups opened so far
+func (h *handler) groups() []string {
+	var groups []string
+	for _, op := range h.ops {
+		if op.group != "" {
+			groups = append(groups, op.group)
+		}
+	}
+	return groups
+}
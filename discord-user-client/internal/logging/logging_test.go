@@ -0,0 +1,189 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/tracing"
+)
+
+// setupBuffer configures JSON logging into a buffer and restores defaults afterwards
+func setupBuffer(t *testing.T, cfg Config) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg.JSON = true
+	cfg.Output = &buf
+	global.configure(cfg)
+	t.Cleanup(func() {
+		global.configure(Config{Level: slog.LevelInfo})
+		global.mu.Lock()
+		global.guilds = make(map[string]slog.Level)
+		global.mu.Unlock()
+	})
+	return &buf
+}
+
+// records decodes JSON log lines
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("Invalid JSON log line %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	buf := setupBuffer(t, Config{
+		Level:           slog.LevelInfo,
+		SubsystemLevels: map[string]slog.Level{"huma": slog.LevelDebug, "discord": slog.LevelWarn},
+	})
+
+	For("huma").Debug("huma debug")
+	For("discord").Info("discord info")
+	For("discord").Warn("discord warn")
+	For("history").Debug("history debug")
+	For("history").Info("history info")
+
+	recs := records(t, buf)
+	var msgs []string
+	for _, r := range recs {
+		msgs = append(msgs, r["msg"].(string))
+	}
+	want := []string{"huma debug", "discord warn", "history info"}
+	if strings.Join(msgs, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, msgs)
+	}
+	if recs[0][KeySubsystem] != "huma" {
+		t.Errorf("Expected subsystem attribute, got %v", recs[0])
+	}
+}
+
+func TestGuildLevelOverride(t *testing.T) {
+	buf := setupBuffer(t, Config{Level: slog.LevelInfo})
+
+	SetGuildLevel("guild1", slog.LevelDebug)
+	SetGuildLevel("guild2", slog.LevelError)
+
+	logger := For("huma")
+	logger.With(KeyGuildID, "guild1").Debug("from logger attrs")
+	logger.Debug("from record attrs", KeyGuildID, "guild1")
+	logger.Debug("other guild", KeyGuildID, "guild3")
+	logger.Warn("quiet guild", KeyGuildID, "guild2")
+
+	recs := records(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %s", len(recs), buf.String())
+	}
+
+	ClearGuildLevel("guild1")
+	buf.Reset()
+	logger.Debug("after clear", KeyGuildID, "guild1")
+	if buf.Len() != 0 {
+		t.Errorf("Expected debug to be dropped after clearing override, got %s", buf.String())
+	}
+
+	levels := GetLevels()
+	if levels.Guilds["guild2"] != "error" || levels.Default != "info" {
+		t.Errorf("Unexpected levels snapshot: %+v", levels)
+	}
+}
+
+func TestTraceIDFromContext(t *testing.T) {
+	buf := setupBuffer(t, Config{Level: slog.LevelInfo})
+
+	ctx, span := tracing.NewTracer("test", nil).StartSpan(context.Background(), "discord.message")
+	For("discord").InfoContext(ctx, "message received", KeyChannelID, "c1")
+
+	recs := records(t, buf)
+	if len(recs) != 1 || recs[0][KeyTraceID] != span.TraceID() {
+		t.Errorf("Expected trace_id %s, got %v", span.TraceID(), recs)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	buf := setupBuffer(t, Config{Level: slog.LevelInfo})
+
+	AddSecret("super-secret-huma-key")
+	defer RemoveSecret("super-secret-huma-key")
+
+	discordToken := "MTIzNDU2Nzg5MDEyMzQ1Njc4.GaBcDe.abcdefghijklmnopqrstuvwxyz0123456789"
+	logger := For("huma")
+	logger.Info("Connecting to wss://api.example.com/socket.io/?agentId=a1&apiKey=abc123&EIO=4")
+	logger.Info("Using key super-secret-huma-key")
+	logger.Info("client created", "token", "anything", "note", "token is "+discordToken)
+	logger.Info("request failed", "error", errors.New("401: Bearer sk-live-123 rejected"))
+
+	out := buf.String()
+	for _, secret := range []string{"abc123", "super-secret-huma-key", "anything", discordToken, "sk-live-123"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted, got %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "agentId=a1") || !strings.Contains(out, "EIO=4") {
+		t.Errorf("Expected non-secret query params to be kept, got %s", out)
+	}
+}
+
+func TestRedactContent(t *testing.T) {
+	buf := setupBuffer(t, Config{Level: slog.LevelInfo, RedactContent: true})
+
+	For("discord").Info("message received", "content", "my private message", "author", "alice")
+
+	recs := records(t, buf)
+	if len(recs) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(recs))
+	}
+	if recs[0]["content"] != "[redacted 18 chars]" {
+		t.Errorf("Expected content to be redacted, got %v", recs[0]["content"])
+	}
+	if recs[0]["author"] != "alice" {
+		t.Errorf("Expected author to be kept, got %v", recs[0]["author"])
+	}
+}
+
+func TestRedactContent_HUMAContext(t *testing.T) {
+	buf := setupBuffer(t, Config{Level: slog.LevelDebug, RedactContent: true})
+
+	For("huma").Debug("Built context", "channel_id", "c1", "context", `{"messages":[{"content":"my private message"}]}`)
+
+	recs := records(t, buf)
+	if len(recs) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(recs))
+	}
+	if context, _ := recs[0]["context"].(string); strings.Contains(context, "private") || !strings.HasPrefix(context, "[redacted") {
+		t.Errorf("Expected the HUMA context to be redacted, got %v", recs[0]["context"])
+	}
+	if recs[0]["channel_id"] != "c1" {
+		t.Errorf("Expected channel_id to be kept, got %v", recs[0]["channel_id"])
+	}
+}
+
+func TestParseSubsystemLevels(t *testing.T) {
+	levels, err := ParseSubsystemLevels("huma=debug, discord = WARN")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if levels["huma"] != slog.LevelDebug || levels["discord"] != slog.LevelWarn {
+		t.Errorf("Unexpected levels: %v", levels)
+	}
+
+	if _, err := ParseSubsystemLevels("huma"); err == nil {
+		t.Error("Expected error for missing level")
+	}
+	if _, err := ParseSubsystemLevels("huma=loud"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
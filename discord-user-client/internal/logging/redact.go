@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redactedValue replaces secrets in log output
+const redactedValue = "[REDACTED]"
+
+// minSecretLen avoids redacting short, common strings registered by mistake
+const minSecretLen = 8
+
+var (
+	// discordTokenPattern matches user and bot tokens (base64 ID, timestamp, HMAC)
+	discordTokenPattern = regexp.MustCompile(`[A-Za-z0-9_-]{24,28}\.[A-Za-z0-9_-]{6,7}\.[A-Za-z0-9_-]{27,}|mfa\.[A-Za-z0-9_-]{20,}`)
+	// queryKeyPattern matches secrets in URLs and key=value pairs (e.g. ?apiKey=...)
+	queryKeyPattern = regexp.MustCompile(`(?i)((?:api[_-]?key|token|secret|password)=)[^&\s"']+`)
+	// bearerPattern matches Authorization header values
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// sensitiveKeys are attribute keys whose values are always redacted
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"api_key":       true,
+	"apikey":        true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+}
+
+// contentKeys are attribute keys holding user or model text, redacted when
+// RedactContent is enabled. "context" is the context sent to HUMA, which
+// carries the recent message history.
+var contentKeys = map[string]bool{
+	"content":  true,
+	"prompt":   true,
+	"response": true,
+	"chunk":    true,
+	"context":  true,
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// AddSecret registers a value (API key, Discord token) that must never appear in logs
+func AddSecret(secret string) {
+	if len(secret) < minSecretLen {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// RemoveSecret unregisters a secret, e.g. when a Discord account is disconnected
+func RemoveSecret(secret string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for i, s := range secrets {
+		if s == secret {
+			secrets = append(secrets[:i], secrets[i+1:]...)
+			return
+		}
+	}
+}
+
+// Redact scrubs registered secrets, Discord tokens, API keys in URLs and bearer tokens from s
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	secretsMu.RUnlock()
+
+	s = discordTokenPattern.ReplaceAllString(s, redactedValue)
+	s = queryKeyPattern.ReplaceAllString(s, "${1}"+redactedValue)
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redactedValue)
+	return s
+}
+
+func redactAttrs(attrs []slog.Attr, redactContent bool) []slog.Attr {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = redactAttr(a, redactContent)
+	}
+	return out
+}
+
+func redactAttr(a slog.Attr, redactContent bool) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if sensitiveKeys[key] {
+		return slog.String(a.Key, redactedValue)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		if redactContent && contentKeys[key] {
+			return slog.String(a.Key, fmt.Sprintf("[redacted %d chars]", len(v.String())))
+		}
+		return slog.String(a.Key, Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redactAttrs(group, redactContent)...)}
+	case slog.KindAny:
+		switch val := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(val.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(val.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
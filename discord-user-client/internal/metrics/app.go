@@ -0,0 +1,76 @@
+package metrics
+
+import "runtime"
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+// SizeBuckets are payload size buckets in bytes
+var SizeBuckets = []float64{1024, 4096, 16384, 65536, 131072, 262144, 524288, 1048576}
+
+// TypingBuckets are simulated typing delay buckets in seconds (delays are capped at 30s)
+var TypingBuckets = []float64{0.5, 1, 2, 3, 5, 8, 13, 20, 30}
+
+// Discord gateway and message processing
+var (
+	DiscordEvents = Default.NewCounterVec("neonrain_discord_events_total",
+		"Discord gateway events received, by event type.", "type")
+	MessagesProcessed = Default.NewCounterVec("neonrain_messages_processed_total",
+		"Discord messages forwarded for processing, by guild.", "guild_id")
+	DiscordSendFailures = Default.NewCounterVec("neonrain_discord_send_failures_total",
+		"Failed Discord message sends, by reason.", "reason")
+	EventQueueDepth = Default.NewGaugeVec("neonrain_event_queue_depth",
+		"Gateway events waiting to be processed, by guild.", "guild_id")
+	EventsDropped = Default.NewCounterVec("neonrain_events_dropped_total",
+		"Gateway events dropped because their guild's queue was busy, by guild and message priority.", "guild_id", "priority")
+	EventQueueWait = Default.NewHistogramVec("neonrain_event_queue_wait_seconds",
+		"Time gateway events wait in their guild's queue.", DefaultBuckets)
+	UserMessagesLimited = Default.NewCounterVec("neonrain_user_messages_limited_total",
+		"Messages kept from HUMA because their author was over a rate limit or ignored, by reason.", "reason")
+)
+
+// HUMA connections and agent behaviour
+var (
+	HumaConnects = Default.NewCounterVec("neonrain_huma_connects_total",
+		"Successful HUMA WebSocket connections.")
+	HumaConnectErrors = Default.NewCounterVec("neonrain_huma_connect_errors_total",
+		"Failed HUMA agent creations or WebSocket connections.")
+	HumaReconnects = Default.NewCounterVec("neonrain_huma_reconnects_total",
+		"HUMA agent reconnects after a dead connection.")
+	HumaContextUpdateBytes = Default.NewHistogramVec("neonrain_huma_context_update_bytes",
+		"Size of context update frames sent to HUMA.", SizeBuckets, "event")
+	HumaToolCallDuration = Default.NewHistogramVec("neonrain_huma_tool_call_duration_seconds",
+		"Time from receiving a tool call to sending its result, by tool.", DefaultBuckets, "tool")
+	SendsBlocked = Default.NewCounterVec("neonrain_sends_blocked_total",
+		"Agent sends blocked by the send policy, by rule.", "rule")
+	TypingDelay = Default.NewHistogramVec("neonrain_typing_delay_seconds",
+		"Simulated typing delay before sending a message.", TypingBuckets)
+)
+
+// Backend API calls
+var (
+	BackendRequestDuration = Default.NewHistogramVec("neonrain_backend_request_duration_seconds",
+		"Backend API call latency, by endpoint.", DefaultBuckets, "endpoint")
+	BackendRequestErrors = Default.NewCounterVec("neonrain_backend_request_errors_total",
+		"Failed backend API calls, by endpoint.", "endpoint")
+)
+
+// Live activity stream
+var (
+	ActivityStreamSubscribers = Default.NewGaugeVec("neonrain_activity_stream_subscribers",
+		"Connected activity stream (SSE) subscribers.")
+	ActivityEventsDropped = Default.NewCounterVec("neonrain_activity_events_dropped_total",
+		"Activity events dropped because a subscriber was too slow.")
+)
+
+// Caches and runtime
+var (
+	HistoryChannels = Default.NewGaugeVec("neonrain_history_cached_channels",
+		"Channels with cached message history.")
+	HistoryMessages = Default.NewGaugeVec("neonrain_history_cached_messages",
+		"Messages held in the history cache.")
+	HumaAgents = Default.NewGaugeVec("neonrain_huma_agents",
+		"Live HUMA agents.")
+	Goroutines = Default.NewGaugeFunc("neonrain_goroutines",
+		"Number of running goroutines.", func() float64 { return float64(runtime.NumGoroutine()) })
+)
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_events_total", "Test events.", "type")
+
+	c.Inc("MessageCreate")
+	c.Inc("MessageCreate")
+	c.Add(3, "Ready")
+
+	if got := c.Value("MessageCreate"); got != 2 {
+		t.Errorf("Expected 2, got %v", got)
+	}
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	out := sb.String()
+
+	for _, want := range []string{
+		"# HELP test_events_total Test events.",
+		"# TYPE test_events_total counter",
+		`test_events_total{type="MessageCreate"} 2`,
+		`test_events_total{type="Ready"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_duration_seconds", "Test durations.", []float64{1, 0.1}, "tool")
+
+	h.Observe(0.05, "send_message")
+	h.Observe(0.5, "send_message")
+	h.Observe(5, "send_message")
+
+	if got := h.Count("send_message"); got != 3 {
+		t.Errorf("Expected 3 observations, got %d", got)
+	}
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{tool="send_message",le="0.1"} 1`,
+		`test_duration_seconds_bucket{tool="send_message",le="1"} 2`,
+		`test_duration_seconds_bucket{tool="send_message",le="+Inf"} 3`,
+		`test_duration_seconds_sum{tool="send_message"} 5.55`,
+		`test_duration_seconds_count{tool="send_message"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGauges(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_cache_size", "Test cache size.")
+	r.NewGaugeFunc("test_answer", "Test gauge func.", func() float64 { return 42 })
+
+	g.Set(10)
+	g.Set(7)
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	out := sb.String()
+
+	if !strings.Contains(out, "test_cache_size 7\n") {
+		t.Errorf("Expected gauge value 7, got:\n%s", out)
+	}
+	if !strings.Contains(out, "test_answer 42\n") {
+		t.Errorf("Expected gauge func value 42, got:\n%s", out)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_escaped_total", "Test escaping.", "reason")
+	c.Inc("say \"hi\"\nnow")
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+
+	want := `test_escaped_total{reason="say \"hi\"\nnow"} 1`
+	if !strings.Contains(sb.String(), want) {
+		t.Errorf("Expected %q, got:\n%s", want, sb.String())
+	}
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_dup_total", "First.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	r.NewCounterVec("test_dup_total", "Second.")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_requests_total", "Test requests.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain content type, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "test_requests_total 1") {
+		t.Errorf("Unexpected body:\n%s", w.Body.String())
+	}
+}
+
+func TestDefaultRegistryIncludesAppMetrics(t *testing.T) {
+	var sb strings.Builder
+	Default.WriteText(&sb)
+	out := sb.String()
+
+	for _, name := range []string{
+		"neonrain_discord_events_total",
+		"neonrain_huma_tool_call_duration_seconds",
+		"neonrain_backend_request_errors_total",
+		"neonrain_goroutines",
+	} {
+		if !strings.Contains(out, "# TYPE "+name) {
+			t.Errorf("Expected default registry to expose %s", name)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package pause tracks operator kill switches for guilds and channels.
+// Pauses take effect immediately and are persisted to a JSON file so they
+// survive restarts until someone lifts them.
+package pause
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a pause on a whole guild (ChannelID empty) or a single channel
+type Entry struct {
+	GuildID   string    `json:"guildId"`
+	ChannelID string    `json:"channelId,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	PausedBy  string    `json:"pausedBy,omitempty"`
+	PausedAt  time.Time `json:"pausedAt"`
+}
+
+// Store holds active pauses. A nil Store pauses nothing.
+type Store struct {
+	path    string
+	entries map[string]Entry // key(guildID, channelID) -> entry
+	mu      sync.RWMutex
+}
+
+// key identifies a pause; guild-wide pauses have an empty channel part
+func key(guildID, channelID string) string {
+	return guildID + "/" + channelID
+}
+
+// NewStore creates a store persisted at path, loading any pauses saved there.
+// An empty path keeps pauses in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		entries: make(map[string]Entry),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pause state: %w", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse pause state: %w", err)
+	}
+	for _, entry := range entries {
+		s.entries[key(entry.GuildID, entry.ChannelID)] = entry
+	}
+	return s, nil
+}
+
+// Pause pauses a guild, or one channel in it when channelID is set
+func (s *Store) Pause(guildID, channelID, reason, pausedBy string) (Entry, error) {
+	entry := Entry{
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Reason:    reason,
+		PausedBy:  pausedBy,
+		PausedAt:  time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key(guildID, channelID)] = entry
+	return entry, s.saveLocked()
+}
+
+// Resume lifts a pause. Returns false if there was no such pause.
+// Resuming a channel does not lift a pause on its guild.
+func (s *Store) Resume(guildID, channelID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(guildID, channelID)
+	if _, exists := s.entries[k]; !exists {
+		return false, nil
+	}
+	delete(s.entries, k)
+	return true, s.saveLocked()
+}
+
+// IsPaused reports whether a channel is paused, either directly or through its guild.
+// An empty channelID checks the guild-wide pause only.
+func (s *Store) IsPaused(guildID, channelID string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, paused := s.entries[key(guildID, "")]; paused {
+		return true
+	}
+	if channelID == "" {
+		return false
+	}
+	_, paused := s.entries[key(guildID, channelID)]
+	return paused
+}
+
+// List returns all active pauses sorted by guild, then channel
+func (s *Store) List() []Entry {
+	if s == nil {
+		return []Entry{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sortedLocked()
+}
+
+func (s *Store) sortedLocked() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].GuildID != entries[j].GuildID {
+			return entries[i].GuildID < entries[j].GuildID
+		}
+		return entries[i].ChannelID < entries[j].ChannelID
+	})
+	return entries
+}
+
+// saveLocked writes the pauses to disk atomically. Caller must hold mu.
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pause state: %w", err)
+	}
+
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create pause state directory: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write pause state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save pause state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package pause
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGuildAndChannelPauses(t *testing.T) {
+	store, err := NewStore("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	store.Pause("guild1", "chan1", "noisy", "api")
+	if !store.IsPaused("guild1", "chan1") {
+		t.Error("Expected chan1 to be paused")
+	}
+	if store.IsPaused("guild1", "chan2") || store.IsPaused("guild1", "") {
+		t.Error("Expected channel pause not to affect other channels or the guild")
+	}
+
+	store.Pause("guild1", "", "", "api")
+	if !store.IsPaused("guild1", "chan2") {
+		t.Error("Expected guild pause to cover every channel")
+	}
+
+	// Resuming a channel leaves the guild pause in place
+	if resumed, _ := store.Resume("guild1", "chan1"); !resumed {
+		t.Error("Expected chan1 pause to be lifted")
+	}
+	if !store.IsPaused("guild1", "chan1") {
+		t.Error("Expected chan1 to stay paused through its guild")
+	}
+
+	if resumed, _ := store.Resume("guild2", ""); resumed {
+		t.Error("Expected resume of unpaused guild to report false")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "pauses.json")
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.Pause("guild1", "", "incident", "owner:tok_1"); err != nil {
+		t.Fatalf("Failed to pause: %v", err)
+	}
+	store.Pause("guild2", "chan1", "", "api")
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	entries := reloaded.List()
+	if len(entries) != 2 || entries[0].GuildID != "guild1" || entries[0].Reason != "incident" {
+		t.Fatalf("Expected pauses to survive reload, got %+v", entries)
+	}
+	if !reloaded.IsPaused("guild2", "chan1") {
+		t.Error("Expected channel pause to survive reload")
+	}
+
+	reloaded.Resume("guild1", "")
+	reloaded, _ = NewStore(path)
+	if reloaded.IsPaused("guild1", "any") {
+		t.Error("Expected resume to be persisted")
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var store *Store
+	if store.IsPaused("guild1", "chan1") {
+		t.Error("Expected nil store to pause nothing")
+	}
+	if len(store.List()) != 0 {
+		t.Error("Expected nil store to list nothing")
+	}
+}
@@ -0,0 +1,364 @@
+// Package recording writes everything that crosses the client's boundaries -
+// Discord gateway events, Discord REST calls and HUMA frames - to a compressed
+// JSONL file, so agent behaviour can be replayed offline.
+//
+// A recording holds message content. Treat it like a database dump.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+)
+
+// logger is used for recorder failures
+var logger = logging.For("recording")
+
+// Entry kinds
+const (
+	// KindSettings is written first: the settings replay needs to reproduce timing
+	KindSettings = "settings"
+	// KindConfig is the guild configs in effect, written when they change
+	KindConfig = "config"
+	// KindDiscordEvent is a gateway event handed to the client's EventHandler
+	KindDiscordEvent = "discord_event"
+	// KindDiscordCall is a Discord REST call and its response
+	KindDiscordCall = "discord_call"
+	// KindHumaAgent maps a HUMA agent to the guild it serves
+	KindHumaAgent = "huma_agent"
+	// KindHumaSent and KindHumaReceived are Socket.IO frames to and from HUMA
+	KindHumaSent     = "huma_sent"
+	KindHumaReceived = "huma_received"
+)
+
+// flushInterval bounds how much of a recording a crash can lose
+const flushInterval = time.Second
+
+// Entry is one line of a recording
+type Entry struct {
+	Seq     uint64    `json:"seq"`
+	At      time.Time `json:"at"`
+	Kind    string    `json:"kind"`
+	Account string    `json:"account,omitempty"` // token fingerprint
+	GuildID string    `json:"guildId,omitempty"`
+	AgentID string    `json:"agentId,omitempty"`
+	// Event is the discordgo type name of a gateway event, e.g. "MessageCreate"
+	Event string `json:"event,omitempty"`
+	// Frame is a HUMA Socket.IO frame as sent on the wire
+	Frame string          `json:"frame,omitempty"`
+	Call  *Call           `json:"call,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Call is a Discord REST call. Request headers, and with them the token, are
+// not recorded.
+type Call struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"` // path and query
+	Request    string `json:"request,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Settings are the timing and size settings of the recorded process
+type Settings struct {
+	TypingWPM      int           `json:"typingWpm"`
+	MaxTypingDelay time.Duration `json:"maxTypingDelay"`
+	FetchLimit     int           `json:"fetchLimit"`
+	HistorySize    int           `json:"historySize"`
+	DebounceWindow time.Duration `json:"debounceWindow,omitempty"`
+
+	// Send policy, so replayed sends are blocked as they were
+	SendCooldown        time.Duration `json:"sendCooldown,omitempty"`
+	SendRequireHuman    bool          `json:"sendRequireHuman,omitempty"`
+	SendChannelHourly   int           `json:"sendChannelHourly,omitempty"`
+	SendGuildHourly     int           `json:"sendGuildHourly,omitempty"`
+	SendDuplicateWindow time.Duration `json:"sendDuplicateWindow,omitempty"`
+}
+
+// Recorder appends entries to a gzip-compressed JSONL file. A nil *Recorder
+// records nothing, so callers don't need to check whether recording is on.
+type Recorder struct {
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	gz     *gzip.Writer
+	seq    uint64
+	failed bool
+	config string // last recorded config, to skip unchanged ones
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Open starts recording to path. An existing recording is appended to; gzip
+// readers treat the result as one stream.
+func Open(path string) (*Recorder, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create recording directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+
+	r := &Recorder{
+		path: path,
+		file: file,
+		gz:   gzip.NewWriter(file),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go r.flushLoop()
+	return r, nil
+}
+
+// Path returns the recording file path
+func (r *Recorder) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// flushLoop periodically flushes compressed data to the file
+func (r *Recorder) flushLoop() {
+	defer close(r.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.gz != nil && !r.failed {
+				if err := r.gz.Flush(); err != nil {
+					r.failLocked(err)
+				}
+			}
+			r.mu.Unlock()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// write appends an entry, stamping its sequence number and time
+func (r *Recorder) write(entry Entry) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gz == nil || r.failed {
+		return
+	}
+
+	r.seq++
+	entry.Seq = r.seq
+	entry.At = time.Now().UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logger.Warn("Failed to marshal recording entry", "kind", entry.Kind, "error", err)
+		return
+	}
+	line = append(line, '\n')
+	if _, err := r.gz.Write(line); err != nil {
+		r.failLocked(err)
+	}
+}
+
+// failLocked stops recording after a write error rather than logging every entry
+func (r *Recorder) failLocked(err error) {
+	r.failed = true
+	logger.Error("Recording failed, no longer recording", "path", r.path, "error", err)
+}
+
+// data marshals a payload, logging rather than failing the caller
+func data(kind string, payload interface{}) json.RawMessage {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		logger.Warn("Failed to marshal recording payload", "kind", kind, "error", err)
+		return nil
+	}
+	return raw
+}
+
+// Settings records the settings replay needs. Call it once, first.
+func (r *Recorder) Settings(settings Settings) {
+	if r == nil {
+		return
+	}
+	r.write(Entry{Kind: KindSettings, Data: data(KindSettings, settings)})
+}
+
+// Config records the guild configs in effect, unless they are the same as last time
+func (r *Recorder) Config(configs interface{}) {
+	if r == nil {
+		return
+	}
+	raw := data(KindConfig, configs)
+	r.mu.Lock()
+	unchanged := string(raw) == r.config
+	r.config = string(raw)
+	r.mu.Unlock()
+	if !unchanged {
+		r.write(Entry{Kind: KindConfig, Data: raw})
+	}
+}
+
+// DiscordEvent records a gateway event received by an account
+func (r *Recorder) DiscordEvent(account string, event interface{}) {
+	if r == nil {
+		return
+	}
+	r.write(Entry{Kind: KindDiscordEvent, Account: account, Event: TypeName(event), Data: data(KindDiscordEvent, event)})
+}
+
+// DiscordCall records a REST call made by an account
+func (r *Recorder) DiscordCall(account string, call Call) {
+	if r == nil {
+		return
+	}
+	r.write(Entry{Kind: KindDiscordCall, Account: account, Call: &call})
+}
+
+// HumaAgent records which guild an agent serves
+func (r *Recorder) HumaAgent(guildID, agentID string) {
+	if r == nil {
+		return
+	}
+	r.write(Entry{Kind: KindHumaAgent, GuildID: guildID, AgentID: agentID})
+}
+
+// HumaSent records a frame sent to HUMA
+func (r *Recorder) HumaSent(guildID, agentID string, frame []byte) {
+	if r == nil {
+		return
+	}
+	r.write(Entry{Kind: KindHumaSent, GuildID: guildID, AgentID: agentID, Frame: string(frame)})
+}
+
+// HumaReceived records a frame received from HUMA
+func (r *Recorder) HumaReceived(guildID, agentID string, frame []byte) {
+	if r == nil {
+		return
+	}
+	r.write(Entry{Kind: KindHumaReceived, GuildID: guildID, AgentID: agentID, Frame: string(frame)})
+}
+
+// Close flushes and closes the recording
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gz == nil {
+		return nil
+	}
+	gzErr := r.gz.Close()
+	fileErr := r.file.Close()
+	r.gz = nil
+	if gzErr != nil {
+		return fmt.Errorf("failed to close recording: %w", gzErr)
+	}
+	if fileErr != nil {
+		return fmt.Errorf("failed to close recording: %w", fileErr)
+	}
+	return nil
+}
+
+// TypeName returns the discordgo type name of an event (e.g. "MessageCreate")
+func TypeName(event interface{}) string {
+	name := fmt.Sprintf("%T", event)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// maxLine bounds one entry; Ready events for large accounts are big
+const maxLine = 64 * 1024 * 1024
+
+// Read reads a recording, compressed or not. A recording cut off by a crash
+// returns the entries read so far.
+func Read(r io.Reader) ([]Entry, error) {
+	buffered := bufio.NewReader(r)
+	var input io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		defer gz.Close()
+		input = gz
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(text, &entry); err != nil {
+			return entries, fmt.Errorf("line %d: invalid entry: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return entries, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return entries, nil
+}
+
+// ReadFile reads a recording from a file
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// IsRecording reports whether the start of a file looks like a recording
+// rather than some other JSONL: it is gzip-compressed, or its first line has a
+// kind field.
+func IsRecording(head []byte) bool {
+	if len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b {
+		return true
+	}
+	line := head
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		line = head[:i]
+	}
+	var probe struct {
+		Kind string `json:"kind"`
+	}
+	return json.Unmarshal(line, &probe) == nil && probe.Kind != ""
+}
@@ -0,0 +1,114 @@
+package recording
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec", "session.jsonl.gz")
+
+	recorder, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	recorder.Settings(Settings{TypingWPM: 90})
+	recorder.Config([]string{"a"})
+	recorder.Config([]string{"a"}) // unchanged, skipped
+	recorder.DiscordEvent("acct", &discordgo.MessageCreate{Message: &discordgo.Message{ID: "m1", Content: "hi"}})
+	recorder.HumaSent("g1", "agent1", []byte(`42["message",{}]`))
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	// Reopening appends a second gzip stream
+	recorder, err = Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	recorder.HumaReceived("g1", "agent1", []byte(`2`))
+	recorder.Close()
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	kinds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		kinds = append(kinds, entry.Kind)
+	}
+	want := "settings,config,discord_event,huma_sent,huma_received"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Fatalf("Expected kinds %s, got %s", want, got)
+	}
+	if entries[2].Event != "MessageCreate" || entries[2].Account != "acct" {
+		t.Errorf("Unexpected event entry: %+v", entries[2])
+	}
+	if entries[3].Frame != `42["message",{}]` || entries[3].GuildID != "g1" {
+		t.Errorf("Unexpected frame entry: %+v", entries[3])
+	}
+}
+
+func TestNilRecorderIsSafe(t *testing.T) {
+	var recorder *Recorder
+	recorder.Settings(Settings{})
+	recorder.DiscordEvent("acct", &discordgo.Ready{})
+	recorder.HumaSent("g1", "agent1", nil)
+	if err := recorder.Close(); err != nil {
+		t.Errorf("Expected nil recorder to close cleanly, got %v", err)
+	}
+}
+
+func TestTransportRecordsCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"m1"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "calls.jsonl.gz")
+	recorder, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := &http.Client{Transport: &Transport{Recorder: recorder, Account: "acct"}}
+	resp, err := client.Post(server.URL+"/api/v9/channels/c1/messages?x=1", "application/json", strings.NewReader(`{"content":"hi"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if body.String() != `{"id":"m1"}` {
+		t.Errorf("Expected the caller to still see the body, got %q", body.String())
+	}
+	recorder.Close()
+
+	entries, err := ReadFile(path)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one entry, got %d (%v)", len(entries), err)
+	}
+	call := entries[0].Call
+	if call == nil || call.Method != "POST" || call.Path != "/api/v9/channels/c1/messages?x=1" ||
+		call.Request != `{"content":"hi"}` || call.Status != http.StatusCreated || call.Response != `{"id":"m1"}` {
+		t.Errorf("Unexpected call: %+v", call)
+	}
+}
+
+func TestIsRecording(t *testing.T) {
+	if !IsRecording([]byte{0x1f, 0x8b, 0x08}) {
+		t.Error("Expected gzip data to be a recording")
+	}
+	if !IsRecording([]byte(`{"seq":1,"kind":"settings"}` + "\n")) {
+		t.Error("Expected plain JSONL with kinds to be a recording")
+	}
+	if IsRecording([]byte(`{"type":"message_received"}` + "\n")) {
+		t.Error("Expected an event log not to be a recording")
+	}
+}
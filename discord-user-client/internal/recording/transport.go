@@ -0,0 +1,56 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport records every request made through it. It wraps the http.Client
+// of a discordgo session, which carries all of the session's REST calls.
+type Transport struct {
+	Base     http.RoundTripper
+	Recorder *Recorder
+	Account  string
+}
+
+// RoundTrip performs the request and records it along with the response
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Recorder == nil {
+		return base.RoundTrip(req)
+	}
+
+	call := Call{Method: req.Method, Path: req.URL.RequestURI()}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			raw, _ := io.ReadAll(body)
+			body.Close()
+			call.Request = string(raw)
+		}
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	call.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		call.Error = err.Error()
+		t.Recorder.DiscordCall(t.Account, call)
+		return resp, err
+	}
+
+	raw, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	call.Status = resp.StatusCode
+	call.Response = string(raw)
+	if readErr != nil {
+		call.Error = readErr.Error()
+	}
+	t.Recorder.DiscordCall(t.Account, call)
+	return resp, readErr
+}
@@ -0,0 +1,239 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+)
+
+// newEvent returns an empty gateway event of a recorded type, false for types
+// replay doesn't know. These are the events that change state or reach the
+// client's handlers.
+func newEvent(name string) (interface{}, bool) {
+	switch name {
+	case "Ready":
+		return &discordgo.Ready{}, true
+	case "Resumed":
+		return &discordgo.Resumed{}, true
+	case "GuildCreate":
+		return &discordgo.GuildCreate{}, true
+	case "GuildUpdate":
+		return &discordgo.GuildUpdate{}, true
+	case "GuildDelete":
+		return &discordgo.GuildDelete{}, true
+	case "GuildMemberAdd":
+		return &discordgo.GuildMemberAdd{}, true
+	case "GuildMemberUpdate":
+		return &discordgo.GuildMemberUpdate{}, true
+	case "GuildMemberRemove":
+		return &discordgo.GuildMemberRemove{}, true
+	case "GuildRoleCreate":
+		return &discordgo.GuildRoleCreate{}, true
+	case "GuildRoleUpdate":
+		return &discordgo.GuildRoleUpdate{}, true
+	case "GuildRoleDelete":
+		return &discordgo.GuildRoleDelete{}, true
+	case "ChannelCreate":
+		return &discordgo.ChannelCreate{}, true
+	case "ChannelUpdate":
+		return &discordgo.ChannelUpdate{}, true
+	case "ChannelDelete":
+		return &discordgo.ChannelDelete{}, true
+	case "ThreadCreate":
+		return &discordgo.ThreadCreate{}, true
+	case "ThreadUpdate":
+		return &discordgo.ThreadUpdate{}, true
+	case "ThreadDelete":
+		return &discordgo.ThreadDelete{}, true
+	case "MessageCreate":
+		return &discordgo.MessageCreate{}, true
+	case "MessageUpdate":
+		return &discordgo.MessageUpdate{}, true
+	case "MessageDelete":
+		return &discordgo.MessageDelete{}, true
+	}
+	return nil, false
+}
+
+// decodeEvent turns a recorded gateway event back into its discordgo type
+func decodeEvent(entry recording.Entry) (interface{}, bool, error) {
+	event, ok := newEvent(entry.Event)
+	if !ok {
+		return nil, false, nil
+	}
+	if err := json.Unmarshal(entry.Data, event); err != nil {
+		return nil, true, fmt.Errorf("seq %d: invalid %s: %w", entry.Seq, entry.Event, err)
+	}
+	return event, true, nil
+}
+
+// configs is the ConfigProvider of a replay, fed from recorded configs
+type configs struct {
+	mu     sync.RWMutex
+	guilds map[string]client.GuildConfigWithUser
+}
+
+// set replaces the configs with a recorded set
+func (c *configs) set(list []client.GuildConfigWithUser) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.guilds = make(map[string]client.GuildConfigWithUser, len(list))
+	for _, config := range list {
+		c.guilds[config.GuildID] = config
+	}
+}
+
+// GetConfigForGuild implements client.ConfigProvider
+func (c *configs) GetConfigForGuild(guildID string) (client.GuildConfigWithUser, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	config, ok := c.guilds[guildID]
+	return config, ok
+}
+
+// activeGuilds returns the guilds an account has the bot active in
+func (c *configs) activeGuilds(account string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var guilds []string
+	for guildID, config := range c.guilds {
+		if config.TokenFingerprint == account && config.BotActive {
+			guilds = append(guilds, guildID)
+		}
+	}
+	return guilds
+}
+
+// transport stands in for Discord's REST API for one account. Reads are
+// answered from the recording in the order they were made; writes are answered
+// with the recorded response when there is one, and captured for comparison.
+type transport struct {
+	mu       sync.Mutex
+	recorded map[string][]recording.Call // method+path -> calls not yet replayed
+	writes   []string                    // described writes made during replay
+	calls    int
+	nextID   int
+}
+
+func newTransport() *transport {
+	return &transport{recorded: make(map[string][]recording.Call)}
+}
+
+// add queues a recorded call
+func (t *transport) add(call recording.Call) {
+	key := call.Method + " " + call.Path
+	t.recorded[key] = append(t.recorded[key], call)
+}
+
+// RoundTrip answers a request from the recording
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	path := req.URL.RequestURI()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	if req.Method != http.MethodGet {
+		t.writes = append(t.writes, describeWrite(req.Method, path, string(body)))
+	}
+
+	key := req.Method + " " + path
+	if queue := t.recorded[key]; len(queue) > 0 {
+		call := queue[0]
+		// The last recorded answer keeps serving repeats
+		if len(queue) > 1 {
+			t.recorded[key] = queue[1:]
+		}
+		if call.Error != "" && call.Status == 0 {
+			return nil, fmt.Errorf("recorded error: %s", call.Error)
+		}
+		return response(req, call.Status, call.Response), nil
+	}
+	return t.synthesize(req, path, body), nil
+}
+
+// synthesize answers a request the recording has no answer for. Sends get a
+// plausible message back so the client carries on; everything else is empty.
+func (t *transport) synthesize(req *http.Request, path string, body []byte) *http.Response {
+	if req.Method == http.MethodGet {
+		return response(req, http.StatusNotFound, `{"message":"Not in recording","code":0}`)
+	}
+	if req.Method == http.MethodPost && strings.HasSuffix(strings.SplitN(path, "?", 2)[0], "/messages") {
+		var send struct {
+			Content string `json:"content"`
+		}
+		json.Unmarshal(body, &send)
+		t.nextID++
+		message, _ := json.Marshal(map[string]interface{}{
+			"id":         fmt.Sprintf("replay-msg-%d", t.nextID),
+			"channel_id": channelFromPath(path),
+			"content":    send.Content,
+		})
+		return response(req, http.StatusOK, string(message))
+	}
+	return response(req, http.StatusNoContent, "")
+}
+
+// response builds an HTTP response
+func response(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// channelFromPath returns the channel ID of a /channels/{id}/... path
+func channelFromPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if part == "channels" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// callsMade returns how many requests were made, for settling
+func (t *transport) callsMade() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls
+}
+
+// madeWrites returns the writes made so far
+func (t *transport) madeWrites() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.writes...)
+}
+
+// describeWrite normalizes a write for comparison. The nonce discordgo adds to
+// sends differs every run.
+func describeWrite(method, path, body string) string {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(body), &fields) == nil {
+		delete(fields, "nonce")
+		normalized, _ := json.Marshal(fields)
+		body = string(normalized)
+	}
+	body = string(bytes.TrimSpace([]byte(body)))
+	if body == "" {
+		return method + " " + path
+	}
+	return method + " " + path + " " + body
+}
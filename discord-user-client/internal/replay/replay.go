@@ -0,0 +1,466 @@
+// Package replay drives Discord clients and guild agents from a recording made
+// with RECORD_FILE. Recorded gateway events and HUMA tool calls are fed in
+// order against a virtual clock, recorded REST responses stand in for Discord
+// and a fake HUMA for the real one, and what the client sends is compared with
+// what it sent when recorded.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/clock"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// Defaults for Options
+const (
+	// DefaultQuiet is how long nothing must happen before the next entry is fed
+	DefaultQuiet = 30 * time.Millisecond
+	// DefaultSettleTimeout bounds the wait for one entry's effects
+	DefaultSettleTimeout = 5 * time.Second
+)
+
+// Options tune a replay
+type Options struct {
+	Quiet         time.Duration
+	SettleTimeout time.Duration
+}
+
+// Result summarizes a replay
+type Result struct {
+	Events     int // gateway events replayed
+	Skipped    int // gateway events of types replay doesn't handle
+	ToolCalls  int // HUMA frames injected
+	Streams    int // HUMA and Discord streams compared
+	Mismatches int
+}
+
+// Run replays a recording and writes a comparison of each stream to out
+func Run(ctx context.Context, entries []recording.Entry, out io.Writer, opts Options) (*Result, error) {
+	if opts.Quiet <= 0 {
+		opts.Quiet = DefaultQuiet
+	}
+	if opts.SettleTimeout <= 0 {
+		opts.SettleTimeout = DefaultSettleTimeout
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("recording is empty")
+	}
+
+	r := newRunner(ctx, entries, opts)
+	defer r.close()
+	if err := r.run(); err != nil {
+		return r.result, err
+	}
+	r.compare(out)
+	return r.result, nil
+}
+
+// account is one replayed Discord account
+type account struct {
+	client    *client.DiscordClient
+	session   *discordgo.Session
+	handler   func(any)
+	transport *transport
+	ready     bool
+	expected  []string // described writes made when recorded
+}
+
+// frame is a frame an agent sent to the fake HUMA
+type frame struct {
+	agentID string
+	frame   string
+}
+
+// runner holds the state of one replay
+type runner struct {
+	ctx     context.Context
+	entries []recording.Entry
+	opts    Options
+	result  *Result
+
+	clock    *clock.Virtual
+	server   *humatest.Server
+	manager  *huma.Manager
+	configs  *configs
+	pauses   *pause.Store
+	settings recording.Settings
+	accounts map[string]*account
+
+	mu         sync.Mutex
+	frames     []frame
+	agentGuild map[string]string   // fake agent ID -> guild
+	expected   map[string][]string // guild -> described frames sent when recorded
+	missing    map[string]int      // guild -> tool calls with no agent to receive them
+}
+
+func newRunner(ctx context.Context, entries []recording.Entry, opts Options) *runner {
+	r := &runner{
+		ctx:        ctx,
+		entries:    entries,
+		opts:       opts,
+		result:     &Result{},
+		clock:      clock.NewVirtual(entries[0].At),
+		server:     humatest.NewServer(""),
+		configs:    &configs{},
+		accounts:   make(map[string]*account),
+		agentGuild: make(map[string]string),
+		expected:   make(map[string][]string),
+		missing:    make(map[string]int),
+	}
+	r.pauses, _ = pause.NewStore("")
+	r.server.OnFrame = func(agentID, data string) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.frames = append(r.frames, frame{agentID, data})
+	}
+
+	for _, entry := range entries {
+		if entry.Kind == recording.KindSettings {
+			json.Unmarshal(entry.Data, &r.settings)
+			break
+		}
+	}
+
+	r.manager = huma.NewManager("replay")
+	r.manager.SetBaseURL(r.server.URL())
+	r.manager.SetClock(r.clock)
+	r.manager.SetReporter(discardReporter{})
+	r.manager.SetTuning(huma.Tuning{
+		TypingWPM:      r.settings.TypingWPM,
+		MaxTypingDelay: r.settings.MaxTypingDelay,
+		FetchLimit:     r.settings.FetchLimit,
+	})
+	r.manager.SetSendPolicy(huma.SendPolicy{
+		Cooldown:            r.settings.SendCooldown,
+		RequireHumanMessage: r.settings.SendRequireHuman,
+		ChannelHourlySends:  r.settings.SendChannelHourly,
+		GuildHourlySends:    r.settings.SendGuildHourly,
+		DuplicateWindow:     r.settings.SendDuplicateWindow,
+	})
+	return r
+}
+
+// close stops agents and the fake HUMA
+func (r *runner) close() {
+	for _, acct := range r.accounts {
+		acct.client.StopAcceptingEvents()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r.manager.Drain(ctx)
+	r.manager.Shutdown(ctx)
+	r.server.Close()
+}
+
+// run feeds every entry, then lets pending timers fire
+func (r *runner) run() error {
+	for _, entry := range r.entries {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		r.advanceTo(entry.At)
+		if err := r.feed(entry); err != nil {
+			return err
+		}
+	}
+
+	// Let messages still being typed go out, as they would have
+	maxDelay := r.settings.MaxTypingDelay
+	if maxDelay <= 0 {
+		maxDelay = huma.DefaultTuning().MaxTypingDelay
+	}
+	r.advanceTo(r.entries[len(r.entries)-1].At.Add(maxDelay + time.Second))
+	return nil
+}
+
+// feed applies one entry
+func (r *runner) feed(entry recording.Entry) error {
+	switch entry.Kind {
+	case recording.KindConfig:
+		var list []client.GuildConfigWithUser
+		if err := json.Unmarshal(entry.Data, &list); err != nil {
+			return fmt.Errorf("seq %d: invalid config: %w", entry.Seq, err)
+		}
+		r.configs.set(list)
+		for name, acct := range r.accounts {
+			acct.client.UpdateMonitoredGuilds(r.configs.activeGuilds(name))
+		}
+
+	case recording.KindDiscordEvent:
+		event, known, err := decodeEvent(entry)
+		if err != nil {
+			return err
+		}
+		acct := r.account(entry.Account)
+		_, isReady := event.(*discordgo.Ready)
+		if !known || (!acct.ready && !isReady) {
+			r.result.Skipped++
+			return nil
+		}
+		acct.ready = true
+		acct.session.State.OnInterface(acct.session, event)
+		acct.handler(event)
+		r.result.Events++
+		switch event.(type) {
+		case *discordgo.Ready, *discordgo.MessageCreate:
+			r.settle()
+		}
+
+	case recording.KindDiscordCall:
+		if entry.Call != nil && entry.Call.Method != http.MethodGet {
+			acct := r.account(entry.Account)
+			acct.expected = append(acct.expected, describeWrite(entry.Call.Method, entry.Call.Path, entry.Call.Request))
+		}
+
+	case recording.KindHumaSent:
+		if strings.HasPrefix(entry.Frame, "42") {
+			r.expected[entry.GuildID] = append(r.expected[entry.GuildID], describeFrame(entry.Frame))
+		}
+
+	case recording.KindHumaReceived:
+		// Only events; the fake HUMA does its own handshake and pings
+		if !strings.HasPrefix(entry.Frame, "42") {
+			return nil
+		}
+		agent := r.manager.GetAgent(entry.GuildID)
+		if agent == nil || r.server.SendFrame(agent.AgentID, entry.Frame) != nil {
+			r.missing[entry.GuildID]++
+			return nil
+		}
+		r.result.ToolCalls++
+		r.settle()
+	}
+	return nil
+}
+
+// account returns a replayed account, creating its client on first use. Its
+// REST calls are answered from the account's recorded calls.
+func (r *runner) account(name string) *account {
+	if acct, ok := r.accounts[name]; ok {
+		return acct
+	}
+
+	t := newTransport()
+	for _, entry := range r.entries {
+		if entry.Kind == recording.KindDiscordCall && entry.Account == name && entry.Call != nil {
+			t.add(*entry.Call)
+		}
+	}
+	session, _ := discordgo.New("replay")
+	session.Client = &http.Client{Transport: t}
+
+	dc := client.NewMultiGuildDiscordClient(r.manager, nil, r.configs)
+	dc.SetPauseStore(r.pauses)
+	if r.settings.HistorySize > 0 {
+		dc.SetHistorySize(r.settings.HistorySize)
+	}
+	dc.SetDebounceWindow(r.settings.DebounceWindow)
+	handler := dc.AttachSession(client.NewGatewaySession(session), name)
+	dc.UpdateMonitoredGuilds(r.configs.activeGuilds(name))
+
+	acct := &account{client: dc, session: session, handler: handler, transport: t}
+	r.accounts[name] = acct
+	return acct
+}
+
+// advanceTo moves the virtual clock to t, letting each timer's effects settle
+// before the next one fires
+func (r *runner) advanceTo(t time.Time) {
+	for {
+		next, ok := r.clock.Next()
+		if !ok || next.After(t) {
+			break
+		}
+		r.clock.AdvanceTo(next)
+		r.settle()
+	}
+	r.clock.AdvanceTo(t)
+}
+
+// settle waits until nothing has been sent to HUMA or Discord and no timer has
+// been started or stopped for the quiet period
+func (r *runner) settle() {
+	deadline := time.Now().Add(r.opts.SettleTimeout)
+	last := r.activity()
+	quietSince := time.Now()
+	for time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+		current := r.activity()
+		if current != last {
+			last, quietSince = current, time.Now()
+			continue
+		}
+		if time.Since(quietSince) >= r.opts.Quiet {
+			break
+		}
+	}
+	r.mapAgents()
+}
+
+// activity summarizes everything that can change while an entry settles
+func (r *runner) activity() string {
+	r.mu.Lock()
+	frames := len(r.frames)
+	r.mu.Unlock()
+	calls := 0
+	for _, acct := range r.accounts {
+		calls += acct.transport.callsMade()
+	}
+	next, _ := r.clock.Next()
+	return fmt.Sprintf("%d/%d/%d/%d", frames, calls, r.clock.Pending(), next.UnixNano())
+}
+
+// mapAgents remembers which guild each fake agent serves. Agents can be removed
+// and recreated, so this runs after every settle rather than at the end.
+func (r *runner) mapAgents() {
+	agents := r.manager.GetAgents()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, agent := range agents {
+		r.agentGuild[agent.AgentID] = agent.GuildID
+	}
+}
+
+// compare prints each stream's recorded and replayed sends and counts differences
+func (r *runner) compare(out io.Writer) {
+	r.mapAgents()
+
+	r.mu.Lock()
+	replayed := make(map[string][]string)
+	for _, f := range r.frames {
+		if guildID, ok := r.agentGuild[f.agentID]; ok && strings.HasPrefix(f.frame, "42") {
+			replayed[guildID] = append(replayed[guildID], describeFrame(f.frame))
+		}
+	}
+	r.mu.Unlock()
+
+	guilds := make(map[string]bool)
+	for guildID := range r.expected {
+		guilds[guildID] = true
+	}
+	for guildID := range replayed {
+		guilds[guildID] = true
+	}
+	for _, guildID := range sortedKeys(guilds) {
+		title := fmt.Sprintf("HUMA guild %s", guildID)
+		if n := r.missing[guildID]; n > 0 {
+			title += fmt.Sprintf(" (%d recorded tool calls had no agent)", n)
+			r.result.Mismatches += n
+		}
+		r.compareStream(out, title, r.expected[guildID], replayed[guildID])
+	}
+
+	names := make(map[string]bool)
+	for name := range r.accounts {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		acct := r.accounts[name]
+		r.compareStream(out, fmt.Sprintf("Discord account %s", name), acct.expected, acct.transport.madeWrites())
+	}
+
+	fmt.Fprintf(out, "\n%d events, %d tool calls replayed (%d events skipped), %d streams", r.result.Events, r.result.ToolCalls, r.result.Skipped, r.result.Streams)
+	if r.result.Mismatches > 0 {
+		fmt.Fprintf(out, ", %d differ from the recording", r.result.Mismatches)
+	}
+	fmt.Fprintln(out)
+}
+
+// compareStream prints one stream, line by line
+func (r *runner) compareStream(out io.Writer, title string, recorded, replayed []string) {
+	r.result.Streams++
+	fmt.Fprintf(out, "%s: %d recorded, %d replayed\n", title, len(recorded), len(replayed))
+	for i := 0; i < len(recorded) || i < len(replayed); i++ {
+		switch {
+		case i >= len(replayed):
+			r.result.Mismatches++
+			fmt.Fprintf(out, "    MISMATCH missing: %s\n", recorded[i])
+		case i >= len(recorded):
+			r.result.Mismatches++
+			fmt.Fprintf(out, "    MISMATCH extra: %s\n", replayed[i])
+		case recorded[i] != replayed[i]:
+			r.result.Mismatches++
+			fmt.Fprintf(out, "    MISMATCH recorded: %s\n", recorded[i])
+			fmt.Fprintf(out, "             replayed: %s\n", replayed[i])
+		default:
+			fmt.Fprintf(out, "    ok %s\n", recorded[i])
+		}
+	}
+}
+
+// describeFrame summarizes a frame sent to HUMA: the event name and
+// description of a context update, or the outcome of a tool result. Context
+// payloads carry times and are left out.
+func describeFrame(data string) string {
+	var payload []json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "42")), &payload); err != nil || len(payload) < 2 {
+		return truncate(data, 200)
+	}
+	var event struct {
+		Content struct {
+			Type        string          `json:"type"`
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			ToolCallID  string          `json:"toolCallId"`
+			Status      string          `json:"status"`
+			Success     bool            `json:"success"`
+			Result      json.RawMessage `json:"result"`
+			Error       string          `json:"error"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(payload[1], &event); err != nil {
+		return truncate(data, 200)
+	}
+	c := event.Content
+	if c.Type == "tool-result" {
+		switch {
+		case c.Status == "canceled":
+			return fmt.Sprintf("tool-result %s canceled: %s", c.ToolCallID, c.Error)
+		case !c.Success:
+			return fmt.Sprintf("tool-result %s failed: %s", c.ToolCallID, c.Error)
+		default:
+			return fmt.Sprintf("tool-result %s ok: %s", c.ToolCallID, truncate(string(c.Result), 200))
+		}
+	}
+	return fmt.Sprintf("%s: %s", c.Name, truncate(c.Description, 200))
+}
+
+// truncate shortens s to n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// discardReporter drops agent reports; a replay has no backend
+type discardReporter struct{}
+
+func (discardReporter) ReportStatsBatch(types.StatsBatchPayload) error       { return nil }
+func (discardReporter) ReportAgentAction(types.AgentActionPayload) error     { return nil }
+func (discardReporter) ReportAgentActivity(types.AgentActivityPayload) error { return nil }
@@ -0,0 +1,156 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/recording"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// recordingBuilder writes entries the way the recorder would
+type recordingBuilder struct {
+	at      time.Time
+	entries []recording.Entry
+}
+
+func (b *recordingBuilder) add(after time.Duration, entry recording.Entry) {
+	b.at = b.at.Add(after)
+	entry.Seq = uint64(len(b.entries) + 1)
+	entry.At = b.at
+	b.entries = append(b.entries, entry)
+}
+
+func (b *recordingBuilder) data(t *testing.T, v interface{}) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	return raw
+}
+
+func (b *recordingBuilder) event(t *testing.T, after time.Duration, event interface{}) {
+	b.add(after, recording.Entry{Kind: recording.KindDiscordEvent, Account: "acct", Event: recording.TypeName(event), Data: b.data(t, event)})
+}
+
+// conversation records a user asking a question and the agent answering it
+func conversation(t *testing.T, recordedSends []recording.Entry) []recording.Entry {
+	b := &recordingBuilder{at: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)}
+	b.add(0, recording.Entry{Kind: recording.KindSettings, Data: b.data(t, recording.Settings{TypingWPM: 60, MaxTypingDelay: 10 * time.Second, FetchLimit: 20, HistorySize: 10})})
+	b.add(0, recording.Entry{Kind: recording.KindConfig, Data: b.data(t, []client.GuildConfigWithUser{{
+		ServerConfig:     types.ServerConfig{GuildID: "g1", GuildName: "Guild", BotActive: true, Personality: "friendly"},
+		UserID:           "owner",
+		TokenFingerprint: "acct",
+	}})})
+
+	bot := &discordgo.User{ID: "bot", Username: "helper"}
+	b.event(t, time.Second, &discordgo.Ready{Version: 9, SessionID: "s", User: bot})
+	b.event(t, time.Second, &discordgo.GuildCreate{Guild: &discordgo.Guild{
+		ID:       "g1",
+		Name:     "Guild",
+		Channels: []*discordgo.Channel{{ID: "c1", GuildID: "g1", Name: "general", Type: discordgo.ChannelTypeGuildText}},
+	}})
+
+	// Lookups made while handling the message, recorded as they were answered
+	channel := `{"id":"c1","guild_id":"g1","name":"general","type":0}`
+	for _, call := range []recording.Call{
+		{Method: "GET", Path: "/api/v9/channels/c1", Status: 200, Response: channel},
+		{Method: "GET", Path: "/api/v9/channels/c1/messages?limit=10", Status: 200, Response: "[]"},
+		{Method: "GET", Path: "/api/v9/guilds/g1/channels", Status: 200, Response: "[" + channel + "]"},
+	} {
+		call := call
+		b.add(0, recording.Entry{Kind: recording.KindDiscordCall, Account: "acct", Call: &call})
+	}
+	b.event(t, 0, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID: "m1", ChannelID: "c1", GuildID: "g1", Content: "how do I reset my password?",
+		Author: &discordgo.User{ID: "u1", Username: "alice"}, Timestamp: b.at,
+	}})
+
+	// HUMA answers a second later
+	b.add(time.Second, recording.Entry{Kind: recording.KindHumaReceived, GuildID: "g1", AgentID: "agent-live",
+		Frame: `42["event",{"type":"tool-call","toolCallId":"call-1","toolName":"send_message","arguments":{"channel_id":"c1","message":"Use the reset link on the login page"}}]`})
+
+	for _, entry := range recordedSends {
+		b.add(0, entry)
+	}
+	return b.entries
+}
+
+func TestRun_MatchesItself(t *testing.T) {
+	// Replay once to learn what the client sends, then check a recording that
+	// contains exactly that matches on a second run
+	var first bytes.Buffer
+	result, err := Run(context.Background(), conversation(t, nil), &first, Options{})
+	if err != nil {
+		t.Fatalf("Replay failed: %v\n%s", err, first.String())
+	}
+	if result.Events != 3 || result.ToolCalls != 1 {
+		t.Fatalf("Expected 3 events and 1 tool call, got %+v\n%s", result, first.String())
+	}
+	output := first.String()
+	for _, want := range []string{
+		"MISMATCH extra: POST /api/v9/channels/c1/messages",
+		"Use the reset link on the login page",
+		"tool-result call-1 ok",
+		`new-message: User alice sent a new message in channel #general: "how do I reset my password?"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	// Build the recording the live client would have made
+	var sends []recording.Entry
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "MISMATCH extra: POST ") {
+			continue
+		}
+		fields := strings.SplitN(strings.TrimPrefix(line, "MISMATCH extra: "), " ", 3)
+		call := &recording.Call{Method: fields[0], Path: fields[1], Status: 204}
+		if len(fields) == 3 {
+			call.Request = fields[2]
+		}
+		sends = append(sends, recording.Entry{Kind: recording.KindDiscordCall, Account: "acct", Call: call})
+	}
+	if len(sends) < 2 {
+		t.Fatalf("Expected a typing indicator and a send, got %d writes", len(sends))
+	}
+
+	var second bytes.Buffer
+	if _, err := Run(context.Background(), conversation(t, sends), &second, Options{}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	_, discord, found := strings.Cut(second.String(), "Discord account acct: ")
+	if !found {
+		t.Fatalf("Expected the account's stream in the output:\n%s", second.String())
+	}
+	if strings.Contains(discord, "MISMATCH") {
+		t.Errorf("Expected Discord writes to match the recording:\n%s", second.String())
+	}
+}
+
+func TestRun_UsesVirtualTime(t *testing.T) {
+	// A 10 second typing delay must not take 10 seconds
+	started := time.Now()
+	if _, err := Run(context.Background(), conversation(t, nil), &bytes.Buffer{}, Options{}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected replay to run on the virtual clock, took %s", elapsed)
+	}
+}
+
+func TestDescribeWrite_DropsNonce(t *testing.T) {
+	a := describeWrite("POST", "/api/v9/channels/c1/messages", `{"content":"hi","nonce":"1"}`)
+	b := describeWrite("POST", "/api/v9/channels/c1/messages", `{"nonce":"2","content":"hi"}`)
+	if a != b {
+		t.Errorf("Expected sends differing only in nonce to match: %q vs %q", a, b)
+	}
+}
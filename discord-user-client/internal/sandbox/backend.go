@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// Backend stands in for the Node backend's /api/discord/* routes. It serves
+// token configs the sandbox controls and keeps the stats and agent reports the
+// client posts.
+type Backend struct {
+	server *httptest.Server
+	apiKey string
+
+	mu      sync.Mutex
+	tokens  []types.TokenConfig
+	stats   []types.StatsBatchPayload
+	reports []json.RawMessage // agent actions and activity trails
+}
+
+// NewBackend starts a stand-in backend that accepts requests with apiKey
+func NewBackend(apiKey string) *Backend {
+	b := &Backend{apiKey: apiKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/discord/tokens", b.handleTokens)
+	mux.HandleFunc("POST /api/discord/stats", b.handleStats)
+	mux.HandleFunc("POST /api/discord/agent-action", b.handleAgentAction)
+	b.server = httptest.NewServer(b.authorize(mux))
+	return b
+}
+
+// URL is the base URL to pass to backend.NewClient
+func (b *Backend) URL() string {
+	return b.server.URL
+}
+
+// Close stops the server
+func (b *Backend) Close() {
+	b.server.Close()
+}
+
+// SetTokenConfigs replaces the configs served from /api/discord/tokens
+func (b *Backend) SetTokenConfigs(tokens []types.TokenConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = tokens
+}
+
+// TokenConfigs returns the configs being served
+func (b *Backend) TokenConfigs() []types.TokenConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]types.TokenConfig(nil), b.tokens...)
+}
+
+// Stats returns the stats batches posted so far
+func (b *Backend) Stats() []types.StatsBatchPayload {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]types.StatsBatchPayload(nil), b.stats...)
+}
+
+// Reports returns the agent action and activity payloads posted so far
+func (b *Backend) Reports() []json.RawMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]json.RawMessage(nil), b.reports...)
+}
+
+// authorize rejects requests without the API key
+func (b *Backend) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != b.apiKey {
+			http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (b *Backend) handleTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.TokenResponse{Success: true, Tokens: b.TokenConfigs()})
+}
+
+func (b *Backend) handleStats(w http.ResponseWriter, r *http.Request) {
+	var batch types.StatsBatchPayload
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, `{"error":"Invalid stats batch"}`, http.StatusBadRequest)
+		return
+	}
+	b.mu.Lock()
+	b.stats = append(b.stats, batch)
+	b.mu.Unlock()
+	writeSuccess(w)
+}
+
+func (b *Backend) handleAgentAction(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
+		http.Error(w, `{"error":"Invalid report"}`, http.StatusBadRequest)
+		return
+	}
+	b.mu.Lock()
+	b.reports = append(b.reports, body)
+	b.mu.Unlock()
+	writeSuccess(w)
+}
+
+// writeSuccess answers a report the way the backend does
+func writeSuccess(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"success":true}`))
+}
@@ -0,0 +1,183 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// cancelReason is the reason given for cancels issued from the REPL
+const cancelReason = "Canceled from the sandbox"
+
+// replHelp lists the REPL commands
+const replHelp = `Type a message to post it as the current user in the current channel.
+  alice: hi          post as a known user, who becomes the current user
+  #random            switch to a channel
+  /as <user>         switch user, adding them to the guild if new
+  /channel <name>    switch channel, creating it if new
+  /send [#ch] <text> the agent sends a message (send_message)
+  /tool <name> [{json args}]
+                     the agent calls any tool
+  /cancel [id]       the agent cancels a tool call (default: the latest)
+  /bot on|off        turn the bot on or off through the backend config
+  /history           show the current channel
+  /who               list users and channels
+  /help, /quit`
+
+// userLine matches "name: message"
+var userLine = regexp.MustCompile(`^([\w.-]+):\s+(.+)$`)
+
+// REPL reads sandbox commands and posts messages typed as guild members
+type REPL struct {
+	sandbox *Sandbox
+	user    string
+	channel string
+}
+
+// NewREPL starts as the first configured user in the first channel
+func NewREPL(s *Sandbox) *REPL {
+	r := &REPL{sandbox: s, user: "alice", channel: "general"}
+	if len(s.options.Users) > 0 {
+		r.user = s.options.Users[0]
+	}
+	if len(s.options.Channels) > 0 {
+		r.channel = s.options.Channels[0]
+	}
+	return r
+}
+
+// Run executes lines from in until /quit or EOF
+func (r *REPL) Run(in io.Reader) error {
+	r.sandbox.printf("Posting as %s in #%s. /help lists commands.", r.user, r.channel)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !r.Execute(scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Execute runs one line. Returns false on /quit.
+func (r *REPL) Execute(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	if err := r.execute(line); err != nil {
+		if errors.Is(err, errQuit) {
+			return false
+		}
+		r.sandbox.printf("! %v", err)
+	}
+	return true
+}
+
+// errQuit ends the REPL
+var errQuit = errors.New("quit")
+
+func (r *REPL) execute(line string) error {
+	s := r.sandbox
+	if strings.HasPrefix(line, "#") && !strings.Contains(line, " ") {
+		if _, ok := s.ChannelID(line); !ok {
+			return fmt.Errorf("unknown channel %s; /channel creates one", line)
+		}
+		r.channel = strings.TrimPrefix(line, "#")
+		s.printf("Now in #%s", r.channel)
+		return nil
+	}
+	if !strings.HasPrefix(line, "/") {
+		if match := userLine.FindStringSubmatch(line); match != nil && r.knownUser(match[1]) {
+			r.user, line = match[1], match[2]
+		}
+		_, err := s.Post(r.channel, r.user, line)
+		return err
+	}
+
+	command, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch command {
+	case "/as":
+		if rest == "" {
+			return fmt.Errorf("usage: /as <user>")
+		}
+		r.user = rest
+		s.user(rest)
+		s.printf("Posting as %s", r.user)
+	case "/channel":
+		if rest == "" {
+			return fmt.Errorf("usage: /channel <name>")
+		}
+		r.channel = strings.TrimPrefix(rest, "#")
+		s.AddChannel(r.channel)
+		s.printf("Now in #%s", r.channel)
+	case "/send":
+		channel := r.channel
+		if strings.HasPrefix(rest, "#") {
+			channel, rest, _ = strings.Cut(rest, " ")
+		}
+		if rest == "" {
+			return fmt.Errorf("usage: /send [#channel] <text>")
+		}
+		_, err := s.Send(channel, rest)
+		return err
+	case "/tool":
+		name, argsJSON, _ := strings.Cut(rest, " ")
+		if name == "" {
+			return fmt.Errorf("usage: /tool <name> [{json args}]")
+		}
+		args := map[string]interface{}{}
+		if argsJSON = strings.TrimSpace(argsJSON); argsJSON != "" {
+			if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+				return fmt.Errorf("invalid tool arguments: %w", err)
+			}
+		}
+		_, err := s.CallTool(name, args)
+		return err
+	case "/cancel":
+		return s.Cancel(rest, cancelReason)
+	case "/bot":
+		switch rest {
+		case "on", "off":
+			if err := s.SetBotActive(rest == "on"); err != nil {
+				return err
+			}
+			s.printf("Bot is %s", rest)
+		default:
+			return fmt.Errorf("usage: /bot on|off")
+		}
+	case "/history":
+		messages, err := s.History(r.channel)
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			s.printf("  %s %s: %s", message.Timestamp.Format("15:04:05"), message.Author.Username, message.Content)
+		}
+	case "/who":
+		s.printf("Users: %s", strings.Join(s.Users(), ", "))
+		s.printf("Channels: #%s", strings.Join(s.Channels(), ", #"))
+		s.printf("Posting as %s in #%s", r.user, r.channel)
+	case "/help":
+		s.printf("%s", replHelp)
+	case "/quit", "/exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %s; /help lists commands", command)
+	}
+	return nil
+}
+
+// knownUser reports whether name is a guild member
+func (r *REPL) knownUser(name string) bool {
+	for _, user := range r.sandbox.Users() {
+		if user == name {
+			return true
+		}
+	}
+	return false
+}
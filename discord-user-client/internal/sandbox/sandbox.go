@@ -0,0 +1,427 @@
+// Package sandbox runs the client's full stack in-process against a fake
+// Discord guild, the fake HUMA and a stand-in for the backend, so
+// internal/huma and internal/client can be worked on without tokens or API
+// keys. The real ClientManager, huma.Manager and backend client are used; only
+// the services they talk to are fakes.
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client/discordtest"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// IDs of the sandbox guild and account
+const (
+	GuildID = "sandbox-guild"
+	OwnerID = "sandbox-owner"
+	Token   = "sandbox-token"
+	APIKey  = "sandbox"
+)
+
+// agentWait is how long tool calls wait for the guild's agent to be created
+const agentWait = 2 * time.Second
+
+// Options configure the sandbox guild and bot
+type Options struct {
+	GuildName   string
+	Channels    []string
+	Users       []string
+	BotName     string
+	Personality string
+	Rules       string
+	Information string
+	// TypingWPM is the bot's simulated typing speed
+	TypingWPM int
+	// DebounceWindow batches a channel's messages until it has been quiet
+	// this long; zero sends each message to the agent at once
+	DebounceWindow time.Duration
+	// SendPolicy limits the agent's sends; nil uses the same defaults as serve
+	SendPolicy *huma.SendPolicy
+	// HumaURL and HumaAPIKey point agents at a real HUMA instead of the fake
+	HumaURL    string
+	HumaAPIKey string
+}
+
+// DefaultOptions returns a guild with two channels and two users
+func DefaultOptions() Options {
+	return Options{
+		GuildName:   "Sandbox",
+		Channels:    []string{"general", "random"},
+		Users:       []string{"alice", "bob"},
+		BotName:     "helper",
+		Personality: "Friendly and concise.",
+		TypingWPM:   huma.DefaultTypingWPM,
+	}
+}
+
+// Sandbox is a running stack. Everything the agent sees and does is written
+// to the transcript.
+type Sandbox struct {
+	Discord *discordtest.Session
+	Huma    *humatest.Server // nil when using a real HUMA
+	Backend *Backend
+	Manager *client.ClientManager
+
+	humaManager *huma.Manager
+	stats       *backend.StatsReporter
+	events      *events.Subscription
+	watchDone   chan struct{}
+	options     Options
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	mu           sync.Mutex
+	channels     map[string]string // name -> ID
+	users        map[string]*discordgo.User
+	lastToolCall string
+}
+
+// Start builds the stack and connects the sandbox account. The transcript is
+// written to out.
+func Start(opts Options, out io.Writer) (*Sandbox, error) {
+	defaults := DefaultOptions()
+	if opts.GuildName == "" {
+		opts.GuildName = defaults.GuildName
+	}
+	if len(opts.Channels) == 0 {
+		opts.Channels = defaults.Channels
+	}
+	if opts.BotName == "" {
+		opts.BotName = defaults.BotName
+	}
+
+	s := &Sandbox{
+		Discord:   discordtest.NewSession(&discordgo.User{ID: "sandbox-bot", Username: opts.BotName}),
+		Backend:   NewBackend(APIKey),
+		options:   opts,
+		out:       out,
+		channels:  make(map[string]string),
+		users:     make(map[string]*discordgo.User),
+		watchDone: make(chan struct{}),
+	}
+	s.Discord.AddGuild(GuildID, opts.GuildName)
+	for _, name := range opts.Channels {
+		s.AddChannel(name)
+	}
+	for _, name := range opts.Users {
+		s.user(name)
+	}
+
+	humaURL, humaKey := opts.HumaURL, opts.HumaAPIKey
+	if humaURL == "" {
+		s.Huma = humatest.NewServer("")
+		s.Huma.OnEvent = s.onAgentEvent
+		humaURL, humaKey = s.Huma.URL(), APIKey
+	}
+
+	backendClient := backend.NewClient(s.Backend.URL(), APIKey)
+	s.stats = backend.NewStatsReporter(backendClient, time.Minute)
+	s.stats.Start()
+
+	s.humaManager = huma.NewManager(humaKey)
+	s.humaManager.SetBaseURL(humaURL)
+	s.humaManager.SetTuning(huma.Tuning{TypingWPM: opts.TypingWPM})
+	policy := huma.DefaultSendPolicy()
+	if opts.SendPolicy != nil {
+		policy = *opts.SendPolicy
+	}
+	s.humaManager.SetSendPolicy(policy)
+	s.humaManager.SetReporter(backendClient)
+	s.humaManager.SetStatsReporter(s.stats)
+
+	pauses, err := pause.NewStore("")
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to create pause store: %w", err)
+	}
+	s.Manager = client.NewClientManager(s.humaManager, backendClient)
+	s.Manager.SetStatsReporter(s.stats)
+	s.Manager.SetPauseStore(pauses)
+	s.Manager.SetDebounceWindow(opts.DebounceWindow)
+	s.Manager.SetConnector(func(dc *client.DiscordClient, token string) error {
+		s.Discord.SetEventHandler(dc.AttachSession(s.Discord, client.TokenFingerprint(token)))
+		s.Discord.Connect()
+		return nil
+	})
+
+	s.events = events.Default.Subscribe([]string{GuildID}, 0)
+	go s.watch()
+
+	s.Backend.SetTokenConfigs(s.tokenConfigs(true))
+	if _, err := s.Manager.Resync(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to sync sandbox config: %w", err)
+	}
+	return s, nil
+}
+
+// Close stops the agent, the account and the fakes
+func (s *Sandbox) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if s.Manager != nil {
+		s.Manager.StopAcceptingEvents()
+	}
+	s.humaManager.Shutdown(ctx)
+	if s.Manager != nil {
+		s.Manager.DisconnectAll()
+	}
+	s.stats.Stop()
+	if s.events != nil {
+		s.events.Close()
+		<-s.watchDone
+	}
+	if s.Huma != nil {
+		s.Huma.Close()
+	}
+	s.Backend.Close()
+}
+
+// tokenConfigs is the sandbox account's config as the backend serves it
+func (s *Sandbox) tokenConfigs(botActive bool) []types.TokenConfig {
+	return []types.TokenConfig{{
+		DiscordToken: Token,
+		UserID:       OwnerID,
+		Servers: []types.ServerConfig{{
+			GuildID:     GuildID,
+			GuildName:   s.options.GuildName,
+			BotActive:   botActive,
+			BotName:     s.options.BotName,
+			Personality: s.options.Personality,
+			Rules:       s.options.Rules,
+			Information: s.options.Information,
+		}},
+	}}
+}
+
+// SetBotActive turns the bot on or off in the guild through the backend config
+func (s *Sandbox) SetBotActive(active bool) error {
+	s.Backend.SetTokenConfigs(s.tokenConfigs(active))
+	if _, err := s.Manager.Resync(); err != nil {
+		return fmt.Errorf("failed to resync: %w", err)
+	}
+	return nil
+}
+
+// AddChannel adds a text channel, returning its ID
+func (s *Sandbox) AddChannel(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.channels[name]; ok {
+		return id
+	}
+	id := "ch-" + name
+	s.Discord.AddChannel(GuildID, id, name)
+	s.channels[name] = id
+	return id
+}
+
+// ChannelID returns the ID of a channel by name
+func (s *Sandbox) ChannelID(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.channels[strings.TrimPrefix(name, "#")]
+	return id, ok
+}
+
+// channelName returns the name of a channel by ID, or the ID if unknown
+func (s *Sandbox) channelName(channelID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, id := range s.channels {
+		if id == channelID {
+			return name
+		}
+	}
+	return channelID
+}
+
+// Channels returns the channel names, sorted
+func (s *Sandbox) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedNames(s.channels)
+}
+
+// Users returns the names of the guild members who have posted or were
+// configured, sorted
+func (s *Sandbox) Users() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedNames(s.users)
+}
+
+// user returns a member by name, adding them to the guild on first use
+func (s *Sandbox) user(name string) *discordgo.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[name]; ok {
+		return user
+	}
+	user := &discordgo.User{ID: "u-" + name, Username: name}
+	s.Discord.AddMember(GuildID, user)
+	s.users[name] = user
+	return user
+}
+
+// Post sends a message as a user, adding them to the guild if needed
+func (s *Sandbox) Post(channel, username, content string) (*discordgo.Message, error) {
+	channelID, ok := s.ChannelID(channel)
+	if !ok {
+		return nil, fmt.Errorf("unknown channel #%s", strings.TrimPrefix(channel, "#"))
+	}
+	return s.Discord.Post(channelID, s.user(username).ID, content)
+}
+
+// History returns a channel's messages, oldest first
+func (s *Sandbox) History(channel string) ([]*discordgo.Message, error) {
+	channelID, ok := s.ChannelID(channel)
+	if !ok {
+		return nil, fmt.Errorf("unknown channel #%s", strings.TrimPrefix(channel, "#"))
+	}
+	return s.Discord.Messages(channelID), nil
+}
+
+// agentID returns the fake HUMA's ID of the guild's agent
+func (s *Sandbox) agentID() (string, error) {
+	if s.Huma == nil {
+		return "", fmt.Errorf("tool calls come from the real HUMA; the sandbox can only issue them with the fake")
+	}
+	// The first message creates the agent in the background
+	deadline := time.Now().Add(agentWait)
+	for {
+		if agent := s.humaManager.GetAgent(GuildID); agent != nil {
+			return agent.AgentID, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("no agent yet: agents are created on the first message")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// CallTool has the agent call a tool, as HUMA would. Returns the tool call ID.
+func (s *Sandbox) CallTool(name string, args map[string]interface{}) (string, error) {
+	agentID, err := s.agentID()
+	if err != nil {
+		return "", err
+	}
+	toolCallID, err := s.Huma.CallTool(agentID, name, args)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.lastToolCall = toolCallID
+	s.mu.Unlock()
+	return toolCallID, nil
+}
+
+// Send has the agent send a message to a channel
+func (s *Sandbox) Send(channel, message string) (string, error) {
+	channelID, ok := s.ChannelID(channel)
+	if !ok {
+		return "", fmt.Errorf("unknown channel #%s", strings.TrimPrefix(channel, "#"))
+	}
+	return s.CallTool("send_message", map[string]interface{}{"channel_id": channelID, "message": message})
+}
+
+// Cancel has the agent cancel a tool call; an empty ID cancels the latest
+func (s *Sandbox) Cancel(toolCallID, reason string) error {
+	agentID, err := s.agentID()
+	if err != nil {
+		return err
+	}
+	if toolCallID == "" {
+		s.mu.Lock()
+		toolCallID = s.lastToolCall
+		s.mu.Unlock()
+	}
+	if toolCallID == "" {
+		return fmt.Errorf("no tool call to cancel")
+	}
+	return s.Huma.CancelToolCall(agentID, toolCallID, reason)
+}
+
+// printf writes a transcript line
+func (s *Sandbox) printf(format string, args ...interface{}) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintf(s.out, format+"\n", args...)
+}
+
+// onAgentEvent writes what the client sent the fake HUMA
+func (s *Sandbox) onAgentEvent(event humatest.Event) {
+	if result := event.ToolResult; result != nil {
+		switch {
+		case result.Canceled():
+			s.printf("  agent <- %s canceled", result.ToolCallID)
+		case result.Success:
+			s.printf("  agent <- %s ok%s", result.ToolCallID, resultSummary(result.Result))
+		default:
+			s.printf("  agent <- %s failed: %s", result.ToolCallID, result.Error)
+		}
+		return
+	}
+	s.printf("  agent <- %s: %s", event.Name, event.Description)
+}
+
+// resultSummary renders a tool result briefly, for tools that return data
+func resultSummary(result interface{}) string {
+	if result == nil {
+		return ""
+	}
+	data, err := json.Marshal(result)
+	if err != nil || string(data) == "{}" {
+		return ""
+	}
+	if len(data) > 200 {
+		data = append(data[:200], "..."...)
+	}
+	return " " + string(data)
+}
+
+// watch writes the agent's activity from the event bus
+func (s *Sandbox) watch() {
+	defer close(s.watchDone)
+	for event := range s.events.C {
+		switch event.Type {
+		case events.TypeToolCall:
+			s.printf("  agent -> %s %s", event.ToolName, event.ToolCallID)
+		case events.TypeTypingStarted:
+			s.printf("  %s is typing in #%s (%s)", s.options.BotName, s.channelName(event.ChannelID), time.Duration(event.DelayMs)*time.Millisecond)
+		case events.TypeMessageSent:
+			s.printf("#%s %s: %s", s.channelName(event.ChannelID), s.options.BotName, event.Content)
+		case events.TypeMessageCanceled:
+			s.printf("  %s canceled: %s", event.ToolCallID, event.Reason)
+		case events.TypeError:
+			s.printf("  error (%s): %s", event.Reason, event.Error)
+		}
+	}
+}
+
+// sortedNames returns the keys of a name map, sorted
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,134 @@
+package sandbox
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// transcript is a writer the sandbox and the test can share
+type transcript struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (t *transcript) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.Write(p)
+}
+
+// waitFor waits until the transcript contains want
+func (t *transcript) waitFor(tb testing.TB, want string) {
+	tb.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		t.mu.Lock()
+		found := strings.Contains(t.buf.String(), want)
+		t.mu.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tb.Fatalf("Expected transcript to contain %q:\n%s", want, t.buf.String())
+}
+
+func startSandbox(t *testing.T) (*Sandbox, *transcript) {
+	t.Helper()
+	out := &transcript{}
+	opts := DefaultOptions()
+	opts.TypingWPM = 10000
+	box, err := Start(opts, out)
+	if err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+	t.Cleanup(box.Close)
+	return box, out
+}
+
+func TestSandboxConversation(t *testing.T) {
+	box, out := startSandbox(t)
+
+	if _, err := box.Post("general", "alice", "is anyone here?"); err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	out.waitFor(t, `agent <- new-message: User alice sent a new message in channel #general: "is anyone here?"`)
+
+	toolCallID, err := box.Send("#general", "I'm here!")
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if !box.Discord.WaitForSent(1, 5*time.Second) {
+		t.Fatal("Expected the agent's message to be sent")
+	}
+	out.waitFor(t, "#general helper: I'm here!")
+	out.waitFor(t, "agent <- "+toolCallID+" ok")
+
+	// The sandbox enforces the same send policy as serve
+	followUpID, err := box.Send("#general", "hello?")
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	out.waitFor(t, "agent <- "+followUpID+" failed: Blocked by send policy (human_message)")
+
+	if err := box.Cancel("", "late"); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+}
+
+func TestSandboxBotOff(t *testing.T) {
+	box, _ := startSandbox(t)
+
+	if err := box.SetBotActive(false); err != nil {
+		t.Fatalf("Failed to turn the bot off: %v", err)
+	}
+	if guilds := box.Manager.GetGuildConfigs(); len(guilds) != 1 || guilds[0].BotActive {
+		t.Errorf("Expected the guild config to be inactive, got %+v", guilds)
+	}
+	if _, err := box.Send("general", "hi"); err == nil {
+		t.Error("Expected no agent while the bot is off")
+	}
+}
+
+func TestREPL(t *testing.T) {
+	box, out := startSandbox(t)
+	repl := NewREPL(box)
+
+	for _, line := range []string{"#nowhere", "/as carol", "/channel help-desk", "carol: hello", "/bogus"} {
+		if !repl.Execute(line) {
+			t.Fatalf("Expected %q not to end the REPL", line)
+		}
+	}
+	if repl.Execute("/quit") {
+		t.Error("Expected /quit to end the REPL")
+	}
+
+	out.waitFor(t, "! unknown channel #nowhere")
+	out.waitFor(t, "! unknown command /bogus")
+	history, err := box.History("help-desk")
+	if err != nil || len(history) != 1 || history[0].Author.Username != "carol" || history[0].Content != "hello" {
+		t.Errorf("Expected carol's message in #help-desk, got %+v (%v)", history, err)
+	}
+}
+
+func TestBackendRequiresAPIKey(t *testing.T) {
+	b := NewBackend(APIKey)
+	defer b.Close()
+	b.SetTokenConfigs([]types.TokenConfig{{DiscordToken: "t", UserID: "u"}})
+
+	if _, err := backend.NewClient(b.URL(), "wrong").FetchTokenConfigs(); err == nil {
+		t.Error("Expected a wrong API key to be rejected")
+	}
+	configs, err := backend.NewClient(b.URL(), APIKey).FetchTokenConfigs()
+	if err != nil || len(configs) != 1 || configs[0].UserID != "u" {
+		t.Errorf("Expected the configured token, got %+v (%v)", configs, err)
+	}
+}
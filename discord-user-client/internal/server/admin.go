@@ -0,0 +1,365 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+)
+
+// adminRoutes registers the on-call inspection and control endpoints
+func (s *Server) adminRoutes() {
+	s.mux.HandleFunc("GET /admin/accounts", s.handleAdminAccounts)
+	s.mux.HandleFunc("GET /admin/guilds", s.handleAdminGuilds)
+	s.mux.HandleFunc("GET /admin/agents", s.handleAdminAgents)
+	s.mux.HandleFunc("GET /admin/agents/{guildID}/pending", s.handleAdminGetPending)
+	s.mux.HandleFunc("DELETE /admin/agents/{guildID}/pending", s.handleAdminCancelPending)
+	s.mux.HandleFunc("POST /admin/agents/{guildID}/reconnect", s.handleAdminReconnectAgent)
+	s.mux.HandleFunc("POST /admin/agents/{guildID}/recreate", s.handleAdminRecreateAgent)
+	s.mux.HandleFunc("POST /admin/resync", s.handleAdminResync)
+	s.mux.HandleFunc("GET /admin/pauses", s.handleAdminGetPauses)
+	s.mux.HandleFunc("PUT /admin/pauses/{guildID}", s.handleAdminPause)
+	s.mux.HandleFunc("DELETE /admin/pauses/{guildID}", s.handleAdminResume)
+	s.mux.HandleFunc("PUT /admin/pauses/{guildID}/channels/{channelID}", s.handleAdminPause)
+	s.mux.HandleFunc("DELETE /admin/pauses/{guildID}/channels/{channelID}", s.handleAdminResume)
+	s.mux.HandleFunc("GET /admin/ignored-users", s.handleAdminGetIgnoredUsers)
+	s.mux.HandleFunc("DELETE /admin/ignored-users/{guildID}/{userID}", s.handleAdminUnignore)
+	s.mux.HandleFunc("POST /admin/guilds/{guildID}/messages", s.handleAdminSendMessage)
+	s.mux.HandleFunc("POST /admin/guilds/{guildID}/simulate", s.handleAdminSimulate)
+}
+
+// adminGuildView is a guild config as shown to operators. Website content is
+// summarized rather than returned in full.
+type adminGuildView struct {
+	GuildID     string             `json:"guildId"`
+	GuildName   string             `json:"guildName"`
+	BotActive   bool               `json:"botActive"`
+	BotName     string             `json:"botName"`
+	UserID      string             `json:"userId"`
+	Account     string             `json:"account"`
+	Personality string             `json:"personality"`
+	Rules       string             `json:"rules"`
+	Information string             `json:"information"`
+	Websites    []adminWebsiteView `json:"websites"`
+	HasAgent    bool               `json:"hasAgent"`
+}
+
+type adminWebsiteView struct {
+	URL           string `json:"url"`
+	Name          string `json:"name"`
+	ScrapedAt     string `json:"scrapedAt"`
+	MarkdownChars int    `json:"markdownChars"`
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes {"error": message} with the given status
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// humaManager returns the shared HUMA manager, writing 503 if there is none
+func (s *Server) humaManager(w http.ResponseWriter) *huma.Manager {
+	if s.clientManager == nil || s.clientManager.GetHumaManager() == nil {
+		writeError(w, http.StatusServiceUnavailable, "No HUMA manager available")
+		return nil
+	}
+	return s.clientManager.GetHumaManager()
+}
+
+// agentForRequest looks up the agent named by the {guildID} path value, writing 404 if there is none
+func (s *Server) agentForRequest(w http.ResponseWriter, r *http.Request) *huma.GuildAgent {
+	manager := s.humaManager(w)
+	if manager == nil {
+		return nil
+	}
+
+	guildID := r.PathValue("guildID")
+	agent := manager.GetAgent(guildID)
+	if agent == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No agent for guild %s", guildID))
+		return nil
+	}
+	return agent
+}
+
+func (s *Server) handleAdminAccounts(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"accounts": s.clientManager.GetAccounts(),
+	})
+}
+
+func (s *Server) handleAdminGuilds(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	manager := s.clientManager.GetHumaManager()
+	configs := s.clientManager.GetGuildConfigs()
+	guilds := make([]adminGuildView, 0, len(configs))
+	for _, config := range configs {
+		view := adminGuildView{
+			GuildID:     config.GuildID,
+			GuildName:   config.GuildName,
+			BotActive:   config.BotActive,
+			BotName:     config.BotName,
+			UserID:      config.UserID,
+			Account:     config.TokenFingerprint,
+			Personality: config.Personality,
+			Rules:       config.Rules,
+			Information: config.Information,
+			Websites:    make([]adminWebsiteView, 0, len(config.Websites)),
+			HasAgent:    manager != nil && manager.GetAgent(config.GuildID) != nil,
+		}
+		for _, website := range config.Websites {
+			view.Websites = append(view.Websites, adminWebsiteView{
+				URL:           website.URL,
+				Name:          website.Name,
+				ScrapedAt:     website.ScrapedAt,
+				MarkdownChars: len(website.Markdown),
+			})
+		}
+		guilds = append(guilds, view)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"guilds":  guilds,
+	})
+}
+
+func (s *Server) handleAdminAgents(w http.ResponseWriter, r *http.Request) {
+	manager := s.humaManager(w)
+	if manager == nil {
+		return
+	}
+
+	agents := manager.GetAgents()
+	states := make([]huma.AgentState, 0, len(agents))
+	for _, agent := range agents {
+		states = append(states, agent.State())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].GuildID < states[j].GuildID
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"agents":  states,
+	})
+}
+
+func (s *Server) handleAdminGetPending(w http.ResponseWriter, r *http.Request) {
+	agent := s.agentForRequest(w, r)
+	if agent == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"pendingMessages": agent.GetPendingMessages(),
+	})
+}
+
+func (s *Server) handleAdminCancelPending(w http.ResponseWriter, r *http.Request) {
+	agent := s.agentForRequest(w, r)
+	if agent == nil {
+		return
+	}
+
+	canceled := agent.CancelPendingMessages(r.URL.Query().Get("channel_id"), "Canceled by operator")
+	if len(canceled) == 0 {
+		writeError(w, http.StatusNotFound, "No pending message")
+		return
+	}
+
+	for _, pending := range canceled {
+		logger.Info("Operator canceled pending message", logging.KeyGuildID, agent.GuildID, logging.KeyChannelID, pending.ChannelID, "tool_call_id", pending.ToolCallID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"canceled": canceled,
+	})
+}
+
+func (s *Server) handleAdminReconnectAgent(w http.ResponseWriter, r *http.Request) {
+	manager := s.humaManager(w)
+	if manager == nil {
+		return
+	}
+
+	guildID := r.PathValue("guildID")
+	agent := manager.GetAgent(guildID)
+	if agent == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No agent for guild %s", guildID))
+		return
+	}
+
+	if err := manager.ReconnectAgent(guildID); err != nil {
+		logger.Error("Operator reconnect failed", logging.KeyGuildID, guildID, "error", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"agent":   agent.State(),
+	})
+}
+
+func (s *Server) handleAdminRecreateAgent(w http.ResponseWriter, r *http.Request) {
+	manager := s.humaManager(w)
+	if manager == nil {
+		return
+	}
+
+	guildID := r.PathValue("guildID")
+	if manager.GetAgent(guildID) == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No agent for guild %s", guildID))
+		return
+	}
+
+	agent, err := manager.RecreateAgent(guildID)
+	if err != nil {
+		logger.Error("Operator recreate failed", logging.KeyGuildID, guildID, "error", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"agent":   agent.State(),
+	})
+}
+
+func (s *Server) handleAdminResync(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	tokens, err := s.clientManager.Resync()
+	if err != nil {
+		logger.Error("Operator resync failed", "error", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	logger.Info("Operator forced config resync", "tokens", tokens)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"tokens":  tokens,
+		"guilds":  s.clientManager.GetMonitoredGuildCount(),
+	})
+}
+
+// pauseRequest is the optional body of the pause endpoints
+type pauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) handleAdminGetPauses(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pauses":  s.clientManager.GetPauses(),
+	})
+}
+
+// handleAdminPause pauses a guild, or a channel when {channelID} is in the path
+func (s *Server) handleAdminPause(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	var req pauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	entry, err := s.clientManager.Pause(r.PathValue("guildID"), r.PathValue("channelID"), req.Reason, "api")
+	if err != nil {
+		logger.Error("Pause failed", logging.KeyGuildID, r.PathValue("guildID"), "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"pause":   entry,
+	})
+}
+
+// handleAdminResume lifts a guild pause, or a channel pause when {channelID} is in the path
+func (s *Server) handleAdminResume(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	resumed, err := s.clientManager.Resume(r.PathValue("guildID"), r.PathValue("channelID"))
+	if err != nil {
+		logger.Error("Resume failed", logging.KeyGuildID, r.PathValue("guildID"), "error", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !resumed {
+		writeError(w, http.StatusNotFound, "Not paused")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func (s *Server) handleAdminGetIgnoredUsers(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"ignoredUsers": s.clientManager.GetIgnoredUsers(),
+	})
+}
+
+// handleAdminUnignore stops ignoring a user before their time is up
+func (s *Server) handleAdminUnignore(w http.ResponseWriter, r *http.Request) {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return
+	}
+
+	if !s.clientManager.Unignore(r.PathValue("guildID"), r.PathValue("userID")) {
+		writeError(w, http.StatusNotFound, "Not ignored")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/pause"
+)
+
+// adminRequest sends an authenticated request and decodes the JSON response
+func adminRequest(t *testing.T, server *Server, method, path string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestAdminEndpoints_Empty(t *testing.T) {
+	manager := client.NewClientManager(huma.NewManager("test-key"), nil)
+	server := NewServer("8080", manager)
+	server.SetAPIKey(testAPIKey)
+
+	for _, path := range []string{"/admin/accounts", "/admin/guilds", "/admin/agents", "/admin/ignored-users"} {
+		status, body := adminRequest(t, server, "GET", path)
+		if status != http.StatusOK || body["success"] != true {
+			t.Errorf("%s: expected success, got %d %v", path, status, body)
+		}
+	}
+}
+
+func TestAdminEndpoints_UnknownAgent(t *testing.T) {
+	manager := client.NewClientManager(huma.NewManager("test-key"), nil)
+	server := NewServer("8080", manager)
+	server.SetAPIKey(testAPIKey)
+
+	tests := []struct{ method, path string }{
+		{"GET", "/admin/agents/guild1/pending"},
+		{"DELETE", "/admin/agents/guild1/pending"},
+		{"POST", "/admin/agents/guild1/reconnect"},
+		{"POST", "/admin/agents/guild1/recreate"},
+		{"DELETE", "/admin/ignored-users/guild1/user1"},
+	}
+	for _, tt := range tests {
+		status, _ := adminRequest(t, server, tt.method, tt.path)
+		if status != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", tt.method, tt.path, status)
+		}
+	}
+}
+
+func TestAdminEndpoints_RequireAPIKey(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("POST", "/admin/resync", nil)
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminResync(t *testing.T) {
+	fetches := 0
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"tokens":[]}`))
+	}))
+	defer backendServer.Close()
+
+	manager := client.NewClientManager(nil, backend.NewClient(backendServer.URL, "backend-key"))
+	server := NewServer("8080", manager)
+	server.SetAPIKey(testAPIKey)
+
+	status, body := adminRequest(t, server, "POST", "/admin/resync")
+	if status != http.StatusOK || body["tokens"] != float64(0) {
+		t.Errorf("Expected successful resync, got %d %v", status, body)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected 1 backend fetch, got %d", fetches)
+	}
+}
+
+func TestAdminResync_NoBackend(t *testing.T) {
+	server := newTestServer()
+
+	status, _ := adminRequest(t, server, "POST", "/admin/resync")
+	if status != http.StatusBadGateway {
+		t.Errorf("Expected 502 without backend, got %d", status)
+	}
+}
+
+func TestAdminPauses(t *testing.T) {
+	store, err := pause.NewStore("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	manager := client.NewClientManager(huma.NewManager("test-key"), nil)
+	manager.SetPauseStore(store)
+	server := NewServer("8080", manager)
+	server.SetAPIKey(testAPIKey)
+
+	req := httptest.NewRequest("PUT", "/admin/pauses/guild1", strings.NewReader(`{"reason":"incident"}`))
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp := serve(server, req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 pausing guild, got %d", resp.StatusCode)
+	}
+
+	status, _ := adminRequest(t, server, "PUT", "/admin/pauses/guild2/channels/chan1")
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200 pausing channel without body, got %d", status)
+	}
+	if !store.IsPaused("guild1", "any") || !store.IsPaused("guild2", "chan1") || store.IsPaused("guild2", "chan2") {
+		t.Errorf("Unexpected pause state: %+v", store.List())
+	}
+
+	status, body := adminRequest(t, server, "GET", "/admin/pauses")
+	if pauses, _ := body["pauses"].([]interface{}); status != http.StatusOK || len(pauses) != 2 {
+		t.Errorf("Expected 2 pauses, got %d %v", status, body)
+	}
+
+	status, _ = adminRequest(t, server, "DELETE", "/admin/pauses/guild1")
+	if status != http.StatusOK || store.IsPaused("guild1", "any") {
+		t.Errorf("Expected guild1 to be resumed, got %d", status)
+	}
+	status, _ = adminRequest(t, server, "DELETE", "/admin/pauses/guild1")
+	if status != http.StatusNotFound {
+		t.Errorf("Expected 404 resuming unpaused guild, got %d", status)
+	}
+}
+
+func TestAdminOperatorEndpoints_Validation(t *testing.T) {
+	server := newTestServer()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"send invalid JSON", "/admin/guilds/guild1/messages", `{`, http.StatusBadRequest},
+		{"send missing content", "/admin/guilds/guild1/messages", `{"channelId":"c1"}`, http.StatusBadRequest},
+		{"send unknown guild", "/admin/guilds/guild1/messages", `{"channelId":"c1","content":"hi"}`, http.StatusNotFound},
+		{"simulate missing channel", "/admin/guilds/guild1/simulate", `{"content":"hi"}`, http.StatusBadRequest},
+		{"simulate unknown guild", "/admin/guilds/guild1/simulate", `{"channelId":"c1","content":"hi"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-API-Key", testAPIKey)
+			resp := serve(server, req)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
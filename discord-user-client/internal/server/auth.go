@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+)
+
+// publicPaths are served without an API key so orchestrators can probe the process
+var publicPaths = map[string]bool{
+	"/health": true,
+	"/livez":  true,
+	"/readyz": true,
+}
+
+// requireAPIKey rejects requests that do not carry the internal API key, either
+// as X-API-Key (what the backend sends) or as a bearer token (what Prometheus sends)
+func (s *Server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !s.hasAPIKey(r) {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasAPIKey reports whether a request carries the internal API key
+func (s *Server) hasAPIKey(r *http.Request) bool {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	return s.apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKey)) == 1
+}
+
+// resolveAccount finds the Discord client a request refers to. Accounts are
+// addressed by user ID (X-User-ID) or token fingerprint (X-Token-Fingerprint),
+// never by the raw token. On failure an error response has been written.
+func (s *Server) resolveAccount(w http.ResponseWriter, r *http.Request) *client.DiscordClient {
+	if r.Header.Get("X-Discord-Token") != "" {
+		http.Error(w, `{"error":"X-Discord-Token is no longer accepted, use X-User-ID or X-Token-Fingerprint"}`, http.StatusBadRequest)
+		return nil
+	}
+
+	var discordClient *client.DiscordClient
+	if userID := r.Header.Get("X-User-ID"); userID != "" {
+		discordClient = s.clientManager.GetClientByUserID(userID)
+	} else if fingerprint := r.Header.Get("X-Token-Fingerprint"); fingerprint != "" {
+		discordClient = s.clientManager.GetClientByFingerprint(fingerprint)
+	} else {
+		// SECURITY: An account is required to prevent cross-user data leakage
+		http.Error(w, `{"error":"X-User-ID or X-Token-Fingerprint header is required"}`, http.StatusBadRequest)
+		return nil
+	}
+
+	if discordClient == nil || discordClient.GetSession() == nil {
+		http.Error(w, `{"error":"No Discord session active for this account"}`, http.StatusServiceUnavailable)
+		return nil
+	}
+	return discordClient
+}
+
+// loadTLSConfig builds a server TLS config. When clientCAFile is set, clients
+// must present a certificate signed by that CA (mTLS).
+func loadTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		caPEM, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+)
+
+// eventsHeartbeatInterval keeps idle streams alive through proxies
+const eventsHeartbeatInterval = 15 * time.Second
+
+// handleEvents streams live agent activity as server-sent events. Filter with
+// ?guild_id=..., repeated or comma-separated; without it every guild is streamed.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var guildIDs []string
+	for _, value := range r.URL.Query()["guild_id"] {
+		for _, guildID := range strings.Split(value, ",") {
+			if guildID = strings.TrimSpace(guildID); guildID != "" {
+				guildIDs = append(guildIDs, guildID)
+			}
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Tell the client the stream is open before the first event arrives
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		logger.Error("Activity stream does not support flushing", "error", err)
+		return
+	}
+
+	sub := events.Default.Subscribe(guildIDs, events.DefaultBufferSize)
+	defer sub.Close()
+	logger.Debug("Activity stream opened", "guilds", guildIDs)
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Debug("Activity stream closed", "guilds", guildIDs)
+			return
+
+		case <-s.stopping:
+			// Streams never go idle, so end them or shutdown waits for the deadline
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
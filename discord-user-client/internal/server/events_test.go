@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+)
+
+func TestHandleEvents(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events?guild_id=guild1", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("Expected connected comment, got %q", line)
+	}
+	reader.ReadString('\n')
+
+	events.Publish(events.Event{Type: events.TypeTypingStarted, GuildID: "guild2", ChannelID: "other"})
+	events.Publish(events.Event{Type: events.TypeTypingStarted, GuildID: "guild1", ChannelID: "c1", ChannelName: "support"})
+
+	var eventName, data string
+	for data == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended early: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventName = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+		}
+	}
+
+	var event events.Event
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("Invalid event data %q: %v", data, err)
+	}
+	if eventName != events.TypeTypingStarted || event.GuildID != "guild1" || event.ChannelName != "support" {
+		t.Errorf("Expected guild1 typing event, got %s %+v", eventName, event)
+	}
+}
+
+func TestHandleEvents_RequiresAPIKey(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandleEvents_EndsOnShutdown(t *testing.T) {
+	server := newTestServer()
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	reader.ReadString('\n')
+
+	server.BeginShutdown()
+	for {
+		if _, err := reader.ReadString('\n'); err != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected the stream to end on shutdown, not on the test timeout")
+	}
+}
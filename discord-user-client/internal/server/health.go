@@ -0,0 +1,306 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+)
+
+// Thresholds past which a component is considered unhealthy
+const (
+	// Discord heartbeats every ~41s, two missed acks means the gateway is dead
+	heartbeatStaleAfter = 90 * time.Second
+	// Configs are polled every few seconds
+	configSyncStaleAfter = time.Minute
+	// Stats that survived this many flush intervals are stuck
+	outboxStaleIntervals = 3
+)
+
+// healthStatus is the outcome of a check. Only fail makes /readyz return 503;
+// degraded is reported in the body for uptime checks and dashboards.
+type healthStatus string
+
+const (
+	statusOK       healthStatus = "ok"
+	statusDegraded healthStatus = "degraded"
+	statusFail     healthStatus = "fail"
+)
+
+// worse returns the more severe of two statuses
+func worse(a, b healthStatus) healthStatus {
+	rank := map[healthStatus]int{statusOK: 0, statusDegraded: 1, statusFail: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// componentHealth is the result of one readiness check
+type componentHealth struct {
+	Status  healthStatus `json:"status"`
+	Message string       `json:"message,omitempty"`
+	Details interface{}  `json:"details,omitempty"`
+}
+
+// healthReport is the body of /livez and /readyz
+type healthReport struct {
+	Status        healthStatus               `json:"status"`
+	CheckedAt     time.Time                  `json:"checkedAt"`
+	UptimeSeconds int64                      `json:"uptimeSeconds"`
+	Goroutines    int                        `json:"goroutines,omitempty"`
+	Checks        map[string]componentHealth `json:"checks,omitempty"`
+}
+
+// startTime is when the process started serving, for uptime reporting
+var startTime = time.Now()
+
+// accountHealth is the gateway state of one Discord account
+type accountHealth struct {
+	Fingerprint         string       `json:"fingerprint"`
+	Status              healthStatus `json:"status"`
+	Message             string       `json:"message,omitempty"`
+	GatewayReady        bool         `json:"gatewayReady"`
+	LastHeartbeatAck    *time.Time   `json:"lastHeartbeatAck,omitempty"`
+	HeartbeatAgeSeconds float64      `json:"heartbeatAgeSeconds,omitempty"`
+}
+
+// agentHealth is the connection state of one HUMA agent
+type agentHealth struct {
+	GuildID string       `json:"guildId"`
+	AgentID string       `json:"agentId"`
+	Status  healthStatus `json:"status"`
+}
+
+// outboxHealth is the backlog of stats waiting to be flushed to the backend
+type outboxHealth struct {
+	Pending    int     `json:"pending"`
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
+// checkDiscord grades each account by its last heartbeat ack. The component fails
+// only when no account has a live gateway.
+func checkDiscord(accounts []client.AccountState, now time.Time) componentHealth {
+	if len(accounts) == 0 {
+		return componentHealth{Status: statusOK, Message: "no accounts configured", Details: []accountHealth{}}
+	}
+
+	details := make([]accountHealth, 0, len(accounts))
+	failed := 0
+	status := statusOK
+	for _, account := range accounts {
+		entry := accountHealth{
+			Fingerprint:      account.Fingerprint,
+			Status:           statusOK,
+			GatewayReady:     account.GatewayReady,
+			LastHeartbeatAck: account.LastHeartbeatAck,
+		}
+		switch {
+		case !account.Connected:
+			entry.Status, entry.Message = statusFail, "no session"
+		case !account.GatewayReady || account.LastHeartbeatAck == nil:
+			entry.Status, entry.Message = statusDegraded, "connecting"
+		default:
+			age := now.Sub(*account.LastHeartbeatAck)
+			entry.HeartbeatAgeSeconds = age.Seconds()
+			if age > heartbeatStaleAfter {
+				entry.Status, entry.Message = statusFail, "heartbeat stale"
+			}
+		}
+		if entry.Status == statusFail {
+			failed++
+		}
+		status = worse(status, entry.Status)
+		details = append(details, entry)
+	}
+
+	result := componentHealth{Status: status, Details: details}
+	if failed > 0 && failed < len(accounts) {
+		result.Status = statusDegraded
+		result.Message = fmt.Sprintf("%d of %d accounts down", failed, len(accounts))
+	}
+	return result
+}
+
+// checkHuma grades each agent by whether its Socket.IO namespace is usable. The
+// component fails only when no agent can receive events.
+func checkHuma(agents []agentHealth) componentHealth {
+	if len(agents) == 0 {
+		return componentHealth{Status: statusOK, Message: "no agents", Details: agents}
+	}
+
+	notReady := 0
+	for _, agent := range agents {
+		if agent.Status != statusOK {
+			notReady++
+		}
+	}
+
+	switch {
+	case notReady == 0:
+		return componentHealth{Status: statusOK, Details: agents}
+	case notReady == len(agents):
+		return componentHealth{Status: statusFail, Message: "no agent namespace is ready", Details: agents}
+	default:
+		return componentHealth{Status: statusDegraded, Message: fmt.Sprintf("%d of %d agents not ready", notReady, len(agents)), Details: agents}
+	}
+}
+
+// checkBackend reports whether the most recent backend call got through. The
+// Discord side keeps working without the backend, so this never fails readiness.
+func checkBackend(backendClient *backend.Client) componentHealth {
+	if backendClient == nil {
+		return componentHealth{Status: statusDegraded, Message: "no backend client configured"}
+	}
+
+	contact := backendClient.LastContact()
+	details := map[string]interface{}{}
+	if !contact.LastSuccess.IsZero() {
+		details["lastSuccess"] = contact.LastSuccess
+	}
+	if contact.LastError != nil {
+		details["lastError"] = contact.LastError.Error()
+		details["lastErrorAt"] = contact.LastErrorAt
+	}
+
+	switch {
+	case contact.Reachable():
+		return componentHealth{Status: statusOK, Details: details}
+	case contact.LastSuccess.IsZero() && contact.LastError == nil:
+		return componentHealth{Status: statusDegraded, Message: "not contacted yet", Details: details}
+	default:
+		return componentHealth{Status: statusDegraded, Message: "unreachable", Details: details}
+	}
+}
+
+// checkConfigSync reports how long ago token configs were last applied
+func checkConfigSync(lastSync, now time.Time) componentHealth {
+	if lastSync.IsZero() {
+		return componentHealth{Status: statusDegraded, Message: "never synced"}
+	}
+
+	age := now.Sub(lastSync)
+	details := map[string]interface{}{
+		"lastSync":   lastSync,
+		"ageSeconds": age.Seconds(),
+	}
+	if age > configSyncStaleAfter {
+		return componentHealth{Status: statusDegraded, Message: "config sync stale", Details: details}
+	}
+	return componentHealth{Status: statusOK, Details: details}
+}
+
+// checkOutbox reports stats that have been waiting too long for a successful flush
+func checkOutbox(stats *backend.StatsReporter, now time.Time) componentHealth {
+	if stats == nil {
+		return componentHealth{Status: statusOK, Message: "no stats reporter"}
+	}
+
+	pending, since := stats.Backlog()
+	details := outboxHealth{Pending: pending}
+	if pending == 0 {
+		return componentHealth{Status: statusOK, Details: details}
+	}
+
+	age := now.Sub(since)
+	details.AgeSeconds = age.Seconds()
+	if age > outboxStaleIntervals*stats.Interval() {
+		return componentHealth{Status: statusDegraded, Message: "stats backlog is not draining", Details: details}
+	}
+	return componentHealth{Status: statusOK, Details: details}
+}
+
+// readiness runs every component check
+func (s *Server) readiness(now time.Time) healthReport {
+	report := healthReport{
+		Status:        statusOK,
+		CheckedAt:     now.UTC(),
+		UptimeSeconds: int64(now.Sub(startTime).Seconds()),
+		Checks:        make(map[string]componentHealth),
+	}
+
+	if s.isStopping() {
+		report.Status = statusFail
+		report.Checks["shutdown"] = componentHealth{Status: statusFail, Message: "shutting down"}
+		return report
+	}
+	if s.clientManager == nil {
+		report.Status = statusFail
+		report.Checks["manager"] = componentHealth{Status: statusFail, Message: "no client manager"}
+		return report
+	}
+
+	agents := []agentHealth{}
+	if humaManager := s.clientManager.GetHumaManager(); humaManager != nil {
+		for _, agent := range humaManager.GetAgents() {
+			entry := agentHealth{GuildID: agent.GuildID, AgentID: agent.AgentID, Status: statusFail}
+			if agent.Client != nil && agent.Client.IsNamespaceReady() {
+				entry.Status = statusOK
+			}
+			agents = append(agents, entry)
+		}
+	}
+
+	report.Checks["discord"] = checkDiscord(s.clientManager.GetAccounts(), now)
+	report.Checks["huma"] = checkHuma(agents)
+	if backendClient := s.clientManager.GetBackendClient(); backendClient == nil && s.clientManager.GetConfigSource() != nil {
+		// Standalone mode reads configs from a file and reports to a local sink
+		report.Checks["backend"] = componentHealth{Status: statusOK, Message: "not used in standalone mode"}
+	} else {
+		report.Checks["backend"] = checkBackend(backendClient)
+	}
+	report.Checks["configSync"] = checkConfigSync(s.clientManager.GetLastSync(), now)
+	report.Checks["outbox"] = checkOutbox(s.clientManager.GetStatsReporter(), now)
+
+	for _, check := range report.Checks {
+		report.Status = worse(report.Status, check.Status)
+	}
+	return report
+}
+
+// handleLivez reports that the process is up and serving. It checks no
+// dependencies, so a dead gateway never gets the pod restarted.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	writeJSON(w, http.StatusOK, healthReport{
+		Status:        statusOK,
+		CheckedAt:     now.UTC(),
+		UptimeSeconds: int64(now.Sub(startTime).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+	})
+}
+
+// handleReadyz reports per-component health. It returns 503 when any component
+// fails so orchestrators stop routing to the pod; degraded components are
+// reported in the body with a 200. The probe is public, so per-account and
+// per-agent details are only included for callers with the API key.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := s.readiness(time.Now())
+	if !s.hasAPIKey(r) {
+		for name, check := range report.Checks {
+			check.Details = nil
+			report.Checks[name] = check
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status == statusFail {
+		status = http.StatusServiceUnavailable
+		logger.Warn("Readiness check failed", "checks", failedChecks(report))
+	}
+	writeJSON(w, status, report)
+}
+
+// failedChecks lists the components that failed, for logging
+func failedChecks(report healthReport) []string {
+	var failed []string
+	for name, check := range report.Checks {
+		if check.Status == statusFail {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
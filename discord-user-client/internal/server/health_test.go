@@ -0,0 +1,221 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/backend"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+func TestCheckDiscord(t *testing.T) {
+	now := time.Now()
+	fresh := now.Add(-10 * time.Second)
+	stale := now.Add(-5 * time.Minute)
+
+	live := client.AccountState{Fingerprint: "tok_live", Connected: true, GatewayReady: true, LastHeartbeatAck: &fresh}
+	dead := client.AccountState{Fingerprint: "tok_dead", Connected: true, GatewayReady: true, LastHeartbeatAck: &stale}
+	connecting := client.AccountState{Fingerprint: "tok_new", Connected: true}
+	noSession := client.AccountState{Fingerprint: "tok_gone"}
+
+	tests := []struct {
+		name     string
+		accounts []client.AccountState
+		want     healthStatus
+	}{
+		{"no accounts", nil, statusOK},
+		{"all live", []client.AccountState{live}, statusOK},
+		{"connecting", []client.AccountState{live, connecting}, statusDegraded},
+		{"one of two dead", []client.AccountState{live, dead}, statusDegraded},
+		{"all dead", []client.AccountState{dead, noSession}, statusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkDiscord(tt.accounts, now).Status; got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckHuma(t *testing.T) {
+	ready := agentHealth{GuildID: "g1", Status: statusOK}
+	down := agentHealth{GuildID: "g2", Status: statusFail}
+
+	if got := checkHuma(nil).Status; got != statusOK {
+		t.Errorf("No agents: expected ok, got %s", got)
+	}
+	if got := checkHuma([]agentHealth{ready, down}).Status; got != statusDegraded {
+		t.Errorf("Some agents down: expected degraded, got %s", got)
+	}
+	if got := checkHuma([]agentHealth{down}).Status; got != statusFail {
+		t.Errorf("All agents down: expected fail, got %s", got)
+	}
+}
+
+func TestCheckConfigSync(t *testing.T) {
+	now := time.Now()
+	if got := checkConfigSync(time.Time{}, now).Status; got != statusDegraded {
+		t.Errorf("Never synced: expected degraded, got %s", got)
+	}
+	if got := checkConfigSync(now.Add(-5*time.Second), now).Status; got != statusOK {
+		t.Errorf("Recent sync: expected ok, got %s", got)
+	}
+	if got := checkConfigSync(now.Add(-10*time.Minute), now).Status; got != statusDegraded {
+		t.Errorf("Stale sync: expected degraded, got %s", got)
+	}
+}
+
+func TestCheckOutbox(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backendServer.Close()
+
+	stats := backend.NewStatsReporter(backend.NewClient(backendServer.URL, "key"), time.Second)
+	if got := checkOutbox(stats, time.Now()).Status; got != statusOK {
+		t.Errorf("Empty backlog: expected ok, got %s", got)
+	}
+
+	stats.RecordMessageReceived("user1", "guild1")
+	if err := stats.Flush(); err == nil {
+		t.Fatal("Expected flush to fail")
+	}
+	if got := checkOutbox(stats, time.Now()).Status; got != statusOK {
+		t.Errorf("Fresh backlog: expected ok, got %s", got)
+	}
+	if got := checkOutbox(stats, time.Now().Add(time.Minute)).Status; got != statusDegraded {
+		t.Errorf("Stuck backlog: expected degraded, got %s", got)
+	}
+}
+
+func TestCheckBackend(t *testing.T) {
+	up := true
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"success":true,"tokens":[]}`))
+	}))
+	defer backendServer.Close()
+
+	backendClient := backend.NewClient(backendServer.URL, "key")
+	if got := checkBackend(backendClient); got.Status != statusDegraded || got.Message != "not contacted yet" {
+		t.Errorf("Expected degraded before any call, got %+v", got)
+	}
+
+	backendClient.FetchTokenConfigs()
+	if got := checkBackend(backendClient).Status; got != statusOK {
+		t.Errorf("Expected ok after a successful call, got %s", got)
+	}
+
+	up = false
+	backendClient.FetchTokenConfigs()
+	if got := checkBackend(backendClient); got.Status != statusDegraded || got.Message != "unreachable" {
+		t.Errorf("Expected unreachable after a 502, got %+v", got)
+	}
+}
+
+func TestLivez(t *testing.T) {
+	server := NewServer("8080", nil)
+
+	// Probes must work without the API key
+	resp := serve(server, httptest.NewRequest("GET", "/livez", nil))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestReadyz_NoManager(t *testing.T) {
+	server := NewServer("8080", nil)
+
+	resp := serve(server, httptest.NewRequest("GET", "/readyz", nil))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestReadyz_Breakdown(t *testing.T) {
+	server := newTestServer()
+
+	decode := func(resp *http.Response) healthReport {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		var report healthReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return report
+	}
+
+	// Nothing has synced and there is no backend yet: degraded, but still ready
+	report := decode(serve(server, httptest.NewRequest("GET", "/readyz", nil)))
+	if report.Status != statusDegraded {
+		t.Errorf("Expected degraded, got %s", report.Status)
+	}
+	for _, name := range []string{"discord", "huma", "backend", "configSync", "outbox"} {
+		check, ok := report.Checks[name]
+		if !ok {
+			t.Errorf("Missing check %s", name)
+			continue
+		}
+		if check.Details != nil {
+			t.Errorf("Check %s: details leaked to an unauthenticated probe", name)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	report = decode(serve(server, req))
+	if report.Checks["discord"].Details == nil {
+		t.Error("Expected details for an authenticated caller")
+	}
+}
+
+func TestReadyz_FailsDuringShutdown(t *testing.T) {
+	server := newTestServer()
+	server.BeginShutdown()
+
+	resp := serve(server, httptest.NewRequest("GET", "/readyz", nil))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", resp.StatusCode)
+	}
+}
+
+// staticConfigSource serves a fixed set of token configs
+type staticConfigSource []types.TokenConfig
+
+func (s staticConfigSource) FetchTokenConfigs() ([]types.TokenConfig, error) {
+	return s, nil
+}
+
+func TestReadyz_StandaloneBackend(t *testing.T) {
+	server := newTestServer()
+	server.clientManager.SetConfigSource(staticConfigSource{})
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	var report healthReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if check := report.Checks["backend"]; check.Status != statusOK {
+		t.Errorf("Expected backend ok in standalone mode, got %+v", check)
+	}
+}
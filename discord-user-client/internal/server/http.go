@@ -1,69 +1,162 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
 
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/metrics"
 	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
+// logger is used for HTTP server events
+var logger = logging.For("http")
+
 // Server provides HTTP endpoints for the Discord client
 type Server struct {
 	port          string
 	clientManager *client.ClientManager
+	apiKey        string
+	tlsConfig     *tls.Config
+	mux           *http.ServeMux
+	httpServer    *http.Server
+
+	// Closed when shutdown begins: /readyz fails and event streams end
+	stopping     chan struct{}
+	stoppingOnce sync.Once
 }
 
 // NewServer creates a new HTTP server
 func NewServer(port string, clientManager *client.ClientManager) *Server {
-	return &Server{
+	s := &Server{
 		port:          port,
 		clientManager: clientManager,
+		mux:           http.NewServeMux(),
+		stopping:      make(chan struct{}),
+	}
+	s.routes()
+	return s
+}
+
+// SetAPIKey sets the internal API key every request except /health must carry
+func (s *Server) SetAPIKey(apiKey string) {
+	s.apiKey = apiKey
+}
+
+// SetTLS serves HTTPS with the given certificate. If clientCAFile is set,
+// clients must authenticate with a certificate signed by that CA.
+func (s *Server) SetTLS(certFile, keyFile, clientCAFile string) error {
+	config, err := loadTLSConfig(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return err
 	}
+	s.tlsConfig = config
+	return nil
+}
+
+// routes registers all endpoints on the server's mux
+func (s *Server) routes() {
+	s.mux.HandleFunc("/guilds", s.handleGetGuilds)
+	s.mux.HandleFunc("/channels", s.handleGetChannels)
+	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("GET /livez", s.handleLivez)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	s.mux.HandleFunc("GET /log-levels", s.handleGetLogLevels)
+	s.mux.HandleFunc("PUT /log-levels/guilds/{guildID}", s.handleSetGuildLogLevel)
+	s.mux.HandleFunc("DELETE /log-levels/guilds/{guildID}", s.handleClearGuildLogLevel)
+	s.mux.HandleFunc("PUT /log-levels/subsystems/{subsystem}", s.handleSetSubsystemLogLevel)
+	s.adminRoutes()
+}
+
+// Handler returns the authenticated HTTP handler
+func (s *Server) Handler() http.Handler {
+	return s.requireAPIKey(s.mux)
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() {
-	http.HandleFunc("/guilds", s.handleGetGuilds)
-	http.HandleFunc("/channels", s.handleGetChannels)
-	http.HandleFunc("/health", s.handleHealth)
-	http.HandleFunc("/status", s.handleStatus)
+	if s.apiKey == "" {
+		logger.Warn("No API key set, all authenticated endpoints will reject requests")
+	}
+
+	httpServer := &http.Server{
+		Addr:      ":" + s.port,
+		Handler:   s.Handler(),
+		TLSConfig: s.tlsConfig,
+	}
+	s.httpServer = httpServer
 
-	log.Printf("Starting HTTP server on port %s", s.port)
+	logger.Info("Starting HTTP server", "port", s.port, "tls", s.tlsConfig != nil, "mtls", s.tlsConfig != nil && s.tlsConfig.ClientCAs != nil)
 	go func() {
-		if err := http.ListenAndServe(":"+s.port, nil); err != nil {
-			log.Printf("HTTP server error: %v", err)
+		var err error
+		if s.tlsConfig != nil {
+			// Certificates are already loaded into TLSConfig
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server error", "error", err)
 		}
 	}()
 }
 
+// BeginShutdown marks the server as going away: /readyz starts failing so load
+// balancers stop routing here, and open event streams are ended. Requests are
+// still served until Shutdown.
+func (s *Server) BeginShutdown() {
+	s.stoppingOnce.Do(func() { close(s.stopping) })
+}
+
+// isStopping reports whether shutdown has begun
+func (s *Server) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until
+// ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.BeginShutdown()
+	if s.httpServer == nil {
+		return nil
+	}
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	return nil
+}
+
 func (s *Server) handleGetGuilds(w http.ResponseWriter, r *http.Request) {
 	if s.clientManager == nil {
 		http.Error(w, `{"error":"No client manager available"}`, http.StatusServiceUnavailable)
 		return
 	}
 
-	// Get client for the specific user's Discord token
-	// The backend passes X-Discord-Token header to identify which user's guilds to fetch
-	token := r.Header.Get("X-Discord-Token")
-	if token == "" {
-		// SECURITY: Token header is required to prevent cross-user data leakage
-		http.Error(w, `{"error":"X-Discord-Token header is required"}`, http.StatusBadRequest)
-		return
-	}
-
-	discordClient := s.clientManager.GetClientByToken(token)
-	if discordClient == nil || discordClient.GetSession() == nil {
-		// Token provided but no active client for this token
-		http.Error(w, `{"error":"No Discord session active for this token"}`, http.StatusServiceUnavailable)
+	// The backend passes X-User-ID to identify which user's guilds to fetch
+	discordClient := s.resolveAccount(w, r)
+	if discordClient == nil {
 		return
 	}
 
 	guilds, err := discordClient.GetSession().UserGuilds(100, "", "", false)
 	if err != nil {
-		log.Printf("Error fetching guilds: %v", err)
+		logger.Error("Error fetching guilds", "account", discordClient.GetFingerprint(), "error", err)
 		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch guilds: %s"}`, err.Error()), http.StatusInternalServerError)
 		return
 	}
@@ -90,18 +183,8 @@ func (s *Server) handleGetChannels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get client for the specific user's Discord token
-	token := r.Header.Get("X-Discord-Token")
-	if token == "" {
-		// SECURITY: Token header is required to prevent cross-user data leakage
-		http.Error(w, `{"error":"X-Discord-Token header is required"}`, http.StatusBadRequest)
-		return
-	}
-
-	discordClient := s.clientManager.GetClientByToken(token)
-	if discordClient == nil || discordClient.GetSession() == nil {
-		// Token provided but no active client for this token
-		http.Error(w, `{"error":"No Discord session active for this token"}`, http.StatusServiceUnavailable)
+	discordClient := s.resolveAccount(w, r)
+	if discordClient == nil {
 		return
 	}
 
@@ -114,7 +197,7 @@ func (s *Server) handleGetChannels(w http.ResponseWriter, r *http.Request) {
 
 	channels, err := discordClient.GetSession().GuildChannels(guildID)
 	if err != nil {
-		log.Printf("Error fetching channels: %v", err)
+		logger.Error("Error fetching channels", logging.KeyGuildID, guildID, "error", err)
 		http.Error(w, fmt.Sprintf(`{"error":"Failed to fetch channels: %s"}`, err.Error()), http.StatusInternalServerError)
 		return
 	}
@@ -124,9 +207,11 @@ func (s *Server) handleGetChannels(w http.ResponseWriter, r *http.Request) {
 		// Only include text channels (type 0) and announcement channels (type 5)
 		if channel.Type == 0 || channel.Type == 5 {
 			channelList = append(channelList, types.ChannelListInfo{
-				ID:   channel.ID,
-				Name: channel.Name,
-				Type: int(channel.Type),
+				ID:      channel.ID,
+				Name:    channel.Name,
+				Type:    int(channel.Type),
+				Read:    discordClient.CanRead(guildID, channel.ID),
+				Respond: discordClient.CanRespondIn(guildID, channel.ID),
 			})
 		}
 	}
@@ -179,3 +264,79 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"guildCount":      s.clientManager.GetMonitoredGuildCount(),
 	})
 }
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	// Refresh gauges that are sampled at scrape time
+	if s.clientManager != nil {
+		channels, messages := s.clientManager.GetHistoryStats()
+		metrics.HistoryChannels.Set(float64(channels))
+		metrics.HistoryMessages.Set(float64(messages))
+		metrics.HumaAgents.Set(float64(s.clientManager.GetAgentCount()))
+	}
+	metrics.ActivityStreamSubscribers.Set(float64(events.Default.SubscriberCount()))
+
+	metrics.Default.Handler().ServeHTTP(w, r)
+}
+
+func (s *Server) handleGetLogLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"levels":  logging.GetLevels(),
+	})
+}
+
+// logLevelRequest is the body of the log level endpoints
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// decodeLogLevel reads {"level": "debug"} from the request body
+func decodeLogLevel(w http.ResponseWriter, r *http.Request) (slog.Level, bool) {
+	var req logLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid JSON body"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		http.Error(w, `{"error":"level must be one of debug, info, warn, error"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	return level, true
+}
+
+func (s *Server) handleSetGuildLogLevel(w http.ResponseWriter, r *http.Request) {
+	level, ok := decodeLogLevel(w, r)
+	if !ok {
+		return
+	}
+
+	guildID := r.PathValue("guildID")
+	logging.SetGuildLevel(guildID, level)
+	logger.Info("Guild log level changed", logging.KeyGuildID, guildID, "level", level.String())
+
+	s.handleGetLogLevels(w, r)
+}
+
+func (s *Server) handleClearGuildLogLevel(w http.ResponseWriter, r *http.Request) {
+	guildID := r.PathValue("guildID")
+	logging.ClearGuildLevel(guildID)
+	logger.Info("Guild log level cleared", logging.KeyGuildID, guildID)
+
+	s.handleGetLogLevels(w, r)
+}
+
+func (s *Server) handleSetSubsystemLogLevel(w http.ResponseWriter, r *http.Request) {
+	level, ok := decodeLogLevel(w, r)
+	if !ok {
+		return
+	}
+
+	subsystem := r.PathValue("subsystem")
+	logging.SetSubsystemLevel(subsystem, level)
+	logger.Info("Subsystem log level changed", "target", subsystem, "level", level.String())
+
+	s.handleGetLogLevels(w, r)
+}
@@ -4,11 +4,31 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client/discordtest"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
 )
 
+const testAPIKey = "test-internal-key"
+
+// newTestServer creates a server with an empty client manager and the test API key
+func newTestServer() *Server {
+	server := NewServer("8080", client.NewClientManager(nil, nil))
+	server.SetAPIKey(testAPIKey)
+	return server
+}
+
+// serve sends a request through the authenticated handler
+func serve(server *Server, req *http.Request) *http.Response {
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	return w.Result()
+}
+
 func TestHandleHealth_NoClient(t *testing.T) {
 	server := NewServer("8080", nil)
 
@@ -29,27 +49,22 @@ func TestHandleHealth_NoClient(t *testing.T) {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if result["status"] != "disconnected" {
-		t.Errorf("Expected status 'disconnected', got '%s'", result["status"])
+	if result["status"] != "no_manager" {
+		t.Errorf("Expected status 'no_manager', got '%s'", result["status"])
 	}
 }
 
 func TestHandleHealth_WithClient(t *testing.T) {
 	// Note: We can't easily test with a real session without mocking discordgo
 	// This test verifies the basic structure
-	dc := client.NewDiscordClient()
-	server := NewServer("8080", dc)
+	server := newTestServer()
 
 	req := httptest.NewRequest("GET", "/health", nil)
-	w := httptest.NewRecorder()
-
-	server.handleHealth(w, req)
-
-	resp := w.Result()
+	resp := serve(server, req)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		t.Errorf("Expected status 200 without API key, got %d", resp.StatusCode)
 	}
 
 	var result map[string]string
@@ -63,16 +78,57 @@ func TestHandleHealth_WithClient(t *testing.T) {
 	}
 }
 
-func TestHandleGetGuilds_NoSession(t *testing.T) {
-	dc := client.NewDiscordClient()
-	server := NewServer("8080", dc)
+func TestRequireAPIKey(t *testing.T) {
+	server := newTestServer()
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"missing", "", "", http.StatusUnauthorized},
+		{"wrong key", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"X-API-Key", "X-API-Key", testAPIKey, http.StatusOK},
+		{"bearer", "Authorization", "Bearer " + testAPIKey, http.StatusOK},
+	}
 
-	req := httptest.NewRequest("GET", "/guilds", nil)
-	w := httptest.NewRecorder()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/status", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			resp := serve(server, req)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
 
-	server.handleGetGuilds(w, req)
+func TestRequireAPIKey_NoKeyConfigured(t *testing.T) {
+	server := NewServer("8080", client.NewClientManager(nil, nil))
 
-	resp := w.Result()
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.Header.Set("X-API-Key", "")
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 when no key is configured, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandleGetGuilds_NoSession(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("GET", "/guilds", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-User-ID", "user1")
+	resp := serve(server, req)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusServiceUnavailable {
@@ -80,16 +136,40 @@ func TestHandleGetGuilds_NoSession(t *testing.T) {
 	}
 }
 
-func TestHandleGetChannels_NoSession(t *testing.T) {
-	dc := client.NewDiscordClient()
-	server := NewServer("8080", dc)
+func TestHandleGetGuilds_MissingAccount(t *testing.T) {
+	server := newTestServer()
 
-	req := httptest.NewRequest("GET", "/channels?guild_id=123", nil)
-	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/guilds", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp := serve(server, req)
+	defer resp.Body.Close()
 
-	server.handleGetChannels(w, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
 
-	resp := w.Result()
+func TestHandleGetGuilds_RejectsRawToken(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("GET", "/guilds", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-Discord-Token", "raw-token")
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandleGetChannels_NoSession(t *testing.T) {
+	server := newTestServer()
+
+	req := httptest.NewRequest("GET", "/channels?guild_id=123", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-Token-Fingerprint", client.TokenFingerprint("some-token"))
+	resp := serve(server, req)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusServiceUnavailable {
@@ -99,14 +179,11 @@ func TestHandleGetChannels_NoSession(t *testing.T) {
 
 func TestHandleGetChannels_MissingGuildID(t *testing.T) {
 	// Even with no client, we should get BadRequest for missing guild_id
-	server := NewServer("8080", nil)
+	server := newTestServer()
 
 	req := httptest.NewRequest("GET", "/channels", nil)
-	w := httptest.NewRecorder()
-
-	server.handleGetChannels(w, req)
-
-	resp := w.Result()
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp := serve(server, req)
 	defer resp.Body.Close()
 
 	// Should check for missing parameter before checking session
@@ -115,9 +192,92 @@ func TestHandleGetChannels_MissingGuildID(t *testing.T) {
 	}
 }
 
+// newFakeAccountServer creates a server whose manager has one account for
+// user1, connected to a fake Discord with guild g1
+func newFakeAccountServer(t *testing.T) (*Server, *discordtest.Session) {
+	t.Helper()
+	fake := discordtest.NewSession(&discordgo.User{ID: "bot", Username: "helper"})
+	fake.AddGuild("g1", "Guild")
+	fake.AddChannel("g1", "c1", "general")
+	fake.AddChannel("g1", "c2", "announcements")
+
+	manager := client.NewClientManager(nil, nil)
+	manager.SetConnector(func(dc *client.DiscordClient, token string) error {
+		fake.SetEventHandler(dc.AttachSession(fake, client.TokenFingerprint(token)))
+		fake.Connect()
+		return nil
+	})
+	manager.SyncTokenConfigs([]types.TokenConfig{{
+		DiscordToken: "token1",
+		UserID:       "user1",
+		Servers:      []types.ServerConfig{{GuildID: "g1", GuildName: "Guild", BotActive: true}},
+	}})
+
+	server := NewServer("8080", manager)
+	server.SetAPIKey(testAPIKey)
+	return server, fake
+}
+
+func TestHandleGetGuilds(t *testing.T) {
+	server, _ := newFakeAccountServer(t)
+
+	req := httptest.NewRequest("GET", "/guilds", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-User-ID", "user1")
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Guilds []types.GuildInfo `json:"guilds"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Guilds) != 1 || body.Guilds[0].ID != "g1" || body.Guilds[0].Name != "Guild" {
+		t.Errorf("Expected guild g1, got %+v", body.Guilds)
+	}
+}
+
+func TestHandleGetChannels(t *testing.T) {
+	server, fake := newFakeAccountServer(t)
+	fake.State().ChannelAdd(&discordgo.Channel{ID: "v1", GuildID: "g1", Name: "voice", Type: discordgo.ChannelTypeGuildVoice})
+
+	req := httptest.NewRequest("GET", "/channels?guild_id=g1", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-Token-Fingerprint", client.TokenFingerprint("token1"))
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Channels []types.ChannelListInfo `json:"channels"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Channels) != 2 {
+		t.Errorf("Expected the two text channels and not the voice channel, got %+v", body.Channels)
+	}
+}
+
+func TestHandleGetChannels_UnknownGuild(t *testing.T) {
+	server, _ := newFakeAccountServer(t)
+
+	req := httptest.NewRequest("GET", "/channels?guild_id=nope", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("X-User-ID", "user1")
+	resp := serve(server, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", resp.StatusCode)
+	}
+}
+
 func TestNewServer(t *testing.T) {
-	dc := client.NewDiscordClient()
-	server := NewServer("8080", dc)
+	manager := client.NewClientManager(nil, nil)
+	server := NewServer("8080", manager)
 
 	if server == nil {
 		t.Fatal("NewServer returned nil")
@@ -127,24 +287,45 @@ func TestNewServer(t *testing.T) {
 		t.Errorf("Expected port '8080', got '%s'", server.port)
 	}
 
-	if server.client != dc {
-		t.Error("Server client not set correctly")
+	if server.clientManager != manager {
+		t.Error("Server client manager not set correctly")
 	}
 }
 
-func TestUpdateClient(t *testing.T) {
-	dc1 := client.NewDiscordClient()
-	dc2 := client.NewDiscordClient()
+func TestSetGuildLogLevel(t *testing.T) {
+	server := newTestServer()
 
-	server := NewServer("8080", dc1)
+	req := httptest.NewRequest("PUT", "/log-levels/guilds/guild1", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp := serve(server, req)
+	defer resp.Body.Close()
 
-	if server.client != dc1 {
-		t.Error("Initial client not set correctly")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
 	}
 
-	server.UpdateClient(dc2)
+	var result struct {
+		Levels struct {
+			Guilds map[string]string `json:"guilds"`
+		} `json:"levels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Levels.Guilds["guild1"] != "debug" {
+		t.Errorf("Expected guild1 at debug, got %v", result.Levels.Guilds)
+	}
 
-	if server.client != dc2 {
-		t.Error("Client not updated correctly")
+	req = httptest.NewRequest("DELETE", "/log-levels/guilds/guild1", nil)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp = serve(server, req)
+	defer resp.Body.Close()
+
+	req = httptest.NewRequest("PUT", "/log-levels/guilds/guild1", strings.NewReader(`{"level":"loud"}`))
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp = serve(server, req)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid level, got %d", resp.StatusCode)
 	}
 }
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/client"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+)
+
+const (
+	// defaultSimulateWait is how long a dry run waits for the agent to act
+	defaultSimulateWait = 20 * time.Second
+	// maxSimulateWait caps waitSeconds so a request can't hang indefinitely
+	maxSimulateWait = 60 * time.Second
+)
+
+// sendMessageRequest is the body of POST /admin/guilds/{guildID}/messages
+type sendMessageRequest struct {
+	ChannelID string `json:"channelId"`
+	Content   string `json:"content"`
+}
+
+// simulateRequest is the body of POST /admin/guilds/{guildID}/simulate.
+// DryRun defaults to true; set it to false to run the message through the live agent.
+type simulateRequest struct {
+	ChannelID   string `json:"channelId"`
+	AuthorID    string `json:"authorId"`
+	AuthorName  string `json:"authorName"`
+	Content     string `json:"content"`
+	DryRun      *bool  `json:"dryRun"`
+	WaitSeconds int    `json:"waitSeconds"`
+}
+
+// clientForGuild finds the account that monitors the {guildID} path value, writing 404 if there is none
+func (s *Server) clientForGuild(w http.ResponseWriter, r *http.Request) *client.DiscordClient {
+	if s.clientManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "No client manager available")
+		return nil
+	}
+
+	guildID := r.PathValue("guildID")
+	discordClient := s.clientManager.GetClientForGuild(guildID)
+	if discordClient == nil || discordClient.GetSession() == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No Discord session for guild %s", guildID))
+		return nil
+	}
+	return discordClient
+}
+
+// writeOperatorError maps an operator action error to a status code
+func writeOperatorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, client.ErrInvalidTarget) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadGateway, err.Error())
+}
+
+func (s *Server) handleAdminSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req sendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.ChannelID == "" || req.Content == "" {
+		writeError(w, http.StatusBadRequest, "channelId and content are required")
+		return
+	}
+
+	discordClient := s.clientForGuild(w, r)
+	if discordClient == nil {
+		return
+	}
+
+	guildID := r.PathValue("guildID")
+	sent, err := discordClient.SendAsAgent(r.Context(), guildID, req.ChannelID, req.Content)
+	if err != nil {
+		logger.Error("Operator send failed", logging.KeyGuildID, guildID, logging.KeyChannelID, req.ChannelID, "error", err)
+		writeOperatorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"messageId": sent.ID,
+	})
+}
+
+func (s *Server) handleAdminSimulate(w http.ResponseWriter, r *http.Request) {
+	var req simulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.ChannelID == "" || req.Content == "" {
+		writeError(w, http.StatusBadRequest, "channelId and content are required")
+		return
+	}
+	if req.AuthorID == "" {
+		req.AuthorID = "simulated-user"
+	}
+	if req.AuthorName == "" {
+		req.AuthorName = "Operator"
+	}
+	wait := defaultSimulateWait
+	if req.WaitSeconds > 0 {
+		wait = min(time.Duration(req.WaitSeconds)*time.Second, maxSimulateWait)
+	}
+
+	discordClient := s.clientForGuild(w, r)
+	if discordClient == nil {
+		return
+	}
+
+	guildID := r.PathValue("guildID")
+	sim := client.SimulatedMessage{
+		ChannelID:  req.ChannelID,
+		AuthorID:   req.AuthorID,
+		AuthorName: req.AuthorName,
+		Content:    req.Content,
+	}
+
+	if req.DryRun != nil && !*req.DryRun {
+		traceID, err := discordClient.InjectMessage(r.Context(), guildID, sim)
+		if err != nil {
+			logger.Error("Simulated message failed", logging.KeyGuildID, guildID, "error", err)
+			writeOperatorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"dryRun":  false,
+			"traceId": traceID,
+		})
+		return
+	}
+
+	result, err := discordClient.DryRunMessage(r.Context(), guildID, sim, wait)
+	if err != nil {
+		logger.Error("Dry run failed", logging.KeyGuildID, guildID, "error", err)
+		writeOperatorError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"dryRun":  true,
+		"result":  result,
+	})
+}
@@ -0,0 +1,147 @@
+package simulate
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/events"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+)
+
+// maxEventLine bounds one line of an event log
+const maxEventLine = 1024 * 1024
+
+// ScriptFromEvents builds a script from a recorded event log: the JSONL or SSE
+// output of /events. Each received message becomes a step and the agent's tool
+// calls in that guild become its actions, with their recorded outcome as the
+// expectation. guildID picks the guild if the log has several; empty means the
+// guild of the first received message.
+func ScriptFromEvents(r io.Reader, guildID string) (*Script, error) {
+	b := &scriptBuilder{guildID: guildID, channels: make(map[string]bool), calls: make(map[string]int)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "data:") {
+			text = strings.TrimSpace(strings.TrimPrefix(text, "data:"))
+		} else if !strings.HasPrefix(text, "{") {
+			// SSE id/event lines, comments and blank lines
+			continue
+		}
+
+		var event events.Event
+		if err := json.Unmarshal([]byte(text), &event); err != nil {
+			return nil, fmt.Errorf("line %d: invalid event: %w", line, err)
+		}
+		b.add(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+
+	if len(b.script.Steps) == 0 {
+		return nil, fmt.Errorf("no received messages found in event log")
+	}
+	if err := b.script.Validate(); err != nil {
+		return nil, err
+	}
+	return &b.script, nil
+}
+
+// scriptBuilder accumulates a script event by event
+type scriptBuilder struct {
+	guildID  string
+	script   Script
+	channels map[string]bool
+	calls    map[string]int // toolCallID -> index of its action in the current step
+}
+
+// add folds one event into the script
+func (b *scriptBuilder) add(event events.Event) {
+	if b.guildID == "" && event.Type == events.TypeMessageReceived {
+		b.guildID = event.GuildID
+	}
+	if event.GuildID != b.guildID {
+		return
+	}
+	b.script.Guild.ID = event.GuildID
+	b.addChannel(event.ChannelID, event.ChannelName)
+
+	if event.Type == events.TypeMessageReceived {
+		b.script.Steps = append(b.script.Steps, Step{Channel: event.ChannelID, Author: event.Author, Content: event.Content})
+		b.calls = make(map[string]int)
+		return
+	}
+	if len(b.script.Steps) == 0 {
+		// Agent activity from before the log starts
+		return
+	}
+	step := &b.script.Steps[len(b.script.Steps)-1]
+	index, known := b.calls[event.ToolCallID]
+
+	switch event.Type {
+	case events.TypeToolCall:
+		action := Action{Tool: event.ToolName, Args: map[string]interface{}{}}
+		if event.ChannelID != "" {
+			action.Args["channel_id"] = event.ChannelID
+		}
+		b.calls[event.ToolCallID] = len(step.Huma)
+		step.Huma = append(step.Huma, action)
+
+	case events.TypeTypingStarted:
+		// The tool call event doesn't carry arguments, the typing event has the message
+		if known && step.Huma[index].Tool == "send_message" {
+			step.Huma[index].Args["message"] = event.Content
+		}
+
+	case events.TypeMessageSent:
+		if known {
+			step.Huma[index].Expect = OutcomeSent
+		}
+
+	case events.TypeMessageCanceled:
+		if !known {
+			return
+		}
+		if huma.IsClientCancelReason(event.Reason) {
+			step.Huma[index].Expect = OutcomeCanceled
+			return
+		}
+		// HUMA canceled it; replay the cancel right after the call
+		b.insertCancel(step, index, event.Reason)
+
+	case events.TypeError:
+		if known {
+			step.Huma[index].Expect = OutcomeFailed
+		}
+	}
+}
+
+// insertCancel puts a cancel action right after the call at index
+func (b *scriptBuilder) insertCancel(step *Step, index int, reason string) {
+	cancel := Action{Cancel: reason, Expect: OutcomeCanceled}
+	step.Huma = append(step.Huma[:index+1], append([]Action{cancel}, step.Huma[index+1:]...)...)
+	for id, i := range b.calls {
+		if i > index {
+			b.calls[id] = i + 1
+		}
+	}
+}
+
+// addChannel records a channel the first time it is seen
+func (b *scriptBuilder) addChannel(id, name string) {
+	if id == "" || b.channels[id] {
+		return
+	}
+	if name == "" {
+		name = id
+	}
+	b.channels[id] = true
+	b.script.Channels = append(b.script.Channels, Channel{ID: id, Name: name})
+}
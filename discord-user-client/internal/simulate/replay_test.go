@@ -0,0 +1,69 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// recordedLog is /events output for two messages in g1 and one in another guild
+const recordedLog = `{"id":1,"type":"message_received","guildId":"g1","channelId":"c1","channelName":"general","author":"alice","content":"hi neon"}
+{"id":2,"type":"message_received","guildId":"g2","channelId":"x1","channelName":"other","author":"eve","content":"ignored"}
+{"id":3,"type":"context_sent","guildId":"g1","channelId":"c1","channelName":"general"}
+{"id":4,"type":"tool_call","guildId":"g1","channelId":"c1","channelName":"general","toolCallId":"t1","toolName":"send_message"}
+{"id":5,"type":"typing_started","guildId":"g1","channelId":"c1","channelName":"general","toolCallId":"t1","content":"hello alice"}
+{"id":6,"type":"message_sent","guildId":"g1","channelId":"c1","channelName":"general","toolCallId":"t1","content":"hello alice"}
+{"id":7,"type":"message_received","guildId":"g1","channelId":"c1","channelName":"general","author":"alice","content":"wait"}
+{"id":8,"type":"tool_call","guildId":"g1","channelId":"c1","toolCallId":"t2","toolName":"send_message"}
+{"id":9,"type":"typing_started","guildId":"g1","channelId":"c1","toolCallId":"t2","content":"sure"}
+{"id":10,"type":"message_canceled","guildId":"g1","channelId":"c1","toolCallId":"t2","reason":"new message arrived"}
+`
+
+func TestScriptFromEvents(t *testing.T) {
+	script, err := ScriptFromEvents(strings.NewReader(recordedLog), "")
+	if err != nil {
+		t.Fatalf("ScriptFromEvents: %v", err)
+	}
+
+	if script.Guild.ID != "g1" || len(script.Channels) != 1 || script.Channels[0].Name != "general" {
+		t.Errorf("Unexpected guild or channels: %+v %+v", script.Guild, script.Channels)
+	}
+	if len(script.Steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %d", len(script.Steps))
+	}
+
+	first := script.Steps[0].Huma
+	if len(first) != 1 || first[0].Tool != "send_message" || first[0].Args["message"] != "hello alice" || first[0].Expect != OutcomeSent {
+		t.Errorf("Unexpected first step actions: %+v", first)
+	}
+	second := script.Steps[1].Huma
+	if len(second) != 2 || second[1].Cancel != "new message arrived" || second[1].Expect != OutcomeCanceled {
+		t.Errorf("Unexpected second step actions: %+v", second)
+	}
+
+	// The rebuilt script replays without differences
+	var out bytes.Buffer
+	result, err := Run(context.Background(), script, &out, Options{})
+	if err != nil {
+		t.Fatalf("Run: %v\n%s", err, out.String())
+	}
+	if result.Mismatches != 0 {
+		t.Errorf("Expected replay to match the recording\n%s", out.String())
+	}
+}
+
+func TestScriptFromEvents_SSE(t *testing.T) {
+	sse := "id: 1\nevent: message_received\ndata: {\"type\":\"message_received\",\"guildId\":\"g1\",\"channelId\":\"c1\",\"author\":\"a\",\"content\":\"x\"}\n\n: keepalive\n\n"
+	script, err := ScriptFromEvents(strings.NewReader(sse), "g1")
+	if err != nil {
+		t.Fatalf("ScriptFromEvents: %v", err)
+	}
+	if len(script.Steps) != 1 || script.Steps[0].Content != "x" {
+		t.Errorf("Unexpected steps: %+v", script.Steps)
+	}
+
+	if _, err := ScriptFromEvents(strings.NewReader(sse), "other"); err == nil {
+		t.Error("Expected an error when the guild has no messages")
+	}
+}
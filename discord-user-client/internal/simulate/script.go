@@ -0,0 +1,181 @@
+// Package simulate drives a guild agent through a scripted conversation with a
+// fake HUMA and a fake Discord, so field issues can be reproduced locally.
+package simulate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script is a conversation to feed through an agent
+type Script struct {
+	Guild    Guild     `yaml:"guild"`
+	Bot      Bot       `yaml:"bot"`
+	Channels []Channel `yaml:"channels"`
+	Steps    []Step    `yaml:"steps"`
+}
+
+// Guild is the simulated server
+type Guild struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// Bot is the account the agent speaks as and its configuration
+type Bot struct {
+	Name        string `yaml:"name"`
+	Personality string `yaml:"personality,omitempty"`
+	Rules       string `yaml:"rules,omitempty"`
+	Information string `yaml:"information,omitempty"`
+}
+
+// Channel is a monitored channel
+type Channel struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// Step is one message posted by a user, followed by what HUMA does about it
+type Step struct {
+	// Channel is the channel ID or name the message is posted in
+	Channel string   `yaml:"channel"`
+	Author  string   `yaml:"author"`
+	Content string   `yaml:"content"`
+	Huma    []Action `yaml:"huma,omitempty"`
+}
+
+// Action is one thing the fake HUMA does. Exactly one of Tool, Send or Cancel is set.
+type Action struct {
+	// Tool and Args issue an arbitrary tool call
+	Tool string                 `yaml:"tool,omitempty"`
+	Args map[string]interface{} `yaml:"args,omitempty"`
+	// Send is shorthand for send_message in the step's channel
+	Send string `yaml:"send,omitempty"`
+	// Cancel cancels the previous tool call of the step with this reason
+	Cancel string `yaml:"cancel,omitempty"`
+	// Expect is the outcome seen in the field ("sent", "canceled", "failed"),
+	// reported as a mismatch if the simulation differs
+	Expect string `yaml:"expect,omitempty"`
+}
+
+// Outcomes an action can have
+const (
+	OutcomeSent     = "sent"
+	OutcomeOK       = "ok"
+	OutcomeCanceled = "canceled"
+	OutcomeFailed   = "failed"
+)
+
+// LoadScript reads a script from a YAML file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	script, err := ParseScript(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return script, nil
+}
+
+// ParseScript parses and validates a YAML script
+func ParseScript(data []byte) (*Script, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var script Script
+	if err := decoder.Decode(&script); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+	if err := script.Validate(); err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+// Validate checks the script and fills in defaults
+func (s *Script) Validate() error {
+	var problems []string
+	if s.Guild.ID == "" {
+		s.Guild.ID = "sim-guild"
+	}
+	if s.Guild.Name == "" {
+		s.Guild.Name = "Simulation"
+	}
+	if s.Bot.Name == "" {
+		s.Bot.Name = "bot"
+	}
+	if len(s.Channels) == 0 {
+		problems = append(problems, "at least one channel is required")
+	}
+	seen := make(map[string]bool)
+	for i, ch := range s.Channels {
+		if ch.ID == "" || ch.Name == "" {
+			problems = append(problems, fmt.Sprintf("channels[%d]: id and name are required", i))
+		}
+		if seen[ch.ID] {
+			problems = append(problems, fmt.Sprintf("channels[%d]: duplicate id %s", i, ch.ID))
+		}
+		seen[ch.ID] = true
+	}
+
+	for i, step := range s.Steps {
+		if _, ok := s.channel(step.Channel); !ok {
+			problems = append(problems, fmt.Sprintf("steps[%d]: unknown channel %q", i, step.Channel))
+		}
+		if step.Author == "" {
+			problems = append(problems, fmt.Sprintf("steps[%d]: author is required", i))
+		}
+		for j, action := range step.Huma {
+			set := 0
+			for _, field := range []string{action.Tool, action.Send, action.Cancel} {
+				if field != "" {
+					set++
+				}
+			}
+			if set != 1 {
+				problems = append(problems, fmt.Sprintf("steps[%d].huma[%d]: exactly one of tool, send and cancel is required", i, j))
+			}
+			if action.Cancel != "" && j == 0 {
+				problems = append(problems, fmt.Sprintf("steps[%d].huma[%d]: cancel needs a tool call before it", i, j))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid script: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// channel finds a channel by ID or name
+func (s *Script) channel(ref string) (Channel, bool) {
+	ref = strings.TrimPrefix(ref, "#")
+	for _, ch := range s.Channels {
+		if ch.ID == ref || ch.Name == ref {
+			return ch, true
+		}
+	}
+	return Channel{}, false
+}
+
+// Marshal returns the script as YAML
+func (s *Script) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(s); err != nil {
+		return nil, fmt.Errorf("failed to marshal script: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal script: %w", err)
+	}
+	return buf.Bytes(), nil
+}
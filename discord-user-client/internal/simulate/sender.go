@@ -0,0 +1,109 @@
+package simulate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// fakeSender is the Discord side of a simulation. Messages the agent sends go
+// into the shared history, as they would after Discord echoes them back.
+type fakeSender struct {
+	script  *Script
+	history *history.MessageHistoryManager
+
+	mu     sync.Mutex
+	now    time.Time
+	nextID int
+}
+
+func newFakeSender(script *Script, history *history.MessageHistoryManager) *fakeSender {
+	return &fakeSender{script: script, history: history}
+}
+
+// post adds a message to the history at the simulated time
+func (s *fakeSender) post(channelID, authorID, author, content string) string {
+	s.mu.Lock()
+	s.nextID++
+	id := fmt.Sprintf("sim-msg-%d", s.nextID)
+	timestamp := s.now.Add(time.Duration(s.nextID) * time.Second)
+	s.mu.Unlock()
+
+	s.history.AddMessage(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        id,
+		ChannelID: channelID,
+		GuildID:   s.script.Guild.ID,
+		Author:    &discordgo.User{ID: authorID, Username: author},
+		Content:   content,
+		Timestamp: timestamp,
+	}})
+	return id
+}
+
+// setNow moves the simulated clock
+func (s *fakeSender) setNow(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SendMessage posts the message as the bot
+func (s *fakeSender) SendMessage(channelID, content string) error {
+	if _, ok := s.script.channel(channelID); !ok {
+		return fmt.Errorf("unknown channel %s", channelID)
+	}
+	s.post(channelID, botUserID, s.script.Bot.Name, content)
+	return nil
+}
+
+// SendTypingIndicator does nothing
+func (s *fakeSender) SendTypingIndicator(channelID string) error {
+	return nil
+}
+
+// GetBotUsername returns the scripted bot name
+func (s *fakeSender) GetBotUsername() string {
+	return s.script.Bot.Name
+}
+
+// GetMonitoredChannelsForGuild returns the scripted channels
+func (s *fakeSender) GetMonitoredChannelsForGuild(guildID string) []huma.MonitoredChannel {
+	channels := make([]huma.MonitoredChannel, 0, len(s.script.Channels))
+	for _, ch := range s.script.Channels {
+		channels = append(channels, huma.MonitoredChannel{ID: ch.ID, Name: ch.Name, CanRespond: true})
+	}
+	return channels
+}
+
+// GetAllChannelsForGuild returns the scripted channels as text channels
+func (s *fakeSender) GetAllChannelsForGuild(guildID string) []huma.ChannelInfo {
+	channels := make([]huma.ChannelInfo, 0, len(s.script.Channels))
+	for _, ch := range s.script.Channels {
+		channels = append(channels, huma.ChannelInfo{ID: ch.ID, Name: ch.Name, Type: "text"})
+	}
+	return channels
+}
+
+// FetchChannelMessages returns the latest messages from the history
+func (s *fakeSender) FetchChannelMessages(guildID, channelID string, limit int) ([]history.Message, error) {
+	if _, ok := s.script.channel(channelID); !ok {
+		return nil, fmt.Errorf("unknown channel %s", channelID)
+	}
+	messages := s.history.GetMessages(channelID)
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+// discardReporter drops agent reports; a simulation has no backend
+type discardReporter struct{}
+
+func (discardReporter) ReportStatsBatch(types.StatsBatchPayload) error       { return nil }
+func (discardReporter) ReportAgentAction(types.AgentActionPayload) error     { return nil }
+func (discardReporter) ReportAgentActivity(types.AgentActivityPayload) error { return nil }
@@ -0,0 +1,256 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/history"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma"
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/huma/humatest"
+)
+
+// Defaults for Options
+const (
+	// DefaultTypingWPM is fast enough that every send takes the minimum delay
+	DefaultTypingWPM   = 100000
+	DefaultStepTimeout = 10 * time.Second
+)
+
+// botUserID is the user ID of the simulated account
+const botUserID = "sim-bot"
+
+// start is the simulated time of the first message
+var start = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Options tune a simulation run
+type Options struct {
+	// TypingWPM is the simulated typing speed
+	TypingWPM int
+	// StepTimeout bounds how long one tool call may take
+	StepTimeout time.Duration
+}
+
+// Result summarizes a simulation run
+type Result struct {
+	Steps      int
+	ToolCalls  int
+	Sent       int
+	Canceled   int
+	Failed     int
+	Mismatches int
+}
+
+// Run feeds the script through a guild agent connected to a fake HUMA and
+// writes each message and the agent's decisions to out
+func Run(ctx context.Context, script *Script, out io.Writer, opts Options) (*Result, error) {
+	if opts.TypingWPM <= 0 {
+		opts.TypingWPM = DefaultTypingWPM
+	}
+	if opts.StepTimeout <= 0 {
+		opts.StepTimeout = DefaultStepTimeout
+	}
+
+	server := humatest.NewServer("")
+	defer server.Close()
+
+	messages := history.NewMessageHistoryManager()
+	sender := newFakeSender(script, messages)
+
+	manager := huma.NewManager("simulate")
+	manager.SetBaseURL(server.URL())
+	manager.SetTuning(huma.Tuning{TypingWPM: opts.TypingWPM, RequestTimeout: opts.StepTimeout})
+	manager.SetMessageSender(sender)
+	manager.SetHistoryManager(messages)
+	manager.SetReporter(discardReporter{})
+	manager.SetConfig(script.Bot.Personality, script.Bot.Rules, script.Bot.Information)
+	defer manager.Shutdown(context.Background())
+
+	agent, err := manager.GetOrCreateAgent(script.Guild.ID, script.Guild.Name, botUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	agent.UpdateConfig(sender, messages, script.Bot.Personality, script.Bot.Rules, script.Bot.Information, nil)
+
+	waitCtx, cancel := context.WithTimeout(ctx, opts.StepTimeout)
+	agentID, err := server.WaitForAgent(waitCtx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &runner{ctx: ctx, script: script, out: out, opts: opts, server: server, agentID: agentID, result: &Result{}}
+	for i, step := range script.Steps {
+		channel, _ := script.channel(step.Channel)
+		sender.setNow(start.Add(time.Duration(i) * time.Minute))
+		messageID := sender.post(channel.ID, "sim-user-"+step.Author, step.Author, step.Content)
+
+		fmt.Fprintf(out, "[%d] #%s %s: %s\n", i+1, channel.Name, step.Author, step.Content)
+		if err := agent.SendNewMessage(ctx, channel.ID, channel.Name, "sim-user-"+step.Author, step.Author, step.Content, messageID); err != nil {
+			return r.result, fmt.Errorf("step %d: failed to send message to agent: %w", i+1, err)
+		}
+		if err := r.step(channel, step); err != nil {
+			return r.result, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		r.result.Steps++
+	}
+
+	fmt.Fprintf(out, "\n%d steps, %d tool calls: %d sent, %d canceled, %d failed", r.result.Steps, r.result.ToolCalls, r.result.Sent, r.result.Canceled, r.result.Failed)
+	if r.result.Mismatches > 0 {
+		fmt.Fprintf(out, ", %d differ from the recording", r.result.Mismatches)
+	}
+	fmt.Fprintln(out)
+	return r.result, nil
+}
+
+// runner plays the HUMA side of each step
+type runner struct {
+	ctx     context.Context
+	script  *Script
+	out     io.Writer
+	opts    Options
+	server  *humatest.Server
+	agentID string
+	result  *Result
+}
+
+// step issues the step's actions in order. A call followed by a cancel is not
+// waited for, so the cancel lands while the message is still being typed, and
+// neither is a call expected to be canceled, so a later send can supersede it.
+func (r *runner) step(channel Channel, step Step) error {
+	if len(step.Huma) == 0 {
+		fmt.Fprintln(r.out, "    (HUMA stays silent)")
+		return nil
+	}
+
+	type deferredCall struct {
+		toolCallID, tool, description, expect string
+	}
+	var deferred []deferredCall
+	var lastCall, lastTool string
+	for j, action := range step.Huma {
+		if action.Cancel != "" {
+			if err := r.server.CancelToolCall(r.agentID, lastCall, action.Cancel); err != nil {
+				return fmt.Errorf("failed to cancel tool call: %w", err)
+			}
+			outcome, err := r.wait(lastCall, lastTool)
+			if err != nil {
+				return err
+			}
+			r.report(fmt.Sprintf("cancel %q", action.Cancel), outcome, action.Expect)
+			continue
+		}
+
+		tool, args := r.call(channel, action)
+		toolCallID, err := r.server.CallTool(r.agentID, tool, args)
+		if err != nil {
+			return fmt.Errorf("failed to call %s: %w", tool, err)
+		}
+		r.result.ToolCalls++
+		lastCall, lastTool = toolCallID, tool
+
+		description := describeCall(r.script, tool, args)
+		if j+1 < len(step.Huma) && step.Huma[j+1].Cancel != "" {
+			fmt.Fprintf(r.out, "    %s ...\n", description)
+			continue
+		}
+		if action.Expect == OutcomeCanceled && j+1 < len(step.Huma) {
+			deferred = append(deferred, deferredCall{toolCallID, tool, description, action.Expect})
+			continue
+		}
+		outcome, err := r.wait(toolCallID, tool)
+		if err != nil {
+			return err
+		}
+		r.report(description, outcome, action.Expect)
+	}
+
+	for _, call := range deferred {
+		outcome, err := r.wait(call.toolCallID, call.tool)
+		if err != nil {
+			return err
+		}
+		r.report(call.description, outcome, call.expect)
+	}
+	return nil
+}
+
+// call returns the tool name and arguments of an action. Channel names in
+// channel_id are resolved to IDs.
+func (r *runner) call(channel Channel, action Action) (string, map[string]interface{}) {
+	if action.Send != "" {
+		return "send_message", map[string]interface{}{"channel_id": channel.ID, "message": action.Send}
+	}
+	args := make(map[string]interface{}, len(action.Args))
+	for key, value := range action.Args {
+		args[key] = value
+	}
+	if ref, ok := args["channel_id"].(string); ok {
+		if ch, ok := r.script.channel(ref); ok {
+			args["channel_id"] = ch.ID
+		}
+	}
+	if limit, ok := args["limit"].(int); ok {
+		// HUMA sends numbers as JSON, YAML gives ints
+		args["limit"] = float64(limit)
+	}
+	return action.Tool, args
+}
+
+// wait blocks until the agent answers a tool call and returns its outcome
+func (r *runner) wait(toolCallID, tool string) (string, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.opts.StepTimeout)
+	defer cancel()
+	result, err := r.server.WaitToolResult(ctx, toolCallID)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case result.Canceled():
+		r.result.Canceled++
+		return fmt.Sprintf("%s (%s)", OutcomeCanceled, result.Error), nil
+	case !result.Success:
+		r.result.Failed++
+		return fmt.Sprintf("%s: %s", OutcomeFailed, result.Error), nil
+	case tool == "send_message":
+		r.result.Sent++
+		return OutcomeSent, nil
+	default:
+		return OutcomeOK, nil
+	}
+}
+
+// report prints an action's outcome and flags it if it differs from expect
+func (r *runner) report(description, outcome, expect string) {
+	fmt.Fprintf(r.out, "    %s -> %s\n", description, outcome)
+	if expect != "" && !strings.HasPrefix(outcome, expect) {
+		r.result.Mismatches++
+		fmt.Fprintf(r.out, "      MISMATCH: recorded %s\n", expect)
+	}
+}
+
+// describeCall formats a tool call for the transcript
+func describeCall(script *Script, tool string, args map[string]interface{}) string {
+	channel := ""
+	if id, ok := args["channel_id"].(string); ok {
+		channel = " #" + id
+		if ch, ok := script.channel(id); ok {
+			channel = " #" + ch.Name
+		}
+	}
+	switch tool {
+	case "send_message":
+		message, _ := args["message"].(string)
+		return fmt.Sprintf("send_message%s %q", channel, message)
+	case "fetch_channel_messages":
+		if limit, ok := args["limit"].(float64); ok {
+			return fmt.Sprintf("fetch_channel_messages%s limit=%d", channel, int(limit))
+		}
+		return "fetch_channel_messages" + channel
+	default:
+		return fmt.Sprintf("%s %v", tool, args)
+	}
+}
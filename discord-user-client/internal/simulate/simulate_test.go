@@ -0,0 +1,94 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const testScript = `
+guild: {id: g1, name: Demo}
+bot: {name: neon}
+channels:
+  - {id: c1, name: general}
+  - {id: c2, name: support}
+steps:
+  - channel: general
+    author: alice
+    content: hey everyone
+  - channel: support
+    author: bob
+    content: neon, how do I reset my password?
+    huma:
+      - tool: fetch_channel_messages
+        args: {channel_id: general, limit: 5}
+      - send: Settings > Account > Reset password
+        expect: sent
+  - channel: general
+    author: alice
+    content: anyone around?
+    huma:
+      - send: I am!
+      - cancel: user kept typing
+        expect: sent
+`
+
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript([]byte(testScript))
+	if err != nil {
+		t.Fatalf("ParseScript: %v", err)
+	}
+	if len(script.Steps) != 3 || len(script.Steps[1].Huma) != 2 {
+		t.Fatalf("Unexpected script: %+v", script)
+	}
+
+	tests := map[string]string{
+		"unknown channel":   "channels: [{id: c1, name: general}]\nsteps: [{channel: random, author: a, content: x}]",
+		"cancel first":      "channels: [{id: c1, name: general}]\nsteps: [{channel: c1, author: a, content: x, huma: [{cancel: no}]}]",
+		"two kinds":         "channels: [{id: c1, name: general}]\nsteps: [{channel: c1, author: a, content: x, huma: [{send: a, tool: b}]}]",
+		"no channels":       "steps: []",
+		"unknown field":     "channels: [{id: c1, name: general}]\nstep: []",
+		"duplicate channel": "channels: [{id: c1, name: a}, {id: c1, name: b}]",
+	}
+	for name, input := range tests {
+		if _, err := ParseScript([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	script, err := ParseScript([]byte(testScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	result, err := Run(context.Background(), script, &out, Options{})
+	if err != nil {
+		t.Fatalf("Run: %v\n%s", err, out.String())
+	}
+
+	if result.Steps != 3 || result.ToolCalls != 3 || result.Sent != 1 || result.Canceled != 1 || result.Failed != 0 {
+		t.Errorf("Unexpected result %+v\n%s", result, out.String())
+	}
+	// The cancel was recorded as too late in the field
+	if result.Mismatches != 1 {
+		t.Errorf("Expected 1 mismatch, got %d\n%s", result.Mismatches, out.String())
+	}
+
+	transcript := out.String()
+	for _, want := range []string{
+		"[1] #general alice: hey everyone",
+		"(HUMA stays silent)",
+		"fetch_channel_messages #general limit=5 -> ok",
+		`send_message #support "Settings > Account > Reset password" -> sent`,
+		`cancel "user kept typing" -> canceled (user kept typing)`,
+		"MISMATCH: recorded sent",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("Expected transcript to contain %q\n%s", want, transcript)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Package standalone runs the client without the Node backend: token and server
+// configs come from a local YAML or JSON file, websites from local markdown
+// files, and stats and agent reports go to a local JSONL file.
+package standalone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/internal/logging"
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// logger is used for config loading and reload events
+var logger = logging.For("standalone")
+
+// defaultUserID is used for tokens that don't set userId
+const defaultUserID = "local"
+
+// File is the standalone config file
+type File struct {
+	Tokens []TokenEntry `json:"tokens" yaml:"tokens"`
+}
+
+// TokenEntry is a Discord account and the servers it runs in. The token can be
+// given inline or, to keep it out of the file, as the name of an env var.
+type TokenEntry struct {
+	DiscordToken    string        `json:"discordToken" yaml:"discordToken"`
+	DiscordTokenEnv string        `json:"discordTokenEnv" yaml:"discordTokenEnv"`
+	UserID          string        `json:"userId" yaml:"userId"`
+	Servers         []ServerEntry `json:"servers" yaml:"servers"`
+}
+
+// ServerEntry is the bot configuration for one guild. BotActive defaults to true.
+type ServerEntry struct {
+	GuildID     string         `json:"guildId" yaml:"guildId"`
+	GuildName   string         `json:"guildName" yaml:"guildName"`
+	BotActive   *bool          `json:"botActive" yaml:"botActive"`
+	BotName     string         `json:"botName" yaml:"botName"`
+	Personality string         `json:"personality" yaml:"personality"`
+	Rules       string         `json:"rules" yaml:"rules"`
+	Information string         `json:"information" yaml:"information"`
+	Websites    []WebsiteEntry `json:"websites" yaml:"websites"`
+	UserLimits  *UserLimits    `json:"userLimits" yaml:"userLimits"`
+
+	// Channel, user and role lists, by ID; see types.ServerConfig
+	ReadChannels    []string `json:"readChannels" yaml:"readChannels"`
+	RespondChannels []string `json:"respondChannels" yaml:"respondChannels"`
+	IgnoredUsers    []string `json:"ignoredUsers" yaml:"ignoredUsers"`
+	IgnoredRoles    []string `json:"ignoredRoles" yaml:"ignoredRoles"`
+	RespondToRoles  []string `json:"respondToRoles" yaml:"respondToRoles"`
+}
+
+// UserLimits caps how often one user's messages reach the agent; see
+// types.UserLimits. Left out, the client's defaults apply.
+type UserLimits struct {
+	MessageBurst      int     `json:"messageBurst" yaml:"messageBurst"`
+	MessagesPerMinute float64 `json:"messagesPerMinute" yaml:"messagesPerMinute"`
+	MentionBurst      int     `json:"mentionBurst" yaml:"mentionBurst"`
+	MentionsPerMinute float64 `json:"mentionsPerMinute" yaml:"mentionsPerMinute"`
+	IgnoreAfter       int     `json:"ignoreAfter" yaml:"ignoreAfter"`
+	IgnoreMinutes     int     `json:"ignoreMinutes" yaml:"ignoreMinutes"`
+}
+
+// WebsiteEntry is knowledge-base content, read from a markdown file (relative
+// to the config file) or given inline
+type WebsiteEntry struct {
+	Name     string `json:"name" yaml:"name"`
+	URL      string `json:"url" yaml:"url"`
+	File     string `json:"file" yaml:"file"`
+	Markdown string `json:"markdown" yaml:"markdown"`
+}
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampFile records a file's current version in stamps. Stat before reading so
+// a write during the read is picked up on the next change check.
+func stampFile(path string, stamps map[string]fileStamp) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	return nil
+}
+
+// parseFile decodes a config file as JSON or YAML depending on its extension.
+// Unknown fields are rejected so typos don't silently drop settings.
+func parseFile(path string, data []byte) (*File, error) {
+	var file File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (expected .yaml, .yml or .json)", filepath.Ext(path))
+	}
+	return &file, nil
+}
+
+// build validates a parsed file and turns it into token configs, reading
+// website markdown relative to dir and recording each file read in stamps
+func (f *File) build(dir string, stamps map[string]fileStamp) ([]types.TokenConfig, error) {
+	var configs []types.TokenConfig
+	seenGuilds := make(map[string]bool)
+
+	for i, entry := range f.Tokens {
+		token := entry.DiscordToken
+		if entry.DiscordTokenEnv != "" {
+			if token != "" {
+				return nil, fmt.Errorf("tokens[%d]: set discordToken or discordTokenEnv, not both", i)
+			}
+			token = os.Getenv(entry.DiscordTokenEnv)
+			if token == "" {
+				return nil, fmt.Errorf("tokens[%d]: env var %s is not set", i, entry.DiscordTokenEnv)
+			}
+		}
+		if token == "" {
+			return nil, fmt.Errorf("tokens[%d]: discordToken or discordTokenEnv is required", i)
+		}
+
+		config := types.TokenConfig{
+			DiscordToken: token,
+			UserID:       entry.UserID,
+		}
+		if config.UserID == "" {
+			config.UserID = defaultUserID
+		}
+
+		for j, server := range entry.Servers {
+			where := fmt.Sprintf("tokens[%d].servers[%d]", i, j)
+			if server.GuildID == "" {
+				return nil, fmt.Errorf("%s: guildId is required", where)
+			}
+			if seenGuilds[server.GuildID] {
+				return nil, fmt.Errorf("%s: guild %s is configured more than once", where, server.GuildID)
+			}
+			seenGuilds[server.GuildID] = true
+
+			serverConfig := types.ServerConfig{
+				GuildID:     server.GuildID,
+				GuildName:   server.GuildName,
+				BotActive:   server.BotActive == nil || *server.BotActive,
+				BotName:     server.BotName,
+				Personality: server.Personality,
+				Rules:       server.Rules,
+				Information: server.Information,
+
+				ReadChannels:    server.ReadChannels,
+				RespondChannels: server.RespondChannels,
+				IgnoredUsers:    server.IgnoredUsers,
+				IgnoredRoles:    server.IgnoredRoles,
+				RespondToRoles:  server.RespondToRoles,
+			}
+			for k, website := range server.Websites {
+				data, err := website.load(dir, stamps)
+				if err != nil {
+					return nil, fmt.Errorf("%s.websites[%d]: %w", where, k, err)
+				}
+				serverConfig.Websites = append(serverConfig.Websites, data)
+			}
+			if server.UserLimits != nil {
+				limits, err := server.UserLimits.build()
+				if err != nil {
+					return nil, fmt.Errorf("%s.userLimits: %w", where, err)
+				}
+				serverConfig.UserLimits = &limits
+			}
+			config.Servers = append(config.Servers, serverConfig)
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// build validates the limits
+func (l UserLimits) build() (types.UserLimits, error) {
+	limits := types.UserLimits(l)
+	if l.MessageBurst < 0 || l.MentionBurst < 0 || l.IgnoreAfter < 0 || l.IgnoreMinutes < 0 {
+		return limits, fmt.Errorf("limits must not be negative")
+	}
+	if (l.MessageBurst > 0 && l.MessagesPerMinute <= 0) || (l.MentionBurst > 0 && l.MentionsPerMinute <= 0) {
+		return limits, fmt.Errorf("a bucket with a burst needs a positive rate per minute")
+	}
+	if l.IgnoreAfter > 0 && l.IgnoreMinutes == 0 {
+		return limits, fmt.Errorf("ignoreAfter needs ignoreMinutes")
+	}
+	return limits, nil
+}
+
+// load reads the website's markdown, recording the file it read in stamps
+func (w WebsiteEntry) load(dir string, stamps map[string]fileStamp) (types.WebsiteData, error) {
+	data := types.WebsiteData{
+		Name:      w.Name,
+		URL:       w.URL,
+		Markdown:  w.Markdown,
+		ScrapedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	switch {
+	case w.File != "" && w.Markdown != "":
+		return data, fmt.Errorf("set file or markdown, not both")
+	case w.File == "" && w.Markdown == "":
+		return data, fmt.Errorf("file or markdown is required")
+	case w.File == "":
+		return data, nil
+	}
+
+	path := w.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if err := stampFile(path, stamps); err != nil {
+		return data, fmt.Errorf("failed to read website file: %w", err)
+	}
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		return data, fmt.Errorf("failed to read website file: %w", err)
+	}
+
+	data.Markdown = string(markdown)
+	data.ScrapedAt = stamps[path].modTime.UTC().Format(time.RFC3339)
+	if data.Name == "" {
+		data.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if data.URL == "" {
+		data.URL = "file://" + path
+	}
+	return data, nil
+}
+
+// Load reads and validates a standalone config file
+func Load(path string) ([]types.TokenConfig, error) {
+	configs, _, err := load(path)
+	return configs, err
+}
+
+// load reads a config file and returns its token configs, with stamps of the
+// config file and every markdown file it uses
+func load(path string) ([]types.TokenConfig, map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	if err := stampFile(path, stamps); err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file, err := parseFile(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	configs, err := file.build(filepath.Dir(path), stamps)
+	if err != nil {
+		return nil, nil, err
+	}
+	return configs, stamps, nil
+}
+
+// Source serves token configs from a config file, reloading it when the file or
+// any markdown file it references changes. It implements backend.ConfigSource.
+type Source struct {
+	path string
+
+	mu      sync.Mutex
+	configs []types.TokenConfig
+	stamps  map[string]fileStamp // path -> version when last loaded
+}
+
+// NewSource loads a config file. It fails if the file is missing or invalid,
+// so a bad config is caught at startup.
+func NewSource(path string) (*Source, error) {
+	s := &Source{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the config file path
+func (s *Source) Path() string {
+	return s.path
+}
+
+// FetchTokenConfigs returns the current configs, reloading them first if any
+// file changed. If the changed file is invalid the error is returned and the
+// previous configs stay in effect.
+func (s *Source) FetchTokenConfigs() ([]types.TokenConfig, error) {
+	s.mu.Lock()
+	changed := s.changedLocked()
+	s.mu.Unlock()
+
+	if changed {
+		if err := s.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.configs, nil
+}
+
+// changedLocked reports whether any loaded file was modified. Caller must hold mu.
+func (s *Source) changedLocked() bool {
+	for path, stamp := range s.stamps {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(stamp.modTime) || info.Size() != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+// reload re-reads the config file and the markdown it references
+func (s *Source) reload() error {
+	configs, stamps, err := load(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	first := s.stamps == nil
+	s.configs = configs
+	s.stamps = stamps
+	s.mu.Unlock()
+
+	servers := 0
+	for _, config := range configs {
+		servers += len(config.Servers)
+	}
+	message := "Reloaded standalone config"
+	if first {
+		message = "Loaded standalone config"
+	}
+	logger.Info(message, "path", s.path, "tokens", len(configs), "servers", servers, "website_files", len(stamps)-1)
+	return nil
+}
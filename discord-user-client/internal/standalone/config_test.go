@@ -0,0 +1,177 @@
+package standalone
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to dir/name and bumps its mtime so reloads see it
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		later := info.ModTime().Add(time.Second)
+		os.Chtimes(path, later, later)
+	}
+	return path
+}
+
+const yamlConfig = `
+tokens:
+  - discordToken: tok_abc
+    servers:
+      - guildId: "111"
+        guildName: Test Guild
+        botName: Helper
+        personality: Friendly
+        websites:
+          - file: docs/faq.md
+          - name: Inline
+            url: https://example.com
+            markdown: "# Inline"
+        userLimits:
+          messageBurst: 5
+          messagesPerMinute: 3
+        readChannels: ["c1", "c2"]
+        respondToRoles: ["members"]
+      - guildId: "222"
+        botActive: false
+`
+
+func TestLoadYAML(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "docs"), 0o755)
+	writeFile(t, dir, "docs/faq.md", "# FAQ")
+	path := writeFile(t, dir, "config.yaml", yamlConfig)
+
+	configs, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(configs) != 1 || configs[0].DiscordToken != "tok_abc" || configs[0].UserID != defaultUserID {
+		t.Fatalf("Unexpected token config: %+v", configs)
+	}
+
+	servers := configs[0].Servers
+	if len(servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %d", len(servers))
+	}
+	if !servers[0].BotActive || servers[1].BotActive {
+		t.Error("Expected botActive to default to true and honour false")
+	}
+	if limits := servers[0].UserLimits; limits == nil || limits.MessageBurst != 5 || limits.MessagesPerMinute != 3 || limits.MentionBurst != 0 {
+		t.Errorf("Expected the configured user limits, got %+v", limits)
+	}
+	if len(servers[0].ReadChannels) != 2 || servers[0].RespondToRoles[0] != "members" || servers[1].ReadChannels != nil {
+		t.Errorf("Expected the configured channel and role lists, got %+v and %+v", servers[0], servers[1])
+	}
+	if servers[1].UserLimits != nil {
+		t.Errorf("Expected no user limits where none are set, got %+v", servers[1].UserLimits)
+	}
+
+	websites := servers[0].Websites
+	if len(websites) != 2 {
+		t.Fatalf("Expected 2 websites, got %d", len(websites))
+	}
+	if websites[0].Name != "faq" || websites[0].Markdown != "# FAQ" || !strings.HasPrefix(websites[0].URL, "file://") {
+		t.Errorf("Unexpected file website: %+v", websites[0])
+	}
+	if websites[1].Name != "Inline" || websites[1].Markdown != "# Inline" {
+		t.Errorf("Unexpected inline website: %+v", websites[1])
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	t.Setenv("TEST_DISCORD_TOKEN", "tok_env")
+	path := writeFile(t, t.TempDir(), "config.json",
+		`{"tokens":[{"discordTokenEnv":"TEST_DISCORD_TOKEN","userId":"u1","servers":[{"guildId":"111"}]}]}`)
+
+	configs, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if configs[0].DiscordToken != "tok_env" || configs[0].UserID != "u1" {
+		t.Errorf("Unexpected token config: %+v", configs[0])
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown field", "c.yaml", "tokens:\n  - discordToken: a\n    guilds: []\n", "guilds"},
+		{"unknown json field", "c.json", `{"tokens":[],"extra":1}`, "extra"},
+		{"bad extension", "c.toml", "", "unsupported"},
+		{"missing token", "c.yaml", "tokens:\n  - servers: []\n", "tokens[0]: discordToken or discordTokenEnv is required"},
+		{"unset env", "c.yaml", "tokens:\n  - discordTokenEnv: TEST_UNSET_TOKEN_VAR\n", "TEST_UNSET_TOKEN_VAR is not set"},
+		{"missing guild", "c.yaml", "tokens:\n  - discordToken: a\n    servers:\n      - guildName: x\n", "tokens[0].servers[0]: guildId is required"},
+		{"duplicate guild", "c.yaml", "tokens:\n  - discordToken: a\n    servers: [{guildId: '1'}]\n  - discordToken: b\n    servers: [{guildId: '1'}]\n", "more than once"},
+		{"website without content", "c.yaml", "tokens:\n  - discordToken: a\n    servers: [{guildId: '1', websites: [{name: x}]}]\n", "websites[0]: file or markdown is required"},
+		{"user limits without a rate", "c.yaml", "tokens:\n  - discordToken: a\n    servers: [{guildId: '1', userLimits: {mentionBurst: 2}}]\n", "servers[0].userLimits: a bucket with a burst needs a positive rate"},
+		{"missing website file", "c.yaml", "tokens:\n  - discordToken: a\n    servers: [{guildId: '1', websites: [{file: nope.md}]}]\n", "failed to read website file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), tt.file, tt.content)
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSourceReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "faq.md", "v1")
+	path := writeFile(t, dir, "config.yaml",
+		"tokens:\n  - discordToken: a\n    servers: [{guildId: '1', websites: [{file: faq.md}]}]\n")
+
+	source, err := NewSource(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	websiteMarkdown := func() string {
+		t.Helper()
+		configs, err := source.FetchTokenConfigs()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return configs[0].Servers[0].Websites[0].Markdown
+	}
+	if got := websiteMarkdown(); got != "v1" {
+		t.Fatalf("Expected v1, got %q", got)
+	}
+
+	// Editing a referenced markdown file triggers a reload
+	writeFile(t, dir, "faq.md", "v2")
+	if got := websiteMarkdown(); got != "v2" {
+		t.Errorf("Expected v2 after editing the markdown, got %q", got)
+	}
+
+	// An invalid edit is reported and the previous configs stay in effect
+	writeFile(t, dir, "config.yaml", "tokens:\n  - servers: []\n")
+	if _, err := source.FetchTokenConfigs(); err == nil {
+		t.Error("Expected an error for an invalid config")
+	}
+
+	// Fixing the file picks up the new config
+	writeFile(t, dir, "config.yaml", "tokens:\n  - discordToken: b\n")
+	configs, err := source.FetchTokenConfigs()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if configs[0].DiscordToken != "b" {
+		t.Errorf("Expected reloaded token b, got %+v", configs[0])
+	}
+}
@@ -0,0 +1,104 @@
+package standalone
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+// Record types written to the sink
+const (
+	RecordStatsBatch    = "stats_batch"
+	RecordAgentAction   = "agent_action"
+	RecordAgentActivity = "agent_activity"
+)
+
+// Record is one line of the sink file
+type Record struct {
+	Type       string          `json:"type"`
+	RecordedAt time.Time       `json:"recordedAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Sink appends stats and agent reports to a JSONL file, one Record per line. It
+// implements backend.Reporter so it can stand in for the backend client.
+type Sink struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewSink opens (or creates) a JSONL file for appending
+func NewSink(path string) (*Sink, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create sink directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sink: %w", err)
+	}
+	return &Sink{path: path, file: file}, nil
+}
+
+// Path returns the sink file path
+func (s *Sink) Path() string {
+	return s.path
+}
+
+// write appends one record. Each line is written with a single call so
+// concurrent readers (tail -f, CI assertions) never see partial lines.
+func (s *Sink) write(recordType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", recordType, err)
+	}
+	line, err := json.Marshal(Record{Type: recordType, RecordedAt: time.Now().UTC(), Payload: data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", recordType, err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("sink is closed")
+	}
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write %s: %w", recordType, err)
+	}
+	return nil
+}
+
+// ReportStatsBatch appends a stats batch
+func (s *Sink) ReportStatsBatch(batch types.StatsBatchPayload) error {
+	return s.write(RecordStatsBatch, batch)
+}
+
+// ReportAgentAction appends a message the agent sent
+func (s *Sink) ReportAgentAction(action types.AgentActionPayload) error {
+	return s.write(RecordAgentAction, action)
+}
+
+// ReportAgentActivity appends a finished decision trail
+func (s *Sink) ReportAgentActivity(activity types.AgentActivityPayload) error {
+	return s.write(RecordAgentActivity, activity)
+}
+
+// Close closes the file. Later reports fail.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
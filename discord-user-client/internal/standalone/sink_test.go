@@ -0,0 +1,71 @@
+package standalone
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjacniacki/neonrain/discord-user-client/pkg/types"
+)
+
+func TestSinkAppendsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "reports.jsonl")
+
+	sink, err := NewSink(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sink.ReportAgentAction(types.AgentActionPayload{GuildID: "111", AgentMessage: "hi"}); err != nil {
+		t.Fatalf("Failed to report action: %v", err)
+	}
+	if err := sink.ReportStatsBatch(types.StatsBatchPayload{}); err != nil {
+		t.Fatalf("Failed to report stats: %v", err)
+	}
+	sink.Close()
+
+	if err := sink.ReportAgentAction(types.AgentActionPayload{}); err == nil {
+		t.Error("Expected reports after Close to fail")
+	}
+
+	// Reopening appends instead of truncating
+	sink, err = NewSink(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sink.ReportAgentActivity(types.AgentActivityPayload{GuildID: "111"})
+	sink.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open sink file: %v", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+
+	want := []string{RecordAgentAction, RecordStatsBatch, RecordAgentActivity}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %d", len(want), len(records))
+	}
+	for i, record := range records {
+		if record.Type != want[i] {
+			t.Errorf("Record %d: expected %s, got %s", i, want[i], record.Type)
+		}
+	}
+
+	var action types.AgentActionPayload
+	json.Unmarshal(records[0].Payload, &action)
+	if action.GuildID != "111" || action.AgentMessage != "hi" {
+		t.Errorf("Unexpected action payload: %+v", action)
+	}
+}
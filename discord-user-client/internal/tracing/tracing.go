@@ -0,0 +1,347 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is how often finished spans are exported
+const DefaultFlushInterval = 5 * time.Second
+
+// maxBufferedSpans bounds memory use when the exporter is unavailable
+const maxBufferedSpans = 10000
+
+// TraceID identifies a trace (one inbound Discord message and everything it caused)
+type TraceID [16]byte
+
+// String returns the hex encoding of the trace ID
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the trace ID is non-zero
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the hex encoding of the span ID
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the span ID is non-zero
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext is the part of a span that is propagated to children
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid reports whether the span context carries a trace
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Status codes, matching OTLP
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// SpanData is a finished span as handed to exporters
+type SpanData struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Name          string
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	StatusCode    int
+	StatusMessage string
+}
+
+// Span is an in-progress unit of work
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext returns the span's propagation context
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID}
+}
+
+// TraceID returns the hex trace ID, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID.String()
+}
+
+// SetAttribute sets a string, bool, int, int64 or float64 attribute on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.StatusCode = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// End finishes the span and queues it for export. Calling End twice is a no-op.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if s.data.StatusCode == StatusUnset {
+		s.data.StatusCode = StatusOK
+	}
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+// Tracer creates spans and exports them in batches
+type Tracer struct {
+	serviceName string
+	exporter    Exporter
+	mu          sync.Mutex
+	buffer      []SpanData
+	stopChan    chan struct{}
+	doneChan    chan struct{}
+}
+
+// NewTracer creates a tracer. A nil exporter still generates trace IDs for
+// log correlation but drops spans.
+func NewTracer(serviceName string, exporter Exporter) *Tracer {
+	return &Tracer{
+		serviceName: serviceName,
+		exporter:    exporter,
+	}
+}
+
+// Start begins exporting buffered spans every interval
+func (t *Tracer) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	t.mu.Lock()
+	if t.stopChan != nil {
+		t.mu.Unlock()
+		return
+	}
+	t.stopChan = make(chan struct{})
+	t.doneChan = make(chan struct{})
+	stopChan, doneChan := t.stopChan, t.doneChan
+	t.mu.Unlock()
+
+	go func() {
+		defer close(doneChan)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Flush(); err != nil {
+					slog.Warn("Trace export failed", "error", err)
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the export loop and exports remaining spans
+func (t *Tracer) Shutdown() {
+	t.mu.Lock()
+	stopChan, doneChan := t.stopChan, t.doneChan
+	t.stopChan = nil
+	t.mu.Unlock()
+
+	if stopChan != nil {
+		close(stopChan)
+		<-doneChan
+	}
+
+	if err := t.Flush(); err != nil {
+		slog.Error("Final trace export failed", "error", err)
+	}
+}
+
+// Flush exports all buffered spans
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	spans := t.buffer
+	t.buffer = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Export(spans)
+}
+
+// enqueue buffers a finished span, dropping the oldest spans when full
+func (t *Tracer) enqueue(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buffer = append(t.buffer, data)
+	if len(t.buffer) > maxBufferedSpans {
+		t.buffer = t.buffer[len(t.buffer)-maxBufferedSpans:]
+	}
+}
+
+// StartSpan starts a span as a child of the span in ctx, or a new trace if there is none
+func (t *Tracer) StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.startSpan(ctx, name, SpanContextFromContext(ctx))
+}
+
+// StartSpanWithParent starts a span under an explicit parent, used where work
+// continues asynchronously from an earlier trace (e.g. HUMA tool calls)
+func (t *Tracer) StartSpanWithParent(ctx context.Context, name string, parent SpanContext) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.startSpan(ctx, name, parent)
+}
+
+func (t *Tracer) startSpan(ctx context.Context, name string, parent SpanContext) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:  name,
+			Start: time.Now(),
+		},
+	}
+
+	if parent.IsValid() {
+		span.data.TraceID = parent.TraceID
+		span.data.ParentSpanID = parent.SpanID
+	} else {
+		span.data.TraceID = newTraceID()
+	}
+	span.data.SpanID = newSpanID()
+
+	return ContextWithSpan(ctx, span), span
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a context carrying span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the propagation context of the span in ctx
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return SpanFromContext(ctx).SpanContext()
+}
+
+// TraceIDFromContext returns the hex trace ID in ctx, or ""
+func TraceIDFromContext(ctx context.Context) string {
+	return SpanFromContext(ctx).TraceID()
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+var (
+	globalMu     sync.RWMutex
+	globalTracer = NewTracer("discord-user-client", nil)
+)
+
+// SetTracer replaces the global tracer
+func SetTracer(t *Tracer) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalTracer = t
+}
+
+// GetTracer returns the global tracer
+func GetTracer() *Tracer {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalTracer
+}
+
+// Start starts a span on the global tracer
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return GetTracer().StartSpan(ctx, name)
+}
+
+// StartWithParent starts a span on the global tracer under an explicit parent
+func StartWithParent(ctx context.Context, name string, parent SpanContext) (context.Context, *Span) {
+	return GetTracer().StartSpanWithParent(ctx, name, parent)
+}
@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStartSpan_PropagatesTrace(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer("test", exporter)
+
+	ctx, root := tracer.StartSpan(context.Background(), "discord.message")
+	_, child := tracer.StartSpan(ctx, "huma.send_context")
+	child.End()
+	root.End()
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	childData, rootData := spans[0], spans[1]
+	if childData.TraceID != rootData.TraceID {
+		t.Error("Expected child to share the root's trace ID")
+	}
+	if childData.ParentSpanID != rootData.SpanID {
+		t.Error("Expected child's parent to be the root span")
+	}
+	if rootData.ParentSpanID.IsValid() {
+		t.Error("Expected root span to have no parent")
+	}
+	if rootData.StatusCode != StatusOK {
+		t.Errorf("Expected status OK, got %d", rootData.StatusCode)
+	}
+}
+
+func TestStartSpanWithParent(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer("test", exporter)
+
+	_, root := tracer.StartSpan(context.Background(), "discord.message")
+	root.End()
+
+	// Tool calls arrive later on another goroutine without the original context
+	_, toolSpan := tracer.StartSpanWithParent(context.Background(), "huma.tool_call", root.SpanContext())
+	toolSpan.SetAttribute("tool.name", "send_message")
+	toolSpan.RecordError(errors.New("boom"))
+	toolSpan.End()
+	toolSpan.End()
+
+	tracer.Flush()
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans (double End is a no-op), got %d", len(spans))
+	}
+	if spans[1].TraceID != spans[0].TraceID || spans[1].ParentSpanID != spans[0].SpanID {
+		t.Error("Expected tool call span to continue the root trace")
+	}
+	if spans[1].StatusCode != StatusError || spans[1].StatusMessage != "boom" {
+		t.Errorf("Expected error status, got %d %q", spans[1].StatusCode, spans[1].StatusMessage)
+	}
+	if spans[1].Attributes["tool.name"] != "send_message" {
+		t.Errorf("Expected tool.name attribute, got %v", spans[1].Attributes)
+	}
+}
+
+func TestTracer_NilExporterDropsSpans(t *testing.T) {
+	tracer := NewTracer("test", nil)
+
+	ctx, span := tracer.StartSpan(context.Background(), "discord.message")
+	if TraceIDFromContext(ctx) == "" || !span.SpanContext().IsValid() {
+		t.Error("Expected trace IDs to be generated without an exporter")
+	}
+	span.End()
+
+	if len(tracer.buffer) != 0 {
+		t.Errorf("Expected no buffered spans, got %d", len(tracer.buffer))
+	}
+}
+
+func TestSpan_NilSafe(t *testing.T) {
+	var span *Span
+	span.SetAttribute("k", "v")
+	span.RecordError(errors.New("boom"))
+	span.End()
+	if span.TraceID() != "" || span.SpanContext().IsValid() {
+		t.Error("Expected empty context for nil span")
+	}
+	if TraceIDFromContext(context.Background()) != "" {
+		t.Error("Expected empty trace ID for context without span")
+	}
+}
+
+func TestOTLPExporter_Export(t *testing.T) {
+	var body []byte
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Expected path /v1/traces, got %s", r.URL.Path)
+		}
+		authHeader = r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL+"/", "neonrain", map[string]string{"Authorization": "Bearer xyz"})
+	tracer := NewTracer("neonrain", exporter)
+
+	ctx, root := tracer.StartSpan(context.Background(), "discord.message")
+	root.SetAttribute("guild.id", "guild1")
+	_, child := tracer.StartSpan(ctx, "discord.send")
+	child.SetAttribute("attempt", 2)
+	child.End()
+	root.End()
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if authHeader != "Bearer xyz" {
+		t.Errorf("Expected custom header to be sent, got %q", authHeader)
+	}
+
+	var req otlpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("Invalid OTLP JSON: %v", err)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Unexpected request shape: %s", body)
+	}
+	attrs := req.ResourceSpans[0].Resource.Attributes
+	if len(attrs) != 1 || attrs[0].Key != "service.name" || *attrs[0].Value.StringValue != "neonrain" {
+		t.Errorf("Expected service.name resource attribute, got %+v", attrs)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if len(spans[0].TraceID) != 32 || len(spans[0].SpanID) != 16 {
+		t.Errorf("Expected hex IDs, got trace %q span %q", spans[0].TraceID, spans[0].SpanID)
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID {
+		t.Error("Expected child parentSpanId to match root spanId")
+	}
+	if spans[1].ParentSpanID != "" {
+		t.Error("Expected root span to omit parentSpanId")
+	}
+	if got := spans[0].Attributes[0]; got.Key != "attempt" || got.Value.IntValue == nil || *got.Value.IntValue != "2" {
+		t.Errorf("Expected int attribute encoded as string, got %+v", got)
+	}
+}
+
+func TestOTLPExporter_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("collector down"))
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, "neonrain", nil)
+	err := exporter.Export([]SpanData{{Name: "x"}})
+	if err == nil {
+		t.Fatal("Expected error for non-2xx response")
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers := ParseHeaders("authorization=Bearer abc, x-tenant = neonrain,invalid,=empty")
+
+	if len(headers) != 2 {
+		t.Fatalf("Expected 2 headers, got %v", headers)
+	}
+	if headers["authorization"] != "Bearer abc" {
+		t.Errorf("Unexpected authorization header: %q", headers["authorization"])
+	}
+	if headers["x-tenant"] != "neonrain" {
+		t.Errorf("Unexpected x-tenant header: %q", headers["x-tenant"])
+	}
+}
@@ -0,0 +1,30 @@
+package types
+
+// StatsBatchVersion is the payload version for batched stats reports
+const StatsBatchVersion = 2
+
+// GuildStatsEntry holds aggregated counters for a single (user, guild) pair
+// collected during one flush interval
+type GuildStatsEntry struct {
+	UserID                string `json:"userId"`
+	GuildID               string `json:"guildId"`
+	MessagesReceived      int64  `json:"messagesReceived"`
+	MessagesSent          int64  `json:"messagesSent"`
+	ToolCalls             int64  `json:"toolCalls"`
+	SuppressedResponses   int64  `json:"suppressedResponses"`
+	HumaErrors            int64  `json:"humaErrors"`
+	ResponseCount         int64  `json:"responseCount"`
+	ResponseLatencyMsSum  int64  `json:"responseLatencyMsSum"`
+	ResponseLatencyMsMax  int64  `json:"responseLatencyMsMax"`
+	TypingTimeMs          int64  `json:"typingTimeMs"`
+	LastMessageReceivedAt string `json:"lastMessageReceivedAt,omitempty"`
+	LastMessageSentAt     string `json:"lastMessageSentAt,omitempty"`
+}
+
+// StatsBatchPayload is the body sent to /api/discord/stats in batch mode
+type StatsBatchPayload struct {
+	Version     int               `json:"version"`
+	PeriodStart string            `json:"periodStart"`
+	PeriodEnd   string            `json:"periodEnd"`
+	Entries     []GuildStatsEntry `json:"entries"`
+}
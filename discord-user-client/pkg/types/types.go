@@ -18,6 +18,39 @@ type ServerConfig struct {
 	Rules       string        `json:"rules"`
 	Information string        `json:"information"`
 	Websites    []WebsiteData `json:"websites"`
+
+	// UserLimits caps how often one user's messages reach the agent. Nil uses
+	// the client's defaults.
+	UserLimits *UserLimits `json:"userLimits,omitempty"`
+
+	// Channel, user and role lists, by ID. An empty list restricts nothing.
+	// ReadChannels are the channels whose messages are read; RespondChannels
+	// are the read channels the bot may also respond in.
+	ReadChannels    []string `json:"readChannels,omitempty"`
+	RespondChannels []string `json:"respondChannels,omitempty"`
+	// Messages from IgnoredUsers and members with IgnoredRoles are not read
+	IgnoredUsers []string `json:"ignoredUsers,omitempty"`
+	IgnoredRoles []string `json:"ignoredRoles,omitempty"`
+	// RespondToRoles, when set, limits responses to members with one of them.
+	// Other members' messages are still read as context.
+	RespondToRoles []string `json:"respondToRoles,omitempty"`
+}
+
+// UserLimits are per-user token buckets for one guild. Messages refill at a
+// rate per minute up to a burst; a zero burst turns its bucket off.
+type UserLimits struct {
+	// MessageBurst and MessagesPerMinute limit every message a user sends
+	MessageBurst      int     `json:"messageBurst"`
+	MessagesPerMinute float64 `json:"messagesPerMinute"`
+	// MentionBurst and MentionsPerMinute limit mentions of and replies to the
+	// account, which ask for an answer
+	MentionBurst      int     `json:"mentionBurst"`
+	MentionsPerMinute float64 `json:"mentionsPerMinute"`
+	// IgnoreAfter is how many limited mentions and replies, before the user's
+	// allowance has refilled, get them ignored for IgnoreMinutes. Zero never
+	// ignores.
+	IgnoreAfter   int `json:"ignoreAfter"`
+	IgnoreMinutes int `json:"ignoreMinutes"`
 }
 
 // TokenConfig represents a user's token with all their server configs
@@ -60,6 +93,10 @@ type ChannelListInfo struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 	Type int    `json:"type"`
+	// Whether the bot reads the channel and may respond in it, under the
+	// guild's channel lists
+	Read    bool `json:"read"`
+	Respond bool `json:"respond"`
 }
 
 // MessageHistoryEntry represents a single message in the agent action history
@@ -78,11 +115,66 @@ type AgentActionMessageHistory struct {
 
 // AgentActionPayload represents the data sent when an agent takes an action
 type AgentActionPayload struct {
+	ActionType         string                    `json:"actionType"`
 	UserID             string                    `json:"userId"`
 	GuildID            string                    `json:"guildId"`
 	ChannelID          string                    `json:"channelId"`
 	ChannelName        string                    `json:"channelName"`
 	AgentMessage       string                    `json:"agentMessage"`
 	TriggerDescription string                    `json:"triggerDescription"`
+	TraceID            string                    `json:"traceId,omitempty"`
 	MessageHistory     AgentActionMessageHistory `json:"messageHistory"`
 }
+
+// Agent action types reported to /api/discord/agent-action
+const (
+	// AgentActionTypeMessage is an action where the agent sent a message
+	AgentActionTypeMessage = "message"
+	// AgentActionTypeActivity is the full decision trail for a single trigger
+	AgentActionTypeActivity = "activity"
+)
+
+// Agent activity entry kinds
+const (
+	ActivityKindTrigger    = "trigger"
+	ActivityKindToolCall   = "tool_call"
+	ActivityKindToolResult = "tool_result"
+	ActivityKindCanceled   = "canceled"
+	ActivityKindSent       = "sent"
+)
+
+// Agent activity outcomes
+const (
+	ActivityOutcomeResponded = "responded"
+	ActivityOutcomeSilent    = "silent"
+)
+
+// AgentActivityEntry represents a single step in the agent's decision trail
+type AgentActivityEntry struct {
+	Kind       string                 `json:"kind"`
+	Timestamp  string                 `json:"timestamp"`
+	ChannelID  string                 `json:"channelId,omitempty"`
+	ToolCallID string                 `json:"toolCallId,omitempty"`
+	ToolName   string                 `json:"toolName,omitempty"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	Success    *bool                  `json:"success,omitempty"`
+	Result     string                 `json:"result,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+}
+
+// AgentActivityPayload represents the decision trail sent for a trigger,
+// including triggers the agent chose not to respond to
+type AgentActivityPayload struct {
+	ActionType         string               `json:"actionType"`
+	UserID             string               `json:"userId"`
+	GuildID            string               `json:"guildId"`
+	ChannelID          string               `json:"channelId"`
+	ChannelName        string               `json:"channelName"`
+	TriggerDescription string               `json:"triggerDescription"`
+	TraceID            string               `json:"traceId,omitempty"`
+	Outcome            string               `json:"outcome"`
+	StartedAt          string               `json:"startedAt"`
+	EndedAt            string               `json:"endedAt"`
+	Entries            []AgentActivityEntry `json:"entries"`
+}
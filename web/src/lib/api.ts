@@ -84,6 +84,16 @@ export async function saveAgentConfig(token: string, config: Partial<AgentConfig
   });
 }
 
+// Per-user rate limits; a burst of 0 turns its bucket off
+export interface UserLimits {
+  messageBurst: number;
+  messagesPerMinute: number;
+  mentionBurst: number;
+  mentionsPerMinute: number;
+  ignoreAfter: number;
+  ignoreMinutes: number;
+}
+
 // Server configuration (multi-server support)
 export interface ServerConfig {
   id: string;
@@ -95,6 +105,13 @@ export interface ServerConfig {
   personality: string;
   rules: string;
   information: string;
+  userLimits: UserLimits | null; // null uses the client's defaults
+  // Channel, user and role IDs; an empty list restricts nothing
+  readChannels: string[];
+  respondChannels: string[];
+  ignoredUsers: string[];
+  ignoredRoles: string[];
+  respondToRoles: string[];
   messagesSentCount: number;
   messagesReceivedCount: number;
   lastMessageSentAt: string | null;
@@ -126,6 +143,12 @@ export async function updateServerConfig(
     rules: string;
     information: string;
     botActive: boolean;
+    userLimits: UserLimits | null;
+    readChannels: string[];
+    respondChannels: string[];
+    ignoredUsers: string[];
+    ignoredRoles: string[];
+    respondToRoles: string[];
   }>
 ): Promise<{ success: boolean; server: ServerConfig }> {
   return fetchWithAuth(`/api/server-configs/${configId}`, token, {
@@ -232,23 +255,50 @@ export interface AgentActionMessageHistory {
   agentResponse: MessageHistoryEntry;
 }
 
+export interface AgentActivityEntry {
+  kind: 'trigger' | 'tool_call' | 'tool_result' | 'canceled' | 'sent';
+  timestamp: string;
+  channelId?: string;
+  toolCallId?: string;
+  toolName?: string;
+  arguments?: Record<string, unknown>;
+  success?: boolean;
+  result?: string;
+  reason?: string;
+  message?: string;
+}
+
+export interface AgentActivity {
+  startedAt: string;
+  endedAt: string;
+  entries: AgentActivityEntry[];
+}
+
+export type AgentActionType = 'message' | 'activity';
+
 export interface AgentAction {
   id: string;
+  actionType: AgentActionType;
   channelId: string;
   channelName: string;
   agentMessage: string;
   triggerDescription: string;
   messageHistory: AgentActionMessageHistory;
+  outcome: 'responded' | 'silent' | null;
+  activity: AgentActivity | null;
+  traceId: string | null;
   createdAt: string;
 }
 
 export async function getAgentActions(
   token: string,
   configId: string,
-  limit?: number
+  limit?: number,
+  type?: AgentActionType | 'all'
 ): Promise<{ success: boolean; actions: AgentAction[] }> {
   const params = new URLSearchParams();
   if (limit) params.set('limit', limit.toString());
+  if (type) params.set('type', type);
   const query = params.toString();
   return fetchWithAuth(`/api/server-configs/${configId}/actions${query ? `?${query}` : ''}`, token);
 }